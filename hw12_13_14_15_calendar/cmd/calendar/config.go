@@ -1,20 +1,179 @@
 package main
 
+import (
+	"fmt"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/app"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/envconfig"
+)
+
+// envPrefix is the prefix of environment variables overriding the config file,
+// e.g. CALENDAR_STORAGE_DSN overrides dsn in the [storage] section.
+const envPrefix = "CALENDAR"
+
 // При желании конфигурацию можно вынести в internal/config.
 // Организация конфига в main принуждает нас сужать API компонентов, использовать
 // при их конструировании только необходимые параметры, а также уменьшает вероятность циклической зависимости.
 type Config struct {
-	Logger LoggerConf
-	// TODO
+	Logger      LoggerConf
+	Storage     StorageConf
+	HTTP        APIServerConf
+	GRPC        APIServerConf
+	RateLimit   RateLimitConf
+	Idempotency IdempotencyConf
+	Auth        AuthConf
+	Attachments AttachmentsConf
+	Batch       BatchConf
+	Holidays    HolidaysConf
+	// Metrics is served apart from the public API.
+	Metrics ServerConf
+	Tracing TracingConf
+}
+
+// AuthConf selects how API users are identified.
+type AuthConf struct {
+	// Mode is "jwt" or "header", the latter trusts the user ID sent by clients and is for local use only.
+	Mode string
+	// Algorithm of tokens is "HS256" with Secret or "RS256" with PublicKeyFile.
+	Algorithm     string
+	Secret        string
+	PublicKeyFile string `toml:"public_key_file"`
+	// UserClaim is the token claim holding the user ID.
+	UserClaim string `toml:"user_claim"`
+	// Issuer and Audience of tokens are checked if not empty.
+	Issuer   string
+	Audience string
+	// Admins are IDs of users allowed to see data across all users, e.g. the purge preview.
+	Admins []string
+}
+
+type TracingConf struct {
+	// Exporter is "otlp", "stdout" or "none".
+	Exporter string
+	Endpoint string
+	Insecure bool
 }
 
 type LoggerConf struct {
 	Level string
-	// TODO
 }
 
-func NewConfig() Config {
-	return Config{}
+type StorageConf struct {
+	// Type is either "memory" or "sql".
+	Type string
+	DSN  string
+	// Snapshot persists the memory storage, it is kept in memory only if File is empty.
+	Snapshot SnapshotConf
+	Cache    CacheConf
+	// Purge runs in the calendar service for the memory storage only,
+	// the SQL storage is purged by the scheduler.
+	Purge PurgeConf
+}
+
+// CacheConf keeps up to Size results of event range queries for TTL each, zero Size disables the cache.
+// Changes made by other replicas sharing the database are seen when the results expire.
+type CacheConf struct {
+	Size int
+	TTL  time.Duration
+}
+
+// SnapshotConf writes the memory storage to File every Interval and on shutdown.
+// LogFile is the optional write-ahead log keeping changes made since the last snapshot if the process crashes.
+type SnapshotConf struct {
+	File     string
+	Interval time.Duration
+	LogFile  string `toml:"log_file"`
+}
+
+// PurgeConf hard deletes events soft deleted longer than Retention ago, events ended longer than MaxAge ago
+// and expired idempotency keys every Interval, never if Interval is zero.
+type PurgeConf struct {
+	Interval  time.Duration
+	Retention time.Duration
+	MaxAge    time.Duration `toml:"max_age"`
+}
+
+// RateLimitConf limits requests per user, or per client IP for anonymous requests and ones failing authentication.
+// Rate is requests per second, zero disables limiting. IdleTimeout is 10 minutes if not positive.
+type RateLimitConf struct {
+	Rate        float64
+	Burst       int
+	IdleTimeout time.Duration `toml:"idle_timeout"`
 }
 
-// TODO
+type IdempotencyConf struct {
+	// TTL is how long retries of event creation with the same key return the event created first.
+	TTL time.Duration
+}
+
+// AttachmentsConf keeps attachment contents in the directory, attachments are disabled if Dir is empty.
+type AttachmentsConf struct {
+	Dir string
+	// MaxSize is the maximum attachment size in bytes.
+	MaxSize int64 `toml:"max_size"`
+	// GCInterval is how often contents left by purged events are deleted, never if zero.
+	GCInterval time.Duration `toml:"gc_interval"`
+}
+
+type BatchConf struct {
+	// MaxSize is the maximum number of items in a batch request.
+	MaxSize int `toml:"max_size"`
+}
+
+// HolidaysConf loads holiday calendars users can attach to their working hours from .ics and .json files
+// of the directory, a calendar is named by its file name without the extension.
+type HolidaysConf struct {
+	Dir string
+}
+
+type ServerConf struct {
+	Host string
+	Port string
+}
+
+type APIServerConf struct {
+	Host string
+	Port string
+	TLS  TLSConf
+}
+
+// TLSConf enables TLS if CertFile is set, the certificate and the key are reloaded when the files change.
+type TLSConf struct {
+	CertFile string `toml:"cert_file"`
+	KeyFile  string `toml:"key_file"`
+	// ClientCAFile enables mutual TLS, clients must present certificates signed by it.
+	ClientCAFile string `toml:"client_ca_file"`
+	// MinVersion is "1.2" or "1.3".
+	MinVersion string `toml:"min_version"`
+}
+
+func NewConfig(path string) (Config, error) {
+	config := Config{
+		Logger:  LoggerConf{Level: "INFO"},
+		Tracing: TracingConf{Exporter: "none"},
+		Storage: StorageConf{
+			Type:  "memory",
+			Purge: PurgeConf{Interval: time.Hour, Retention: 30 * 24 * time.Hour, MaxAge: 365 * 24 * time.Hour},
+		},
+		HTTP:        APIServerConf{Port: "8080"},
+		GRPC:        APIServerConf{Port: "50051"},
+		Metrics:     ServerConf{Port: "9100"},
+		Idempotency: IdempotencyConf{TTL: 24 * time.Hour},
+		Attachments: AttachmentsConf{MaxSize: app.DefaultMaxAttachmentSize, GCInterval: time.Hour},
+		Batch:       BatchConf{MaxSize: app.DefaultMaxBatchSize},
+		Auth:        AuthConf{Mode: "jwt", Algorithm: "HS256", UserClaim: "sub"},
+		RateLimit: RateLimitConf{
+			Burst:       20,
+			IdleTimeout: 10 * time.Minute,
+		},
+	}
+	if _, err := toml.DecodeFile(path, &config); err != nil {
+		return Config{}, fmt.Errorf("unable to read config: %w", err)
+	}
+	if err := envconfig.Apply(envPrefix, &config); err != nil {
+		return Config{}, fmt.Errorf("unable to read config from environment: %w", err)
+	}
+	return config, nil
+}
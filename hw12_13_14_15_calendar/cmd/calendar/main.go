@@ -3,15 +3,28 @@ package main
 import (
 	"context"
 	"flag"
+	"fmt"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
 	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/app"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/auth"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/blob"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/holiday"
 	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/logger"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/metrics"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/ratelimit"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/scheduler"
+	internalgrpc "github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/server/grpc"
 	internalhttp "github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/server/http"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage/cache"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage/instrumented"
 	memorystorage "github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage/memory"
+	sqlstorage "github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage/sql"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/tlsconfig"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/tracing"
 )
 
 var configFile string
@@ -28,34 +41,247 @@ func main() {
 		return
 	}
 
-	config := NewConfig()
+	config, err := NewConfig(configFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 	logg := logger.New(config.Logger.Level)
 
-	storage := memorystorage.New()
-	calendar := app.New(logg, storage)
-
-	server := internalhttp.NewServer(logg, calendar)
-
 	ctx, cancel := signal.NotifyContext(context.Background(),
 		syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
 	defer cancel()
 
+	shutdownTracing, err := tracing.Setup(ctx, "calendar", tracing.Config{
+		Exporter: config.Tracing.Exporter,
+		Endpoint: config.Tracing.Endpoint,
+		Insecure: config.Tracing.Insecure,
+	})
+	if err != nil {
+		logg.Error("failed to init tracing: " + err.Error())
+		cancel()
+		os.Exit(1) //nolint:gocritic
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			logg.Error("failed to flush traces: " + err.Error())
+		}
+	}()
+
+	storage, closeStorage, err := newStorage(ctx, config.Storage, logg)
+	if err != nil {
+		logg.Error("failed to init storage: " + err.Error())
+		cancel()
+		os.Exit(1) //nolint:gocritic
+	}
+	defer closeStorage()
+
+	options := app.Options{
+		IdempotencyTTL:    config.Idempotency.TTL,
+		MaxAttachmentSize: config.Attachments.MaxSize,
+		MaxBatchSize:      config.Batch.MaxSize,
+		Admins:            config.Auth.Admins,
+	}
+	if config.Attachments.Dir != "" {
+		blobs, err := blob.NewFS(config.Attachments.Dir)
+		if err != nil {
+			logg.Error("failed to init attachments: " + err.Error())
+			cancel()
+			os.Exit(1) //nolint:gocritic
+		}
+		options.Blobs = blobs
+	}
+	if config.Holidays.Dir != "" {
+		if options.Holidays, err = holiday.LoadDir(config.Holidays.Dir); err != nil {
+			logg.Error("failed to load holiday calendars: " + err.Error())
+			cancel()
+			os.Exit(1) //nolint:gocritic
+		}
+	}
+	calendar := app.New(logg, storage, options)
+	if options.Blobs != nil && config.Attachments.GCInterval > 0 {
+		go collectAttachments(ctx, calendar, config.Attachments.GCInterval, logg)
+	}
+
+	var httpLimiter internalhttp.Limiter
+	var grpcLimiter internalgrpc.Limiter
+	if config.RateLimit.Rate > 0 {
+		// HTTP and gRPC share buckets, so a user gets one budget across both APIs.
+		limiter := ratelimit.New(config.RateLimit.Rate, config.RateLimit.Burst, config.RateLimit.IdleTimeout)
+		httpLimiter, grpcLimiter = limiter, limiter
+	}
+
+	httpAuth, grpcAuth, err := newAuthenticators(config.Auth)
+	if err != nil {
+		logg.Error("failed to init authentication: " + err.Error())
+		cancel()
+		os.Exit(1) //nolint:gocritic
+	}
+	if httpAuth == nil {
+		logg.Info("authentication is disabled, user ids sent by clients are trusted")
+	}
+
+	httpTLS, err := tlsconfig.New(tlsconfig.Options{
+		CertFile:   config.HTTP.TLS.CertFile,
+		KeyFile:    config.HTTP.TLS.KeyFile,
+		CAFile:     config.HTTP.TLS.ClientCAFile,
+		MinVersion: config.HTTP.TLS.MinVersion,
+		Server:     true,
+	}, logg)
+	if err != nil {
+		logg.Error("failed to init http tls: " + err.Error())
+		cancel()
+		os.Exit(1) //nolint:gocritic
+	}
+	grpcTLS, err := tlsconfig.New(tlsconfig.Options{
+		CertFile:   config.GRPC.TLS.CertFile,
+		KeyFile:    config.GRPC.TLS.KeyFile,
+		CAFile:     config.GRPC.TLS.ClientCAFile,
+		MinVersion: config.GRPC.TLS.MinVersion,
+		Server:     true,
+	}, logg)
+	if err != nil {
+		logg.Error("failed to init grpc tls: " + err.Error())
+		cancel()
+		os.Exit(1) //nolint:gocritic
+	}
+
+	httpServer := internalhttp.NewServer(
+		logg, calendar, httpLimiter, httpAuth, httpTLS, config.HTTP.Host, config.HTTP.Port,
+	)
+	grpcServer := internalgrpc.NewServer(
+		logg, calendar, grpcLimiter, grpcAuth, grpcTLS, config.GRPC.Host, config.GRPC.Port,
+	)
+	metricsServer := metrics.NewServer(logg, config.Metrics.Host, config.Metrics.Port)
+
+	stopped := make(chan struct{})
 	go func() {
+		defer close(stopped)
 		<-ctx.Done()
 
 		ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
 		defer cancel()
 
-		if err := server.Stop(ctx); err != nil {
+		if err := httpServer.Stop(ctx); err != nil {
 			logg.Error("failed to stop http server: " + err.Error())
 		}
+		if err := grpcServer.Stop(ctx); err != nil {
+			logg.Error("failed to stop grpc server: " + err.Error())
+		}
+		if err := metricsServer.Stop(ctx); err != nil {
+			logg.Error("failed to stop metrics server: " + err.Error())
+		}
+	}()
+
+	go func() {
+		if err := metricsServer.Start(ctx); err != nil {
+			logg.Error("failed to start metrics server: " + err.Error())
+		}
+	}()
+
+	go func() {
+		if err := grpcServer.Start(ctx); err != nil {
+			logg.Error("failed to start grpc server: " + err.Error())
+			cancel()
+		}
 	}()
 
 	logg.Info("calendar is running...")
 
-	if err := server.Start(ctx); err != nil {
+	if err := httpServer.Start(ctx); err != nil {
 		logg.Error("failed to start http server: " + err.Error())
 		cancel()
-		os.Exit(1) //nolint:gocritic
 	}
+	<-stopped
+}
+
+// collectAttachments deletes contents of attachments removed with purged events until ctx is done.
+func collectAttachments(ctx context.Context, calendar *app.App, interval time.Duration, logg *logger.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			deleted, err := calendar.CollectAttachments(ctx)
+			if err != nil {
+				logg.Error("unable to collect attachments: " + err.Error())
+			}
+			if deleted > 0 {
+				logg.Info(fmt.Sprintf("deleted %d attachment contents", deleted))
+			}
+		}
+	}
+}
+
+// newAuthenticators returns nil authenticators in the header mode, so servers trust the user ID sent by clients.
+func newAuthenticators(conf AuthConf) (internalhttp.Authenticator, internalgrpc.Authenticator, error) {
+	switch conf.Mode {
+	case "header":
+		return nil, nil, nil
+	case "jwt":
+		verifier, err := auth.NewJWT(auth.Config{
+			Algorithm:     conf.Algorithm,
+			Secret:        conf.Secret,
+			PublicKeyFile: conf.PublicKeyFile,
+			UserClaim:     conf.UserClaim,
+			Issuer:        conf.Issuer,
+			Audience:      conf.Audience,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		return verifier, verifier, nil
+	}
+	return nil, nil, fmt.Errorf("unknown auth mode %q", conf.Mode)
+}
+
+func newStorage(ctx context.Context, conf StorageConf, logg *logger.Logger) (app.Storage, func(), error) {
+	switch conf.Type {
+	case "memory":
+		s, err := memorystorage.Open(memorystorage.Options{
+			SnapshotFile: conf.Snapshot.File,
+			LogFile:      conf.Snapshot.LogFile,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		metrics.RegisterMemoryEvents(s.Len)
+		storage := withCache(instrumented.New(s), conf.Cache)
+		if conf.Snapshot.File != "" && conf.Snapshot.Interval > 0 {
+			go s.RunSnapshots(ctx, conf.Snapshot.Interval, logg)
+		}
+		// the scheduler can't reach the memory of this process
+		if conf.Purge.Interval > 0 {
+			go scheduler.New(logg, storage, nil, scheduler.Config{
+				PurgeInterval: conf.Purge.Interval,
+				Retention:     conf.Purge.Retention,
+				MaxAge:        conf.Purge.MaxAge,
+			}).RunPurge(ctx)
+		}
+		closeStorage := func() {
+			if err := s.Close(); err != nil {
+				logg.Error("failed to write storage snapshot: " + err.Error())
+			}
+		}
+		return storage, closeStorage, nil
+	case "sql":
+		s := sqlstorage.New(conf.DSN)
+		if err := s.Connect(ctx); err != nil {
+			return nil, nil, err
+		}
+		return withCache(instrumented.New(s), conf.Cache), func() { _ = s.Close(context.Background()) }, nil
+	}
+	return nil, nil, fmt.Errorf("unknown storage type %q", conf.Type)
+}
+
+// withCache puts the cache of event range queries in front of the storage if it is enabled.
+func withCache(s cache.Storage, conf CacheConf) cache.Storage {
+	if conf.Size <= 0 {
+		return s
+	}
+	return cache.New(s, cache.Options{Size: conf.Size, TTL: conf.TTL})
 }
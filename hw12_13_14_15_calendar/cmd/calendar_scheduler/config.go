@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/envconfig"
+)
+
+// envPrefix is the prefix of environment variables overriding the config file,
+// e.g. CALENDAR_STORAGE_DSN overrides dsn in the [storage] section.
+const envPrefix = "CALENDAR"
+
+type Config struct {
+	Logger  LoggerConf
+	Storage StorageConf
+	Queue   QueueConf
+	Notify  NotifyConf
+	Purge   PurgeConf
+	Leader  LeaderConf
+	Metrics MetricsConf
+	Tracing TracingConf
+}
+
+type TracingConf struct {
+	// Exporter is "otlp", "stdout" or "none".
+	Exporter string
+	Endpoint string
+	Insecure bool
+}
+
+type LoggerConf struct {
+	Level string
+}
+
+type StorageConf struct {
+	DSN string
+}
+
+type QueueConf struct {
+	URL      string
+	Exchange string
+	Queue    string
+	TLS      TLSConf
+}
+
+type NotifyConf struct {
+	Interval time.Duration
+	// RelayInterval is how often notifications left in the outbox are published again.
+	RelayInterval time.Duration `toml:"relay_interval"`
+}
+
+// TLSConf applies to amqps URLs and to URLs with certificate files,
+// the system roots verify the broker if CAFile is empty.
+type TLSConf struct {
+	CAFile string `toml:"ca_file"`
+	// CertFile and KeyFile are the client certificate for brokers requiring mutual TLS,
+	// they are reloaded when the files change.
+	CertFile string `toml:"cert_file"`
+	KeyFile  string `toml:"key_file"`
+	// MinVersion is "1.2" or "1.3", "1.2" if empty.
+	MinVersion string `toml:"min_version"`
+}
+
+type MetricsConf struct {
+	Host string
+	Port string
+}
+
+// LeaderConf makes one of several replicas scan at a time.
+type LeaderConf struct {
+	// Lock is "sql" for a Postgres advisory lock, "file" for replicas on one host or "none" for a single replica.
+	Lock string
+	// Key identifies the advisory lock.
+	Key int64
+	// File is the path of the file lock.
+	File string
+	// Interval is how often followers try to take over and the leader checks it still holds the lock.
+	Interval time.Duration
+}
+
+type PurgeConf struct {
+	Interval time.Duration
+	// Retention is how long soft deleted events are kept.
+	Retention time.Duration
+	// MaxAge is how long events are kept after they ended.
+	MaxAge time.Duration
+}
+
+func NewConfig(path string) (Config, error) {
+	config := Config{
+		Logger:  LoggerConf{Level: "INFO"},
+		Tracing: TracingConf{Exporter: "none"},
+		Queue: QueueConf{
+			Exchange: "calendar",
+			Queue:    "notifications",
+		},
+		Notify: NotifyConf{Interval: time.Minute, RelayInterval: 10 * time.Second},
+		Purge: PurgeConf{
+			Interval:  time.Hour,
+			Retention: 30 * 24 * time.Hour,
+			MaxAge:    365 * 24 * time.Hour,
+		},
+		Leader: LeaderConf{
+			Lock:     "sql",
+			Key:      4242,
+			File:     "/tmp/calendar_scheduler.lock",
+			Interval: 5 * time.Second,
+		},
+		Metrics: MetricsConf{Port: "9101"},
+	}
+	if _, err := toml.DecodeFile(path, &config); err != nil {
+		return Config{}, fmt.Errorf("unable to read config: %w", err)
+	}
+	if err := envconfig.Apply(envPrefix, &config); err != nil {
+		return Config{}, fmt.Errorf("unable to read config from environment: %w", err)
+	}
+	return config, nil
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/leader"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/logger"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/metrics"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/queue/rabbit"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/scheduler"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage/instrumented"
+	sqlstorage "github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage/sql"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/tlsconfig"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/tracing"
+)
+
+var configFile string
+
+func init() {
+	flag.StringVar(&configFile, "config", "/etc/calendar/scheduler_config.toml", "Path to configuration file")
+}
+
+func main() {
+	flag.Parse()
+
+	config, err := NewConfig(configFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	logg := logger.New(config.Logger.Level)
+
+	ctx, cancel := signal.NotifyContext(context.Background(),
+		syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	defer cancel()
+
+	shutdownTracing, err := tracing.Setup(ctx, "calendar_scheduler", tracing.Config{
+		Exporter: config.Tracing.Exporter,
+		Endpoint: config.Tracing.Endpoint,
+		Insecure: config.Tracing.Insecure,
+	})
+	if err != nil {
+		logg.Error("failed to init tracing: " + err.Error())
+		cancel()
+		os.Exit(1) //nolint:gocritic
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			logg.Error("failed to flush traces: " + err.Error())
+		}
+	}()
+
+	storage := sqlstorage.New(config.Storage.DSN)
+	if err := storage.Connect(ctx); err != nil {
+		logg.Error("failed to connect to storage: " + err.Error())
+		cancel()
+		os.Exit(1) //nolint:gocritic
+	}
+	defer storage.Close(context.Background())
+
+	queueTLS, err := tlsconfig.New(tlsconfig.Options{
+		CertFile:   config.Queue.TLS.CertFile,
+		KeyFile:    config.Queue.TLS.KeyFile,
+		CAFile:     config.Queue.TLS.CAFile,
+		MinVersion: config.Queue.TLS.MinVersion,
+		URL:        config.Queue.URL,
+	}, logg)
+	if err != nil {
+		logg.Error("failed to init queue tls: " + err.Error())
+		cancel()
+		os.Exit(1) //nolint:gocritic
+	}
+
+	publisher := rabbit.New(config.Queue.URL, config.Queue.Exchange, config.Queue.Queue, queueTLS)
+	if err := publisher.Connect(ctx); err != nil {
+		logg.Error("failed to connect to queue: " + err.Error())
+		cancel()
+		os.Exit(1) //nolint:gocritic
+	}
+	defer publisher.Close()
+
+	metricsServer := metrics.NewServer(logg, config.Metrics.Host, config.Metrics.Port)
+	go func() {
+		if err := metricsServer.Start(ctx); err != nil {
+			logg.Error("failed to start metrics server: " + err.Error())
+		}
+	}()
+
+	elector, err := newElector(config.Leader, storage, logg)
+	if err != nil {
+		logg.Error("failed to init leader election: " + err.Error())
+		cancel()
+		os.Exit(1) //nolint:gocritic
+	}
+
+	logg.Info("scheduler is running...")
+
+	s := scheduler.New(logg, instrumented.New(storage), publisher, scheduler.Config{
+		NotifyInterval: config.Notify.Interval,
+		RelayInterval:  config.Notify.RelayInterval,
+		PurgeInterval:  config.Purge.Interval,
+		Retention:      config.Purge.Retention,
+		MaxAge:         config.Purge.MaxAge,
+	})
+	if elector != nil {
+		elector.Run(ctx, s.Run)
+	} else {
+		s.Run(ctx)
+	}
+
+	stopCtx, stopCancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer stopCancel()
+	if err := metricsServer.Stop(stopCtx); err != nil {
+		logg.Error("failed to stop metrics server: " + err.Error())
+	}
+
+	logg.Info("scheduler is stopped")
+}
+
+// newElector returns nil if the scheduler runs as a single replica.
+func newElector(conf LeaderConf, storage *sqlstorage.Storage, logger leader.Logger) (*leader.Elector, error) {
+	switch conf.Lock {
+	case "none":
+		return nil, nil
+	case "sql":
+		return leader.New(logger, storage.AdvisoryLock(conf.Key), conf.Interval), nil
+	case "file":
+		return leader.New(logger, leader.NewFileLock(conf.File), conf.Interval), nil
+	}
+	return nil, fmt.Errorf("unknown leader lock %q", conf.Lock)
+}
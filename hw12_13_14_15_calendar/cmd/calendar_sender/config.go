@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/envconfig"
+)
+
+// envPrefix is the prefix of environment variables overriding the config file,
+// e.g. CALENDAR_STORAGE_DSN overrides dsn in the [storage] section.
+const envPrefix = "CALENDAR"
+
+type Config struct {
+	Logger    LoggerConf
+	Queue     QueueConf
+	Metrics   MetricsConf
+	Tracing   TracingConf
+	Webhook   WebhookConf
+	Templates TemplatesConf
+}
+
+// TemplatesConf configures notification messages, see sender.TemplateOptions.
+type TemplatesConf struct {
+	// Dir has templates named <channel>.<locale>.tmpl, messages are built in if it is empty.
+	Dir    string
+	Locale string
+	// TimeZone is used for users without notification preferences.
+	TimeZone string `toml:"time_zone"`
+}
+
+type WebhookConf struct {
+	// URL receives reminders of the webhook channel, the channel is disabled if it is empty.
+	URL     string
+	Timeout time.Duration
+}
+
+type TracingConf struct {
+	// Exporter is "otlp", "stdout" or "none".
+	Exporter string
+	Endpoint string
+	Insecure bool
+}
+
+type LoggerConf struct {
+	Level string
+}
+
+type QueueConf struct {
+	URL      string
+	Exchange string
+	Queue    string
+	TLS      TLSConf
+	// StatusQueue receives delivery statuses, they are not published if it is empty.
+	StatusQueue string `toml:"status_queue"`
+}
+
+// TLSConf applies to amqps URLs and to URLs with certificate files,
+// the system roots verify the broker if CAFile is empty.
+type TLSConf struct {
+	CAFile string `toml:"ca_file"`
+	// CertFile and KeyFile are the client certificate for brokers requiring mutual TLS,
+	// they are reloaded when the files change.
+	CertFile string `toml:"cert_file"`
+	KeyFile  string `toml:"key_file"`
+	// MinVersion is "1.2" or "1.3", "1.2" if empty.
+	MinVersion string `toml:"min_version"`
+}
+
+type MetricsConf struct {
+	Host string
+	Port string
+}
+
+func NewConfig(path string) (Config, error) {
+	config := Config{
+		Logger:  LoggerConf{Level: "INFO"},
+		Tracing: TracingConf{Exporter: "none"},
+		Queue: QueueConf{
+			Exchange:    "calendar",
+			Queue:       "notifications",
+			StatusQueue: "notifications.status",
+		},
+		Metrics:   MetricsConf{Port: "9102"},
+		Webhook:   WebhookConf{Timeout: 5 * time.Second},
+		Templates: TemplatesConf{Locale: "en", TimeZone: "UTC"},
+	}
+	if _, err := toml.DecodeFile(path, &config); err != nil {
+		return Config{}, fmt.Errorf("unable to read config: %w", err)
+	}
+	if err := envconfig.Apply(envPrefix, &config); err != nil {
+		return Config{}, fmt.Errorf("unable to read config from environment: %w", err)
+	}
+	return config, nil
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+	_ "time/tzdata" // time zones of users are loaded in images without tzdata
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/logger"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/metrics"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/queue"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/queue/rabbit"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/sender"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/tlsconfig"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/tracing"
+)
+
+var configFile string
+
+func init() {
+	flag.StringVar(&configFile, "config", "/etc/calendar/sender_config.toml", "Path to configuration file")
+}
+
+func main() {
+	flag.Parse()
+
+	config, err := NewConfig(configFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	logg := logger.New(config.Logger.Level)
+
+	ctx, cancel := signal.NotifyContext(context.Background(),
+		syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	defer cancel()
+
+	shutdownTracing, err := tracing.Setup(ctx, "calendar_sender", tracing.Config{
+		Exporter: config.Tracing.Exporter,
+		Endpoint: config.Tracing.Endpoint,
+		Insecure: config.Tracing.Insecure,
+	})
+	if err != nil {
+		logg.Error("failed to init tracing: " + err.Error())
+		cancel()
+		os.Exit(1) //nolint:gocritic
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			logg.Error("failed to flush traces: " + err.Error())
+		}
+	}()
+
+	templates, err := sender.LoadTemplates(sender.TemplateOptions{
+		Dir:      config.Templates.Dir,
+		Locale:   config.Templates.Locale,
+		TimeZone: config.Templates.TimeZone,
+	})
+	if err != nil {
+		logg.Error("failed to load templates: " + err.Error())
+		cancel()
+		os.Exit(1) //nolint:gocritic
+	}
+
+	queueTLS, err := tlsconfig.New(tlsconfig.Options{
+		CertFile:   config.Queue.TLS.CertFile,
+		KeyFile:    config.Queue.TLS.KeyFile,
+		CAFile:     config.Queue.TLS.CAFile,
+		MinVersion: config.Queue.TLS.MinVersion,
+		URL:        config.Queue.URL,
+	}, logg)
+	if err != nil {
+		logg.Error("failed to init queue tls: " + err.Error())
+		cancel()
+		os.Exit(1) //nolint:gocritic
+	}
+
+	consumer := rabbit.New(config.Queue.URL, config.Queue.Exchange, config.Queue.Queue, queueTLS)
+	if err := consumer.Connect(ctx); err != nil {
+		logg.Error("failed to connect to queue: " + err.Error())
+		cancel()
+		os.Exit(1) //nolint:gocritic
+	}
+	defer consumer.Close()
+
+	var status queue.Publisher
+	if config.Queue.StatusQueue != "" {
+		statusQueue := rabbit.New(config.Queue.URL, config.Queue.Exchange, config.Queue.StatusQueue, queueTLS)
+		if err := statusQueue.Connect(ctx); err != nil {
+			logg.Error("failed to connect to status queue: " + err.Error())
+			cancel()
+			os.Exit(1) //nolint:gocritic
+		}
+		defer statusQueue.Close()
+		status = statusQueue
+	}
+
+	metricsServer := metrics.NewServer(logg, config.Metrics.Host, config.Metrics.Port)
+	go func() {
+		if err := metricsServer.Start(ctx); err != nil {
+			logg.Error("failed to start metrics server: " + err.Error())
+		}
+	}()
+
+	logg.Info("sender is running...")
+
+	channels := make(map[string]sender.Channel)
+	if config.Webhook.URL != "" {
+		channels["webhook"] = sender.NewWebhookChannel(config.Webhook.URL, config.Webhook.Timeout)
+	}
+
+	if err := sender.New(logg, consumer, status, channels, templates).Run(ctx); err != nil {
+		logg.Error("sender failed: " + err.Error())
+	}
+
+	stopCtx, stopCancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer stopCancel()
+	if err := metricsServer.Stop(stopCtx); err != nil {
+		logg.Error("failed to stop metrics server: " + err.Error())
+	}
+
+	logg.Info("sender is stopped")
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/pkg/eventpb"
+)
+
+const uploadChunkSize = 64 << 10
+
+// attachment is the JSON form of an attachment, the same as in the HTTP API.
+type attachment struct {
+	ID          string    `json:"id"`
+	EventID     string    `json:"event_id"`
+	Name        string    `json:"name"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	UserID      string    `json:"user_id"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// parseArgs parses the flags followed by exactly n arguments.
+func parseArgs(fs *flag.FlagSet, args []string, n int) error {
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != n {
+		fs.Usage()
+		return errUsage
+	}
+	return nil
+}
+
+func runAttach(ctx context.Context, c *client, args []string) error {
+	fs := newFlagSet("attach")
+	name := fs.String("name", "", "Attachment name, the file name if empty")
+	contentType := fs.String("type", "", "Content type, guessed by the file extension if empty")
+	if err := parseArgs(fs, args, 2); err != nil {
+		return err
+	}
+	eventID, file := fs.Arg(0), fs.Arg(1)
+	if *name == "" {
+		*name = filepath.Base(file)
+	}
+	if *contentType == "" {
+		*contentType = mime.TypeByExtension(filepath.Ext(file))
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return fmt.Errorf("unable to open file: %w", err)
+	}
+	defer f.Close()
+
+	stream, err := c.api.UploadAttachment(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to upload attachment: %w", err)
+	}
+	err = stream.Send(&eventpb.UploadAttachmentRequest{Data: &eventpb.UploadAttachmentRequest_Info{
+		Info: &eventpb.AttachmentInfo{EventId: eventID, Name: *name, ContentType: *contentType},
+	}})
+	buf := make([]byte, uploadChunkSize)
+	for err == nil {
+		var n int
+		n, err = f.Read(buf)
+		if n > 0 {
+			chunk := &eventpb.UploadAttachmentRequest{Data: &eventpb.UploadAttachmentRequest_Chunk{Chunk: buf[:n]}}
+			if sendErr := stream.Send(chunk); sendErr != nil {
+				err = sendErr
+			}
+		}
+	}
+	// the server may have already failed the upload, its status is returned by CloseAndRecv
+	if !errors.Is(err, io.EOF) {
+		if _, recvErr := stream.CloseAndRecv(); recvErr != nil && !errors.Is(recvErr, io.EOF) {
+			return fmt.Errorf("unable to upload attachment: %w", recvErr)
+		}
+		return fmt.Errorf("unable to upload attachment: %w", err)
+	}
+	created, err := stream.CloseAndRecv()
+	if err != nil {
+		return fmt.Errorf("unable to upload attachment: %w", err)
+	}
+	return c.print.attachments([]*eventpb.Attachment{created})
+}
+
+func runAttachments(ctx context.Context, c *client, args []string) error {
+	eventID, err := parseID(newFlagSet("attachments"), args)
+	if err != nil {
+		return err
+	}
+	resp, err := c.api.ListAttachments(ctx, &eventpb.ListAttachmentsRequest{EventId: eventID})
+	if err != nil {
+		return fmt.Errorf("unable to list attachments: %w", err)
+	}
+	return c.print.attachments(resp.GetAttachments())
+}
+
+func runDownload(ctx context.Context, c *client, args []string) error {
+	fs := newFlagSet("download")
+	file := fs.String("file", "", "Output file, the attachment name if empty, - is the standard output")
+	if err := parseArgs(fs, args, 2); err != nil {
+		return err
+	}
+
+	stream, err := c.api.DownloadAttachment(ctx, &eventpb.DownloadAttachmentRequest{EventId: fs.Arg(0), Id: fs.Arg(1)})
+	if err != nil {
+		return fmt.Errorf("unable to download attachment: %w", err)
+	}
+	first, err := stream.Recv()
+	if err != nil {
+		return fmt.Errorf("unable to download attachment: %w", err)
+	}
+	if *file == "" {
+		*file = filepath.Base(first.GetAttachment().GetName())
+	}
+
+	var w io.Writer = os.Stdout
+	if *file != "-" {
+		out, err := os.Create(*file)
+		if err != nil {
+			return fmt.Errorf("unable to create file: %w", err)
+		}
+		defer out.Close()
+		w = out
+	}
+	for {
+		resp, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("unable to download attachment: %w", err)
+		}
+		if _, err := w.Write(resp.GetChunk()); err != nil {
+			return fmt.Errorf("unable to write file: %w", err)
+		}
+	}
+}
+
+func runDetach(ctx context.Context, c *client, args []string) error {
+	fs := newFlagSet("detach")
+	if err := parseArgs(fs, args, 2); err != nil {
+		return err
+	}
+	_, err := c.api.DeleteAttachment(ctx, &eventpb.DeleteAttachmentRequest{EventId: fs.Arg(0), Id: fs.Arg(1)})
+	if err != nil {
+		return fmt.Errorf("unable to delete attachment: %w", err)
+	}
+	return nil
+}
+
+func (p printer) attachments(attachments []*eventpb.Attachment) error {
+	if p.json {
+		list := make([]attachment, 0, len(attachments))
+		for _, a := range attachments {
+			list = append(list, attachment{
+				ID:          a.GetId(),
+				EventID:     a.GetEventId(),
+				Name:        a.GetName(),
+				ContentType: a.GetContentType(),
+				Size:        a.GetSize(),
+				UserID:      a.GetUserId(),
+				CreatedAt:   a.GetCreatedAt().AsTime(),
+			})
+		}
+		return p.encode(list)
+	}
+
+	tw := tabwriter.NewWriter(p.w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tTYPE\tSIZE\tADDED\tID")
+	for _, a := range attachments {
+		added := a.GetCreatedAt().AsTime().In(p.location).Format("2006-01-02 15:04")
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\n", a.GetName(), a.GetContentType(), a.GetSize(), added, a.GetId())
+	}
+	return tw.Flush()
+}
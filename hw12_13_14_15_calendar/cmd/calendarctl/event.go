@@ -0,0 +1,253 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/ical"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/pkg/eventpb"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// event is the JSON form of an event, the same as in the HTTP API.
+type event struct {
+	ID          string    `json:"id,omitempty"`
+	Title       string    `json:"title"`
+	StartsAt    time.Time `json:"starts_at"`
+	EndsAt      time.Time `json:"ends_at"`
+	Description string    `json:"description,omitempty"`
+	// DescriptionHTML is only set by get -html, import ignores it.
+	DescriptionHTML string     `json:"description_html,omitempty"`
+	CalendarID      string     `json:"calendar_id,omitempty"`
+	UserID          string     `json:"user_id,omitempty"`
+	Reminders       []reminder `json:"reminders,omitempty"`
+	Version         int64      `json:"version,omitempty"`
+	CreatedAt       *time.Time `json:"created_at,omitempty"`
+	UpdatedAt       *time.Time `json:"updated_at,omitempty"`
+	Warnings        []string   `json:"warnings,omitempty"`
+}
+
+type reminder struct {
+	Before  string `json:"before"`
+	Channel string `json:"channel"`
+}
+
+func fromProto(e *eventpb.Event) event {
+	created, updated := e.GetCreatedAt().AsTime(), e.GetUpdatedAt().AsTime()
+	return event{
+		ID:              e.GetId(),
+		Title:           e.GetTitle(),
+		StartsAt:        e.GetStartsAt().AsTime(),
+		EndsAt:          e.GetEndsAt().AsTime(),
+		Description:     e.GetDescription(),
+		DescriptionHTML: e.GetDescriptionHtml(),
+		CalendarID:      e.GetCalendarId(),
+		UserID:          e.GetUserId(),
+		Reminders:       remindersFromProto(e.GetReminders()),
+		Version:         e.GetVersion(),
+		CreatedAt:       &created,
+		UpdatedAt:       &updated,
+		Warnings:        e.GetWarnings(),
+	}
+}
+
+func (e event) toProto() (*eventpb.Event, error) {
+	reminders := make([]*eventpb.Reminder, 0, len(e.Reminders))
+	for _, r := range e.Reminders {
+		before, err := time.ParseDuration(r.Before)
+		if err != nil {
+			return nil, fmt.Errorf("invalid reminder offset %q of event %q", r.Before, e.Title)
+		}
+		reminders = append(reminders, &eventpb.Reminder{Before: durationpb.New(before), Channel: r.Channel})
+	}
+	return &eventpb.Event{
+		Id:          e.ID,
+		Title:       e.Title,
+		StartsAt:    timestamppb.New(e.StartsAt),
+		EndsAt:      timestamppb.New(e.EndsAt),
+		Description: e.Description,
+		CalendarId:  e.CalendarID,
+		Reminders:   reminders,
+	}, nil
+}
+
+func remindersFromProto(reminders []*eventpb.Reminder) []reminder {
+	result := make([]reminder, 0, len(reminders))
+	for _, r := range reminders {
+		result = append(result, reminder{Before: r.GetBefore().AsDuration().String(), Channel: r.GetChannel()})
+	}
+	return result
+}
+
+func toICal(e *eventpb.Event) ical.Event {
+	alarms := make([]ical.Alarm, 0, len(e.GetReminders()))
+	for _, r := range e.GetReminders() {
+		alarms = append(alarms, ical.Alarm{Before: r.GetBefore().AsDuration(), Channel: r.GetChannel()})
+	}
+	return ical.Event{
+		UID:         e.GetId(),
+		Summary:     e.GetTitle(),
+		Description: e.GetDescription(),
+		Start:       e.GetStartsAt().AsTime(),
+		End:         e.GetEndsAt().AsTime(),
+		Stamp:       e.GetUpdatedAt().AsTime(),
+		Alarms:      alarms,
+	}
+}
+
+// fromICal converts the calendar event, alarms without a channel are delivered to the log.
+func fromICal(e ical.Event) event {
+	reminders := make([]reminder, 0, len(e.Alarms))
+	for _, a := range e.Alarms {
+		channel := a.Channel
+		if channel == "" {
+			channel = "log"
+		}
+		reminders = append(reminders, reminder{Before: a.Before.String(), Channel: channel})
+	}
+	return event{
+		ID:          e.UID,
+		Title:       e.Summary,
+		StartsAt:    e.Start,
+		EndsAt:      e.End,
+		Description: e.Description,
+		Reminders:   reminders,
+	}
+}
+
+// printer writes events as a table or JSON.
+type printer struct {
+	w        io.Writer
+	json     bool
+	location *time.Location
+}
+
+func (p printer) events(events []*eventpb.Event) error {
+	if p.json {
+		list := make([]event, 0, len(events))
+		for _, e := range events {
+			list = append(list, fromProto(e))
+		}
+		return p.encode(list)
+	}
+
+	tw := tabwriter.NewWriter(p.w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "DATE\tTIME\tTITLE\tREMINDERS\tID")
+	lastDate := ""
+	for _, e := range events {
+		start := e.GetStartsAt().AsTime().In(p.location)
+		end := e.GetEndsAt().AsTime().In(p.location)
+
+		// the date is printed once for all events of the day, like in an agenda
+		date := start.Format("Mon 2006-01-02")
+		if date == lastDate {
+			date = ""
+		} else {
+			lastDate = date
+		}
+		period := start.Format("15:04") + "-" + end.Format("15:04")
+		if !sameDay(start, end) {
+			period += end.Format(" (Jan 2)")
+		}
+
+		reminders := make([]string, 0, len(e.GetReminders()))
+		for _, r := range remindersFromProto(e.GetReminders()) {
+			reminders = append(reminders, r.Before+" "+r.Channel)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", date, period, e.GetTitle(), strings.Join(reminders, ", "), e.GetId())
+	}
+	return tw.Flush()
+}
+
+func (p printer) event(e *eventpb.Event) error {
+	if p.json {
+		return p.encode(fromProto(e))
+	}
+
+	tw := tabwriter.NewWriter(p.w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "ID:\t%s\n", e.GetId())
+	fmt.Fprintf(tw, "Title:\t%s\n", e.GetTitle())
+	fmt.Fprintf(tw, "Starts:\t%s\n", e.GetStartsAt().AsTime().In(p.location).Format(time.RFC1123))
+	fmt.Fprintf(tw, "Ends:\t%s\n", e.GetEndsAt().AsTime().In(p.location).Format(time.RFC1123))
+	if e.GetDescription() != "" {
+		fmt.Fprintf(tw, "Description:\t%s\n", e.GetDescription())
+	}
+	if e.GetDescriptionHtml() != "" {
+		fmt.Fprintf(tw, "Description HTML:\t%s\n", strings.TrimSpace(e.GetDescriptionHtml()))
+	}
+	fmt.Fprintf(tw, "Calendar:\t%s\n", e.GetCalendarId())
+	for _, r := range remindersFromProto(e.GetReminders()) {
+		fmt.Fprintf(tw, "Reminder:\t%s before by %s\n", r.Before, r.Channel)
+	}
+	fmt.Fprintf(tw, "Version:\t%d\n", e.GetVersion())
+	for _, warning := range e.GetWarnings() {
+		fmt.Fprintf(tw, "Warning:\t%s\n", warning)
+	}
+	return tw.Flush()
+}
+
+// calendar is the JSON form of a calendar, the same as in the HTTP API.
+type calendar struct {
+	ID        string    `json:"id"`
+	OwnerID   string    `json:"owner_id"`
+	Name      string    `json:"name"`
+	Shares    []share   `json:"shares"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type share struct {
+	UserID string `json:"user_id"`
+	Access string `json:"access"`
+}
+
+func (p printer) calendars(calendars []*eventpb.Calendar) error {
+	if p.json {
+		list := make([]calendar, 0, len(calendars))
+		for _, c := range calendars {
+			shares := make([]share, 0, len(c.GetShares()))
+			for _, s := range c.GetShares() {
+				shares = append(shares, share{UserID: s.GetUserId(), Access: s.GetAccess()})
+			}
+			list = append(list, calendar{
+				ID:        c.GetId(),
+				OwnerID:   c.GetOwnerId(),
+				Name:      c.GetName(),
+				Shares:    shares,
+				CreatedAt: c.GetCreatedAt().AsTime(),
+			})
+		}
+		return p.encode(list)
+	}
+
+	tw := tabwriter.NewWriter(p.w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tOWNER\tSHARES\tID")
+	for _, c := range calendars {
+		shares := make([]string, 0, len(c.GetShares()))
+		for _, s := range c.GetShares() {
+			shares = append(shares, s.GetUserId()+" "+s.GetAccess())
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", c.GetName(), c.GetOwnerId(), strings.Join(shares, ", "), c.GetId())
+	}
+	return tw.Flush()
+}
+
+func (p printer) encode(v interface{}) error {
+	enc := json.NewEncoder(p.w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func sameDay(a, b time.Time) bool {
+	ay, am, ad := a.Date()
+	by, bm, bd := b.Date()
+	// an event ending at midnight belongs to the previous day
+	if b.Equal(time.Date(by, bm, bd, 0, 0, 0, 0, b.Location())) {
+		by, bm, bd = b.Add(-time.Nanosecond).Date()
+	}
+	return ay == by && am == bm && ad == bd
+}
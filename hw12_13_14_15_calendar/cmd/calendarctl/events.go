@@ -0,0 +1,342 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/pkg/eventpb"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const pageSize = 500
+
+var errUsage = errors.New("invalid usage")
+
+// remindersFlag collects repeated "-remind 15m:webhook" flags, the channel defaults to log.
+type remindersFlag []*eventpb.Reminder
+
+func (f *remindersFlag) String() string {
+	parts := make([]string, 0, len(*f))
+	for _, r := range *f {
+		parts = append(parts, r.GetBefore().AsDuration().String()+":"+r.GetChannel())
+	}
+	return strings.Join(parts, ",")
+}
+
+func (f *remindersFlag) Set(s string) error {
+	before, channel, found := strings.Cut(s, ":")
+	if !found {
+		channel = "log"
+	}
+	d, err := time.ParseDuration(before)
+	if err != nil {
+		return fmt.Errorf("invalid reminder offset %q", before)
+	}
+	*f = append(*f, &eventpb.Reminder{Before: durationpb.New(d), Channel: channel})
+	return nil
+}
+
+// timeFlag accepts RFC 3339 times and "YYYY-MM-DD HH:MM" in the time zone of printed times.
+type timeFlag struct {
+	t        time.Time
+	location *time.Location
+}
+
+func (f *timeFlag) String() string {
+	if f.t.IsZero() {
+		return ""
+	}
+	return f.t.Format(time.RFC3339)
+}
+
+func (f *timeFlag) Set(s string) error {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		if t, err = time.ParseInLocation("2006-01-02 15:04", s, f.location); err != nil {
+			return fmt.Errorf("invalid time %q, expected RFC 3339 or \"YYYY-MM-DD HH:MM\"", s)
+		}
+	}
+	f.t = t
+	return nil
+}
+
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s %s\n", os.Args[0], commands[name].usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseFlags parses the command flags, errors are reported by the flag set itself.
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	return nil
+}
+
+// parseID parses the flags followed by a single event ID.
+func parseID(fs *flag.FlagSet, args []string) (string, error) {
+	if err := parseFlags(fs, args); err != nil {
+		return "", err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return "", errUsage
+	}
+	return fs.Arg(0), nil
+}
+
+func runCreate(ctx context.Context, c *client, args []string) error {
+	var (
+		reminders remindersFlag
+		starts    = timeFlag{location: c.print.location}
+		ends      = timeFlag{location: c.print.location}
+		event     = &eventpb.Event{}
+	)
+	fs := newFlagSet("create")
+	fs.StringVar(&event.Title, "title", "", "Title of the event")
+	fs.StringVar(&event.Description, "description", "", "Description of the event")
+	fs.StringVar(&event.CalendarId, "calendar", "", "Calendar ID, the default calendar if empty")
+	fs.Var(&starts, "starts", "Start time")
+	fs.Var(&ends, "ends", "End time")
+	duration := fs.Duration("duration", time.Hour, "Duration of the event if -ends is not set")
+	fs.Var(&reminders, "remind", "Reminder offset and channel like 15m:log, can be repeated")
+	key := fs.String("idempotency-key", "", "Key to create the event only once on retries")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if event.Title == "" || starts.t.IsZero() {
+		fs.Usage()
+		return errUsage
+	}
+
+	if ends.t.IsZero() {
+		ends.t = starts.t.Add(*duration)
+	}
+	event.StartsAt = timestamppb.New(starts.t)
+	event.EndsAt = timestamppb.New(ends.t)
+	event.Reminders = reminders
+
+	if *key != "" {
+		ctx = withIdempotencyKey(ctx, *key)
+	}
+	created, err := c.api.CreateEvent(ctx, &eventpb.CreateEventRequest{Event: event})
+	if err != nil {
+		return fmt.Errorf("unable to create event: %w", err)
+	}
+	return c.print.event(created)
+}
+
+func runGet(ctx context.Context, c *client, args []string) error {
+	fs := newFlagSet("get")
+	render := fs.Bool("html", false, "Render the Markdown description to HTML")
+	id, err := parseID(fs, args)
+	if err != nil {
+		return err
+	}
+	event, err := c.api.GetEvent(ctx, &eventpb.GetEventRequest{Id: id, RenderDescription: *render})
+	if err != nil {
+		return fmt.Errorf("unable to get event: %w", err)
+	}
+	return c.print.event(event)
+}
+
+func runUpdate(ctx context.Context, c *client, args []string) error {
+	var (
+		reminders remindersFlag
+		starts    = timeFlag{location: c.print.location}
+		ends      = timeFlag{location: c.print.location}
+	)
+	fs := newFlagSet("update")
+	version := fs.Int64("version", 0, "Expected version of the event, the current version if zero")
+	title := fs.String("title", "", "New title")
+	description := fs.String("description", "", "New description")
+	calendarID := fs.String("calendar", "", "ID of the calendar to move the event to")
+	fs.Var(&starts, "starts", "New start time, the event keeps its duration unless -ends is set")
+	fs.Var(&ends, "ends", "New end time")
+	fs.Var(&reminders, "remind", "Reminder offset and channel like 15m:log, replaces all reminders, can be repeated")
+	noReminders := fs.Bool("no-reminders", false, "Remove all reminders")
+	id, err := parseID(fs, args)
+	if err != nil {
+		return err
+	}
+
+	event, err := c.api.GetEvent(ctx, &eventpb.GetEventRequest{Id: id})
+	if err != nil {
+		return fmt.Errorf("unable to get event: %w", err)
+	}
+	if *version == 0 {
+		*version = event.GetVersion()
+	}
+
+	// only the flags given on the command line change the event
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "title":
+			event.Title = *title
+		case "description":
+			event.Description = *description
+		case "calendar":
+			event.CalendarId = *calendarID
+		case "starts":
+			duration := event.GetEndsAt().AsTime().Sub(event.GetStartsAt().AsTime())
+			event.StartsAt = timestamppb.New(starts.t)
+			event.EndsAt = timestamppb.New(starts.t.Add(duration))
+		case "remind":
+			event.Reminders = reminders
+		case "no-reminders":
+			if *noReminders {
+				event.Reminders = nil
+			}
+		}
+	})
+	if !ends.t.IsZero() {
+		event.EndsAt = timestamppb.New(ends.t)
+	}
+
+	updated, err := c.api.UpdateEvent(ctx, &eventpb.UpdateEventRequest{Event: event, ExpectedVersion: *version})
+	if err != nil {
+		return fmt.Errorf("unable to update event: %w", err)
+	}
+	return c.print.event(updated)
+}
+
+func runDelete(ctx context.Context, c *client, args []string) error {
+	fs := newFlagSet("delete")
+	version := fs.Int64("version", 0, "Expected version of the event, the current version if zero")
+	id, err := parseID(fs, args)
+	if err != nil {
+		return err
+	}
+
+	if *version == 0 {
+		event, err := c.api.GetEvent(ctx, &eventpb.GetEventRequest{Id: id})
+		if err != nil {
+			return fmt.Errorf("unable to get event: %w", err)
+		}
+		*version = event.GetVersion()
+	}
+	if _, err := c.api.DeleteEvent(ctx, &eventpb.DeleteEventRequest{Id: id, ExpectedVersion: *version}); err != nil {
+		return fmt.Errorf("unable to delete event: %w", err)
+	}
+	fmt.Fprintf(c.print.w, "event %s deleted\n", id)
+	return nil
+}
+
+// agendaFlags are the flags selecting a day, week or month of events.
+type agendaFlags struct {
+	period   string
+	date     string
+	title    string
+	calendar string
+}
+
+func (a *agendaFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&a.period, "period", "week", "Period of the agenda: day, week or month")
+	fs.StringVar(&a.date, "date", "", "Date in the period as YYYY-MM-DD, today if empty; periods start at midnight UTC")
+	fs.StringVar(&a.title, "title", "", "Case-insensitive substring of titles")
+	fs.StringVar(&a.calendar, "calendar", "", "Calendar ID, all readable calendars if empty")
+}
+
+// list loads all pages of the agenda.
+func (a *agendaFlags) list(ctx context.Context, c *client) ([]*eventpb.Event, error) {
+	var period eventpb.Period
+	switch a.period {
+	case "day":
+		period = eventpb.Period_PERIOD_DAY
+	case "week":
+		period = eventpb.Period_PERIOD_WEEK
+	case "month":
+		period = eventpb.Period_PERIOD_MONTH
+	default:
+		return nil, fmt.Errorf("unknown period %q", a.period)
+	}
+
+	date := time.Now().In(c.print.location).Format(time.DateOnly)
+	if a.date != "" {
+		date = a.date
+	}
+	day, err := time.Parse(time.DateOnly, date)
+	if err != nil {
+		return nil, fmt.Errorf("invalid date %q, expected YYYY-MM-DD", a.date)
+	}
+
+	var events []*eventpb.Event
+	req := &eventpb.ListEventsRequest{
+		Period:     period,
+		Date:       timestamppb.New(day),
+		PageSize:   pageSize,
+		Title:      a.title,
+		CalendarId: a.calendar,
+	}
+	for {
+		resp, err := c.api.ListEvents(ctx, req)
+		if err != nil {
+			return nil, fmt.Errorf("unable to list events: %w", err)
+		}
+		events = append(events, resp.GetEvents()...)
+		if resp.GetNextCursor() == "" {
+			return events, nil
+		}
+		req.Cursor = resp.GetNextCursor()
+	}
+}
+
+func runList(ctx context.Context, c *client, args []string) error {
+	var agenda agendaFlags
+	fs := newFlagSet("list")
+	agenda.register(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	events, err := agenda.list(ctx, c)
+	if err != nil {
+		return err
+	}
+	return c.print.events(events)
+}
+
+func runCalendars(ctx context.Context, c *client, args []string) error {
+	fs := newFlagSet("calendars")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	resp, err := c.api.ListCalendars(ctx, &eventpb.ListCalendarsRequest{})
+	if err != nil {
+		return fmt.Errorf("unable to list calendars: %w", err)
+	}
+	return c.print.calendars(resp.GetCalendars())
+}
+
+func runPurge(ctx context.Context, c *client, args []string) error {
+	fs := newFlagSet("purge")
+	retention := fs.Duration("retention", 30*24*time.Hour, "How long soft deleted events are kept")
+	maxAge := fs.Duration("max-age", 365*24*time.Hour, "How long events are kept after they ended")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	resp, err := c.api.PreviewPurge(ctx, &eventpb.PreviewPurgeRequest{
+		Retention: durationpb.New(*retention),
+		MaxAge:    durationpb.New(*maxAge),
+	})
+	if err != nil {
+		return fmt.Errorf("unable to preview purge: %w", err)
+	}
+	if c.print.json {
+		return c.print.encode(struct {
+			Events int64 `json:"events"`
+		}{resp.GetEvents()})
+	}
+	fmt.Fprintf(c.print.w, "%d events would be purged (dry run, nothing is deleted)\n", resp.GetEvents())
+	return nil
+}
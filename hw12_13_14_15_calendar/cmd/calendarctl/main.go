@@ -0,0 +1,169 @@
+// calendarctl manages events through the gRPC API of the calendar.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/logger"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/tlsconfig"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/pkg/eventpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+)
+
+var (
+	addr     string
+	userID   string
+	token    string
+	useTLS   bool
+	tlsOpts  tlsconfig.Options
+	output   string
+	timezone string
+	timeout  time.Duration
+)
+
+func init() {
+	flag.StringVar(&addr, "addr", envOr("CALENDARCTL_ADDR", "localhost:50051"), "Address of the gRPC API")
+	flag.StringVar(&userID, "user", os.Getenv("CALENDARCTL_USER"), "User ID sent with requests")
+	flag.StringVar(&token, "token", os.Getenv("CALENDARCTL_TOKEN"), "Bearer token of the user, if the API requires one")
+	flag.BoolVar(&useTLS, "tls", false, "Connect with TLS, implied by -ca and -cert")
+	flag.StringVar(&tlsOpts.CAFile, "ca", "", "CA verifying the server instead of the system roots")
+	flag.StringVar(&tlsOpts.CertFile, "cert", "", "Client certificate for servers requiring mutual TLS")
+	flag.StringVar(&tlsOpts.KeyFile, "key", "", "Key of the client certificate")
+	flag.StringVar(&output, "output", "table", "Output format: table or json")
+	flag.StringVar(&timezone, "tz", "Local", "Time zone of printed times")
+	flag.DurationVar(&timeout, "timeout", 30*time.Second, "Timeout of the whole command")
+
+	// commands are set here because they print their own usage
+	commands = map[string]command{
+		"create": {"create -title T -starts TIME (-ends TIME | -duration D) [-description D] [-calendar ID] " +
+			"[-remind 15m:log]", runCreate},
+		"get": {"get [-html] ID", runGet},
+		"update": {"update [-version N] [-title T] [-starts TIME] [-ends TIME] [-calendar ID] " +
+			"[-remind 15m:log | -no-reminders] ID", runUpdate},
+		"delete": {"delete [-version N] ID", runDelete},
+		"list":   {"list [-period day|week|month] [-date YYYY-MM-DD] [-title T] [-calendar ID]", runList},
+		"export": {"export [-period day|week|month] [-date YYYY-MM-DD] [-calendar ID] " +
+			"[-format json|ics] [-file F]", runExport},
+		"import":      {"import [-format json|ics] [-calendar ID] FILE", runImport},
+		"purge":       {"purge [-retention 720h] [-max-age 8760h]", runPurge},
+		"calendars":   {"calendars", runCalendars},
+		"attach":      {"attach [-name N] [-type T] EVENT FILE", runAttach},
+		"attachments": {"attachments EVENT", runAttachments},
+		"download":    {"download [-file F] EVENT ATTACHMENT", runDownload},
+		"detach":      {"detach EVENT ATTACHMENT", runDetach},
+		"hours":       {"hours", runHours},
+		"set-hours":   {"set-hours [-zone Z] [-holidays NAMES] [-strict] DAYS=HH:MM-HH:MM...", runSetHours},
+		"unset-hours": {"unset-hours", runUnsetHours},
+		"prefs":       {"prefs", runPrefs},
+		"set-prefs":   {"set-prefs [-zone Z] [-locale L]", runSetPrefs},
+		"holidays":    {"holidays", runHolidays},
+		"freebusy":    {"freebusy [-from TIME] [-to TIME]", runFreeBusy},
+	}
+}
+
+type command struct {
+	usage string
+	run   func(ctx context.Context, c *client, args []string) error
+}
+
+var commands map[string]command
+
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage: %s [flags] command [command flags]\n\nCommands:\n", os.Args[0])
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(out, "  %s\n", commands[name].usage)
+	}
+	fmt.Fprintf(out, "  version\n\nFlags:\n")
+	flag.PrintDefaults()
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.Arg(0) == "version" {
+		printVersion()
+		return
+	}
+	cmd, ok := commands[flag.Arg(0)]
+	if !ok {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := run(cmd, flag.Args()[1:]); err != nil {
+		// usage errors are already reported by the flag set
+		if errors.Is(err, errUsage) {
+			os.Exit(2)
+		}
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(cmd command, args []string) error {
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return fmt.Errorf("unknown time zone %q", timezone)
+	}
+	if output != "table" && output != "json" {
+		return fmt.Errorf("unknown output format %q", output)
+	}
+
+	creds := insecure.NewCredentials()
+	if useTLS || tlsOpts != (tlsconfig.Options{}) {
+		config, err := tlsconfig.Client(tlsOpts, logger.NewWithWriter("ERROR", os.Stderr))
+		if err != nil {
+			return err
+		}
+		creds = credentials.NewTLS(config)
+	}
+
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return fmt.Errorf("unable to connect to %s: %w", addr, err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if userID != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "x-user-id", userID)
+	}
+	if token != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token)
+	}
+
+	c := &client{
+		api:   eventpb.NewEventServiceClient(conn),
+		print: printer{w: os.Stdout, json: output == "json", location: location},
+	}
+	return cmd.run(ctx, c, args)
+}
+
+type client struct {
+	api   eventpb.EventServiceClient
+	print printer
+}
+
+func envOr(name, value string) string {
+	if v, ok := os.LookupEnv(name); ok {
+		return v
+	}
+	return value
+}
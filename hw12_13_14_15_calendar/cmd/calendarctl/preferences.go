@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"text/tabwriter"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/pkg/eventpb"
+)
+
+// preferences is the JSON form of notification preferences, the same as in the HTTP API.
+type preferences struct {
+	TimeZone  string    `json:"time_zone"`
+	Locale    string    `json:"locale"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func runPrefs(ctx context.Context, c *client, args []string) error {
+	if err := parseArgs(newFlagSet("prefs"), args, 0); err != nil {
+		return err
+	}
+	prefs, err := c.api.GetNotificationPreferences(ctx, &eventpb.GetNotificationPreferencesRequest{})
+	if err != nil {
+		return fmt.Errorf("unable to get notification preferences: %w", err)
+	}
+	return c.print.preferences(prefs)
+}
+
+func runSetPrefs(ctx context.Context, c *client, args []string) error {
+	fs := newFlagSet("set-prefs")
+	tz := fs.String("zone", "", "IANA time zone of dates in notifications, the sender default if empty")
+	locale := fs.String("locale", "", "Language tag of notifications, the sender default if empty")
+	if err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+
+	prefs, err := c.api.SetNotificationPreferences(ctx, &eventpb.SetNotificationPreferencesRequest{
+		Preferences: &eventpb.NotificationPreferences{TimeZone: *tz, Locale: *locale},
+	})
+	if err != nil {
+		return fmt.Errorf("unable to set notification preferences: %w", err)
+	}
+	return c.print.preferences(prefs)
+}
+
+func (p printer) preferences(prefs *eventpb.NotificationPreferences) error {
+	if p.json {
+		var updatedAt time.Time
+		if prefs.GetUpdatedAt() != nil {
+			updatedAt = prefs.GetUpdatedAt().AsTime()
+		}
+		return p.encode(preferences{TimeZone: prefs.GetTimeZone(), Locale: prefs.GetLocale(), UpdatedAt: updatedAt})
+	}
+
+	tw := tabwriter.NewWriter(p.w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "Time zone:\t%s\n", orDefault(prefs.GetTimeZone()))
+	fmt.Fprintf(tw, "Locale:\t%s\n", orDefault(prefs.GetLocale()))
+	return tw.Flush()
+}
+
+func orDefault(s string) string {
+	if s == "" {
+		return "default"
+	}
+	return s
+}
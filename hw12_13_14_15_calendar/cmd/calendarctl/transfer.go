@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/ical"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/pkg/eventpb"
+	"google.golang.org/grpc/metadata"
+)
+
+func withIdempotencyKey(ctx context.Context, key string) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, "idempotency-key", key)
+}
+
+// fileFormat returns the format flag or guesses it by the file extension.
+func fileFormat(format, file string) (string, error) {
+	if format == "" {
+		format = "json"
+		if ext := strings.ToLower(filepath.Ext(file)); ext == ".ics" || ext == ".ical" {
+			format = "ics"
+		}
+	}
+	if format != "json" && format != "ics" {
+		return "", fmt.Errorf("unknown file format %q", format)
+	}
+	return format, nil
+}
+
+func runExport(ctx context.Context, c *client, args []string) error {
+	var agenda agendaFlags
+	fs := newFlagSet("export")
+	agenda.register(fs)
+	format := fs.String("format", "", "File format: json or ics, guessed by the file extension if empty")
+	file := fs.String("file", "-", "Output file, - is the standard output")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	f, err := fileFormat(*format, *file)
+	if err != nil {
+		return err
+	}
+
+	events, err := agenda.list(ctx, c)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if *file != "-" {
+		out, err := os.Create(*file)
+		if err != nil {
+			return fmt.Errorf("unable to create file: %w", err)
+		}
+		defer out.Close()
+		w = out
+	}
+	if err := export(w, f, events); err != nil {
+		return fmt.Errorf("unable to export events: %w", err)
+	}
+	if *file != "-" {
+		fmt.Fprintf(c.print.w, "%d events exported to %s\n", len(events), *file)
+	}
+	return nil
+}
+
+func export(w io.Writer, format string, events []*eventpb.Event) error {
+	if format == "ics" {
+		list := make([]ical.Event, 0, len(events))
+		for _, e := range events {
+			list = append(list, toICal(e))
+		}
+		return ical.Encode(w, list)
+	}
+	return printer{w: w, json: true}.events(events)
+}
+
+func runImport(ctx context.Context, c *client, args []string) error {
+	fs := newFlagSet("import")
+	format := fs.String("format", "", "File format: json or ics, guessed by the file extension if empty")
+	calendarID := fs.String("calendar", "", "Calendar ID to import to, the default calendar if empty")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errUsage
+	}
+	file := fs.Arg(0)
+	f, err := fileFormat(*format, file)
+	if err != nil {
+		return err
+	}
+
+	var r io.Reader = os.Stdin
+	if file != "-" {
+		in, err := os.Open(file)
+		if err != nil {
+			return fmt.Errorf("unable to open file: %w", err)
+		}
+		defer in.Close()
+		r = in
+	}
+	events, err := parseImport(r, f)
+	if err != nil {
+		return fmt.Errorf("unable to read events: %w", err)
+	}
+
+	failed := 0
+	for i, e := range events {
+		name := fmt.Sprintf("event %d %q", i+1, e.Title)
+		pb, err := e.toProto()
+		if err != nil {
+			failed++
+			fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+			continue
+		}
+
+		// importing the same file twice does not duplicate events
+		callCtx := ctx
+		if e.ID != "" {
+			callCtx = withIdempotencyKey(ctx, "import:"+e.ID)
+		}
+		// calendars of the exported events may be unknown to the user, so they are not kept
+		pb.Id = ""
+		pb.CalendarId = *calendarID
+		created, err := c.api.CreateEvent(callCtx, &eventpb.CreateEventRequest{Event: pb})
+		if err != nil {
+			failed++
+			fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+			continue
+		}
+		fmt.Fprintf(c.print.w, "%s imported as %s\n", name, created.GetId())
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d events are not imported", failed, len(events))
+	}
+	return nil
+}
+
+func parseImport(r io.Reader, format string) ([]event, error) {
+	if format == "ics" {
+		calendar, err := ical.Decode(r)
+		if err != nil {
+			return nil, err
+		}
+		events := make([]event, 0, len(calendar))
+		for _, e := range calendar {
+			events = append(events, fromICal(e))
+		}
+		return events, nil
+	}
+
+	var events []event
+	if err := json.NewDecoder(r).Decode(&events); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/pkg/eventpb"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const clockLayout = "15:04"
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// workingHours is the JSON form of working hours, the same as in the HTTP API.
+type workingHours struct {
+	TimeZone  string          `json:"time_zone"`
+	Periods   []workingPeriod `json:"periods"`
+	Holidays  []string        `json:"holidays"`
+	Strict    bool            `json:"strict"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+type workingPeriod struct {
+	Weekday string `json:"weekday"`
+	Start   string `json:"start"`
+	End     string `json:"end"`
+}
+
+type interval struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+func runHours(ctx context.Context, c *client, args []string) error {
+	if err := parseArgs(newFlagSet("hours"), args, 0); err != nil {
+		return err
+	}
+	hours, err := c.api.GetWorkingHours(ctx, &eventpb.GetWorkingHoursRequest{})
+	if err != nil {
+		return fmt.Errorf("unable to get working hours: %w", err)
+	}
+	return c.print.workingHours(hours)
+}
+
+func runSetHours(ctx context.Context, c *client, args []string) error {
+	fs := newFlagSet("set-hours")
+	tz := fs.String("zone", "UTC", "IANA time zone of the periods")
+	holidays := fs.String("holidays", "", "Comma-separated names of holiday calendars")
+	strict := fs.Bool("strict", false, "Reject events out of the working time instead of warning about them")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errUsage
+	}
+
+	hours := &eventpb.WorkingHours{TimeZone: *tz, Strict: *strict}
+	if *holidays != "" {
+		hours.Holidays = strings.Split(*holidays, ",")
+	}
+	for _, arg := range fs.Args() {
+		periods, err := parsePeriods(arg)
+		if err != nil {
+			return err
+		}
+		hours.Periods = append(hours.Periods, periods...)
+	}
+
+	set, err := c.api.SetWorkingHours(ctx, &eventpb.SetWorkingHoursRequest{WorkingHours: hours})
+	if err != nil {
+		return fmt.Errorf("unable to set working hours: %w", err)
+	}
+	return c.print.workingHours(set)
+}
+
+// parsePeriods parses "mon-fri=09:00-18:00" or "sat=10:00-14:00" into a period of every weekday.
+func parsePeriods(s string) ([]*eventpb.WorkingPeriod, error) {
+	invalid := fmt.Errorf("invalid period %q, expected like mon-fri=09:00-18:00", s)
+
+	days, clocks, ok := strings.Cut(s, "=")
+	if !ok {
+		return nil, invalid
+	}
+	first, last, ok := strings.Cut(days, "-")
+	if !ok {
+		last = first
+	}
+	from, ok := weekdays[strings.ToLower(first)]
+	if !ok {
+		return nil, invalid
+	}
+	to, ok := weekdays[strings.ToLower(last)]
+	if !ok || to < from {
+		return nil, invalid
+	}
+	startClock, endClock, ok := strings.Cut(clocks, "-")
+	if !ok {
+		return nil, invalid
+	}
+	start, err := parseClock(startClock)
+	if err != nil {
+		return nil, invalid
+	}
+	end, err := parseClock(endClock)
+	if err != nil {
+		return nil, invalid
+	}
+
+	var periods []*eventpb.WorkingPeriod
+	for day := from; day <= to; day++ {
+		periods = append(periods, &eventpb.WorkingPeriod{
+			Weekday: int32(day),
+			Start:   durationpb.New(start),
+			End:     durationpb.New(end),
+		})
+	}
+	return periods, nil
+}
+
+func parseClock(s string) (time.Duration, error) {
+	if s == "24:00" {
+		return 24 * time.Hour, nil
+	}
+	t, err := time.Parse(clockLayout, s)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+func formatClock(d time.Duration) string {
+	return fmt.Sprintf("%02d:%02d", int(d/time.Hour), int(d%time.Hour/time.Minute))
+}
+
+func runUnsetHours(ctx context.Context, c *client, args []string) error {
+	if err := parseArgs(newFlagSet("unset-hours"), args, 0); err != nil {
+		return err
+	}
+	if _, err := c.api.DeleteWorkingHours(ctx, &eventpb.DeleteWorkingHoursRequest{}); err != nil {
+		return fmt.Errorf("unable to delete working hours: %w", err)
+	}
+	fmt.Fprintln(c.print.w, "working hours deleted")
+	return nil
+}
+
+func runHolidays(ctx context.Context, c *client, args []string) error {
+	if err := parseArgs(newFlagSet("holidays"), args, 0); err != nil {
+		return err
+	}
+	resp, err := c.api.ListHolidayCalendars(ctx, &eventpb.ListHolidayCalendarsRequest{})
+	if err != nil {
+		return fmt.Errorf("unable to list holiday calendars: %w", err)
+	}
+	if c.print.json {
+		return c.print.encode(resp.GetNames())
+	}
+	for _, name := range resp.GetNames() {
+		fmt.Fprintln(c.print.w, name)
+	}
+	return nil
+}
+
+func runFreeBusy(ctx context.Context, c *client, args []string) error {
+	var (
+		from = timeFlag{location: c.print.location}
+		to   = timeFlag{location: c.print.location}
+	)
+	fs := newFlagSet("freebusy")
+	fs.Var(&from, "from", "Start of the period, today's midnight if empty")
+	fs.Var(&to, "to", "End of the period, a week after the start if empty")
+	if err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+	if from.t.IsZero() {
+		year, month, day := time.Now().In(c.print.location).Date()
+		from.t = time.Date(year, month, day, 0, 0, 0, 0, c.print.location)
+	}
+	if to.t.IsZero() {
+		to.t = from.t.AddDate(0, 0, 7)
+	}
+
+	resp, err := c.api.FreeBusy(ctx, &eventpb.FreeBusyRequest{From: timestamppb.New(from.t), To: timestamppb.New(to.t)})
+	if err != nil {
+		return fmt.Errorf("unable to get free/busy time: %w", err)
+	}
+	return c.print.freeBusy(resp)
+}
+
+func (p printer) workingHours(h *eventpb.WorkingHours) error {
+	periods := make([]workingPeriod, 0, len(h.GetPeriods()))
+	for _, period := range h.GetPeriods() {
+		periods = append(periods, workingPeriod{
+			Weekday: strings.ToLower(time.Weekday(period.GetWeekday()).String()),
+			Start:   formatClock(period.GetStart().AsDuration()),
+			End:     formatClock(period.GetEnd().AsDuration()),
+		})
+	}
+	if p.json {
+		return p.encode(workingHours{
+			TimeZone:  h.GetTimeZone(),
+			Periods:   periods,
+			Holidays:  h.GetHolidays(),
+			Strict:    h.GetStrict(),
+			UpdatedAt: h.GetUpdatedAt().AsTime(),
+		})
+	}
+
+	tw := tabwriter.NewWriter(p.w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "Time zone:\t%s\n", h.GetTimeZone())
+	for _, period := range periods {
+		fmt.Fprintf(tw, "%s:\t%s-%s\n", period.Weekday, period.Start, period.End)
+	}
+	if len(h.GetHolidays()) > 0 {
+		fmt.Fprintf(tw, "Holidays:\t%s\n", strings.Join(h.GetHolidays(), ", "))
+	}
+	fmt.Fprintf(tw, "Strict:\t%t\n", h.GetStrict())
+	return tw.Flush()
+}
+
+func (p printer) freeBusy(resp *eventpb.FreeBusyResponse) error {
+	toIntervals := func(list []*eventpb.Interval) []interval {
+		intervals := make([]interval, 0, len(list))
+		for _, i := range list {
+			intervals = append(intervals, interval{Start: i.GetStart().AsTime(), End: i.GetEnd().AsTime()})
+		}
+		return intervals
+	}
+	busy, free := toIntervals(resp.GetBusy()), toIntervals(resp.GetFree())
+	if p.json {
+		return p.encode(struct {
+			Busy []interval `json:"busy"`
+			Free []interval `json:"free"`
+		}{Busy: busy, Free: free})
+	}
+
+	tw := tabwriter.NewWriter(p.w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "STATE\tSTART\tEND")
+	for _, state := range []struct {
+		name      string
+		intervals []interval
+	}{{"busy", busy}, {"free", free}} {
+		for _, i := range state.intervals {
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", state.name,
+				i.Start.In(p.location).Format("Mon 2006-01-02 15:04"), i.End.In(p.location).Format("Mon 2006-01-02 15:04"))
+		}
+	}
+	return tw.Flush()
+}
@@ -2,25 +2,300 @@ package app
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/holiday"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	"github.com/google/uuid"
 )
 
-type App struct { // TODO
+var ErrInvalidEvent = errors.New("invalid event")
+
+type App struct {
+	logger         Logger
+	storage        Storage
+	idempotencyTTL time.Duration
+	blobs          BlobStore
+	maxAttachment  int64
+	maxBatch       int
+	holidays       map[string]*holiday.Calendar
+	admins         map[string]struct{}
+	// changed is broadcast after every change of events to wake up watchers.
+	changed *signal
+}
+
+type Options struct {
+	// IdempotencyTTL is how long retries of a create request return the event created first.
+	IdempotencyTTL time.Duration
+	// Blobs keeps the content of attachments, attachments are disabled if it is nil.
+	Blobs BlobStore
+	// MaxAttachmentSize is the size limit of an attachment in bytes.
+	MaxAttachmentSize int64
+	// MaxBatchSize is the maximum number of items in a batch.
+	MaxBatchSize int
+	// Holidays are the holiday calendars users can attach to their working hours by name.
+	Holidays map[string]*holiday.Calendar
+	// Admins are IDs of users allowed to see data across all users, e.g. the purge preview.
+	Admins []string
 }
 
-type Logger interface { // TODO
+type Logger interface {
+	Info(msg string)
+	Error(msg string)
 }
 
-type Storage interface { // TODO
+type Storage interface {
+	CreateEvent(ctx context.Context, event storage.Event, userID string) (storage.Event, error)
+	CreateEventIdempotent(
+		ctx context.Context, event storage.Event, userID string, idempotency storage.Idempotency,
+	) (storage.Event, error)
+	GetEvent(ctx context.Context, id string) (storage.Event, error)
+	EventCalendar(ctx context.Context, id string) (string, error)
+	UpdateEvent(ctx context.Context, event storage.Event, userID string, expectedVersion int64) (storage.Event, error)
+	DeleteEvent(ctx context.Context, id, userID string, expectedVersion int64) error
+	ApplyBatch(ctx context.Context, items []storage.BatchItem, userID string) ([]storage.BatchResult, error)
+	RestoreEvent(ctx context.Context, id, userID string, expectedVersion int64) (storage.Event, error)
+	EventHistory(ctx context.Context, id string) ([]storage.Change, error)
+	ListChanges(ctx context.Context, query storage.ChangeQuery) ([]storage.Change, error)
+	LatestChangeID(ctx context.Context) (int64, error)
+	ListEvents(ctx context.Context, query storage.Query) ([]storage.Event, error)
+	SearchEvents(ctx context.Context, query storage.SearchQuery) ([]storage.SearchHit, error)
+	CountPurgeable(ctx context.Context, deletedBefore, endedBefore time.Time) (int64, error)
+	CreateCalendar(ctx context.Context, calendar storage.Calendar) (storage.Calendar, error)
+	GetCalendar(ctx context.Context, id string) (storage.Calendar, error)
+	ListCalendars(ctx context.Context, userID string) ([]storage.Calendar, error)
+	ShareCalendar(ctx context.Context, calendarID, userID string, access storage.Access) error
+	UnshareCalendar(ctx context.Context, calendarID, userID string) error
+	CreateAttachment(ctx context.Context, attachment storage.Attachment) (storage.Attachment, error)
+	GetAttachment(ctx context.Context, id string) (storage.Attachment, error)
+	ListAttachments(ctx context.Context, eventID string) ([]storage.Attachment, error)
+	DeleteAttachment(ctx context.Context, id string) error
+	GetWorkingHours(ctx context.Context, userID string) (storage.WorkingHours, error)
+	SetWorkingHours(ctx context.Context, hours storage.WorkingHours) (storage.WorkingHours, error)
+	DeleteWorkingHours(ctx context.Context, userID string) error
+	GetNotificationPreferences(ctx context.Context, userID string) (storage.NotificationPreferences, error)
+	SetNotificationPreferences(
+		ctx context.Context, prefs storage.NotificationPreferences,
+	) (storage.NotificationPreferences, error)
 }
 
-func New(logger Logger, storage Storage) *App {
-	return &App{}
+type BlobStore interface {
+	Put(ctx context.Context, key string, content io.Reader) (int64, error)
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+	Keys(ctx context.Context, before time.Time) ([]string, error)
 }
 
-func (a *App) CreateEvent(ctx context.Context, id, title string) error {
-	// TODO
+func New(logger Logger, storage Storage, opts Options) *App {
+	if opts.IdempotencyTTL <= 0 {
+		opts.IdempotencyTTL = DefaultIdempotencyTTL
+	}
+	if opts.MaxAttachmentSize <= 0 {
+		opts.MaxAttachmentSize = DefaultMaxAttachmentSize
+	}
+	if opts.MaxBatchSize <= 0 {
+		opts.MaxBatchSize = DefaultMaxBatchSize
+	}
+	admins := make(map[string]struct{}, len(opts.Admins))
+	for _, userID := range opts.Admins {
+		admins[userID] = struct{}{}
+	}
+	return &App{
+		logger:         logger,
+		storage:        storage,
+		idempotencyTTL: opts.IdempotencyTTL,
+		blobs:          opts.Blobs,
+		maxAttachment:  opts.MaxAttachmentSize,
+		maxBatch:       opts.MaxBatchSize,
+		holidays:       opts.Holidays,
+		admins:         admins,
+		changed:        newSignal(),
+	}
+}
+
+// CreateEvent creates the event on behalf of the user in the calendar of the event, the user's default calendar
+// is used if it is not set. If idempotencyKey is not empty, retries with the same key
+// return the event created first and ErrIdempotencyKeyReused is returned if the request differs.
+// The warnings are about the event out of the working time of the calendar owner.
+func (a *App) CreateEvent(
+	ctx context.Context,
+	event storage.Event,
+	userID, idempotencyKey string,
+) (storage.Event, []string, error) {
+	event, warnings, err := a.prepareCreate(ctx, event, userID)
+	if err != nil {
+		return storage.Event{}, nil, err
+	}
+	if idempotencyKey == "" {
+		if event, err = a.storage.CreateEvent(ctx, event, userID); err != nil {
+			return storage.Event{}, nil, err
+		}
+		a.changed.broadcast()
+		return event, warnings, nil
+	}
+
+	if len(idempotencyKey) > maxIdempotencyKeyLen {
+		return storage.Event{}, nil, fmt.Errorf("%w: idempotency key is too long", ErrInvalidEvent)
+	}
+	hash, err := requestHash(event)
+	if err != nil {
+		return storage.Event{}, nil, fmt.Errorf("unable to hash request: %w", err)
+	}
+	event, err = a.storage.CreateEventIdempotent(ctx, event, userID, storage.Idempotency{
+		Key:         idempotencyKey,
+		RequestHash: hash,
+		ExpiresAt:   time.Now().Add(a.idempotencyTTL),
+	})
+	if err != nil {
+		return storage.Event{}, nil, err
+	}
+	a.changed.broadcast()
+	return event, warnings, nil
+}
+
+func (a *App) GetEvent(ctx context.Context, id, userID string) (storage.Event, error) {
+	if _, err := a.eventCalendar(ctx, id, userID, storage.AccessRead); err != nil {
+		return storage.Event{}, err
+	}
+	return a.storage.GetEvent(ctx, id)
+}
+
+// UpdateEvent replaces the event on behalf of the user if its current version equals expectedVersion.
+// Pass storage.AnyVersion to skip the check. The event moves to another calendar if its calendar is set,
+// the user needs write access to both. The warnings are like the ones of CreateEvent.
+func (a *App) UpdateEvent(
+	ctx context.Context,
+	event storage.Event,
+	userID string,
+	expectedVersion int64,
+) (storage.Event, []string, error) {
+	event, warnings, err := a.prepareUpdate(ctx, event, userID)
+	if err != nil {
+		return storage.Event{}, nil, err
+	}
+	if event, err = a.storage.UpdateEvent(ctx, event, userID, expectedVersion); err != nil {
+		return storage.Event{}, nil, err
+	}
+	a.changed.broadcast()
+	return event, warnings, nil
+}
+
+// DeleteEvent soft deletes the event, it can be restored until purged.
+func (a *App) DeleteEvent(ctx context.Context, id, userID string, expectedVersion int64) error {
+	if _, err := a.eventCalendar(ctx, id, userID, storage.AccessWrite); err != nil {
+		return err
+	}
+	if err := a.storage.DeleteEvent(ctx, id, userID, expectedVersion); err != nil {
+		return err
+	}
+	a.changed.broadcast()
 	return nil
-	// return a.storage.CreateEvent(storage.Event{ID: id, Title: title})
 }
 
-// TODO
+func (a *App) RestoreEvent(ctx context.Context, id, userID string, expectedVersion int64) (storage.Event, error) {
+	if _, err := a.eventCalendar(ctx, id, userID, storage.AccessWrite); err != nil {
+		return storage.Event{}, err
+	}
+	event, err := a.storage.RestoreEvent(ctx, id, userID, expectedVersion)
+	if err != nil {
+		return storage.Event{}, err
+	}
+	a.changed.broadcast()
+	return event, nil
+}
+
+func (a *App) EventHistory(ctx context.Context, id, userID string) ([]storage.Change, error) {
+	if _, err := a.eventCalendar(ctx, id, userID, storage.AccessRead); err != nil {
+		return nil, err
+	}
+	return a.storage.EventHistory(ctx, id)
+}
+
+// PreviewPurge returns the number of events the scheduler's purge would delete now
+// with the given retention of soft deleted events and maximum age of ended events.
+// The events are of all users, so only admins may see the number.
+func (a *App) PreviewPurge(ctx context.Context, userID string, retention, maxAge time.Duration) (int64, error) {
+	if _, ok := a.admins[userID]; !ok {
+		return 0, fmt.Errorf("%w: purge preview is for admins only", ErrAccessDenied)
+	}
+	if retention < 0 || maxAge < 0 {
+		return 0, fmt.Errorf("%w: retention and max age must not be negative", ErrInvalidQuery)
+	}
+	now := time.Now()
+	return a.storage.CountPurgeable(ctx, now.Add(-retention), now.Add(-maxAge))
+}
+
+// prepareCreate checks the new event and the user's access to its calendar, it assigns the id and the owner.
+func (a *App) prepareCreate(ctx context.Context, event storage.Event, userID string) (storage.Event, []string, error) {
+	if err := validate(event, userID); err != nil {
+		return storage.Event{}, nil, err
+	}
+
+	var (
+		calendar storage.Calendar
+		err      error
+	)
+	if event.CalendarID == "" {
+		calendar, err = a.defaultCalendar(ctx, userID)
+	} else {
+		calendar, err = a.calendar(ctx, event.CalendarID, userID, storage.AccessWrite)
+	}
+	if err != nil {
+		return storage.Event{}, nil, err
+	}
+
+	event.ID = uuid.NewString()
+	event.CalendarID = calendar.ID
+	event.UserID = calendar.OwnerID
+	event.Reminders = sortReminders(event.Reminders)
+	warnings, err := a.checkWorkingTime(ctx, event)
+	if err != nil {
+		return storage.Event{}, nil, err
+	}
+	return event, warnings, nil
+}
+
+// prepareUpdate checks the changed event and the user's access to its current and new calendars.
+func (a *App) prepareUpdate(ctx context.Context, event storage.Event, userID string) (storage.Event, []string, error) {
+	if err := validate(event, userID); err != nil {
+		return storage.Event{}, nil, err
+	}
+	calendar, err := a.eventCalendar(ctx, event.ID, userID, storage.AccessWrite)
+	if err != nil {
+		return storage.Event{}, nil, err
+	}
+	if event.CalendarID != "" && event.CalendarID != calendar.ID {
+		if calendar, err = a.calendar(ctx, event.CalendarID, userID, storage.AccessWrite); err != nil {
+			return storage.Event{}, nil, err
+		}
+	}
+
+	event.CalendarID = calendar.ID
+	event.UserID = calendar.OwnerID
+	event.Reminders = sortReminders(event.Reminders)
+	warnings, err := a.checkWorkingTime(ctx, event)
+	if err != nil {
+		return storage.Event{}, nil, err
+	}
+	return event, warnings, nil
+}
+
+func validate(event storage.Event, userID string) error {
+	switch {
+	case event.Title == "":
+		return fmt.Errorf("%w: title is empty", ErrInvalidEvent)
+	case userID == "":
+		return fmt.Errorf("%w: user id is empty", ErrInvalidEvent)
+	case !event.EndsAt.After(event.StartsAt):
+		return fmt.Errorf("%w: event must end after it starts", ErrInvalidEvent)
+	}
+	return validateReminders(event.Reminders)
+}
+
+func startOfDay(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+}
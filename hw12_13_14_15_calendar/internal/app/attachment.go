@@ -0,0 +1,209 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/blob"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	"github.com/google/uuid"
+)
+
+const (
+	// DefaultMaxAttachmentSize is used if Options.MaxAttachmentSize is not set.
+	DefaultMaxAttachmentSize = 10 << 20
+	defaultContentType       = "application/octet-stream"
+	maxAttachmentNameLen     = 255
+	// attachmentGCGrace keeps recent blobs from collection, as their attachments may not be saved yet.
+	attachmentGCGrace = time.Hour
+)
+
+var (
+	ErrInvalidAttachment  = errors.New("invalid attachment")
+	ErrAttachmentTooLarge = errors.New("attachment is too large")
+	// ErrAttachmentsDisabled is returned when the app has no blob store.
+	ErrAttachmentsDisabled = errors.New("attachments are disabled")
+)
+
+// AddAttachment attaches the file to the event, the user needs write access to its calendar.
+// The content is read until EOF and must not exceed the size limit.
+func (a *App) AddAttachment(
+	ctx context.Context,
+	eventID, userID, name, contentType string,
+	content io.Reader,
+) (storage.Attachment, error) {
+	if a.blobs == nil {
+		return storage.Attachment{}, ErrAttachmentsDisabled
+	}
+	if err := validateAttachmentName(name); err != nil {
+		return storage.Attachment{}, err
+	}
+	contentType, err := normalizeContentType(contentType)
+	if err != nil {
+		return storage.Attachment{}, err
+	}
+	if err := a.liveEvent(ctx, eventID, userID, storage.AccessWrite); err != nil {
+		return storage.Attachment{}, err
+	}
+
+	id := uuid.NewString()
+	size, err := a.blobs.Put(ctx, id, io.LimitReader(content, a.maxAttachment+1))
+	if err != nil {
+		return storage.Attachment{}, fmt.Errorf("unable to store attachment: %w", err)
+	}
+	if size > a.maxAttachment {
+		a.deleteBlob(ctx, id)
+		return storage.Attachment{}, fmt.Errorf("%w: the limit is %d bytes", ErrAttachmentTooLarge, a.maxAttachment)
+	}
+
+	attachment, err := a.storage.CreateAttachment(ctx, storage.Attachment{
+		ID:          id,
+		EventID:     eventID,
+		Name:        name,
+		ContentType: contentType,
+		Size:        size,
+		UserID:      userID,
+	})
+	if err != nil {
+		a.deleteBlob(ctx, id)
+		return storage.Attachment{}, err
+	}
+	return attachment, nil
+}
+
+// ListAttachments returns attachments of the event in the order they were added.
+func (a *App) ListAttachments(ctx context.Context, eventID, userID string) ([]storage.Attachment, error) {
+	if err := a.liveEvent(ctx, eventID, userID, storage.AccessRead); err != nil {
+		return nil, err
+	}
+	return a.storage.ListAttachments(ctx, eventID)
+}
+
+// OpenAttachment returns the attachment of the event with its content, which the caller must close.
+func (a *App) OpenAttachment(
+	ctx context.Context,
+	eventID, id, userID string,
+) (storage.Attachment, io.ReadCloser, error) {
+	if a.blobs == nil {
+		return storage.Attachment{}, nil, ErrAttachmentsDisabled
+	}
+	attachment, err := a.eventAttachment(ctx, eventID, id, userID, storage.AccessRead)
+	if err != nil {
+		return storage.Attachment{}, nil, err
+	}
+	content, err := a.blobs.Open(ctx, id)
+	if errors.Is(err, blob.ErrNotFound) {
+		return storage.Attachment{}, nil, fmt.Errorf("%w: content is missing", storage.ErrAttachmentNotFound)
+	}
+	if err != nil {
+		return storage.Attachment{}, nil, fmt.Errorf("unable to open attachment: %w", err)
+	}
+	return attachment, content, nil
+}
+
+// DeleteAttachment deletes the attachment of the event, the user needs write access to its calendar.
+func (a *App) DeleteAttachment(ctx context.Context, eventID, id, userID string) error {
+	if _, err := a.eventAttachment(ctx, eventID, id, userID, storage.AccessWrite); err != nil {
+		return err
+	}
+	if err := a.storage.DeleteAttachment(ctx, id); err != nil {
+		return err
+	}
+	if a.blobs != nil {
+		a.deleteBlob(ctx, id)
+	}
+	return nil
+}
+
+// CollectAttachments deletes contents left without attachments, e.g. by purged events, and returns their number.
+func (a *App) CollectAttachments(ctx context.Context) (int, error) {
+	if a.blobs == nil {
+		return 0, nil
+	}
+	keys, err := a.blobs.Keys(ctx, time.Now().Add(-attachmentGCGrace))
+	if err != nil {
+		return 0, err
+	}
+	deleted := 0
+	for _, key := range keys {
+		_, err := a.storage.GetAttachment(ctx, key)
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, storage.ErrAttachmentNotFound) {
+			return deleted, err
+		}
+		if err := a.blobs.Delete(ctx, key); err != nil {
+			return deleted, err
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+// eventAttachment returns the attachment if it belongs to the live event and the user has the required access.
+func (a *App) eventAttachment(
+	ctx context.Context,
+	eventID, id, userID string,
+	required storage.Access,
+) (storage.Attachment, error) {
+	if err := a.liveEvent(ctx, eventID, userID, required); err != nil {
+		return storage.Attachment{}, err
+	}
+	attachment, err := a.storage.GetAttachment(ctx, id)
+	if err != nil {
+		return storage.Attachment{}, err
+	}
+	if attachment.EventID != eventID {
+		return storage.Attachment{}, storage.ErrAttachmentNotFound
+	}
+	return attachment, nil
+}
+
+// liveEvent checks that the event is not deleted, attachments of deleted events are hidden until they are restored.
+func (a *App) liveEvent(ctx context.Context, id, userID string, required storage.Access) error {
+	if _, err := a.eventCalendar(ctx, id, userID, required); err != nil {
+		return err
+	}
+	_, err := a.storage.GetEvent(ctx, id)
+	return err
+}
+
+// deleteBlob is best effort, a blob left behind takes space but is never served.
+func (a *App) deleteBlob(ctx context.Context, id string) {
+	if err := a.blobs.Delete(ctx, id); err != nil {
+		a.logger.Error("unable to delete attachment content " + id + ": " + err.Error())
+	}
+}
+
+func validateAttachmentName(name string) error {
+	switch {
+	case name == "":
+		return fmt.Errorf("%w: name is empty", ErrInvalidAttachment)
+	case !utf8.ValidString(name):
+		return fmt.Errorf("%w: name is not valid UTF-8", ErrInvalidAttachment)
+	case utf8.RuneCountInString(name) > maxAttachmentNameLen:
+		return fmt.Errorf("%w: name is too long", ErrInvalidAttachment)
+	case strings.ContainsAny(name, `/\`) || strings.ContainsFunc(name, unicode.IsControl):
+		return fmt.Errorf("%w: name must be a file name", ErrInvalidAttachment)
+	}
+	return nil
+}
+
+func normalizeContentType(contentType string) (string, error) {
+	if contentType == "" {
+		return defaultContentType, nil
+	}
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", fmt.Errorf("%w: content type: %v", ErrInvalidAttachment, err)
+	}
+	return mime.FormatMediaType(mediaType, params), nil
+}
@@ -0,0 +1,153 @@
+package app
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/blob"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/logger"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	memorystorage "github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage/memory"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAttachments(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	blobs, err := blob.NewFS(dir)
+	require.NoError(t, err)
+	a := New(logger.NewWithWriter("ERROR", io.Discard), memorystorage.New(), Options{
+		Blobs:             blobs,
+		MaxAttachmentSize: 10,
+	})
+	day := time.Date(2021, 8, 2, 0, 0, 0, 0, time.UTC)
+	event, _, err := a.CreateEvent(ctx,
+		storage.Event{Title: "event", StartsAt: day, EndsAt: day.Add(time.Hour)}, "owner", "")
+	require.NoError(t, err)
+	_, err = a.ShareCalendar(ctx, event.CalendarID, "owner", "reader", storage.AccessRead)
+	require.NoError(t, err)
+
+	agenda, err := a.AddAttachment(ctx, event.ID, "owner", "agenda.md", "text/markdown; charset=UTF-8",
+		strings.NewReader("# agenda"))
+	require.NoError(t, err)
+	require.Equal(t, int64(8), agenda.Size)
+	require.Equal(t, "text/markdown; charset=UTF-8", agenda.ContentType)
+	require.Equal(t, "owner", agenda.UserID)
+
+	blank, err := a.AddAttachment(ctx, event.ID, "owner", "blank", "", strings.NewReader(""))
+	require.NoError(t, err)
+	require.Equal(t, "application/octet-stream", blank.ContentType)
+
+	// the limit is exact
+	_, err = a.AddAttachment(ctx, event.ID, "owner", "big", "", strings.NewReader("0123456789"))
+	require.NoError(t, err)
+	_, err = a.AddAttachment(ctx, event.ID, "owner", "bigger", "", strings.NewReader("0123456789a"))
+	require.ErrorIs(t, err, ErrAttachmentTooLarge)
+
+	for name, contentType := range map[string]string{
+		"":          "",
+		"../secret": "",
+		"a\nb":      "",
+		"ok":        "not a type",
+	} {
+		_, err := a.AddAttachment(ctx, event.ID, "owner", name, contentType, strings.NewReader(""))
+		require.ErrorIs(t, err, ErrInvalidAttachment)
+	}
+	_, err = a.AddAttachment(ctx, event.ID, "reader", "notes", "", strings.NewReader(""))
+	require.ErrorIs(t, err, ErrAccessDenied)
+	_, err = a.AddAttachment(ctx, event.ID, "stranger", "notes", "", strings.NewReader(""))
+	require.ErrorIs(t, err, storage.ErrEventNotFound)
+
+	attachments, err := a.ListAttachments(ctx, event.ID, "reader")
+	require.NoError(t, err)
+	require.Len(t, attachments, 3)
+
+	got, content, err := a.OpenAttachment(ctx, event.ID, agenda.ID, "reader")
+	require.NoError(t, err)
+	body, err := io.ReadAll(content)
+	require.NoError(t, err)
+	require.NoError(t, content.Close())
+	require.Equal(t, "# agenda", string(body))
+	require.Equal(t, agenda.Name, got.Name)
+
+	other, _, err := a.CreateEvent(ctx, storage.Event{
+		Title: "other", StartsAt: day.Add(2 * time.Hour), EndsAt: day.Add(3 * time.Hour),
+	}, "owner", "")
+	require.NoError(t, err)
+	_, _, err = a.OpenAttachment(ctx, other.ID, agenda.ID, "owner")
+	require.ErrorIs(t, err, storage.ErrAttachmentNotFound, "attachments are found through their event only")
+
+	require.ErrorIs(t, a.DeleteAttachment(ctx, event.ID, agenda.ID, "reader"), ErrAccessDenied)
+	require.NoError(t, a.DeleteAttachment(ctx, event.ID, agenda.ID, "owner"))
+	_, _, err = a.OpenAttachment(ctx, event.ID, agenda.ID, "owner")
+	require.ErrorIs(t, err, storage.ErrAttachmentNotFound)
+
+	// attachments of deleted events are hidden
+	require.NoError(t, a.DeleteEvent(ctx, event.ID, "owner", storage.AnyVersion))
+	_, err = a.ListAttachments(ctx, event.ID, "owner")
+	require.ErrorIs(t, err, storage.ErrEventNotFound)
+
+	files, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, files, 2, "content of rejected and deleted attachments is removed")
+}
+
+func TestAttachmentsDisabled(t *testing.T) {
+	a := New(logger.NewWithWriter("ERROR", io.Discard), memorystorage.New(), Options{})
+	_, err := a.AddAttachment(context.Background(), "event", "user", "name", "", strings.NewReader(""))
+	require.ErrorIs(t, err, ErrAttachmentsDisabled)
+}
+
+func TestCollectAttachments(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	blobs, err := blob.NewFS(dir)
+	require.NoError(t, err)
+	s := memorystorage.New()
+	a := New(logger.NewWithWriter("ERROR", io.Discard), s, Options{Blobs: blobs})
+	day := time.Date(2021, 8, 2, 0, 0, 0, 0, time.UTC)
+	newAttachment := func(hours int) storage.Attachment {
+		startsAt := day.Add(time.Duration(hours) * time.Hour)
+		event, _, err := a.CreateEvent(ctx,
+			storage.Event{Title: "event", StartsAt: startsAt, EndsAt: startsAt.Add(time.Hour)}, "owner", "")
+		require.NoError(t, err)
+		attachment, err := a.AddAttachment(ctx, event.ID, "owner", "notes.txt", "", strings.NewReader("notes"))
+		require.NoError(t, err)
+		return attachment
+	}
+	purged, kept, missing := newAttachment(0), newAttachment(2), newAttachment(4)
+	_, err = blobs.Put(ctx, "orphan", strings.NewReader("orphan"))
+	require.NoError(t, err)
+
+	// a missing content is not found rather than an internal error
+	require.NoError(t, blobs.Delete(ctx, missing.ID))
+	_, _, err = a.OpenAttachment(ctx, missing.EventID, missing.ID, "owner")
+	require.ErrorIs(t, err, storage.ErrAttachmentNotFound)
+
+	require.NoError(t, a.DeleteEvent(ctx, purged.EventID, "owner", storage.AnyVersion))
+	_, err = s.PurgeEvents(ctx, time.Now().Add(time.Hour), time.Time{})
+	require.NoError(t, err)
+
+	deleted, err := a.CollectAttachments(ctx)
+	require.NoError(t, err)
+	require.Zero(t, deleted, "recent contents may belong to attachments being added")
+
+	past := time.Now().Add(-2 * attachmentGCGrace)
+	for _, key := range []string{purged.ID, kept.ID, "orphan"} {
+		require.NoError(t, os.Chtimes(filepath.Join(dir, key), past, past))
+	}
+	deleted, err = a.CollectAttachments(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 2, deleted)
+	_, content, err := a.OpenAttachment(ctx, kept.EventID, kept.ID, "owner")
+	require.NoError(t, err)
+	require.NoError(t, content.Close())
+	files, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+}
@@ -0,0 +1,123 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+)
+
+// DefaultMaxBatchSize is used if Options.MaxBatchSize is not set.
+const DefaultMaxBatchSize = 1000
+
+var ErrInvalidBatch = errors.New("invalid batch")
+
+// ApplyBatch creates, updates and deletes events on behalf of the user in the order of items,
+// the result of every item is at its index. Items are applied one by one and a failed item doesn't stop the others.
+// If atomic is set, either all items are applied or none of them, the items which would succeed are failed
+// with storage.ErrBatchAborted then. An error is returned only if the batch itself is invalid or can't be applied.
+// Applied items get the warnings of the single operations.
+func (a *App) ApplyBatch(
+	ctx context.Context,
+	items []storage.BatchItem,
+	userID string,
+	atomic bool,
+) ([]storage.BatchResult, error) {
+	switch {
+	case len(items) == 0:
+		return nil, fmt.Errorf("%w: no items", ErrInvalidBatch)
+	case len(items) > a.maxBatch:
+		return nil, fmt.Errorf("%w: more than %d items", ErrInvalidBatch, a.maxBatch)
+	}
+
+	prepared := make([]storage.BatchItem, len(items))
+	warnings := make([][]string, len(items))
+	results := make([]storage.BatchResult, len(items))
+	failed := false
+	for i, item := range items {
+		var err error
+		if prepared[i], warnings[i], err = a.prepareItem(ctx, item, userID); err != nil {
+			results[i] = storage.BatchResult{Event: storage.Event{ID: item.Event.ID}, Err: err}
+			failed = true
+		}
+	}
+	if !atomic {
+		for i, item := range prepared {
+			if results[i].Err == nil {
+				results[i] = a.applyItem(ctx, item, userID)
+			}
+		}
+		a.changed.broadcast()
+		return withWarnings(results, warnings), nil
+	}
+
+	if failed {
+		for i := range results {
+			if results[i].Err == nil {
+				results[i] = storage.BatchResult{Event: storage.Event{ID: prepared[i].Event.ID}, Err: storage.ErrBatchAborted}
+			}
+		}
+		return results, nil
+	}
+	results, err := a.storage.ApplyBatch(ctx, prepared, userID)
+	if err != nil {
+		return nil, err
+	}
+	a.changed.broadcast()
+	return withWarnings(results, warnings), nil
+}
+
+// prepareItem checks the item like the single operation does.
+func (a *App) prepareItem(
+	ctx context.Context,
+	item storage.BatchItem,
+	userID string,
+) (storage.BatchItem, []string, error) {
+	var (
+		warnings []string
+		err      error
+	)
+	switch item.Op {
+	case storage.BatchCreate:
+		item.Event, warnings, err = a.prepareCreate(ctx, item.Event, userID)
+	case storage.BatchUpdate:
+		item.Event, warnings, err = a.prepareUpdate(ctx, item.Event, userID)
+	case storage.BatchDelete:
+		_, err = a.eventCalendar(ctx, item.Event.ID, userID, storage.AccessWrite)
+		item.Event = storage.Event{ID: item.Event.ID}
+	default:
+		err = fmt.Errorf("%w: unknown operation %q", ErrInvalidBatch, item.Op)
+	}
+	return item, warnings, err
+}
+
+// withWarnings sets the warnings of the applied items.
+func withWarnings(results []storage.BatchResult, warnings [][]string) []storage.BatchResult {
+	for i := range results {
+		if results[i].Err == nil {
+			results[i].Warnings = warnings[i]
+		}
+	}
+	return results
+}
+
+func (a *App) applyItem(ctx context.Context, item storage.BatchItem, userID string) storage.BatchResult {
+	var (
+		event storage.Event
+		err   error
+	)
+	switch item.Op {
+	case storage.BatchCreate:
+		event, err = a.storage.CreateEvent(ctx, item.Event, userID)
+	case storage.BatchUpdate:
+		event, err = a.storage.UpdateEvent(ctx, item.Event, userID, item.ExpectedVersion)
+	case storage.BatchDelete:
+		err = a.storage.DeleteEvent(ctx, item.Event.ID, userID, item.ExpectedVersion)
+		event = storage.Event{ID: item.Event.ID}
+	}
+	if err != nil {
+		return storage.BatchResult{Event: storage.Event{ID: item.Event.ID}, Err: err}
+	}
+	return storage.BatchResult{Event: event}
+}
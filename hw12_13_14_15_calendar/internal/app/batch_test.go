@@ -0,0 +1,85 @@
+package app
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/logger"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	memorystorage "github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage/memory"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyBatch(t *testing.T) {
+	ctx := context.Background()
+	a := New(logger.NewWithWriter("ERROR", io.Discard), memorystorage.New(), Options{MaxBatchSize: 3})
+	day := time.Date(2021, 8, 2, 0, 0, 0, 0, time.UTC)
+	newEvent := func(title string, startsAt time.Time) storage.Event {
+		return storage.Event{Title: title, StartsAt: startsAt, EndsAt: startsAt.Add(time.Hour)}
+	}
+
+	existing, _, err := a.CreateEvent(ctx, newEvent("existing", day), "owner", "")
+	require.NoError(t, err)
+	_, err = a.ShareCalendar(ctx, existing.CalendarID, "owner", "reader", storage.AccessRead)
+	require.NoError(t, err)
+
+	_, err = a.ApplyBatch(ctx, nil, "owner", false)
+	require.ErrorIs(t, err, ErrInvalidBatch)
+	_, err = a.ApplyBatch(ctx, make([]storage.BatchItem, 4), "owner", false)
+	require.ErrorIs(t, err, ErrInvalidBatch)
+
+	// the reader can't delete, so nothing is applied in the atomic mode
+	items := []storage.BatchItem{
+		{Op: storage.BatchCreate, Event: newEvent("first", day.Add(2*time.Hour))},
+		{Op: storage.BatchDelete, Event: storage.Event{ID: existing.ID}},
+		{Op: "move", Event: storage.Event{ID: existing.ID}},
+	}
+	results, err := a.ApplyBatch(ctx, items, "reader", true)
+	require.NoError(t, err)
+	require.ErrorIs(t, results[0].Err, storage.ErrBatchAborted)
+	require.ErrorIs(t, results[1].Err, ErrAccessDenied)
+	require.ErrorIs(t, results[2].Err, ErrInvalidBatch)
+	page, err := a.ListDayEvents(ctx, "owner", day, ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, page.Events, 1)
+
+	// conflicts found by the storage are reported per item too
+	items = []storage.BatchItem{
+		{Op: storage.BatchCreate, Event: newEvent("first", day.Add(2*time.Hour))},
+		{Op: storage.BatchCreate, Event: newEvent("busy", day.Add(30*time.Minute))},
+		{Op: storage.BatchDelete, Event: storage.Event{ID: existing.ID}, ExpectedVersion: existing.Version + 1},
+	}
+	results, err = a.ApplyBatch(ctx, items, "owner", true)
+	require.NoError(t, err)
+	require.ErrorIs(t, results[0].Err, storage.ErrBatchAborted)
+	require.ErrorIs(t, results[1].Err, storage.ErrDateBusy)
+	require.ErrorIs(t, results[2].Err, storage.ErrVersionMismatch)
+
+	// without the atomic mode the items which can be applied are
+	results, err = a.ApplyBatch(ctx, items, "owner", false)
+	require.NoError(t, err)
+	require.NoError(t, results[0].Err)
+	require.Equal(t, "first", results[0].Event.Title)
+	require.Equal(t, existing.CalendarID, results[0].Event.CalendarID)
+	require.ErrorIs(t, results[1].Err, storage.ErrDateBusy)
+	require.ErrorIs(t, results[2].Err, storage.ErrVersionMismatch)
+
+	moved := existing
+	moved.StartsAt, moved.EndsAt = day.Add(5*time.Hour), day.Add(6*time.Hour)
+	results, err = a.ApplyBatch(ctx, []storage.BatchItem{
+		{Op: storage.BatchUpdate, Event: moved, ExpectedVersion: existing.Version},
+		{Op: storage.BatchCreate, Event: newEvent("second", day)},
+		{Op: storage.BatchDelete, Event: storage.Event{ID: results[0].Event.ID}},
+	}, "owner", true)
+	require.NoError(t, err)
+	for _, result := range results {
+		require.NoError(t, result.Err)
+	}
+	page, err = a.ListDayEvents(ctx, "owner", day, ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, page.Events, 2)
+	require.Equal(t, "second", page.Events[0].Title)
+	require.Equal(t, "existing", page.Events[1].Title)
+}
@@ -0,0 +1,162 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	"github.com/google/uuid"
+)
+
+const (
+	// DefaultCalendarName is the name of the calendar created for events without a calendar.
+	DefaultCalendarName = "default"
+	maxCalendarNameLen  = 100
+)
+
+var (
+	ErrInvalidCalendar = errors.New("invalid calendar")
+	// ErrAccessDenied is returned when the user can see the calendar but the action needs more access.
+	ErrAccessDenied = errors.New("access denied")
+)
+
+func (a *App) CreateCalendar(ctx context.Context, userID, name string) (storage.Calendar, error) {
+	switch {
+	case userID == "":
+		return storage.Calendar{}, fmt.Errorf("%w: user id is empty", ErrInvalidCalendar)
+	case name == "":
+		return storage.Calendar{}, fmt.Errorf("%w: name is empty", ErrInvalidCalendar)
+	case utf8.RuneCountInString(name) > maxCalendarNameLen:
+		return storage.Calendar{}, fmt.Errorf("%w: name is too long", ErrInvalidCalendar)
+	}
+	return a.storage.CreateCalendar(ctx, storage.Calendar{
+		ID:      uuid.NewString(),
+		OwnerID: userID,
+		Name:    name,
+	})
+}
+
+// GetCalendar returns the calendar if the user can read it.
+func (a *App) GetCalendar(ctx context.Context, id, userID string) (storage.Calendar, error) {
+	return a.calendar(ctx, id, userID, storage.AccessRead)
+}
+
+// ListCalendars returns calendars owned by the user or shared with them.
+func (a *App) ListCalendars(ctx context.Context, userID string) ([]storage.Calendar, error) {
+	return a.storage.ListCalendars(ctx, userID)
+}
+
+// ShareCalendar grants another user read or write access to the calendar, only the owner can share it.
+func (a *App) ShareCalendar(
+	ctx context.Context,
+	id, userID, withUserID string,
+	access storage.Access,
+) (storage.Calendar, error) {
+	switch {
+	case withUserID == "":
+		return storage.Calendar{}, fmt.Errorf("%w: user to share with is empty", ErrInvalidCalendar)
+	case withUserID == userID:
+		return storage.Calendar{}, fmt.Errorf("%w: calendar can't be shared with its owner", ErrInvalidCalendar)
+	case access != storage.AccessRead && access != storage.AccessWrite:
+		return storage.Calendar{}, fmt.Errorf("%w: access must be read or write", ErrInvalidCalendar)
+	}
+	if _, err := a.calendar(ctx, id, userID, storage.AccessOwner); err != nil {
+		return storage.Calendar{}, err
+	}
+	if err := a.storage.ShareCalendar(ctx, id, withUserID, access); err != nil {
+		return storage.Calendar{}, err
+	}
+	return a.storage.GetCalendar(ctx, id)
+}
+
+// UnshareCalendar revokes access of another user to the calendar.
+// The owner can revoke any share, other users can only leave the calendar themselves.
+func (a *App) UnshareCalendar(ctx context.Context, id, userID, withUserID string) error {
+	required := storage.AccessOwner
+	if withUserID == userID {
+		required = storage.AccessRead
+	}
+	calendar, err := a.calendar(ctx, id, userID, required)
+	if err != nil {
+		return err
+	}
+	if withUserID == calendar.OwnerID {
+		return fmt.Errorf("%w: owner can't leave the calendar", ErrInvalidCalendar)
+	}
+	return a.storage.UnshareCalendar(ctx, id, withUserID)
+}
+
+// calendar returns the calendar if the user has the required access to it.
+// Calendars the user has no access to at all are reported as not found.
+func (a *App) calendar(ctx context.Context, id, userID string, required storage.Access) (storage.Calendar, error) {
+	calendar, err := a.storage.GetCalendar(ctx, id)
+	if err != nil {
+		return storage.Calendar{}, err
+	}
+	access := calendar.Access(userID)
+	switch {
+	case access == "":
+		return storage.Calendar{}, storage.ErrCalendarNotFound
+	case !access.Allows(required):
+		return storage.Calendar{}, ErrAccessDenied
+	}
+	return calendar, nil
+}
+
+// eventCalendar returns the calendar of the event if the user has the required access to it.
+// Events the user has no access to at all are reported as not found.
+func (a *App) eventCalendar(ctx context.Context, id, userID string, required storage.Access) (storage.Calendar, error) {
+	calendarID, err := a.storage.EventCalendar(ctx, id)
+	if err != nil {
+		return storage.Calendar{}, err
+	}
+	calendar, err := a.calendar(ctx, calendarID, userID, required)
+	if errors.Is(err, storage.ErrCalendarNotFound) {
+		return storage.Calendar{}, storage.ErrEventNotFound
+	}
+	return calendar, err
+}
+
+// defaultCalendar returns the default calendar of the user creating it on first use.
+func (a *App) defaultCalendar(ctx context.Context, userID string) (storage.Calendar, error) {
+	for {
+		calendars, err := a.storage.ListCalendars(ctx, userID)
+		if err != nil {
+			return storage.Calendar{}, err
+		}
+		for _, calendar := range calendars {
+			if calendar.OwnerID == userID && calendar.Name == DefaultCalendarName {
+				return calendar, nil
+			}
+		}
+
+		calendar, err := a.CreateCalendar(ctx, userID, DefaultCalendarName)
+		// a concurrent request has just created it
+		if errors.Is(err, storage.ErrCalendarExists) {
+			continue
+		}
+		return calendar, err
+	}
+}
+
+// calendarIDs returns the calendars the user can read, only the given one if calendarID is not empty.
+func (a *App) calendarIDs(ctx context.Context, userID, calendarID string) ([]string, error) {
+	if calendarID != "" {
+		if _, err := a.calendar(ctx, calendarID, userID, storage.AccessRead); err != nil {
+			return nil, err
+		}
+		return []string{calendarID}, nil
+	}
+
+	calendars, err := a.storage.ListCalendars(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(calendars))
+	for _, calendar := range calendars {
+		ids = append(ids, calendar.ID)
+	}
+	return ids, nil
+}
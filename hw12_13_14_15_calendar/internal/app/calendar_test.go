@@ -0,0 +1,123 @@
+package app
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/logger"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	memorystorage "github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage/memory"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCalendarSharing(t *testing.T) {
+	ctx := context.Background()
+	a := New(logger.NewWithWriter("ERROR", io.Discard), memorystorage.New(), Options{})
+	day := time.Date(2021, 8, 2, 0, 0, 0, 0, time.UTC)
+	newEvent := func(calendarID string) storage.Event {
+		return storage.Event{Title: "event", StartsAt: day, EndsAt: day.Add(time.Hour), CalendarID: calendarID}
+	}
+
+	// the default calendar is created on first use
+	own, _, err := a.CreateEvent(ctx, newEvent(""), "owner", "")
+	require.NoError(t, err)
+	calendars, err := a.ListCalendars(ctx, "owner")
+	require.NoError(t, err)
+	require.Len(t, calendars, 1)
+	require.Equal(t, DefaultCalendarName, calendars[0].Name)
+	require.Equal(t, calendars[0].ID, own.CalendarID)
+	require.Equal(t, "owner", own.UserID)
+
+	work, err := a.CreateCalendar(ctx, "owner", "work")
+	require.NoError(t, err)
+	_, err = a.CreateCalendar(ctx, "owner", "work")
+	require.ErrorIs(t, err, storage.ErrCalendarExists)
+	_, err = a.CreateCalendar(ctx, "owner", "")
+	require.ErrorIs(t, err, ErrInvalidCalendar)
+
+	// strangers can't see the calendar or its events
+	_, err = a.GetCalendar(ctx, work.ID, "reader")
+	require.ErrorIs(t, err, storage.ErrCalendarNotFound)
+	_, _, err = a.CreateEvent(ctx, newEvent(work.ID), "reader", "")
+	require.ErrorIs(t, err, storage.ErrCalendarNotFound)
+	_, err = a.GetEvent(ctx, own.ID, "reader")
+	require.ErrorIs(t, err, storage.ErrEventNotFound)
+
+	_, err = a.ShareCalendar(ctx, work.ID, "owner", "reader", storage.AccessRead)
+	require.NoError(t, err)
+	shared, err := a.ShareCalendar(ctx, work.ID, "owner", "writer", storage.AccessWrite)
+	require.NoError(t, err)
+	require.Equal(t, []storage.Share{
+		{UserID: "reader", Access: storage.AccessRead},
+		{UserID: "writer", Access: storage.AccessWrite},
+	}, shared.Shares)
+
+	_, err = a.ShareCalendar(ctx, work.ID, "owner", "owner", storage.AccessRead)
+	require.ErrorIs(t, err, ErrInvalidCalendar)
+	_, err = a.ShareCalendar(ctx, work.ID, "owner", "reader", storage.AccessOwner)
+	require.ErrorIs(t, err, ErrInvalidCalendar)
+	_, err = a.ShareCalendar(ctx, work.ID, "writer", "reader", storage.AccessWrite)
+	require.ErrorIs(t, err, ErrAccessDenied)
+
+	// events created by a writer belong to the owner
+	event := newEvent(work.ID)
+	event.StartsAt, event.EndsAt = day.Add(2*time.Hour), day.Add(3*time.Hour)
+	event, _, err = a.CreateEvent(ctx, event, "writer", "")
+	require.NoError(t, err)
+	require.Equal(t, "owner", event.UserID)
+
+	_, err = a.GetEvent(ctx, event.ID, "reader")
+	require.NoError(t, err)
+	_, _, err = a.UpdateEvent(ctx, event, "reader", storage.AnyVersion)
+	require.ErrorIs(t, err, ErrAccessDenied)
+	require.ErrorIs(t, a.DeleteEvent(ctx, event.ID, "reader", storage.AnyVersion), ErrAccessDenied)
+
+	page, err := a.ListDayEvents(ctx, "reader", day, ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, page.Events, 1)
+	page, err = a.ListDayEvents(ctx, "owner", day, ListOptions{CalendarID: work.ID})
+	require.NoError(t, err)
+	require.Len(t, page.Events, 1)
+	page, err = a.ListDayEvents(ctx, "owner", day, ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, page.Events, 2)
+
+	// moving an event needs write access to both calendars
+	event.CalendarID = own.CalendarID
+	_, _, err = a.UpdateEvent(ctx, event, "writer", storage.AnyVersion)
+	require.ErrorIs(t, err, storage.ErrCalendarNotFound)
+	moved, _, err := a.UpdateEvent(ctx, event, "owner", storage.AnyVersion)
+	require.NoError(t, err)
+	require.Equal(t, own.CalendarID, moved.CalendarID)
+
+	// users can leave, but only the owner can revoke others
+	require.ErrorIs(t, a.UnshareCalendar(ctx, work.ID, "writer", "reader"), ErrAccessDenied)
+	require.NoError(t, a.UnshareCalendar(ctx, work.ID, "reader", "reader"))
+	require.ErrorIs(t, a.UnshareCalendar(ctx, work.ID, "owner", "owner"), ErrInvalidCalendar)
+	require.NoError(t, a.UnshareCalendar(ctx, work.ID, "owner", "writer"))
+
+	calendars, err = a.ListCalendars(ctx, "reader")
+	require.NoError(t, err)
+	require.Empty(t, calendars)
+	page, err = a.ListDayEvents(ctx, "reader", day, ListOptions{})
+	require.NoError(t, err)
+	require.Empty(t, page.Events)
+}
+
+func TestPreviewPurge(t *testing.T) {
+	ctx := context.Background()
+	a := New(logger.NewWithWriter("ERROR", io.Discard), memorystorage.New(), Options{Admins: []string{"admin"}})
+	day := time.Date(2021, 8, 2, 0, 0, 0, 0, time.UTC)
+	_, _, err := a.CreateEvent(ctx, storage.Event{Title: "old", StartsAt: day, EndsAt: day.Add(time.Hour)}, "user", "")
+	require.NoError(t, err)
+
+	n, err := a.PreviewPurge(ctx, "admin", 0, 24*time.Hour)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, n)
+	_, err = a.PreviewPurge(ctx, "user", 0, 24*time.Hour)
+	require.ErrorIs(t, err, ErrAccessDenied, "the number is of events of all users")
+	_, err = a.PreviewPurge(ctx, "admin", -time.Hour, 0)
+	require.ErrorIs(t, err, ErrInvalidQuery)
+}
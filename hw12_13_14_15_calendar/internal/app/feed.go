@@ -0,0 +1,101 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+)
+
+const (
+	feedBatchSize = 100
+	// feedPollInterval bounds the delay of changes made by other instances of the service.
+	feedPollInterval = 5 * time.Second
+)
+
+// FeedEntry is a change of an event in a calendar the user can read sent to watchers.
+type FeedEntry struct {
+	storage.Change
+	// Event is the current state of the event, it is empty if the event is deleted.
+	Event storage.Event
+}
+
+// signal wakes up all waiters on every broadcast.
+type signal struct {
+	mu sync.Mutex
+	ch chan struct{}
+}
+
+func newSignal() *signal {
+	return &signal{ch: make(chan struct{})}
+}
+
+func (s *signal) wait() <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ch
+}
+
+func (s *signal) broadcast() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	close(s.ch)
+	s.ch = make(chan struct{})
+}
+
+// LatestChangeID returns the position to watch changes made from now on, the id of the latest change.
+func (a *App) LatestChangeID(ctx context.Context) (int64, error) {
+	return a.storage.LatestChangeID(ctx)
+}
+
+// WatchChanges calls send for every change of events in calendars the user can read recorded
+// after the change afterID until ctx is done or send fails. Zero afterID replays the whole history,
+// the servers start clients which don't resume after LatestChangeID to stream new changes only.
+func (a *App) WatchChanges(ctx context.Context, userID string, afterID int64, send func(FeedEntry) error) error {
+	ticker := time.NewTicker(feedPollInterval)
+	defer ticker.Stop()
+
+	for {
+		// subscribe before reading, so a change made in between is not missed
+		changed := a.changed.wait()
+
+		// calendars are resolved every time, so shares granted or revoked meanwhile take effect
+		calendarIDs, err := a.calendarIDs(ctx, userID, "")
+		if err != nil {
+			return err
+		}
+		changes, err := a.storage.ListChanges(ctx, storage.ChangeQuery{
+			CalendarIDs: calendarIDs,
+			AfterID:     afterID,
+			Limit:       feedBatchSize,
+		})
+		if err != nil {
+			return err
+		}
+		for _, change := range changes {
+			entry := FeedEntry{Change: change}
+			if change.Action != storage.ActionDeleted {
+				entry.Event, err = a.storage.GetEvent(ctx, change.EventID)
+				if err != nil && !errors.Is(err, storage.ErrEventNotFound) {
+					return err
+				}
+			}
+			if err := send(entry); err != nil {
+				return err
+			}
+			afterID = change.ID
+		}
+		if len(changes) == feedBatchSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-changed:
+		case <-ticker.C:
+		}
+	}
+}
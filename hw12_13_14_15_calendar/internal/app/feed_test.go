@@ -0,0 +1,50 @@
+package app
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/logger"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	memorystorage "github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage/memory"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChangedBroadcast(t *testing.T) {
+	ctx := context.Background()
+	a := New(logger.NewWithWriter("ERROR", io.Discard), memorystorage.New(), Options{})
+	startsAt := time.Date(2021, 8, 2, 10, 0, 0, 0, time.UTC)
+	event := storage.Event{Title: "meeting", StartsAt: startsAt, EndsAt: startsAt.Add(time.Hour)}
+	woken := func(changed <-chan struct{}) bool {
+		select {
+		case <-changed:
+			return true
+		default:
+			return false
+		}
+	}
+
+	// rejected requests don't wake up watchers
+	changed := a.changed.wait()
+	_, _, err := a.CreateEvent(ctx, event, "owner", strings.Repeat("k", maxIdempotencyKeyLen+1))
+	require.ErrorIs(t, err, ErrInvalidEvent)
+	_, _, err = a.CreateEvent(ctx, storage.Event{Title: "empty"}, "owner", "")
+	require.Error(t, err)
+	require.False(t, woken(changed))
+
+	created, _, err := a.CreateEvent(ctx, event, "owner", "key")
+	require.NoError(t, err)
+	require.True(t, woken(changed))
+
+	changed = a.changed.wait()
+	_, _, err = a.CreateEvent(ctx, event, "owner", "")
+	require.ErrorIs(t, err, storage.ErrDateBusy)
+	require.ErrorIs(t, a.DeleteEvent(ctx, created.ID, "owner", created.Version+1), storage.ErrVersionMismatch)
+	require.False(t, woken(changed))
+
+	require.NoError(t, a.DeleteEvent(ctx, created.ID, "owner", storage.AnyVersion))
+	require.True(t, woken(changed))
+}
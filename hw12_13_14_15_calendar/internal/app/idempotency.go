@@ -0,0 +1,42 @@
+package app
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+)
+
+const (
+	// DefaultIdempotencyTTL is used if Options.IdempotencyTTL is not set.
+	DefaultIdempotencyTTL = 24 * time.Hour
+	maxIdempotencyKeyLen  = 255
+)
+
+// requestHash identifies the create request, the id and the owner are excluded
+// as they are assigned by the app and the key is scoped by user anyway.
+// The calendar is already resolved, so omitting the default calendar is the same request as naming it.
+func requestHash(event storage.Event) (string, error) {
+	b, err := json.Marshal(struct {
+		Title       string
+		StartsAt    time.Time
+		EndsAt      time.Time
+		Description string
+		CalendarID  string
+		Reminders   []storage.Reminder
+	}{
+		Title:       event.Title,
+		StartsAt:    event.StartsAt.UTC(),
+		EndsAt:      event.EndsAt.UTC(),
+		Description: event.Description,
+		CalendarID:  event.CalendarID,
+		Reminders:   event.Reminders,
+	})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
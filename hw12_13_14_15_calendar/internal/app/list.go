@@ -0,0 +1,132 @@
+package app
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+)
+
+const (
+	DefaultPageSize = 100
+	MaxPageSize     = 1000
+)
+
+var ErrInvalidQuery = errors.New("invalid query")
+
+type ListOptions struct {
+	// Cursor is the NextCursor of the previous page, empty for the first page.
+	Cursor string
+	// PageSize is limited by MaxPageSize, zero means DefaultPageSize.
+	PageSize int
+
+	// CalendarID limits events to one calendar, all calendars the user can read if empty.
+	CalendarID      string
+	Title           string
+	HasNotification *bool
+	UpdatedSince    time.Time
+}
+
+type Page struct {
+	Events []storage.Event
+	// NextCursor is empty on the last page.
+	NextCursor string
+}
+
+func (a *App) ListDayEvents(ctx context.Context, userID string, date time.Time, opts ListOptions) (Page, error) {
+	from := startOfDay(date)
+	return a.listEvents(ctx, userID, from, from.AddDate(0, 0, 1), opts)
+}
+
+func (a *App) ListWeekEvents(ctx context.Context, userID string, date time.Time, opts ListOptions) (Page, error) {
+	from := startOfDay(date)
+	return a.listEvents(ctx, userID, from, from.AddDate(0, 0, 7), opts)
+}
+
+func (a *App) ListMonthEvents(ctx context.Context, userID string, date time.Time, opts ListOptions) (Page, error) {
+	from := startOfDay(date)
+	return a.listEvents(ctx, userID, from, from.AddDate(0, 1, 0), opts)
+}
+
+func (a *App) listEvents(ctx context.Context, userID string, from, to time.Time, opts ListOptions) (Page, error) {
+	pageSize, err := limitPageSize(opts.PageSize)
+	if err != nil {
+		return Page{}, err
+	}
+
+	calendarIDs, err := a.calendarIDs(ctx, userID, opts.CalendarID)
+	if err != nil {
+		return Page{}, err
+	}
+
+	query := storage.Query{
+		CalendarIDs:     calendarIDs,
+		From:            from,
+		To:              to,
+		TitleContains:   opts.Title,
+		HasNotification: opts.HasNotification,
+		UpdatedSince:    opts.UpdatedSince,
+		// one more event tells whether there is a next page
+		Limit: pageSize + 1,
+	}
+	if opts.Cursor != "" {
+		cursor, err := decodeCursor(opts.Cursor)
+		if err != nil {
+			return Page{}, err
+		}
+		query.After = &cursor
+	}
+
+	events, err := a.storage.ListEvents(ctx, query)
+	if err != nil {
+		return Page{}, err
+	}
+
+	page := Page{Events: events}
+	if len(events) > pageSize {
+		page.Events = events[:pageSize]
+		last := page.Events[pageSize-1]
+		page.NextCursor = encodeCursor(storage.Cursor{StartsAt: last.StartsAt, ID: last.ID})
+	}
+	return page, nil
+}
+
+func limitPageSize(size int) (int, error) {
+	switch {
+	case size < 0:
+		return 0, fmt.Errorf("%w: page size is negative", ErrInvalidQuery)
+	case size == 0:
+		return DefaultPageSize, nil
+	case size > MaxPageSize:
+		return MaxPageSize, nil
+	}
+	return size, nil
+}
+
+func encodeCursor(c storage.Cursor) string {
+	raw := strconv.FormatInt(c.StartsAt.UnixNano(), 10) + ":" + c.ID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(s string) (storage.Cursor, error) {
+	invalid := fmt.Errorf("%w: malformed cursor", ErrInvalidQuery)
+
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return storage.Cursor{}, invalid
+	}
+	nanos, id, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return storage.Cursor{}, invalid
+	}
+	n, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return storage.Cursor{}, invalid
+	}
+	return storage.Cursor{StartsAt: time.Unix(0, n).UTC(), ID: id}, nil
+}
@@ -0,0 +1,92 @@
+package app
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/logger"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	memorystorage "github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage/memory"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListPagination(t *testing.T) {
+	ctx := context.Background()
+	a := New(logger.NewWithWriter("ERROR", io.Discard), memorystorage.New(), Options{})
+	day := time.Date(2021, 8, 2, 0, 0, 0, 0, time.UTC)
+
+	created := make([]string, 0)
+	for i := 0; i < 5; i++ {
+		startsAt := day.Add(time.Duration(i) * time.Hour)
+		e, _, err := a.CreateEvent(ctx, storage.Event{
+			Title:    "event",
+			StartsAt: startsAt,
+			EndsAt:   startsAt.Add(time.Hour),
+		}, "user", "")
+		require.NoError(t, err)
+		created = append(created, e.ID)
+	}
+
+	listed := make([]string, 0)
+	opts := ListOptions{PageSize: 2}
+	for pages := 1; ; pages++ {
+		page, err := a.ListDayEvents(ctx, "user", day, opts)
+		require.NoError(t, err)
+		for _, e := range page.Events {
+			listed = append(listed, e.ID)
+		}
+		if page.NextCursor == "" {
+			require.Equal(t, 3, pages)
+			break
+		}
+		opts.Cursor = page.NextCursor
+	}
+	require.Equal(t, created, listed)
+
+	_, err := a.ListDayEvents(ctx, "user", day, ListOptions{Cursor: "not a cursor"})
+	require.ErrorIs(t, err, ErrInvalidQuery)
+
+	_, err = a.ListDayEvents(ctx, "user", day, ListOptions{PageSize: -1})
+	require.ErrorIs(t, err, ErrInvalidQuery)
+}
+
+func TestSearchPagination(t *testing.T) {
+	ctx := context.Background()
+	a := New(logger.NewWithWriter("ERROR", io.Discard), memorystorage.New(), Options{})
+	day := time.Date(2021, 8, 2, 0, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 5; i++ {
+		startsAt := day.Add(time.Duration(i) * time.Hour)
+		_, _, err := a.CreateEvent(ctx, storage.Event{
+			Title:       "sync",
+			Description: strings.Repeat("sync ", i),
+			StartsAt:    startsAt,
+			EndsAt:      startsAt.Add(time.Hour),
+		}, "user", "")
+		require.NoError(t, err)
+	}
+
+	descriptions := make([]string, 0)
+	opts := SearchOptions{PageSize: 2}
+	for {
+		page, err := a.SearchEvents(ctx, "user", "sync", opts)
+		require.NoError(t, err)
+		for _, e := range page.Events {
+			descriptions = append(descriptions, e.Description)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		opts.Cursor = page.NextCursor
+	}
+	require.Len(t, descriptions, 5)
+	for i := 1; i < len(descriptions); i++ {
+		require.Greater(t, len(descriptions[i-1]), len(descriptions[i]))
+	}
+
+	_, err := a.SearchEvents(ctx, "user", " ", SearchOptions{})
+	require.ErrorIs(t, err, ErrInvalidQuery)
+}
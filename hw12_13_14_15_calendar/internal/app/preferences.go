@@ -0,0 +1,43 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+)
+
+var ErrInvalidPreferences = errors.New("invalid notification preferences")
+
+// localePattern matches language tags like "en", "pt-BR" or "zh-Hant-TW".
+var localePattern = regexp.MustCompile(`^[a-zA-Z]{2,3}(-[a-zA-Z0-9]{2,8})*$`)
+
+// GetNotificationPreferences returns empty preferences, i.e. the sender defaults, if the user hasn't set them.
+func (a *App) GetNotificationPreferences(ctx context.Context, userID string) (storage.NotificationPreferences, error) {
+	prefs, err := a.storage.GetNotificationPreferences(ctx, userID)
+	if errors.Is(err, storage.ErrPreferencesNotFound) {
+		return storage.NotificationPreferences{UserID: userID}, nil
+	}
+	return prefs, err
+}
+
+// SetNotificationPreferences replaces the notification preferences of prefs.UserID.
+func (a *App) SetNotificationPreferences(
+	ctx context.Context,
+	prefs storage.NotificationPreferences,
+) (storage.NotificationPreferences, error) {
+	if prefs.UserID == "" {
+		return storage.NotificationPreferences{}, fmt.Errorf("%w: user id is empty", ErrInvalidPreferences)
+	}
+	if _, err := time.LoadLocation(prefs.TimeZone); err != nil || prefs.TimeZone == "Local" {
+		return storage.NotificationPreferences{},
+			fmt.Errorf("%w: unknown time zone %q", ErrInvalidPreferences, prefs.TimeZone)
+	}
+	if prefs.Locale != "" && !localePattern.MatchString(prefs.Locale) {
+		return storage.NotificationPreferences{}, fmt.Errorf("%w: invalid locale %q", ErrInvalidPreferences, prefs.Locale)
+	}
+	return a.storage.SetNotificationPreferences(ctx, prefs)
+}
@@ -0,0 +1,44 @@
+package app
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/logger"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	memorystorage "github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage/memory"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNotificationPreferences(t *testing.T) {
+	ctx := context.Background()
+	a := New(logger.NewWithWriter("ERROR", io.Discard), memorystorage.New(), Options{})
+
+	prefs, err := a.GetNotificationPreferences(ctx, "user")
+	require.NoError(t, err)
+	require.Equal(t, storage.NotificationPreferences{UserID: "user"}, prefs, "defaults until the user sets them")
+
+	for _, invalid := range []storage.NotificationPreferences{
+		{TimeZone: "Europe/Moscow"},
+		{UserID: "user", TimeZone: "Mars/Olympus"},
+		{UserID: "user", TimeZone: "Local"},
+		{UserID: "user", Locale: "english language"},
+	} {
+		_, err := a.SetNotificationPreferences(ctx, invalid)
+		require.ErrorIs(t, err, ErrInvalidPreferences, "%+v", invalid)
+	}
+
+	set, err := a.SetNotificationPreferences(ctx,
+		storage.NotificationPreferences{UserID: "user", TimeZone: "Europe/Moscow", Locale: "pt-BR"})
+	require.NoError(t, err)
+	require.False(t, set.UpdatedAt.IsZero())
+
+	prefs, err = a.GetNotificationPreferences(ctx, "user")
+	require.NoError(t, err)
+	require.Equal(t, set, prefs)
+
+	// working hours are independent of the preferences
+	_, err = a.GetWorkingHours(ctx, "user")
+	require.ErrorIs(t, err, storage.ErrWorkingHoursNotFound)
+}
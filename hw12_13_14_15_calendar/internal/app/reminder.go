@@ -0,0 +1,52 @@
+package app
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+)
+
+const maxReminders = 10
+
+func validateReminders(reminders []storage.Reminder) error {
+	if len(reminders) > maxReminders {
+		return fmt.Errorf("%w: an event has more than %d reminders", ErrInvalidEvent, maxReminders)
+	}
+
+	seen := make(map[storage.Reminder]struct{}, len(reminders))
+	for _, r := range reminders {
+		switch {
+		case r.Before <= 0:
+			return fmt.Errorf("%w: reminder offset must be positive", ErrInvalidEvent)
+		case r.Before%time.Second != 0:
+			return fmt.Errorf("%w: reminder offset must be a whole number of seconds", ErrInvalidEvent)
+		case r.Channel != storage.ChannelLog && r.Channel != storage.ChannelWebhook:
+			return fmt.Errorf("%w: unknown reminder channel %q", ErrInvalidEvent, r.Channel)
+		}
+		if _, ok := seen[r]; ok {
+			return fmt.Errorf("%w: duplicate reminder %s by %s", ErrInvalidEvent, r.Before, r.Channel)
+		}
+		seen[r] = struct{}{}
+	}
+	return nil
+}
+
+// sortReminders returns a sorted copy of the reminders, so every storage keeps them in the same order.
+func sortReminders(reminders []storage.Reminder) []storage.Reminder {
+	if len(reminders) == 0 {
+		return nil
+	}
+	sorted := slices.Clone(reminders)
+	slices.SortFunc(sorted, func(a, b storage.Reminder) int {
+		switch {
+		case a.Less(b):
+			return -1
+		case b.Less(a):
+			return 1
+		}
+		return 0
+	})
+	return sorted
+}
@@ -0,0 +1,48 @@
+package app
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/logger"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	memorystorage "github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage/memory"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReminders(t *testing.T) {
+	ctx := context.Background()
+	a := New(logger.NewWithWriter("ERROR", io.Discard), memorystorage.New(), Options{})
+	day := time.Date(2021, 8, 2, 0, 0, 0, 0, time.UTC)
+	newEvent := func(reminders ...storage.Reminder) storage.Event {
+		return storage.Event{
+			Title:     "event",
+			StartsAt:  day,
+			EndsAt:    day.Add(time.Hour),
+			Reminders: reminders,
+		}
+	}
+
+	log := storage.Reminder{Before: 10 * time.Minute, Channel: storage.ChannelLog}
+	webhook := storage.Reminder{Before: 24 * time.Hour, Channel: storage.ChannelWebhook}
+	created, _, err := a.CreateEvent(ctx, newEvent(log, webhook), "user", "")
+	require.NoError(t, err)
+	require.Equal(t, []storage.Reminder{webhook, log}, created.Reminders)
+
+	for name, r := range map[string]storage.Reminder{
+		"zero offset":     {Channel: storage.ChannelLog},
+		"negative offset": {Before: -time.Minute, Channel: storage.ChannelLog},
+		"fractional":      {Before: 1500 * time.Millisecond, Channel: storage.ChannelLog},
+		"unknown channel": {Before: time.Minute, Channel: "pigeon"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, _, err := a.CreateEvent(ctx, newEvent(r), "user", "")
+			require.ErrorIs(t, err, ErrInvalidEvent)
+		})
+	}
+
+	_, _, err = a.CreateEvent(ctx, newEvent(log, log), "user", "")
+	require.ErrorIs(t, err, ErrInvalidEvent)
+}
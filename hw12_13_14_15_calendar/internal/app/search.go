@@ -0,0 +1,89 @@
+package app
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+)
+
+type SearchOptions struct {
+	// Cursor is the NextCursor of the previous page, empty for the first page.
+	Cursor string
+	// PageSize is limited by MaxPageSize, zero means DefaultPageSize.
+	PageSize int
+	// CalendarID limits events to one calendar, all calendars the user can read if empty.
+	CalendarID string
+}
+
+// SearchEvents returns events of calendars the user can read containing all words of the text
+// in the title or description, the most relevant first.
+func (a *App) SearchEvents(ctx context.Context, userID, text string, opts SearchOptions) (Page, error) {
+	if strings.TrimSpace(text) == "" {
+		return Page{}, fmt.Errorf("%w: search text is empty", ErrInvalidQuery)
+	}
+	pageSize, err := limitPageSize(opts.PageSize)
+	if err != nil {
+		return Page{}, err
+	}
+
+	calendarIDs, err := a.calendarIDs(ctx, userID, opts.CalendarID)
+	if err != nil {
+		return Page{}, err
+	}
+
+	query := storage.SearchQuery{
+		CalendarIDs: calendarIDs,
+		Text:        text,
+		Limit:       pageSize + 1,
+	}
+	if opts.Cursor != "" {
+		cursor, err := decodeSearchCursor(opts.Cursor)
+		if err != nil {
+			return Page{}, err
+		}
+		query.After = &cursor
+	}
+
+	hits, err := a.storage.SearchEvents(ctx, query)
+	if err != nil {
+		return Page{}, err
+	}
+
+	page := Page{Events: make([]storage.Event, 0, len(hits))}
+	if len(hits) > pageSize {
+		hits = hits[:pageSize]
+		last := hits[pageSize-1]
+		page.NextCursor = encodeSearchCursor(storage.SearchCursor{Rank: last.Rank, ID: last.Event.ID})
+	}
+	for _, hit := range hits {
+		page.Events = append(page.Events, hit.Event)
+	}
+	return page, nil
+}
+
+func encodeSearchCursor(c storage.SearchCursor) string {
+	raw := strconv.FormatFloat(c.Rank, 'g', -1, 64) + ":" + c.ID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeSearchCursor(s string) (storage.SearchCursor, error) {
+	invalid := fmt.Errorf("%w: malformed cursor", ErrInvalidQuery)
+
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return storage.SearchCursor{}, invalid
+	}
+	rank, id, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return storage.SearchCursor{}, invalid
+	}
+	r, err := strconv.ParseFloat(rank, 64)
+	if err != nil {
+		return storage.SearchCursor{}, invalid
+	}
+	return storage.SearchCursor{Rank: r, ID: id}, nil
+}
@@ -0,0 +1,285 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/holiday"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+)
+
+// MaxFreeBusyPeriod limits the period of a free/busy query.
+const MaxFreeBusyPeriod = 92 * 24 * time.Hour
+
+var (
+	ErrInvalidWorkingHours = errors.New("invalid working hours")
+	// ErrNonWorkingTime is returned instead of warnings about the working time if the owner has chosen the strict mode.
+	ErrNonWorkingTime = errors.New("event is out of working time")
+)
+
+// Period is the time from Start until End.
+type Period struct {
+	Start time.Time
+	End   time.Time
+}
+
+type FreeBusy struct {
+	// Busy are the periods of events in calendars owned by the user, overlapping events are merged.
+	Busy []Period
+	// Free are the periods of the working time without events. All the time is working
+	// if the user has no working hours.
+	Free []Period
+}
+
+func (a *App) GetWorkingHours(ctx context.Context, userID string) (storage.WorkingHours, error) {
+	return a.storage.GetWorkingHours(ctx, userID)
+}
+
+// SetWorkingHours replaces the working hours of hours.UserID.
+func (a *App) SetWorkingHours(ctx context.Context, hours storage.WorkingHours) (storage.WorkingHours, error) {
+	hours, err := a.validateWorkingHours(hours)
+	if err != nil {
+		return storage.WorkingHours{}, err
+	}
+	return a.storage.SetWorkingHours(ctx, hours)
+}
+
+// DeleteWorkingHours makes all the time working for the user again.
+func (a *App) DeleteWorkingHours(ctx context.Context, userID string) error {
+	return a.storage.DeleteWorkingHours(ctx, userID)
+}
+
+// HolidayCalendars returns names of the holiday calendars users can attach ordered by name.
+func (a *App) HolidayCalendars() []string {
+	names := make([]string, 0, len(a.holidays))
+	for name := range a.holidays {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// FreeBusy returns the busy and free time of the user in the [from, to) period.
+func (a *App) FreeBusy(ctx context.Context, userID string, from, to time.Time) (FreeBusy, error) {
+	switch {
+	case !to.After(from):
+		return FreeBusy{}, fmt.Errorf("%w: period must end after it starts", ErrInvalidQuery)
+	case to.Sub(from) > MaxFreeBusyPeriod:
+		return FreeBusy{}, fmt.Errorf("%w: period is longer than %s", ErrInvalidQuery, MaxFreeBusyPeriod)
+	}
+
+	calendars, err := a.storage.ListCalendars(ctx, userID)
+	if err != nil {
+		return FreeBusy{}, err
+	}
+	calendarIDs := make([]string, 0, len(calendars))
+	for _, calendar := range calendars {
+		if calendar.OwnerID == userID {
+			calendarIDs = append(calendarIDs, calendar.ID)
+		}
+	}
+
+	var busy []Period
+	if len(calendarIDs) > 0 {
+		events, err := a.storage.ListEvents(ctx, storage.Query{CalendarIDs: calendarIDs, From: from, To: to})
+		if err != nil {
+			return FreeBusy{}, err
+		}
+		for _, event := range events {
+			busy = appendPeriod(busy, Period{Start: maxTime(event.StartsAt, from), End: minTime(event.EndsAt, to)})
+		}
+	}
+
+	schedule, err := a.schedule(ctx, userID)
+	if err != nil {
+		return FreeBusy{}, err
+	}
+	working := []Period{{Start: from, End: to}}
+	if schedule != nil {
+		working = schedule.workingPeriods(from, to)
+	}
+	return FreeBusy{Busy: busy, Free: subtractPeriods(working, busy)}, nil
+}
+
+// checkWorkingTime returns warnings about the event on holidays or out of the working hours of its owner,
+// they are returned as ErrNonWorkingTime in the strict mode.
+func (a *App) checkWorkingTime(ctx context.Context, event storage.Event) ([]string, error) {
+	schedule, err := a.schedule(ctx, event.UserID)
+	if err != nil || schedule == nil {
+		return nil, err
+	}
+
+	var warnings []string
+	for day := startOfDay(event.StartsAt.In(schedule.location)); day.Before(event.EndsAt); day = day.AddDate(0, 0, 1) {
+		if name, ok := schedule.holiday(day); ok {
+			warnings = append(warnings, fmt.Sprintf("event is on the holiday %q on %s", name, day.Format("2006-01-02")))
+		}
+	}
+	if len(warnings) == 0 {
+		working := schedule.workingPeriods(event.StartsAt, event.EndsAt)
+		covered := len(working) == 1 && working[0].Start.Equal(event.StartsAt) && working[0].End.Equal(event.EndsAt)
+		if !covered {
+			warnings = append(warnings, "event is out of working hours")
+		}
+	}
+
+	if len(warnings) > 0 && schedule.hours.Strict {
+		return nil, fmt.Errorf("%w: %s", ErrNonWorkingTime, strings.Join(warnings, "; "))
+	}
+	return warnings, nil
+}
+
+// schedule returns the working hours of the user ready for lookups, it is nil if the user has none.
+func (a *App) schedule(ctx context.Context, userID string) (*schedule, error) {
+	hours, err := a.storage.GetWorkingHours(ctx, userID)
+	if errors.Is(err, storage.ErrWorkingHoursNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	location, err := time.LoadLocation(hours.TimeZone)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load time zone of working hours: %w", err)
+	}
+
+	s := &schedule{hours: hours, location: location}
+	// calendars removed from the configuration since they were attached are ignored
+	for _, name := range hours.Holidays {
+		if c, ok := a.holidays[name]; ok {
+			s.holidays = append(s.holidays, c)
+		}
+	}
+	return s, nil
+}
+
+func (a *App) validateWorkingHours(hours storage.WorkingHours) (storage.WorkingHours, error) {
+	if hours.UserID == "" {
+		return storage.WorkingHours{}, fmt.Errorf("%w: user id is empty", ErrInvalidWorkingHours)
+	}
+	if _, err := time.LoadLocation(hours.TimeZone); err != nil || hours.TimeZone == "Local" {
+		return storage.WorkingHours{}, fmt.Errorf("%w: unknown time zone %q", ErrInvalidWorkingHours, hours.TimeZone)
+	}
+
+	hours.Periods = slices.Clone(hours.Periods)
+	sort.Slice(hours.Periods, func(i, j int) bool {
+		return hours.Periods[i].Less(hours.Periods[j])
+	})
+	for i, p := range hours.Periods {
+		switch {
+		case p.Weekday < time.Sunday || p.Weekday > time.Saturday:
+			return storage.WorkingHours{}, fmt.Errorf("%w: unknown weekday %d", ErrInvalidWorkingHours, p.Weekday)
+		case p.Start < 0 || p.End > 24*time.Hour || p.Start >= p.End:
+			return storage.WorkingHours{}, fmt.Errorf("%w: period must end after it starts within the day",
+				ErrInvalidWorkingHours)
+		case p.Start%time.Minute != 0 || p.End%time.Minute != 0:
+			return storage.WorkingHours{}, fmt.Errorf("%w: period must start and end at whole minutes",
+				ErrInvalidWorkingHours)
+		case i > 0 && hours.Periods[i-1].Weekday == p.Weekday && hours.Periods[i-1].End > p.Start:
+			return storage.WorkingHours{}, fmt.Errorf("%w: periods of %s overlap", ErrInvalidWorkingHours, p.Weekday)
+		}
+	}
+
+	holidays := make([]string, 0, len(hours.Holidays))
+	for _, name := range hours.Holidays {
+		if _, ok := a.holidays[name]; !ok {
+			return storage.WorkingHours{}, fmt.Errorf("%w: unknown holiday calendar %q", ErrInvalidWorkingHours, name)
+		}
+		if !slices.Contains(holidays, name) {
+			holidays = append(holidays, name)
+		}
+	}
+	sort.Strings(holidays)
+	hours.Holidays = holidays
+	return hours, nil
+}
+
+type schedule struct {
+	hours    storage.WorkingHours
+	location *time.Location
+	holidays []*holiday.Calendar
+}
+
+// holiday returns the name of the holiday on the day in the first calendar having one.
+func (s *schedule) holiday(day time.Time) (string, bool) {
+	for _, c := range s.holidays {
+		if name, ok := c.Lookup(day); ok {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// workingPeriods returns the working time within [from, to), adjacent periods are merged.
+func (s *schedule) workingPeriods(from, to time.Time) []Period {
+	var periods []Period
+	for day := startOfDay(from.In(s.location)); day.Before(to); day = day.AddDate(0, 0, 1) {
+		if _, ok := s.holiday(day); ok {
+			continue
+		}
+		for _, p := range s.hours.Periods {
+			if p.Weekday != day.Weekday() {
+				continue
+			}
+			period := Period{Start: maxTime(s.at(day, p.Start), from), End: minTime(s.at(day, p.End), to)}
+			if period.Start.Before(period.End) {
+				periods = appendPeriod(periods, period)
+			}
+		}
+	}
+	return periods
+}
+
+// at returns the wall clock time of the day at the offset from its midnight,
+// so the working hours stay the same when the clocks change.
+func (s *schedule) at(day time.Time, offset time.Duration) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day(), 0, int(offset/time.Minute), 0, 0, s.location)
+}
+
+// appendPeriod appends the period which doesn't start before the last one, merging them if they overlap or touch.
+func appendPeriod(periods []Period, p Period) []Period {
+	if n := len(periods); n > 0 && !p.Start.After(periods[n-1].End) {
+		periods[n-1].End = maxTime(periods[n-1].End, p.End)
+		return periods
+	}
+	return append(periods, p)
+}
+
+// subtractPeriods returns parts of the periods not covered by the busy ones, both are ordered and don't overlap.
+func subtractPeriods(periods, busy []Period) []Period {
+	var free []Period
+	for _, p := range periods {
+		for _, b := range busy {
+			if !b.End.After(p.Start) || !b.Start.Before(p.End) {
+				continue
+			}
+			if b.Start.After(p.Start) {
+				free = append(free, Period{Start: p.Start, End: b.Start})
+			}
+			p.Start = b.End
+		}
+		if p.Start.Before(p.End) {
+			free = append(free, p)
+		}
+	}
+	return free
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
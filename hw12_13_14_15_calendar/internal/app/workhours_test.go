@@ -0,0 +1,144 @@
+package app
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/holiday"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/logger"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	memorystorage "github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage/memory"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkingHours(t *testing.T) {
+	ctx := context.Background()
+	ru, err := holiday.DecodeJSON("ru", strings.NewReader(`[{"date": "2021-08-03", "name": "Day off"}]`))
+	require.NoError(t, err)
+	a := New(logger.NewWithWriter("ERROR", io.Discard), memorystorage.New(), Options{
+		Holidays: map[string]*holiday.Calendar{"ru": ru},
+	})
+	require.Equal(t, []string{"ru"}, a.HolidayCalendars())
+
+	moscow, err := time.LoadLocation("Europe/Moscow")
+	require.NoError(t, err)
+	// Monday
+	day := time.Date(2021, 8, 2, 0, 0, 0, 0, moscow)
+	at := func(days, hours, minutes int) time.Time {
+		return day.AddDate(0, 0, days).Add(time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute)
+	}
+	newEvent := func(title string, startsAt, endsAt time.Time) storage.Event {
+		return storage.Event{Title: title, StartsAt: startsAt.UTC(), EndsAt: endsAt.UTC()}
+	}
+
+	hours := storage.WorkingHours{UserID: "user", TimeZone: "Europe/Moscow", Holidays: []string{"ru", "ru"}}
+	for weekday := time.Monday; weekday <= time.Friday; weekday++ {
+		hours.Periods = append(hours.Periods,
+			storage.WorkingPeriod{Weekday: weekday, Start: 14 * time.Hour, End: 18 * time.Hour},
+			storage.WorkingPeriod{Weekday: weekday, Start: 9 * time.Hour, End: 13 * time.Hour},
+		)
+	}
+
+	for _, invalid := range []func(h storage.WorkingHours) storage.WorkingHours{
+		func(h storage.WorkingHours) storage.WorkingHours { h.TimeZone = "Mars/Olympus"; return h },
+		func(h storage.WorkingHours) storage.WorkingHours { h.Holidays = []string{"us"}; return h },
+		func(h storage.WorkingHours) storage.WorkingHours {
+			h.Periods = append(h.Periods,
+				storage.WorkingPeriod{Weekday: time.Monday, Start: 17 * time.Hour, End: 19 * time.Hour})
+			return h
+		},
+		func(h storage.WorkingHours) storage.WorkingHours {
+			h.Periods = []storage.WorkingPeriod{{Weekday: time.Monday, Start: 9 * time.Hour, End: 25 * time.Hour}}
+			return h
+		},
+		func(h storage.WorkingHours) storage.WorkingHours {
+			h.Periods = []storage.WorkingPeriod{{Weekday: time.Monday, Start: 9 * time.Hour, End: 9*time.Hour + time.Second}}
+			return h
+		},
+	} {
+		_, err := a.SetWorkingHours(ctx, invalid(hours))
+		require.ErrorIs(t, err, ErrInvalidWorkingHours)
+	}
+
+	set, err := a.SetWorkingHours(ctx, hours)
+	require.NoError(t, err)
+	require.Equal(t, []string{"ru"}, set.Holidays)
+	require.Equal(t, 9*time.Hour, set.Periods[0].Start)
+
+	_, warnings, err := a.CreateEvent(ctx, newEvent("morning", at(0, 10, 0), at(0, 11, 0)), "user", "")
+	require.NoError(t, err)
+	require.Empty(t, warnings)
+	lunch, warnings, err := a.CreateEvent(ctx, newEvent("lunch", at(0, 12, 30), at(0, 13, 30)), "user", "")
+	require.NoError(t, err)
+	require.Equal(t, []string{"event is out of working hours"}, warnings)
+	_, warnings, err = a.CreateEvent(ctx, newEvent("day off", at(1, 10, 0), at(1, 11, 0)), "user", "")
+	require.NoError(t, err)
+	require.Equal(t, []string{`event is on the holiday "Day off" on 2021-08-03`}, warnings)
+
+	// the working hours of the calendar owner apply to events created by other users
+	_, err = a.ShareCalendar(ctx, lunch.CalendarID, "user", "writer", storage.AccessWrite)
+	require.NoError(t, err)
+	_, warnings, err = a.CreateEvent(ctx, storage.Event{
+		Title: "late", StartsAt: at(0, 20, 0).UTC(), EndsAt: at(0, 21, 0).UTC(), CalendarID: lunch.CalendarID,
+	}, "writer", "")
+	require.NoError(t, err)
+	require.Len(t, warnings, 1)
+
+	hours.Strict = true
+	_, err = a.SetWorkingHours(ctx, hours)
+	require.NoError(t, err)
+	_, _, err = a.CreateEvent(ctx, newEvent("evening", at(0, 19, 0), at(0, 20, 0)), "user", "")
+	require.ErrorIs(t, err, ErrNonWorkingTime)
+	lunch.StartsAt, lunch.EndsAt = at(0, 12, 0).UTC(), at(0, 13, 0).UTC()
+	_, warnings, err = a.UpdateEvent(ctx, lunch, "user", storage.AnyVersion)
+	require.NoError(t, err)
+	require.Empty(t, warnings)
+	lunch.StartsAt, lunch.EndsAt = at(1, 12, 0).UTC(), at(1, 13, 0).UTC()
+	_, _, err = a.UpdateEvent(ctx, lunch, "user", storage.AnyVersion)
+	require.ErrorIs(t, err, ErrNonWorkingTime)
+	results, err := a.ApplyBatch(ctx, []storage.BatchItem{
+		{Op: storage.BatchCreate, Event: newEvent("batch", at(2, 19, 0), at(2, 20, 0))},
+	}, "user", false)
+	require.NoError(t, err)
+	require.ErrorIs(t, results[0].Err, ErrNonWorkingTime)
+
+	// the holiday and the evening are not free, nor is the busy time
+	freeBusy, err := a.FreeBusy(ctx, "user", at(0, 0, 0), at(2, 0, 0))
+	require.NoError(t, err)
+	period := func(start, end time.Time) Period {
+		return Period{Start: start.UTC(), End: end.UTC()}
+	}
+	require.Equal(t, []Period{
+		period(at(0, 10, 0), at(0, 11, 0)),
+		period(at(0, 12, 0), at(0, 13, 0)),
+		period(at(0, 20, 0), at(0, 21, 0)),
+		period(at(1, 10, 0), at(1, 11, 0)),
+	}, freeBusy.Busy)
+	require.Len(t, freeBusy.Free, 3)
+	for i, want := range []Period{
+		period(at(0, 9, 0), at(0, 10, 0)),
+		period(at(0, 11, 0), at(0, 12, 0)),
+		period(at(0, 14, 0), at(0, 18, 0)),
+	} {
+		require.True(t, want.Start.Equal(freeBusy.Free[i].Start), "free period %d", i)
+		require.True(t, want.End.Equal(freeBusy.Free[i].End), "free period %d", i)
+	}
+
+	// all the time is working without working hours
+	require.NoError(t, a.DeleteWorkingHours(ctx, "user"))
+	_, err = a.GetWorkingHours(ctx, "user")
+	require.ErrorIs(t, err, storage.ErrWorkingHoursNotFound)
+	freeBusy, err = a.FreeBusy(ctx, "user", at(0, 9, 0), at(0, 12, 0))
+	require.NoError(t, err)
+	require.Len(t, freeBusy.Free, 2)
+	require.True(t, freeBusy.Free[0].End.Equal(at(0, 10, 0)))
+	require.True(t, freeBusy.Free[1].Start.Equal(at(0, 11, 0)))
+
+	_, err = a.FreeBusy(ctx, "user", at(1, 0, 0), at(0, 0, 0))
+	require.ErrorIs(t, err, ErrInvalidQuery)
+	_, err = a.FreeBusy(ctx, "user", at(0, 0, 0), at(0, 0, 0).Add(MaxFreeBusyPeriod+time.Hour))
+	require.ErrorIs(t, err, ErrInvalidQuery)
+}
@@ -0,0 +1,106 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// leeway tolerates clock skew between the token issuer and the service.
+const leeway = 30 * time.Second
+
+var ErrUnauthenticated = errors.New("unauthenticated")
+
+type Config struct {
+	// Algorithm is "HS256" or "RS256".
+	Algorithm string
+	// Secret verifies HS256 tokens.
+	Secret string
+	// PublicKeyFile is a PEM encoded RSA public key verifying RS256 tokens.
+	PublicKeyFile string
+	// UserClaim is the claim holding the user ID, "sub" if empty.
+	UserClaim string
+	// Issuer and Audience are checked if not empty.
+	Issuer   string
+	Audience string
+}
+
+// JWT verifies bearer tokens, every token must be signed with the configured algorithm and expire.
+type JWT struct {
+	key       interface{}
+	algorithm string
+	userClaim string
+	options   []jwt.ParserOption
+}
+
+func NewJWT(cfg Config) (*JWT, error) {
+	j := &JWT{algorithm: cfg.Algorithm, userClaim: cfg.UserClaim}
+	if j.userClaim == "" {
+		j.userClaim = "sub"
+	}
+
+	switch cfg.Algorithm {
+	case jwt.SigningMethodHS256.Alg():
+		if cfg.Secret == "" {
+			return nil, errors.New("secret is required for HS256")
+		}
+		j.key = []byte(cfg.Secret)
+	case jwt.SigningMethodRS256.Alg():
+		pem, err := os.ReadFile(cfg.PublicKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read public key: %w", err)
+		}
+		if j.key, err = jwt.ParseRSAPublicKeyFromPEM(pem); err != nil {
+			return nil, fmt.Errorf("unable to parse public key: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %q", cfg.Algorithm)
+	}
+
+	j.options = []jwt.ParserOption{
+		jwt.WithValidMethods([]string{cfg.Algorithm}),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(leeway),
+	}
+	if cfg.Issuer != "" {
+		j.options = append(j.options, jwt.WithIssuer(cfg.Issuer))
+	}
+	if cfg.Audience != "" {
+		j.options = append(j.options, jwt.WithAudience(cfg.Audience))
+	}
+	return j, nil
+}
+
+// Verify returns the user ID from the token, errors wrap ErrUnauthenticated.
+func (j *JWT) Verify(token string) (string, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(*jwt.Token) (interface{}, error) {
+		return j.key, nil
+	}, j.options...)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrUnauthenticated, err)
+	}
+
+	userID, _ := claims[j.userClaim].(string)
+	if userID == "" {
+		return "", fmt.Errorf("%w: token has no %s claim", ErrUnauthenticated, j.userClaim)
+	}
+	return userID, nil
+}
+
+type userIDKey struct{}
+
+// WithUserID returns the context of a request made by the user.
+func WithUserID(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, userIDKey{}, userID)
+}
+
+// UserID returns the user making the request, it is empty for anonymous requests.
+func UserID(ctx context.Context) string {
+	userID, _ := ctx.Value(userIDKey{}).(string)
+	return userID
+}
@@ -0,0 +1,99 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/require"
+)
+
+func sign(t *testing.T, method jwt.SigningMethod, key interface{}, claims jwt.MapClaims) string {
+	t.Helper()
+
+	token, err := jwt.NewWithClaims(method, claims).SignedString(key)
+	require.NoError(t, err)
+	return token
+}
+
+func TestHS256(t *testing.T) {
+	j, err := NewJWT(Config{Algorithm: "HS256", Secret: "secret", Issuer: "issuer"})
+	require.NoError(t, err)
+	secret := []byte("secret")
+	expires := time.Now().Add(time.Hour).Unix()
+
+	userID, err := j.Verify(sign(t, jwt.SigningMethodHS256, secret, jwt.MapClaims{
+		"sub": "user", "iss": "issuer", "exp": expires,
+	}))
+	require.NoError(t, err)
+	require.Equal(t, "user", userID)
+
+	for name, token := range map[string]string{
+		"expired": sign(t, jwt.SigningMethodHS256, secret, jwt.MapClaims{
+			"sub": "user", "iss": "issuer", "exp": time.Now().Add(-time.Hour).Unix(),
+		}),
+		"no expiration": sign(t, jwt.SigningMethodHS256, secret, jwt.MapClaims{"sub": "user", "iss": "issuer"}),
+		"other issuer": sign(t, jwt.SigningMethodHS256, secret, jwt.MapClaims{
+			"sub": "user", "iss": "other", "exp": expires,
+		}),
+		"other secret": sign(t, jwt.SigningMethodHS256, []byte("other"), jwt.MapClaims{
+			"sub": "user", "iss": "issuer", "exp": expires,
+		}),
+		"other algorithm": sign(t, jwt.SigningMethodHS512, secret, jwt.MapClaims{
+			"sub": "user", "iss": "issuer", "exp": expires,
+		}),
+		"unsigned": sign(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, jwt.MapClaims{
+			"sub": "user", "iss": "issuer", "exp": expires,
+		}),
+		"no user":   sign(t, jwt.SigningMethodHS256, secret, jwt.MapClaims{"iss": "issuer", "exp": expires}),
+		"malformed": "not a token",
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := j.Verify(token)
+			require.ErrorIs(t, err, ErrUnauthenticated)
+		})
+	}
+}
+
+func TestRS256(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+	file := filepath.Join(t.TempDir(), "key.pem")
+	require.NoError(t, os.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o600))
+
+	j, err := NewJWT(Config{Algorithm: "RS256", PublicKeyFile: file, UserClaim: "uid", Audience: "calendar"})
+	require.NoError(t, err)
+
+	userID, err := j.Verify(sign(t, jwt.SigningMethodRS256, key, jwt.MapClaims{
+		"uid": "user", "aud": "calendar", "exp": time.Now().Add(time.Hour).Unix(),
+	}))
+	require.NoError(t, err)
+	require.Equal(t, "user", userID)
+
+	_, err = j.Verify(sign(t, jwt.SigningMethodRS256, key, jwt.MapClaims{
+		"uid": "user", "aud": "other", "exp": time.Now().Add(time.Hour).Unix(),
+	}))
+	require.ErrorIs(t, err, ErrUnauthenticated)
+
+	// the public key must not be usable as an HMAC secret
+	_, err = j.Verify(sign(t, jwt.SigningMethodHS256, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}),
+		jwt.MapClaims{"uid": "user", "aud": "calendar", "exp": time.Now().Add(time.Hour).Unix()}))
+	require.ErrorIs(t, err, ErrUnauthenticated)
+}
+
+func TestNewJWT(t *testing.T) {
+	_, err := NewJWT(Config{Algorithm: "HS256"})
+	require.Error(t, err)
+	_, err = NewJWT(Config{Algorithm: "RS256", PublicKeyFile: filepath.Join(t.TempDir(), "missing.pem")})
+	require.Error(t, err)
+	_, err = NewJWT(Config{Algorithm: "none"})
+	require.Error(t, err)
+}
@@ -0,0 +1,21 @@
+// Package blob keeps binary content by key apart from the storage of events.
+package blob
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+var ErrNotFound = errors.New("blob not found")
+
+type Store interface {
+	// Put stores the content read until EOF under the key and returns its size.
+	Put(ctx context.Context, key string, content io.Reader) (int64, error)
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the content, deleting a missing key is not an error.
+	Delete(ctx context.Context, key string) error
+	// Keys returns keys of the blobs written before the time.
+	Keys(ctx context.Context, before time.Time) ([]string, error)
+}
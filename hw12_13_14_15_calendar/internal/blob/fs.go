@@ -0,0 +1,112 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// FS keeps every blob in a file of the directory named by its key.
+type FS struct {
+	dir string
+}
+
+// NewFS creates the directory if it doesn't exist.
+func NewFS(dir string) (*FS, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("unable to create blob directory: %w", err)
+	}
+	return &FS{dir: dir}, nil
+}
+
+// Put writes the content to a temporary file renamed once it's complete, so readers never see a partial blob.
+func (s *FS) Put(ctx context.Context, key string, content io.Reader) (int64, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return 0, err
+	}
+
+	tmp, err := os.CreateTemp(s.dir, ".tmp-*")
+	if err != nil {
+		return 0, fmt.Errorf("unable to create blob: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	n, err := io.Copy(tmp, content)
+	if err != nil {
+		_ = tmp.Close()
+		return 0, fmt.Errorf("unable to write blob: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return 0, fmt.Errorf("unable to write blob: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return 0, fmt.Errorf("unable to save blob: %w", err)
+	}
+	return n, nil
+}
+
+func (s *FS) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to open blob: %w", err)
+	}
+	return file, nil
+}
+
+func (s *FS) Delete(ctx context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("unable to delete blob: %w", err)
+	}
+	return nil
+}
+
+// Keys skips temporary files of writes in progress.
+func (s *FS) Keys(ctx context.Context, before time.Time) ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list blobs: %w", err)
+	}
+	var keys []string
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		info, err := entry.Info()
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to stat blob: %w", err)
+		}
+		if info.ModTime().Before(before) {
+			keys = append(keys, entry.Name())
+		}
+	}
+	return keys, nil
+}
+
+// path rejects keys which could point outside of the directory or at temporary files.
+func (s *FS) path(key string) (string, error) {
+	if key == "" || strings.HasPrefix(key, ".") || strings.ContainsAny(key, `/\`) {
+		return "", fmt.Errorf("invalid blob key %q", key)
+	}
+	return filepath.Join(s.dir, key), nil
+}
@@ -0,0 +1,68 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) {
+	return 0, errors.New("connection reset")
+}
+
+func TestFS(t *testing.T) {
+	ctx := context.Background()
+	dir := filepath.Join(t.TempDir(), "blobs")
+	s, err := NewFS(dir)
+	require.NoError(t, err)
+
+	n, err := s.Put(ctx, "key", strings.NewReader("content"))
+	require.NoError(t, err)
+	require.Equal(t, int64(7), n)
+
+	r, err := s.Open(ctx, "key")
+	require.NoError(t, err)
+	content, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+	require.Equal(t, "content", string(content))
+
+	_, err = s.Put(ctx, "key", io.MultiReader(strings.NewReader("partial"), failingReader{}))
+	require.Error(t, err)
+	r, err = s.Open(ctx, "key")
+	require.NoError(t, err)
+	content, err = io.ReadAll(r)
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+	require.Equal(t, "content", string(content), "failed writes keep the previous content")
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1, "temporary files are removed")
+
+	keys, err := s.Keys(ctx, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	require.Equal(t, []string{"key"}, keys)
+	keys, err = s.Keys(ctx, time.Now().Add(-time.Minute))
+	require.NoError(t, err)
+	require.Empty(t, keys, "recent blobs are skipped")
+
+	require.NoError(t, s.Delete(ctx, "key"))
+	require.NoError(t, s.Delete(ctx, "key"))
+	_, err = s.Open(ctx, "key")
+	require.ErrorIs(t, err, ErrNotFound)
+
+	for _, key := range []string{"", "../escape", "a/b", `a\b`, ".tmp-1"} {
+		_, err := s.Put(ctx, key, strings.NewReader("content"))
+		require.Error(t, err, key)
+	}
+}
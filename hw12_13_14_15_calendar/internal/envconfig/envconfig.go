@@ -0,0 +1,89 @@
+// Package envconfig overrides configuration fields with environment variables.
+package envconfig
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// Apply sets fields of the struct pointed by v from environment variables named
+// PREFIX_SECTION_FIELD, e.g. CALENDAR_STORAGE_DSN for Config.Storage.DSN.
+// Names are taken from toml tags if present. Unset variables leave fields as is.
+func Apply(prefix string, v interface{}) error {
+	return apply(strings.ToUpper(prefix), reflect.ValueOf(v).Elem())
+}
+
+func apply(prefix string, v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := field.Name
+		if tag := field.Tag.Get("toml"); tag != "" {
+			name = strings.Split(tag, ",")[0]
+		}
+		name = prefix + "_" + strings.ToUpper(name)
+
+		value := v.Field(i)
+		if value.Kind() == reflect.Struct {
+			if err := apply(name, value); err != nil {
+				return err
+			}
+			continue
+		}
+
+		s, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		if err := set(value, s); err != nil {
+			return fmt.Errorf("invalid %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func set(v reflect.Value, s string) error {
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() { //nolint:exhaustive
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package envconfig
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestApply(t *testing.T) {
+	type config struct {
+		Storage struct {
+			Type string
+			DSN  string
+		}
+		RateLimit struct {
+			Rate        float64
+			Burst       int
+			IdleTimeout time.Duration `toml:"idle_timeout"`
+		}
+		Tracing struct {
+			Insecure bool
+		}
+	}
+
+	t.Setenv("TEST_STORAGE_DSN", "postgres://db")
+	t.Setenv("TEST_RATELIMIT_RATE", "2.5")
+	t.Setenv("TEST_RATELIMIT_BURST", "10")
+	t.Setenv("TEST_RATELIMIT_IDLE_TIMEOUT", "1m")
+	t.Setenv("TEST_TRACING_INSECURE", "true")
+
+	var c config
+	c.Storage.Type = "memory"
+	require.NoError(t, Apply("test", &c))
+
+	require.Equal(t, "memory", c.Storage.Type)
+	require.Equal(t, "postgres://db", c.Storage.DSN)
+	require.Equal(t, 2.5, c.RateLimit.Rate)
+	require.Equal(t, 10, c.RateLimit.Burst)
+	require.Equal(t, time.Minute, c.RateLimit.IdleTimeout)
+	require.True(t, c.Tracing.Insecure)
+
+	t.Setenv("TEST_RATELIMIT_BURST", "many")
+	require.ErrorContains(t, Apply("test", &c), "TEST_RATELIMIT_BURST")
+}
@@ -0,0 +1,148 @@
+// Package holiday reads holiday calendars from iCalendar and JSON files.
+package holiday
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/ical"
+)
+
+const dateLayout = "2006-01-02"
+
+var ErrFormat = errors.New("invalid holiday calendar")
+
+// Calendar is a set of days off, the days are dates without time zones.
+type Calendar struct {
+	Name string
+	days map[date]string
+}
+
+type date struct {
+	year  int
+	month time.Month
+	day   int
+}
+
+func dateOf(t time.Time) date {
+	year, month, day := t.Date()
+	return date{year: year, month: month, day: day}
+}
+
+// Lookup returns the name of the holiday on the date of t in its location, the time of the day is ignored.
+func (c *Calendar) Lookup(t time.Time) (string, bool) {
+	name, ok := c.days[dateOf(t)]
+	return name, ok
+}
+
+func (c *Calendar) add(t time.Time, name string) {
+	d := dateOf(t)
+	// the first name wins if a day has several holidays
+	if _, ok := c.days[d]; !ok {
+		c.days[d] = name
+	}
+}
+
+// DecodeICS reads the holidays from events of an iCalendar stream. All-day events cover their dates,
+// other events cover every date they overlap in UTC.
+func DecodeICS(name string, r io.Reader) (*Calendar, error) {
+	events, err := ical.Decode(r)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Calendar{Name: name, days: make(map[date]string)}
+	for _, e := range events {
+		day := e.Start.UTC()
+		day = time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+		for {
+			c.add(day, e.Summary)
+			day = day.AddDate(0, 0, 1)
+			if !day.Before(e.End) {
+				break
+			}
+		}
+	}
+	return c, nil
+}
+
+type jsonHoliday struct {
+	Date string `json:"date"`
+	Name string `json:"name"`
+}
+
+// DecodeJSON reads the holidays from a JSON array of objects with the date in the YYYY-MM-DD format
+// and the name, e.g. [{"date": "2024-01-01", "name": "New Year's Day"}].
+func DecodeJSON(name string, r io.Reader) (*Calendar, error) {
+	var holidays []jsonHoliday
+	if err := json.NewDecoder(r).Decode(&holidays); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrFormat, err)
+	}
+
+	c := &Calendar{Name: name, days: make(map[date]string, len(holidays))}
+	for _, h := range holidays {
+		day, err := time.Parse(dateLayout, h.Date)
+		if err != nil {
+			return nil, fmt.Errorf("%w: date %q must be in the YYYY-MM-DD format", ErrFormat, h.Date)
+		}
+		c.add(day, h.Name)
+	}
+	return c, nil
+}
+
+// Load reads the calendar from a file with the .ics or .json extension,
+// the calendar is named by the file name without the extension.
+func Load(path string) (*Calendar, error) {
+	decode, ok := decoders[strings.ToLower(filepath.Ext(path))]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown extension of %s", ErrFormat, path)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open holiday calendar: %w", err)
+	}
+	defer f.Close()
+
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	c, err := decode(name, f)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read holiday calendar %s: %w", path, err)
+	}
+	return c, nil
+}
+
+// LoadDir loads the calendars of all .ics and .json files in the directory by name, other files are skipped.
+func LoadDir(dir string) (map[string]*Calendar, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read holiday calendars: %w", err)
+	}
+
+	calendars := make(map[string]*Calendar)
+	for _, entry := range entries {
+		if _, ok := decoders[strings.ToLower(filepath.Ext(entry.Name()))]; !ok || entry.IsDir() {
+			continue
+		}
+		c, err := Load(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := calendars[c.Name]; ok {
+			return nil, fmt.Errorf("%w: %s is defined by several files", ErrFormat, c.Name)
+		}
+		calendars[c.Name] = c
+	}
+	return calendars, nil
+}
+
+var decoders = map[string]func(name string, r io.Reader) (*Calendar, error){
+	".ics":  DecodeICS,
+	".json": DecodeJSON,
+}
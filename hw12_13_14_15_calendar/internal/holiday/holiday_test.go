@@ -0,0 +1,96 @@
+package holiday
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeICS(t *testing.T) {
+	data := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"BEGIN:VEVENT",
+		"UID:new-year",
+		"SUMMARY:New Year holidays",
+		"DTSTART;VALUE=DATE:20240101",
+		"DTEND;VALUE=DATE:20240103",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:christmas",
+		"SUMMARY:Christmas",
+		"DTSTART;VALUE=DATE:20240107",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:short",
+		"SUMMARY:Short day",
+		"DTSTART:20240222T150000Z",
+		"DTEND:20240222T180000Z",
+		"END:VEVENT",
+		"END:VCALENDAR",
+	}, "\r\n") + "\r\n"
+
+	c, err := DecodeICS("ru", strings.NewReader(data))
+	require.NoError(t, err)
+	require.Equal(t, "ru", c.Name)
+
+	moscow, err := time.LoadLocation("Europe/Moscow")
+	require.NoError(t, err)
+	for _, day := range []int{1, 2} {
+		name, ok := c.Lookup(time.Date(2024, 1, day, 23, 30, 0, 0, moscow))
+		require.True(t, ok)
+		require.Equal(t, "New Year holidays", name)
+	}
+	_, ok := c.Lookup(time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC))
+	require.False(t, ok)
+	name, ok := c.Lookup(time.Date(2024, 1, 7, 12, 0, 0, 0, time.UTC))
+	require.True(t, ok)
+	require.Equal(t, "Christmas", name)
+	_, ok = c.Lookup(time.Date(2024, 2, 22, 9, 0, 0, 0, time.UTC))
+	require.True(t, ok)
+}
+
+func TestDecodeJSON(t *testing.T) {
+	c, err := DecodeJSON("us", strings.NewReader(`[
+		{"date": "2024-07-04", "name": "Independence Day"},
+		{"date": "2024-07-04", "name": "Duplicate"}
+	]`))
+	require.NoError(t, err)
+	name, ok := c.Lookup(time.Date(2024, 7, 4, 0, 0, 0, 0, time.UTC))
+	require.True(t, ok)
+	require.Equal(t, "Independence Day", name)
+
+	_, err = DecodeJSON("us", strings.NewReader(`[{"date": "07/04/2024"}]`))
+	require.ErrorIs(t, err, ErrFormat)
+	_, err = DecodeJSON("us", strings.NewReader(`{}`))
+	require.ErrorIs(t, err, ErrFormat)
+}
+
+func TestLoadDir(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600))
+	}
+	write("us.json", `[{"date": "2024-07-04", "name": "Independence Day"}]`)
+	write("ru.ics", "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nSUMMARY:Victory Day\r\n"+
+		"DTSTART;VALUE=DATE:20240509\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n")
+	write("README", "not a calendar")
+
+	calendars, err := LoadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, calendars, 2)
+	name, ok := calendars["ru"].Lookup(time.Date(2024, 5, 9, 0, 0, 0, 0, time.UTC))
+	require.True(t, ok)
+	require.Equal(t, "Victory Day", name)
+
+	write("us.ics", "BEGIN:VCALENDAR\r\nEND:VCALENDAR\r\n")
+	_, err = LoadDir(dir)
+	require.ErrorIs(t, err, ErrFormat)
+
+	_, err = Load(filepath.Join(dir, "README"))
+	require.ErrorIs(t, err, ErrFormat)
+}
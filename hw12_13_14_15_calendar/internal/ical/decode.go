@@ -0,0 +1,342 @@
+package ical
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type property struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// component collects the first occurrence of every property.
+type component struct {
+	props  map[string]property
+	alarms []*component
+}
+
+func newComponent() *component {
+	return &component{props: make(map[string]property)}
+}
+
+func (c *component) add(p property) {
+	if _, ok := c.props[p.name]; !ok {
+		c.props[p.name] = p
+	}
+}
+
+// Decode reads events of all calendars in r. Floating times are read in the local time zone.
+func Decode(r io.Reader) ([]Event, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		events []Event
+		stack  []string
+		event  *component
+		alarm  *component
+	)
+	for _, l := range lines {
+		if strings.TrimSpace(l.text) == "" {
+			continue
+		}
+		p, err := parseProperty(l.text)
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: %v", ErrSyntax, l.num, err)
+		}
+
+		switch p.name {
+		case "BEGIN":
+			name := strings.ToUpper(p.value)
+			switch {
+			case name == "VEVENT" && len(stack) > 0 && stack[len(stack)-1] == "VCALENDAR":
+				event = newComponent()
+			case name == "VALARM" && event != nil && stack[len(stack)-1] == "VEVENT":
+				alarm = newComponent()
+			}
+			stack = append(stack, name)
+		case "END":
+			name := strings.ToUpper(p.value)
+			if len(stack) == 0 || stack[len(stack)-1] != name {
+				return nil, fmt.Errorf("%w: line %d: unexpected END:%s", ErrSyntax, l.num, p.value)
+			}
+			stack = stack[:len(stack)-1]
+			switch {
+			case name == "VALARM" && alarm != nil:
+				event.alarms = append(event.alarms, alarm)
+				alarm = nil
+			case name == "VEVENT" && event != nil:
+				e, err := event.toEvent()
+				if err != nil {
+					return nil, fmt.Errorf("%w: event ending at line %d: %v", ErrSyntax, l.num, err)
+				}
+				events = append(events, e)
+				event = nil
+			}
+		default:
+			switch {
+			case alarm != nil:
+				alarm.add(p)
+			case event != nil && stack[len(stack)-1] == "VEVENT":
+				event.add(p)
+			}
+		}
+	}
+	if len(stack) > 0 {
+		return nil, fmt.Errorf("%w: %s is not terminated", ErrSyntax, stack[len(stack)-1])
+	}
+	return events, nil
+}
+
+func (c *component) toEvent() (Event, error) {
+	e := Event{
+		UID:         unescape(c.props["UID"].value),
+		Summary:     unescape(c.props["SUMMARY"].value),
+		Description: unescape(c.props["DESCRIPTION"].value),
+	}
+
+	start, ok := c.props["DTSTART"]
+	if !ok {
+		return Event{}, fmt.Errorf("DTSTART is missing")
+	}
+	var err error
+	if e.Start, e.AllDay, err = parseTime(start); err != nil {
+		return Event{}, fmt.Errorf("DTSTART: %w", err)
+	}
+
+	if end, ok := c.props["DTEND"]; ok {
+		if e.End, _, err = parseTime(end); err != nil {
+			return Event{}, fmt.Errorf("DTEND: %w", err)
+		}
+	} else if d, ok := c.props["DURATION"]; ok {
+		duration, err := parseDuration(d.value)
+		if err != nil {
+			return Event{}, fmt.Errorf("DURATION: %w", err)
+		}
+		e.End = e.Start.Add(duration)
+	} else if e.AllDay {
+		e.End = e.Start.AddDate(0, 0, 1)
+	} else {
+		e.End = e.Start
+	}
+
+	for _, name := range []string{"LAST-MODIFIED", "DTSTAMP"} {
+		if p, ok := c.props[name]; ok {
+			if e.Stamp, _, err = parseTime(p); err != nil {
+				return Event{}, fmt.Errorf("%s: %w", name, err)
+			}
+			break
+		}
+	}
+
+	for _, a := range c.alarms {
+		alarm, err := a.toAlarm(e)
+		if err != nil {
+			return Event{}, fmt.Errorf("VALARM: %w", err)
+		}
+		e.Alarms = append(e.Alarms, alarm)
+	}
+	return e, nil
+}
+
+func (c *component) toAlarm(e Event) (Alarm, error) {
+	trigger, ok := c.props["TRIGGER"]
+	if !ok {
+		return Alarm{}, fmt.Errorf("TRIGGER is missing")
+	}
+
+	var at time.Time
+	if strings.EqualFold(trigger.params["VALUE"], "DATE-TIME") {
+		t, _, err := parseTime(trigger)
+		if err != nil {
+			return Alarm{}, fmt.Errorf("TRIGGER: %w", err)
+		}
+		at = t
+	} else {
+		d, err := parseDuration(trigger.value)
+		if err != nil {
+			return Alarm{}, fmt.Errorf("TRIGGER: %w", err)
+		}
+		at = e.Start.Add(d)
+		if strings.EqualFold(trigger.params["RELATED"], "END") {
+			at = e.End.Add(d)
+		}
+	}
+
+	return Alarm{
+		Before:  e.Start.Sub(at),
+		Action:  strings.ToUpper(c.props["ACTION"].value),
+		Channel: c.props["X-CALENDAR-CHANNEL"].value,
+	}, nil
+}
+
+type line struct {
+	text string
+	num  int
+}
+
+// unfold joins continuation lines, which start with a space or a tab, to the previous line.
+func unfold(r io.Reader) ([]line, error) {
+	var lines []line
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		text := strings.TrimSuffix(scanner.Text(), "\r")
+		if len(lines) > 0 && (strings.HasPrefix(text, " ") || strings.HasPrefix(text, "\t")) {
+			lines[len(lines)-1].text += text[1:]
+			continue
+		}
+		lines = append(lines, line{text: text, num: n})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read calendar: %w", err)
+	}
+	return lines, nil
+}
+
+// parseProperty parses a content line "NAME;PARAM=VALUE;PARAM="QUOTED":VALUE".
+func parseProperty(s string) (property, error) {
+	end := strings.IndexAny(s, ";:")
+	if end <= 0 {
+		return property{}, fmt.Errorf("property name is missing")
+	}
+	p := property{name: strings.ToUpper(s[:end]), params: make(map[string]string)}
+
+	for s = s[end:]; s[0] == ';'; {
+		s = s[1:]
+		eq := strings.IndexByte(s, '=')
+		if eq <= 0 {
+			return property{}, fmt.Errorf("malformed parameter of %s", p.name)
+		}
+		name := strings.ToUpper(s[:eq])
+		s = s[eq+1:]
+
+		var value string
+		if strings.HasPrefix(s, `"`) {
+			closing := strings.IndexByte(s[1:], '"')
+			if closing < 0 {
+				return property{}, fmt.Errorf("unterminated quoted parameter of %s", p.name)
+			}
+			value, s = s[1:closing+1], s[closing+2:]
+		} else {
+			end := strings.IndexAny(s, ";:")
+			if end < 0 {
+				return property{}, fmt.Errorf("value of %s is missing", p.name)
+			}
+			value, s = s[:end], s[end:]
+		}
+		p.params[name] = value
+		if s == "" {
+			return property{}, fmt.Errorf("value of %s is missing", p.name)
+		}
+	}
+	if s[0] != ':' {
+		return property{}, fmt.Errorf("value of %s is missing", p.name)
+	}
+	p.value = s[1:]
+	return p, nil
+}
+
+// parseTime parses DATE and DATE-TIME values, it reports whether the value is a DATE.
+func parseTime(p property) (time.Time, bool, error) {
+	v := p.value
+	if strings.EqualFold(p.params["VALUE"], "DATE") || len(v) == len(dateLayout) {
+		t, err := time.Parse(dateLayout, v)
+		return t, true, err
+	}
+	if strings.HasSuffix(v, "Z") {
+		t, err := time.Parse(utcLayout, v)
+		return t, false, err
+	}
+
+	loc := time.Local
+	if tzid := strings.TrimPrefix(p.params["TZID"], "/"); tzid != "" {
+		var err error
+		if loc, err = time.LoadLocation(tzid); err != nil {
+			return time.Time{}, false, fmt.Errorf("unknown time zone %q", tzid)
+		}
+	}
+	t, err := time.ParseInLocation(dateTimeLayout, v, loc)
+	return t, false, err
+}
+
+// parseDuration parses a duration value like "-P1DT2H30M" or "PT15M".
+func parseDuration(s string) (time.Duration, error) {
+	invalid := fmt.Errorf("invalid duration %q", s)
+
+	sign := time.Duration(1)
+	switch {
+	case strings.HasPrefix(s, "-"):
+		sign, s = -1, s[1:]
+	case strings.HasPrefix(s, "+"):
+		s = s[1:]
+	}
+	if !strings.HasPrefix(s, "P") || len(s) < 3 {
+		return 0, invalid
+	}
+
+	var d time.Duration
+	inTime := false
+	for s = s[1:]; s != ""; {
+		if s[0] == 'T' {
+			inTime, s = true, s[1:]
+			continue
+		}
+		end := strings.IndexFunc(s, func(r rune) bool { return r < '0' || r > '9' })
+		if end <= 0 {
+			return 0, invalid
+		}
+		n, err := strconv.Atoi(s[:end])
+		if err != nil {
+			return 0, invalid
+		}
+
+		var unit time.Duration
+		switch {
+		case s[end] == 'W' && !inTime:
+			unit = 7 * 24 * time.Hour
+		case s[end] == 'D' && !inTime:
+			unit = 24 * time.Hour
+		case s[end] == 'H' && inTime:
+			unit = time.Hour
+		case s[end] == 'M' && inTime:
+			unit = time.Minute
+		case s[end] == 'S' && inTime:
+			unit = time.Second
+		default:
+			return 0, invalid
+		}
+		d += time.Duration(n) * unit
+		s = s[end+1:]
+	}
+	return sign * d, nil
+}
+
+func unescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i == len(s)-1 {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n', 'N':
+			b.WriteByte('\n')
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
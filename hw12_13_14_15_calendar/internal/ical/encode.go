@@ -0,0 +1,127 @@
+package ical
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// maxLineLen is the limit of a content line in octets, longer lines are folded.
+const maxLineLen = 75
+
+// Encode writes the events as a single calendar.
+func Encode(w io.Writer, events []Event) error {
+	enc := &encoder{w: bufio.NewWriter(w)}
+	enc.line("BEGIN:VCALENDAR")
+	enc.line("VERSION:2.0")
+	enc.line("PRODID:-//hw12_13_14_15_calendar//calendarctl//EN")
+	for _, e := range events {
+		enc.event(e)
+	}
+	enc.line("END:VCALENDAR")
+	if enc.err != nil {
+		return enc.err
+	}
+	return enc.w.Flush()
+}
+
+type encoder struct {
+	w   *bufio.Writer
+	err error
+}
+
+func (enc *encoder) event(e Event) {
+	stamp := e.Stamp
+	if stamp.IsZero() {
+		stamp = time.Now()
+	}
+
+	enc.line("BEGIN:VEVENT")
+	enc.line("UID:" + escape(e.UID))
+	enc.line("DTSTAMP:" + stamp.UTC().Format(utcLayout))
+	if e.AllDay {
+		enc.line("DTSTART;VALUE=DATE:" + e.Start.Format(dateLayout))
+		enc.line("DTEND;VALUE=DATE:" + e.End.Format(dateLayout))
+	} else {
+		enc.line("DTSTART:" + e.Start.UTC().Format(utcLayout))
+		enc.line("DTEND:" + e.End.UTC().Format(utcLayout))
+	}
+	enc.line("SUMMARY:" + escape(e.Summary))
+	if e.Description != "" {
+		enc.line("DESCRIPTION:" + escape(e.Description))
+	}
+	for _, a := range e.Alarms {
+		action := a.Action
+		if action == "" {
+			action = "DISPLAY"
+		}
+		enc.line("BEGIN:VALARM")
+		enc.line("ACTION:" + action)
+		enc.line("TRIGGER:" + formatDuration(-a.Before))
+		enc.line("DESCRIPTION:" + escape(e.Summary))
+		if a.Channel != "" {
+			enc.line("X-CALENDAR-CHANNEL:" + a.Channel)
+		}
+		enc.line("END:VALARM")
+	}
+	enc.line("END:VEVENT")
+}
+
+// line writes the content line folded at maxLineLen octets without splitting UTF-8 sequences.
+func (enc *encoder) line(s string) {
+	if enc.err != nil {
+		return
+	}
+	limit := maxLineLen
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		if _, enc.err = enc.w.WriteString(s[:cut] + "\r\n "); enc.err != nil {
+			return
+		}
+		// the leading space of a continuation line counts too
+		s, limit = s[cut:], maxLineLen-1
+	}
+	_, enc.err = enc.w.WriteString(s + "\r\n")
+}
+
+func escape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`, "\r", "").Replace(s)
+}
+
+// formatDuration formats the duration like "-P1DT2H30M", fractions of a second are dropped.
+func formatDuration(d time.Duration) string {
+	d = d.Truncate(time.Second)
+	if d == 0 {
+		return "PT0S"
+	}
+
+	var b strings.Builder
+	if d < 0 {
+		b.WriteByte('-')
+		d = -d
+	}
+	b.WriteByte('P')
+	if days := d / (24 * time.Hour); days > 0 {
+		fmt.Fprintf(&b, "%dD", days)
+		d -= days * 24 * time.Hour
+	}
+	if d >= time.Second {
+		b.WriteByte('T')
+		for _, unit := range []struct {
+			d    time.Duration
+			name string
+		}{{time.Hour, "H"}, {time.Minute, "M"}, {time.Second, "S"}} {
+			if n := d / unit.d; n > 0 {
+				fmt.Fprintf(&b, "%d%s", n, unit.name)
+				d -= n * unit.d
+			}
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,39 @@
+// Package ical reads and writes events in the iCalendar format (RFC 5545).
+// Only the subset needed to exchange events is supported: VEVENT components with VALARM reminders,
+// recurrence rules and other components are ignored.
+package ical
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrSyntax = errors.New("invalid iCalendar data")
+
+type Event struct {
+	UID         string
+	Summary     string
+	Description string
+	Start       time.Time
+	End         time.Time
+	// AllDay is set for events with DATE values, Start and End are midnights in UTC then.
+	AllDay bool
+	// Stamp is the last modification time, the current time is written if it is zero.
+	Stamp  time.Time
+	Alarms []Alarm
+}
+
+type Alarm struct {
+	// Before is the offset of the trigger before the event start.
+	Before time.Duration
+	// Action is DISPLAY, AUDIO or EMAIL, DISPLAY is written if it is empty.
+	Action string
+	// Channel is the X-CALENDAR-CHANNEL property naming the delivery channel of the reminder.
+	Channel string
+}
+
+const (
+	dateLayout     = "20060102"
+	dateTimeLayout = "20060102T150405"
+	utcLayout      = "20060102T150405Z"
+)
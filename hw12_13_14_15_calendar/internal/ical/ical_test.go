@@ -0,0 +1,117 @@
+package ical
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoundTrip(t *testing.T) {
+	start := time.Date(2021, 8, 2, 10, 0, 0, 0, time.UTC)
+	events := []Event{
+		{
+			UID:         "1",
+			Summary:     "Planning; sprint 42, part 1",
+			Description: "Agenda:\n- retro \\ review\n- " + strings.Repeat("очень длинное описание ", 10),
+			Start:       start,
+			End:         start.Add(time.Hour),
+			Stamp:       start.Add(-time.Hour),
+			Alarms: []Alarm{
+				{Before: 24 * time.Hour, Action: "EMAIL", Channel: "webhook"},
+				{Before: 90 * time.Minute, Action: "DISPLAY", Channel: "log"},
+			},
+		},
+		{
+			UID:    "2",
+			Start:  time.Date(2021, 8, 9, 0, 0, 0, 0, time.UTC),
+			End:    time.Date(2021, 8, 10, 0, 0, 0, 0, time.UTC),
+			AllDay: true,
+			Stamp:  start,
+		},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, Encode(&buf, events))
+	for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\r\n"), "\r\n") {
+		require.LessOrEqual(t, len(line), maxLineLen)
+	}
+
+	decoded, err := Decode(&buf)
+	require.NoError(t, err)
+	require.Equal(t, events, decoded)
+}
+
+func TestDecode(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	data := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"BEGIN:VTIMEZONE",
+		"TZID:Europe/Berlin",
+		"END:VTIMEZONE",
+		"BEGIN:VEVENT",
+		"UID:local",
+		"DTSTART;TZID=Europe/Berlin:20210802T100000",
+		"DURATION:PT1H30M",
+		"SUMMARY:folded",
+		"  summary",
+		"BEGIN:VALARM",
+		"TRIGGER;RELATED=END:-PT2H",
+		"ACTION:DISPLAY",
+		"END:VALARM",
+		"BEGIN:VALARM",
+		"TRIGGER;VALUE=DATE-TIME:20210801T080000Z",
+		"ACTION:AUDIO",
+		"END:VALARM",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:holiday",
+		"DTSTART;VALUE=DATE:20211225",
+		"SUMMARY:Christmas",
+		"END:VEVENT",
+		"END:VCALENDAR",
+	}, "\n")
+
+	events, err := Decode(strings.NewReader(data))
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+
+	start := time.Date(2021, 8, 2, 10, 0, 0, 0, berlin)
+	require.Equal(t, "local", events[0].UID)
+	require.Equal(t, "folded summary", events[0].Summary)
+	require.True(t, start.Equal(events[0].Start))
+	require.True(t, start.Add(90*time.Minute).Equal(events[0].End))
+	require.Equal(t, []Alarm{
+		{Before: 30 * time.Minute, Action: "DISPLAY"},
+		{Before: 24 * time.Hour, Action: "AUDIO"},
+	}, events[0].Alarms)
+
+	require.True(t, events[1].AllDay)
+	require.Equal(t, time.Date(2021, 12, 25, 0, 0, 0, 0, time.UTC), events[1].Start)
+	require.Equal(t, time.Date(2021, 12, 26, 0, 0, 0, 0, time.UTC), events[1].End)
+}
+
+func TestDecodeErrors(t *testing.T) {
+	for name, data := range map[string]string{
+		"unterminated":   "BEGIN:VCALENDAR\nBEGIN:VEVENT\nDTSTART:20210802T100000Z\n",
+		"mismatched end": "BEGIN:VCALENDAR\nEND:VEVENT\n",
+		"no start":       "BEGIN:VCALENDAR\nBEGIN:VEVENT\nUID:1\nEND:VEVENT\nEND:VCALENDAR\n",
+		"bad time":       "BEGIN:VCALENDAR\nBEGIN:VEVENT\nDTSTART:tomorrow\nEND:VEVENT\nEND:VCALENDAR\n",
+		"bad duration": "BEGIN:VCALENDAR\nBEGIN:VEVENT\nDTSTART:20210802T100000Z\nDURATION:P1H\n" +
+			"END:VEVENT\nEND:VCALENDAR\n",
+		"no value": "BEGIN:VCALENDAR\nSUMMARY\nEND:VCALENDAR\n",
+		"unknown zone": "BEGIN:VCALENDAR\nBEGIN:VEVENT\nDTSTART;TZID=Mars/Olympus:20210802T100000\n" +
+			"END:VEVENT\nEND:VCALENDAR\n",
+		"unquoted params": "BEGIN:VCALENDAR\nX;A=\"b:c\nEND:VCALENDAR\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := Decode(strings.NewReader(data))
+			require.ErrorIs(t, err, ErrSyntax)
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package leader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"syscall"
+)
+
+// FileLock is an exclusive flock of a file, for replicas running on the same host.
+// The kernel releases the lock when the holding process dies.
+type FileLock struct {
+	path string
+
+	mu   sync.Mutex
+	file *os.File
+}
+
+func NewFileLock(path string) *FileLock {
+	return &FileLock{path: path}
+}
+
+func (l *FileLock) TryLock(ctx context.Context) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file != nil {
+		return true, nil
+	}
+	file, err := os.OpenFile(l.path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return false, fmt.Errorf("unable to open lock file: %w", err)
+	}
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		_ = file.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return false, nil
+		}
+		return false, fmt.Errorf("unable to lock file: %w", err)
+	}
+	l.file = file
+	return true, nil
+}
+
+// Check fails if the lock file has been removed or replaced, another replica could lock the new file.
+func (l *FileLock) Check(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return errors.New("lock is not held")
+	}
+	held, err := l.file.Stat()
+	if err != nil {
+		return fmt.Errorf("unable to stat lock file: %w", err)
+	}
+	current, err := os.Stat(l.path)
+	if err != nil {
+		return fmt.Errorf("unable to stat lock file: %w", err)
+	}
+	if !os.SameFile(held, current) {
+		return errors.New("lock file is replaced")
+	}
+	return nil
+}
+
+func (l *FileLock) Unlock(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return nil
+	}
+	// closing the file releases the lock
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
@@ -0,0 +1,108 @@
+// Package leader elects one of several replicas to do the work which must not run concurrently.
+package leader
+
+import (
+	"context"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/metrics"
+)
+
+// unlockTimeout limits releasing the lock when leadership ends.
+const unlockTimeout = 3 * time.Second
+
+type Logger interface {
+	Info(msg string)
+	Error(msg string)
+}
+
+// Lock is held by the leader. It must be released when the holder dies, so another replica takes over.
+type Lock interface {
+	// TryLock acquires the lock if it is free, it returns false if another replica holds it.
+	TryLock(ctx context.Context) (bool, error)
+	// Check returns an error if the held lock is lost.
+	Check(ctx context.Context) error
+	Unlock(ctx context.Context) error
+}
+
+type Elector struct {
+	logger   Logger
+	lock     Lock
+	interval time.Duration
+}
+
+// New returns an elector trying to become the leader, or checking it still is, every interval.
+func New(logger Logger, lock Lock, interval time.Duration) *Elector {
+	return &Elector{
+		logger:   logger,
+		lock:     lock,
+		interval: interval,
+	}
+}
+
+// Run calls lead every time the replica becomes the leader until ctx is done.
+// The context of lead is canceled when leadership is lost, the lock is released once lead returns.
+func (e *Elector) Run(ctx context.Context, lead func(ctx context.Context)) {
+	for {
+		acquired, err := e.lock.TryLock(ctx)
+		if err != nil && ctx.Err() == nil {
+			e.logger.Error("unable to acquire leader lock: " + err.Error())
+		}
+		if acquired {
+			e.leadWhileLocked(ctx, lead)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(e.interval):
+		}
+	}
+}
+
+func (e *Elector) leadWhileLocked(ctx context.Context, lead func(ctx context.Context)) {
+	e.logger.Info("became the leader")
+	metrics.Leader.Set(1)
+	metrics.LeadershipChanges.Inc()
+
+	leadCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		lead(leadCtx)
+	}()
+	e.hold(leadCtx, done)
+	cancel()
+	<-done
+
+	unlockCtx, unlockCancel := context.WithTimeout(context.Background(), unlockTimeout)
+	defer unlockCancel()
+	if err := e.lock.Unlock(unlockCtx); err != nil {
+		e.logger.Error("unable to release leader lock: " + err.Error())
+	}
+	e.logger.Info("lost leadership")
+	metrics.Leader.Set(0)
+	metrics.LeadershipChanges.Inc()
+}
+
+// hold checks the lock until ctx is done, lead returns or the lock is lost.
+func (e *Elector) hold(ctx context.Context, done <-chan struct{}) {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-done:
+			return
+		case <-ticker.C:
+			if err := e.lock.Check(ctx); err != nil {
+				if ctx.Err() == nil {
+					e.logger.Error("leader lock is lost: " + err.Error())
+				}
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package leader
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/logger"
+	"github.com/stretchr/testify/require"
+)
+
+const interval = 10 * time.Millisecond
+
+// replica records whether it is leading.
+type replica struct {
+	mu      sync.Mutex
+	leading bool
+	terms   int
+}
+
+func (r *replica) lead(ctx context.Context) {
+	r.set(true)
+	<-ctx.Done()
+	r.set(false)
+}
+
+func (r *replica) set(leading bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.leading = leading
+	if leading {
+		r.terms++
+	}
+}
+
+func (r *replica) isLeading() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.leading
+}
+
+func start(ctx context.Context, lock Lock, r *replica) <-chan struct{} {
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		New(logger.NewWithWriter("ERROR", io.Discard), lock, interval).Run(ctx, r.lead)
+	}()
+	return stopped
+}
+
+func TestFailover(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scheduler.lock")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	firstCtx, stopFirst := context.WithCancel(ctx)
+	first, second := &replica{}, &replica{}
+	firstStopped := start(firstCtx, NewFileLock(path), first)
+	require.Eventually(t, first.isLeading, time.Second, interval)
+
+	secondStopped := start(ctx, NewFileLock(path), second)
+	time.Sleep(5 * interval)
+	require.False(t, second.isLeading(), "the lock is held by the leader")
+
+	stopFirst()
+	<-firstStopped
+	require.False(t, first.isLeading())
+	require.Eventually(t, second.isLeading, time.Second, interval)
+
+	cancel()
+	<-secondStopped
+	require.False(t, second.isLeading())
+}
+
+// flakyLock is lost once after the first check.
+type flakyLock struct {
+	mu     sync.Mutex
+	held   bool
+	checks int
+}
+
+func (l *flakyLock) TryLock(context.Context) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.held = true
+	return true, nil
+}
+
+func (l *flakyLock) Check(context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.checks++
+	if l.checks == 2 {
+		return errors.New("connection is broken")
+	}
+	return nil
+}
+
+func (l *flakyLock) Unlock(context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.held = false
+	return nil
+}
+
+func TestLostLock(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	r := &replica{}
+	stopped := start(ctx, &flakyLock{}, r)
+	require.Eventually(t, func() bool {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		return r.terms == 2
+	}, time.Second, interval, "leadership is taken again after the lock is lost")
+
+	cancel()
+	<-stopped
+}
+
+func TestFileLock(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "scheduler.lock")
+	a, b := NewFileLock(path), NewFileLock(path)
+
+	require.Error(t, a.Check(ctx))
+	locked, err := a.TryLock(ctx)
+	require.NoError(t, err)
+	require.True(t, locked)
+	locked, err = a.TryLock(ctx)
+	require.NoError(t, err)
+	require.True(t, locked, "the holder keeps the lock")
+	require.NoError(t, a.Check(ctx))
+
+	locked, err = b.TryLock(ctx)
+	require.NoError(t, err)
+	require.False(t, locked)
+
+	require.NoError(t, a.Unlock(ctx))
+	locked, err = b.TryLock(ctx)
+	require.NoError(t, err)
+	require.True(t, locked)
+
+	_, err = NewFileLock(filepath.Join(path, "missing", "dir")).TryLock(ctx)
+	require.Error(t, err)
+}
@@ -1,20 +1,67 @@
 package logger
 
-import "fmt"
+import (
+	"io"
+	"log"
+	"os"
+	"strings"
+)
 
-type Logger struct { // TODO
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var levels = map[string]Level{
+	"DEBUG": LevelDebug,
+	"INFO":  LevelInfo,
+	"WARN":  LevelWarn,
+	"ERROR": LevelError,
+}
+
+type Logger struct {
+	level  Level
+	logger *log.Logger
 }
 
 func New(level string) *Logger {
-	return &Logger{}
+	return NewWithWriter(level, os.Stderr)
+}
+
+func NewWithWriter(level string, w io.Writer) *Logger {
+	lvl, ok := levels[strings.ToUpper(level)]
+	if !ok {
+		lvl = LevelInfo
+	}
+	return &Logger{
+		level:  lvl,
+		logger: log.New(w, "", log.LstdFlags),
+	}
+}
+
+func (l Logger) Debug(msg string) {
+	l.log(LevelDebug, "DEBUG", msg)
 }
 
 func (l Logger) Info(msg string) {
-	fmt.Println(msg)
+	l.log(LevelInfo, "INFO", msg)
+}
+
+func (l Logger) Warn(msg string) {
+	l.log(LevelWarn, "WARN", msg)
 }
 
 func (l Logger) Error(msg string) {
-	// TODO
+	l.log(LevelError, "ERROR", msg)
 }
 
-// TODO
+func (l Logger) log(level Level, prefix, msg string) {
+	if level < l.level {
+		return
+	}
+	l.logger.Println(prefix + " " + msg)
+}
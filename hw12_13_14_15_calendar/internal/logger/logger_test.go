@@ -1,7 +1,36 @@
 package logger
 
-import "testing"
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
 
 func TestLogger(t *testing.T) {
-	// TODO
+	t.Run("messages below level are skipped", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		l := NewWithWriter("warn", buf)
+
+		l.Debug("debug message")
+		l.Info("info message")
+		require.Empty(t, buf.String())
+
+		l.Warn("warn message")
+		require.Contains(t, buf.String(), "WARN warn message")
+
+		l.Error("error message")
+		require.Contains(t, buf.String(), "ERROR error message")
+	})
+
+	t.Run("unknown level falls back to info", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		l := NewWithWriter("unknown", buf)
+
+		l.Debug("debug message")
+		require.Empty(t, buf.String())
+
+		l.Info("info message")
+		require.Contains(t, buf.String(), "INFO info message")
+	})
 }
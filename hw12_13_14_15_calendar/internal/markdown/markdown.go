@@ -0,0 +1,26 @@
+// Package markdown renders Markdown written by users to HTML safe to embed into pages.
+package markdown
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+var (
+	// raw HTML in the source is dropped by the renderer, the policy also removes unsafe links and attributes.
+	renderer = goldmark.New(goldmark.WithExtensions(extension.GFM))
+	policy   = bluemonday.UGCPolicy()
+)
+
+// Render returns the sanitized HTML of the GitHub flavored Markdown source.
+func Render(source string) (string, error) {
+	var buf bytes.Buffer
+	if err := renderer.Convert([]byte(source), &buf); err != nil {
+		return "", fmt.Errorf("unable to render markdown: %w", err)
+	}
+	return policy.Sanitize(buf.String()), nil
+}
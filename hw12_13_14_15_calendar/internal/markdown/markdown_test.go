@@ -0,0 +1,32 @@
+package markdown
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRender(t *testing.T) {
+	for name, tc := range map[string]struct {
+		source string
+		html   string
+	}{
+		"empty":     {"", ""},
+		"paragraph": {"Call **Bob** at *noon*", "<p>Call <strong>Bob</strong> at <em>noon</em></p>\n"},
+		"list":      {"- one\n- two", "<ul>\n<li>one</li>\n<li>two</li>\n</ul>\n"},
+		"link": {
+			"[agenda](https://example.com/agenda)",
+			`<p><a href="https://example.com/agenda" rel="nofollow">agenda</a></p>` + "\n",
+		},
+		"strikethrough": {"~~cancelled~~", "<p><del>cancelled</del></p>\n"},
+		"raw html":      {"<script>alert(1)</script>\n\ntext", "\n<p>text</p>\n"},
+		"inline html":   {`a <img src=x onerror="alert(1)"> b`, "<p>a  b</p>\n"},
+		"script link":   {"[click](javascript:alert(1))", "<p>click</p>\n"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			html, err := Render(tc.source)
+			require.NoError(t, err)
+			require.Equal(t, tc.html, html)
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "calendar"
+
+var (
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "Number of HTTP requests by route and status code.",
+	}, []string{"method", "route", "code"})
+
+	HTTPDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "HTTP request latency by route.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	GRPCRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "grpc",
+		Name:      "requests_total",
+		Help:      "Number of gRPC requests by method and status code.",
+	}, []string{"method", "code"})
+
+	GRPCDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "grpc",
+		Name:      "request_duration_seconds",
+		Help:      "gRPC request latency by method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method"})
+
+	StorageDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "storage",
+		Name:      "operation_duration_seconds",
+		Help:      "Storage operation latency.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation"})
+
+	// StorageCache is labeled by result, either "hit" or "miss".
+	StorageCache = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "storage",
+		Name:      "cache_requests_total",
+		Help:      "Number of event range queries served by the storage cache or passed to the storage.",
+	}, []string{"result"})
+
+	NotificationsPublished = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "notifications",
+		Name:      "published_total",
+		Help:      "Number of notifications published by the scheduler.",
+	})
+
+	// NotificationsSent is labeled by delivery channel.
+	NotificationsSent = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "notifications",
+		Name:      "sent_total",
+		Help:      "Number of notifications sent by the sender.",
+	}, []string{"channel"})
+
+	// NotificationsFailed is labeled by stage, either "enqueue", "publish" or "send".
+	NotificationsFailed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "notifications",
+		Name:      "failed_total",
+		Help:      "Number of notifications failed to be enqueued, published or sent.",
+	}, []string{"stage"})
+
+	// Leader is 1 while the replica is the leader.
+	Leader = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "leader",
+		Name:      "is_leader",
+		Help:      "Whether the replica is the leader.",
+	})
+
+	LeadershipChanges = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "leader",
+		Name:      "changes_total",
+		Help:      "Number of times the replica has become the leader or lost leadership.",
+	})
+)
+
+// RegisterMemoryEvents exposes the number of events kept by the memory storage.
+func RegisterMemoryEvents(count func() int) {
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "memory",
+		Name:      "events",
+		Help:      "Number of live events in the memory storage.",
+	}, func() float64 {
+		return float64(count())
+	})
+}
@@ -0,0 +1,46 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+type Logger interface {
+	Info(msg string)
+}
+
+// Server exposes /metrics on its own address, apart from the public API.
+type Server struct {
+	logger Logger
+	server *http.Server
+}
+
+func NewServer(logger Logger, host, port string) *Server {
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", promhttp.Handler())
+	return &Server{
+		logger: logger,
+		server: &http.Server{
+			Addr:              net.JoinHostPort(host, port),
+			Handler:           mux,
+			ReadHeaderTimeout: 5 * time.Second,
+		},
+	}
+}
+
+func (s *Server) Start(ctx context.Context) error {
+	s.logger.Info("metrics server is listening on " + s.server.Addr)
+	if err := s.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func (s *Server) Stop(ctx context.Context) error {
+	return s.server.Shutdown(ctx)
+}
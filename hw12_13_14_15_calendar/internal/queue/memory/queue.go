@@ -0,0 +1,42 @@
+package memoryqueue
+
+import (
+	"context"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/queue"
+)
+
+// Queue is an in-process queue, messages are lost on restart.
+type Queue struct {
+	messages chan queue.Message
+}
+
+func New(size int) *Queue {
+	return &Queue{messages: make(chan queue.Message, size)}
+}
+
+func (q *Queue) Publish(ctx context.Context, msg queue.Message) error {
+	headers := make(map[string]string, len(msg.Headers))
+	for k, v := range msg.Headers {
+		headers[k] = v
+	}
+	msg.Headers = headers
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case q.messages <- msg:
+		return nil
+	}
+}
+
+func (q *Queue) Consume(ctx context.Context, handler queue.Handler) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case msg := <-q.messages:
+			_ = handler(ctx, msg)
+		}
+	}
+}
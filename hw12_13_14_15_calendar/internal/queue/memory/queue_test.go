@@ -0,0 +1,32 @@
+package memoryqueue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/queue"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueue(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	q := New(1)
+	headers := map[string]string{"traceparent": "1"}
+	require.NoError(t, q.Publish(ctx, queue.Message{Body: []byte("1"), Headers: headers}))
+	headers["traceparent"] = "2"
+
+	received := make(chan queue.Message)
+	go func() {
+		_ = q.Consume(ctx, func(ctx context.Context, msg queue.Message) error {
+			received <- msg
+			return nil
+		})
+	}()
+
+	msg := <-received
+	require.Equal(t, "1", string(msg.Body))
+	require.Equal(t, "1", msg.Headers["traceparent"])
+}
@@ -0,0 +1,49 @@
+package queue
+
+import (
+	"context"
+	"time"
+)
+
+// Message is a queue message independent of the broker.
+type Message struct {
+	Body    []byte
+	Headers map[string]string
+}
+
+type Publisher interface {
+	Publish(ctx context.Context, msg Message) error
+}
+
+// Handler processes a consumed message, the message is dropped if it returns an error.
+type Handler func(ctx context.Context, msg Message) error
+
+type Consumer interface {
+	// Consume calls handler for every message until ctx is done.
+	Consume(ctx context.Context, handler Handler) error
+}
+
+// Notification is sent by the scheduler to the sender when a reminder about an event is due.
+type Notification struct {
+	EventID  string    `json:"event_id"`
+	Title    string    `json:"title"`
+	StartsAt time.Time `json:"starts_at"`
+	UserID   string    `json:"user_id"`
+	// Channel is the delivery channel of the reminder, empty means "log".
+	Channel      string        `json:"channel,omitempty"`
+	RemindBefore time.Duration `json:"remind_before,omitempty"`
+	// TimeZone and Locale are of the user's notification preferences, empty means the sender defaults.
+	TimeZone string `json:"time_zone,omitempty"`
+	Locale   string `json:"locale,omitempty"`
+}
+
+const StatusSent = "sent"
+
+// Status is published by the sender once it has processed a notification.
+type Status struct {
+	EventID string    `json:"event_id"`
+	UserID  string    `json:"user_id"`
+	Channel string    `json:"channel,omitempty"`
+	Status  string    `json:"status"`
+	At      time.Time `json:"at"`
+}
@@ -0,0 +1,147 @@
+package rabbit
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/queue"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Client publishes to and consumes from a durable queue bound to a direct exchange.
+type Client struct {
+	url      string
+	exchange string
+	queue    string
+	tls      *tls.Config
+
+	conn    *amqp.Connection
+	channel *amqp.Channel
+}
+
+// New returns a client of the queue, tlsConfig is used for amqps URLs and may be nil to use the defaults.
+func New(url, exchange, queue string, tlsConfig *tls.Config) *Client {
+	return &Client{
+		url:      url,
+		exchange: exchange,
+		queue:    queue,
+		tls:      tlsConfig,
+	}
+}
+
+// Connect connects to the broker and declares the exchange and the queue.
+func (c *Client) Connect(ctx context.Context) error {
+	var conn *amqp.Connection
+	var err error
+	if c.tls != nil {
+		uri, parseErr := amqp.ParseURI(c.url)
+		if parseErr != nil {
+			return fmt.Errorf("unable to parse rabbitmq url: %w", parseErr)
+		}
+		if uri.Scheme != "amqps" {
+			return errors.New("rabbitmq url must use the amqps scheme with TLS options")
+		}
+		// the dialer sets the server name on the config
+		conn, err = amqp.DialTLS(c.url, c.tls.Clone())
+	} else {
+		conn, err = amqp.Dial(c.url)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to connect to rabbitmq: %w", err)
+	}
+	ch, err := conn.Channel()
+	if err != nil {
+		_ = conn.Close()
+		return fmt.Errorf("unable to open channel: %w", err)
+	}
+
+	// publishing waits for the broker to confirm that the message is stored
+	if err := ch.Confirm(false); err != nil {
+		_ = conn.Close()
+		return fmt.Errorf("unable to enable publisher confirms: %w", err)
+	}
+	if err := ch.ExchangeDeclare(c.exchange, amqp.ExchangeDirect, true, false, false, false, nil); err != nil {
+		_ = conn.Close()
+		return fmt.Errorf("unable to declare exchange: %w", err)
+	}
+	if _, err := ch.QueueDeclare(c.queue, true, false, false, false, nil); err != nil {
+		_ = conn.Close()
+		return fmt.Errorf("unable to declare queue: %w", err)
+	}
+	if err := ch.QueueBind(c.queue, c.queue, c.exchange, false, nil); err != nil {
+		_ = conn.Close()
+		return fmt.Errorf("unable to bind queue: %w", err)
+	}
+
+	c.conn = conn
+	c.channel = ch
+	return nil
+}
+
+func (c *Client) Close() error {
+	if c.conn == nil {
+		return nil
+	}
+	return c.conn.Close()
+}
+
+func (c *Client) Publish(ctx context.Context, msg queue.Message) error {
+	headers := make(amqp.Table, len(msg.Headers))
+	for k, v := range msg.Headers {
+		headers[k] = v
+	}
+
+	confirmation, err := c.channel.PublishWithDeferredConfirmWithContext(ctx, c.exchange, c.queue, false, false,
+		amqp.Publishing{
+			Headers:      headers,
+			ContentType:  "application/json",
+			DeliveryMode: amqp.Persistent,
+			Body:         msg.Body,
+		})
+	if err != nil {
+		return fmt.Errorf("unable to publish message: %w", err)
+	}
+	acked, err := confirmation.WaitContext(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to confirm message: %w", err)
+	}
+	if !acked {
+		return errors.New("message is rejected by the broker")
+	}
+	return nil
+}
+
+func (c *Client) Consume(ctx context.Context, handler queue.Handler) error {
+	deliveries, err := c.channel.ConsumeWithContext(ctx, c.queue, "", false, false, false, false, nil)
+	if err != nil {
+		return fmt.Errorf("unable to consume messages: %w", err)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case d, ok := <-deliveries:
+			if !ok {
+				return errors.New("delivery channel is closed")
+			}
+
+			msg := queue.Message{Body: d.Body, Headers: make(map[string]string, len(d.Headers))}
+			for k, v := range d.Headers {
+				if s, ok := v.(string); ok {
+					msg.Headers[k] = s
+				}
+			}
+
+			if err := handler(ctx, msg); err != nil {
+				_ = d.Nack(false, false)
+				continue
+			}
+			if err := d.Ack(false); err != nil {
+				return fmt.Errorf("unable to ack message: %w", err)
+			}
+		}
+	}
+}
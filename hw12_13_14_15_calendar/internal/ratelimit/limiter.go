@@ -0,0 +1,92 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// DefaultIdleTimeout is used if the idle timeout is not positive, evicting every bucket would reset them all the time.
+const DefaultIdleTimeout = 10 * time.Minute
+
+// Limiter is a set of token buckets, one per key.
+// Buckets not used for idleTimeout are evicted.
+type Limiter struct {
+	mu          sync.Mutex
+	limit       rate.Limit
+	burst       int
+	idleTimeout time.Duration
+	buckets     map[string]*bucket
+	lastSweep   time.Time
+	now         func() time.Time
+}
+
+type bucket struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// New creates a limiter allowing perSecond requests per key on average with bursts of burst requests.
+func New(perSecond float64, burst int, idleTimeout time.Duration) *Limiter {
+	if burst < 1 {
+		burst = 1
+	}
+	if idleTimeout <= 0 {
+		idleTimeout = DefaultIdleTimeout
+	}
+	return &Limiter{
+		limit:       rate.Limit(perSecond),
+		burst:       burst,
+		idleTimeout: idleTimeout,
+		buckets:     make(map[string]*bucket),
+		now:         time.Now,
+	}
+}
+
+// Allow takes a token from the bucket of the key. If the bucket is empty,
+// it returns false and the time after which a token will be available.
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{limiter: rate.NewLimiter(l.limit, l.burst)}
+		l.buckets[key] = b
+	}
+	b.lastSeen = now
+
+	r := b.limiter.ReserveN(now, 1)
+	if !r.OK() {
+		return false, 0
+	}
+	if delay := r.DelayFrom(now); delay > 0 {
+		r.CancelAt(now)
+		return false, delay
+	}
+	return true, 0
+}
+
+// Len returns the number of tracked buckets.
+func (l *Limiter) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return len(l.buckets)
+}
+
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.idleTimeout {
+		return
+	}
+	for key, b := range l.buckets {
+		if now.Sub(b.lastSeen) >= l.idleTimeout {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
@@ -0,0 +1,66 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimiter(t *testing.T) {
+	now := time.Date(2021, 8, 2, 0, 0, 0, 0, time.UTC)
+	l := New(2, 3, time.Minute)
+	l.now = func() time.Time { return now }
+
+	t.Run("burst then rate", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			ok, _ := l.Allow("user")
+			require.True(t, ok)
+		}
+
+		ok, retryAfter := l.Allow("user")
+		require.False(t, ok)
+		require.Equal(t, 500*time.Millisecond, retryAfter)
+
+		ok, _ = l.Allow("other user")
+		require.True(t, ok)
+
+		now = now.Add(500 * time.Millisecond)
+		ok, _ = l.Allow("user")
+		require.True(t, ok)
+
+		ok, _ = l.Allow("user")
+		require.False(t, ok)
+	})
+
+	t.Run("idle buckets are evicted", func(t *testing.T) {
+		require.Equal(t, 2, l.Len())
+
+		now = now.Add(30 * time.Second)
+		_, _ = l.Allow("user")
+
+		now = now.Add(31 * time.Second)
+		_, _ = l.Allow("new user")
+		require.Equal(t, 2, l.Len())
+
+		now = now.Add(time.Minute)
+		_, _ = l.Allow("new user")
+		require.Equal(t, 1, l.Len())
+	})
+}
+
+func TestDefaultIdleTimeout(t *testing.T) {
+	now := time.Date(2021, 8, 2, 0, 0, 0, 0, time.UTC)
+	l := New(1, 1, 0)
+	l.now = func() time.Time { return now }
+
+	ok, _ := l.Allow("user")
+	require.True(t, ok)
+	now = now.Add(time.Millisecond)
+	ok, _ = l.Allow("user")
+	require.False(t, ok, "the bucket is kept")
+
+	now = now.Add(DefaultIdleTimeout)
+	_, _ = l.Allow("other user")
+	require.Equal(t, 1, l.Len())
+}
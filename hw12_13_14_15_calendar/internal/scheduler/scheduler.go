@@ -0,0 +1,270 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/metrics"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/queue"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/scheduler")
+
+type Logger interface {
+	Info(msg string)
+	Error(msg string)
+}
+
+type Storage interface {
+	PurgeEvents(ctx context.Context, deletedBefore, endedBefore time.Time) (int64, error)
+	PurgeIdempotencyKeys(ctx context.Context, now time.Time) (int64, error)
+	DueNotifications(ctx context.Context, now time.Time) ([]storage.DueReminder, error)
+	MarkNotified(ctx context.Context, id string, reminder storage.Reminder, msg storage.OutboxMessage) error
+	PendingOutbox(ctx context.Context, limit int) ([]storage.OutboxMessage, error)
+	DeleteOutbox(ctx context.Context, id int64) error
+	ListNotificationPreferences(ctx context.Context, userIDs []string) ([]storage.NotificationPreferences, error)
+}
+
+// relayBatch is the number of outbox messages read at once.
+const relayBatch = 100
+
+type Config struct {
+	NotifyInterval time.Duration
+	// RelayInterval is how often messages left in the outbox after failed publishing are retried.
+	RelayInterval time.Duration
+	PurgeInterval time.Duration
+	// Retention is how long soft deleted events are kept before purging.
+	Retention time.Duration
+	// MaxAge is how long events are kept after they ended.
+	MaxAge time.Duration
+}
+
+type Scheduler struct {
+	logger    Logger
+	storage   Storage
+	publisher queue.Publisher
+	config    Config
+}
+
+func New(logger Logger, storage Storage, publisher queue.Publisher, config Config) *Scheduler {
+	return &Scheduler{
+		logger:    logger,
+		storage:   storage,
+		publisher: publisher,
+		config:    config,
+	}
+}
+
+func (s *Scheduler) Run(ctx context.Context) {
+	notifyTicker := time.NewTicker(s.config.NotifyInterval)
+	defer notifyTicker.Stop()
+	relayTicker := time.NewTicker(s.config.RelayInterval)
+	defer relayTicker.Stop()
+	purgeTicker := time.NewTicker(s.config.PurgeInterval)
+	defer purgeTicker.Stop()
+
+	s.Notify(ctx)
+	s.Purge(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-notifyTicker.C:
+			s.Notify(ctx)
+		case <-relayTicker.C:
+			s.Relay(ctx)
+		case <-purgeTicker.C:
+			s.Purge(ctx)
+		}
+	}
+}
+
+// RunPurge only purges every PurgeInterval until ctx is done, it serves storages the scheduler process can't share,
+// e.g. the memory storage of the calendar service. Nothing is published, so the publisher may be nil.
+func (s *Scheduler) RunPurge(ctx context.Context) {
+	ticker := time.NewTicker(s.config.PurgeInterval)
+	defer ticker.Stop()
+
+	s.Purge(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.Purge(ctx)
+		}
+	}
+}
+
+// Notify marks every due reminder sent and adds its notification to the outbox at once, then relays the outbox.
+// A crash between the two steps leaves the notification in the outbox, so it is published on the next relay.
+func (s *Scheduler) Notify(ctx context.Context) {
+	ctx, span := tracer.Start(ctx, "scheduler.notify")
+	defer span.End()
+
+	due, err := s.storage.DueNotifications(ctx, time.Now())
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "unable to select due notifications")
+		s.logger.Error("unable to select due notifications: " + err.Error())
+		return
+	}
+	span.SetAttributes(attribute.Int("notifications.due", len(due)))
+	if len(due) == 0 {
+		s.Relay(ctx)
+		return
+	}
+
+	// the sender formats notifications in the time zone and language of the user's preferences
+	prefs, err := s.preferences(ctx, due)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "unable to select notification preferences")
+		s.logger.Error("unable to select notification preferences: " + err.Error())
+		return
+	}
+
+	for _, d := range due {
+		if err := s.enqueue(ctx, d, prefs[d.Event.UserID]); err != nil {
+			metrics.NotificationsFailed.WithLabelValues("enqueue").Inc()
+			s.logger.Error(fmt.Sprintf("unable to enqueue reminder %s by %s of event %s: %s",
+				d.Reminder.Before, d.Reminder.Channel, d.Event.ID, err))
+		}
+	}
+	s.Relay(ctx)
+}
+
+// preferences loads the notification preferences of the owners of due events at once.
+func (s *Scheduler) preferences(
+	ctx context.Context,
+	due []storage.DueReminder,
+) (map[string]storage.NotificationPreferences, error) {
+	userIDs := make([]string, 0, len(due))
+	seen := make(map[string]struct{}, len(due))
+	for _, d := range due {
+		if _, ok := seen[d.Event.UserID]; !ok {
+			seen[d.Event.UserID] = struct{}{}
+			userIDs = append(userIDs, d.Event.UserID)
+		}
+	}
+
+	list, err := s.storage.ListNotificationPreferences(ctx, userIDs)
+	if err != nil {
+		return nil, err
+	}
+	prefs := make(map[string]storage.NotificationPreferences, len(list))
+	for _, p := range list {
+		prefs[p.UserID] = p
+	}
+	return prefs, nil
+}
+
+// enqueue adds the notification to the outbox with the trace context in message headers,
+// so its delivery is linked to the scan which produced it.
+func (s *Scheduler) enqueue(
+	ctx context.Context,
+	due storage.DueReminder,
+	prefs storage.NotificationPreferences,
+) error {
+	ctx, span := tracer.Start(ctx, "notification.enqueue",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String("event.id", due.Event.ID),
+			attribute.String("notification.channel", string(due.Reminder.Channel))))
+	defer span.End()
+
+	n := queue.Notification{
+		EventID:      due.Event.ID,
+		Title:        due.Event.Title,
+		StartsAt:     due.Event.StartsAt,
+		UserID:       due.Event.UserID,
+		Channel:      string(due.Reminder.Channel),
+		RemindBefore: due.Reminder.Before,
+		TimeZone:     prefs.TimeZone,
+		Locale:       prefs.Locale,
+	}
+
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+
+	msg := storage.OutboxMessage{Body: body, Headers: make(map[string]string)}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.MapCarrier(msg.Headers))
+	err = s.storage.MarkNotified(ctx, due.Event.ID, due.Reminder, msg)
+	if errors.Is(err, storage.ErrAlreadyNotified) {
+		// another scan has enqueued it, e.g. of the previous leader
+		span.SetAttributes(attribute.Bool("notification.duplicate", true))
+		return nil
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "unable to enqueue notification")
+		return err
+	}
+	return nil
+}
+
+// Relay publishes outbox messages in order and deletes every message once the queue has confirmed it.
+// It stops at the first failure to keep the order, the rest is retried on the next run.
+// A message is published again if it fails to be deleted, so the delivery is at least once.
+func (s *Scheduler) Relay(ctx context.Context) {
+	ctx, span := tracer.Start(ctx, "scheduler.relay")
+	defer span.End()
+
+	for {
+		messages, err := s.storage.PendingOutbox(ctx, relayBatch)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "unable to select outbox messages")
+			s.logger.Error("unable to select outbox messages: " + err.Error())
+			return
+		}
+
+		for _, msg := range messages {
+			if err := s.publisher.Publish(ctx, queue.Message{Body: msg.Body, Headers: msg.Headers}); err != nil {
+				metrics.NotificationsFailed.WithLabelValues("publish").Inc()
+				span.RecordError(err)
+				span.SetStatus(codes.Error, "unable to publish notification")
+				s.logger.Error(fmt.Sprintf("unable to publish outbox message %d: %s", msg.ID, err))
+				return
+			}
+			metrics.NotificationsPublished.Inc()
+
+			if err := s.storage.DeleteOutbox(ctx, msg.ID); err != nil {
+				s.logger.Error(fmt.Sprintf("unable to delete outbox message %d: %s", msg.ID, err))
+				return
+			}
+		}
+		if len(messages) < relayBatch {
+			return
+		}
+	}
+}
+
+// Purge hard deletes events soft deleted longer than Retention ago,
+// events ended longer than MaxAge ago and expired idempotency keys.
+func (s *Scheduler) Purge(ctx context.Context) {
+	now := time.Now()
+	purged, err := s.storage.PurgeEvents(ctx, now.Add(-s.config.Retention), now.Add(-s.config.MaxAge))
+	if err != nil {
+		s.logger.Error("unable to purge events: " + err.Error())
+		return
+	}
+	if purged > 0 {
+		s.logger.Info(fmt.Sprintf("purged %d events", purged))
+	}
+
+	if _, err := s.storage.PurgeIdempotencyKeys(ctx, now); err != nil {
+		s.logger.Error("unable to purge idempotency keys: " + err.Error())
+	}
+}
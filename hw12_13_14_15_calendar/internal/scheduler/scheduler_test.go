@@ -0,0 +1,179 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/logger"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/queue"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	memorystorage "github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage/memory"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPurge(t *testing.T) {
+	ctx := context.Background()
+	s := memorystorage.New()
+	now := time.Now()
+
+	for id, startsAt := range map[string]time.Time{
+		"recent":  now,
+		"deleted": now.Add(time.Hour),
+		"old":     now.AddDate(-2, 0, 0),
+	} {
+		_, err := s.CreateEvent(ctx, storage.Event{
+			ID:       id,
+			Title:    id,
+			StartsAt: startsAt,
+			EndsAt:   startsAt.Add(time.Hour),
+			UserID:   "user",
+		}, "user")
+		require.NoError(t, err)
+	}
+	require.NoError(t, s.DeleteEvent(ctx, "deleted", "user", storage.AnyVersion))
+
+	logg := logger.NewWithWriter("ERROR", io.Discard)
+
+	New(logg, s, nil, Config{Retention: time.Hour, MaxAge: 365 * 24 * time.Hour}).Purge(ctx)
+	_, err := s.EventHistory(ctx, "old")
+	require.ErrorIs(t, err, storage.ErrEventNotFound)
+	_, err = s.EventHistory(ctx, "deleted")
+	require.NoError(t, err)
+
+	New(logg, s, nil, Config{MaxAge: 365 * 24 * time.Hour}).Purge(ctx)
+	_, err = s.EventHistory(ctx, "deleted")
+	require.ErrorIs(t, err, storage.ErrEventNotFound)
+
+	_, err = s.GetEvent(ctx, "recent")
+	require.NoError(t, err)
+}
+
+func TestRunPurge(t *testing.T) {
+	s := memorystorage.New()
+	startsAt := time.Now().AddDate(-2, 0, 0)
+	_, err := s.CreateEvent(context.Background(), storage.Event{
+		ID:       "old",
+		Title:    "old",
+		StartsAt: startsAt,
+		EndsAt:   startsAt.Add(time.Hour),
+		UserID:   "user",
+	}, "user")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		New(logger.NewWithWriter("ERROR", io.Discard), s, nil, Config{
+			PurgeInterval: time.Hour,
+			MaxAge:        365 * 24 * time.Hour,
+		}).RunPurge(ctx)
+	}()
+
+	// the first purge runs at once
+	require.Eventually(t, func() bool {
+		_, err := s.EventHistory(context.Background(), "old")
+		return errors.Is(err, storage.ErrEventNotFound)
+	}, time.Second, 10*time.Millisecond)
+	cancel()
+	<-done
+}
+
+type publisher struct {
+	messages []queue.Message
+	err      error
+}
+
+func (p *publisher) Publish(ctx context.Context, msg queue.Message) error {
+	if p.err != nil {
+		return p.err
+	}
+	p.messages = append(p.messages, msg)
+	return nil
+}
+
+func TestNotify(t *testing.T) {
+	ctx := context.Background()
+	s := memorystorage.New()
+	now := time.Now()
+
+	for _, e := range []struct {
+		id        string
+		startsAt  time.Time
+		reminders []storage.Reminder
+	}{
+		{"due", now.Add(time.Hour), []storage.Reminder{
+			{Before: 2 * time.Hour, Channel: storage.ChannelWebhook},
+			{Before: 90 * time.Minute, Channel: storage.ChannelLog},
+			{Before: 30 * time.Minute, Channel: storage.ChannelLog},
+		}},
+		{"later", now.Add(48 * time.Hour), []storage.Reminder{{Before: 30 * time.Minute, Channel: storage.ChannelLog}}},
+		{"disabled", now.Add(2 * time.Hour), nil},
+	} {
+		_, err := s.CreateEvent(ctx, storage.Event{
+			ID:        e.id,
+			Title:     e.id,
+			StartsAt:  e.startsAt,
+			EndsAt:    e.startsAt.Add(time.Hour),
+			UserID:    "user",
+			Reminders: e.reminders,
+		}, "user")
+		require.NoError(t, err)
+	}
+	_, err := s.SetNotificationPreferences(ctx, storage.NotificationPreferences{
+		UserID: "user", TimeZone: "Europe/Moscow", Locale: "ru",
+	})
+	require.NoError(t, err)
+
+	logg := logger.NewWithWriter("ERROR", io.Discard)
+
+	t.Run("publish failure is relayed later", func(t *testing.T) {
+		New(logg, s, &publisher{err: errors.New("broken")}, Config{}).Notify(ctx)
+
+		due, err := s.DueNotifications(ctx, time.Now())
+		require.NoError(t, err)
+		require.Empty(t, due, "reminders are marked together with adding them to the outbox")
+		outbox, err := s.PendingOutbox(ctx, 10)
+		require.NoError(t, err)
+		require.Len(t, outbox, 2)
+	})
+
+	t.Run("outbox is published once", func(t *testing.T) {
+		p := &publisher{}
+		New(logg, s, p, Config{}).Relay(ctx)
+		New(logg, s, p, Config{}).Notify(ctx)
+		require.Len(t, p.messages, 2)
+
+		outbox, err := s.PendingOutbox(ctx, 10)
+		require.NoError(t, err)
+		require.Empty(t, outbox)
+
+		var n queue.Notification
+		require.NoError(t, json.Unmarshal(p.messages[0].Body, &n))
+		require.Equal(t, "due", n.EventID)
+		require.Equal(t, "user", n.UserID)
+		require.Equal(t, "webhook", n.Channel)
+		require.Equal(t, 2*time.Hour, n.RemindBefore)
+		require.Equal(t, "Europe/Moscow", n.TimeZone)
+		require.Equal(t, "ru", n.Locale)
+
+		require.NoError(t, json.Unmarshal(p.messages[1].Body, &n))
+		require.Equal(t, "log", n.Channel)
+		require.Equal(t, 90*time.Minute, n.RemindBefore)
+	})
+
+	t.Run("overlapping scans notify once", func(t *testing.T) {
+		event, err := s.GetEvent(ctx, "due")
+		require.NoError(t, err)
+		due := storage.DueReminder{Event: event, Reminder: event.Reminders[0]}
+		require.NoError(t, New(logg, s, &publisher{}, Config{}).enqueue(ctx, due, storage.NotificationPreferences{}))
+
+		outbox, err := s.PendingOutbox(ctx, 10)
+		require.NoError(t, err)
+		require.Empty(t, outbox)
+	})
+}
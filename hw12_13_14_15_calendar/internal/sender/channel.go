@@ -0,0 +1,73 @@
+package sender
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/queue"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// Channel delivers notifications to users, text is the message rendered from the channel template.
+type Channel interface {
+	Deliver(ctx context.Context, n queue.Notification, text string) error
+}
+
+// LogChannel writes notifications to the log.
+type LogChannel struct {
+	logger Logger
+}
+
+func NewLogChannel(logger Logger) *LogChannel {
+	return &LogChannel{logger: logger}
+}
+
+func (c *LogChannel) Deliver(ctx context.Context, n queue.Notification, text string) error {
+	c.logger.Info(fmt.Sprintf("notification for user %s: %s", n.UserID, text))
+	return nil
+}
+
+// WebhookChannel posts notifications as JSON to the URL, with the text in the "message" field.
+type WebhookChannel struct {
+	url    string
+	client *http.Client
+}
+
+func NewWebhookChannel(url string, timeout time.Duration) *WebhookChannel {
+	return &WebhookChannel{
+		url: url,
+		client: &http.Client{
+			Timeout:   timeout,
+			Transport: otelhttp.NewTransport(http.DefaultTransport),
+		},
+	}
+}
+
+func (c *WebhookChannel) Deliver(ctx context.Context, n queue.Notification, text string) error {
+	body, err := json.Marshal(struct {
+		queue.Notification
+		Message string `json:"message"`
+	}{Notification: n, Message: text})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("unable to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("unable to call webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package sender
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/metrics"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/queue"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/sender")
+
+type Logger interface {
+	Info(msg string)
+	Error(msg string)
+}
+
+// Sender delivers notifications consumed from the queue through the channel of their reminders.
+type Sender struct {
+	logger   Logger
+	consumer queue.Consumer
+	// status receives delivery statuses if it is not nil.
+	status    queue.Publisher
+	channels  map[string]Channel
+	templates *Templates
+}
+
+// New creates a sender delivering notifications through the channels by name,
+// the "log" channel writing to the logger is added unless given.
+// Messages are built in if templates are nil.
+func New(
+	logger Logger,
+	consumer queue.Consumer,
+	status queue.Publisher,
+	channels map[string]Channel,
+	templates *Templates,
+) *Sender {
+	if templates == nil {
+		templates = newTemplates("", time.UTC)
+	}
+	s := &Sender{
+		logger:    logger,
+		consumer:  consumer,
+		status:    status,
+		channels:  map[string]Channel{"log": NewLogChannel(logger)},
+		templates: templates,
+	}
+	for name, channel := range channels {
+		s.channels[name] = channel
+	}
+	return s
+}
+
+func (s *Sender) Run(ctx context.Context) error {
+	return s.consumer.Consume(ctx, s.Send)
+}
+
+// Send delivers the notification in a span continuing the trace of the scheduler which published it.
+func (s *Sender) Send(ctx context.Context, msg queue.Message) error {
+	ctx = otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(msg.Headers))
+	ctx, span := tracer.Start(ctx, "notification.send", trace.WithSpanKind(trace.SpanKindConsumer))
+	defer span.End()
+
+	var n queue.Notification
+	if err := json.Unmarshal(msg.Body, &n); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "unable to decode notification")
+		metrics.NotificationsFailed.WithLabelValues("send").Inc()
+		s.logger.Error("unable to decode notification: " + err.Error())
+		return err
+	}
+
+	// notifications published before reminders had channels are logged
+	if n.Channel == "" {
+		n.Channel = "log"
+	}
+	span.SetAttributes(attribute.String("event.id", n.EventID), attribute.String("notification.channel", n.Channel))
+
+	channel, ok := s.channels[n.Channel]
+	if !ok {
+		err := fmt.Errorf("unknown channel %q", n.Channel)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "unknown channel")
+		metrics.NotificationsFailed.WithLabelValues("send").Inc()
+		s.logger.Error(fmt.Sprintf("unable to send notification for event %s: %s", n.EventID, err))
+		return err
+	}
+	text, err := s.templates.Render(n)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "unable to render notification")
+		metrics.NotificationsFailed.WithLabelValues("send").Inc()
+		s.logger.Error(fmt.Sprintf("unable to render notification for event %s: %s", n.EventID, err))
+		return err
+	}
+	if err := channel.Deliver(ctx, n, text); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "unable to deliver notification")
+		metrics.NotificationsFailed.WithLabelValues("send").Inc()
+		s.logger.Error(fmt.Sprintf("unable to send notification for event %s by %s: %s", n.EventID, n.Channel, err))
+		return err
+	}
+	metrics.NotificationsSent.WithLabelValues(n.Channel).Inc()
+
+	if s.status != nil {
+		if err := s.publishStatus(ctx, n); err != nil {
+			s.logger.Error(fmt.Sprintf("unable to publish status of event %s notification: %s", n.EventID, err))
+		}
+	}
+	return nil
+}
+
+func (s *Sender) publishStatus(ctx context.Context, n queue.Notification) error {
+	body, err := json.Marshal(queue.Status{
+		EventID: n.EventID,
+		UserID:  n.UserID,
+		Channel: n.Channel,
+		Status:  queue.StatusSent,
+		At:      time.Now().UTC(),
+	})
+	if err != nil {
+		return err
+	}
+
+	msg := queue.Message{Body: body, Headers: make(map[string]string)}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.MapCarrier(msg.Headers))
+	return s.status.Publish(ctx, msg)
+}
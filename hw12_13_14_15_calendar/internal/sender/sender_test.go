@@ -0,0 +1,114 @@
+package sender
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/logger"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/queue"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+func TestSend(t *testing.T) {
+	var buf bytes.Buffer
+	s := New(logger.NewWithWriter("INFO", &buf), nil, nil, nil, nil)
+
+	body, err := json.Marshal(queue.Notification{
+		EventID:  "1",
+		Title:    "meeting",
+		StartsAt: time.Date(2021, 8, 2, 10, 0, 0, 0, time.UTC),
+		UserID:   "user",
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, s.Send(context.Background(), queue.Message{Body: body}))
+	require.Contains(t, buf.String(), `notification for user user: event "meeting" starts at 2021-08-02T10:00:00Z`)
+
+	require.Error(t, s.Send(context.Background(), queue.Message{Body: []byte("{")}))
+}
+
+type statusPublisher struct {
+	messages []queue.Message
+}
+
+func (p *statusPublisher) Publish(ctx context.Context, msg queue.Message) error {
+	p.messages = append(p.messages, msg)
+	return nil
+}
+
+func TestSendWebhook(t *testing.T) {
+	type webhookBody struct {
+		queue.Notification
+		Message string `json:"message"`
+	}
+	received := make(chan webhookBody, 1)
+	var failing atomic.Bool
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing.Load() {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		var body webhookBody
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		received <- body
+	}))
+	t.Cleanup(ts.Close)
+
+	status := &statusPublisher{}
+	s := New(logger.NewWithWriter("ERROR", io.Discard), nil, status, map[string]Channel{
+		"webhook": NewWebhookChannel(ts.URL, time.Second),
+	}, nil)
+	send := func(channel string) error {
+		body, err := json.Marshal(queue.Notification{EventID: "1", Title: "meeting", UserID: "user", Channel: channel})
+		require.NoError(t, err)
+		return s.Send(context.Background(), queue.Message{Body: body})
+	}
+
+	require.NoError(t, send("webhook"))
+	body := <-received
+	require.Equal(t, "1", body.EventID)
+	require.Equal(t, `event "meeting" starts at 0001-01-01T00:00:00Z`, body.Message)
+	require.Len(t, status.messages, 1)
+	var st queue.Status
+	require.NoError(t, json.Unmarshal(status.messages[0].Body, &st))
+	require.Equal(t, "webhook", st.Channel)
+
+	failing.Store(true)
+	require.Error(t, send("webhook"))
+	require.Error(t, send("pigeon"))
+	require.Len(t, status.messages, 1, "failed deliveries have no status")
+}
+
+func TestSendContinuesTrace(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { otel.SetTracerProvider(noop.NewTracerProvider()) })
+
+	ctx, publish := provider.Tracer("test").Start(context.Background(), "notification.publish")
+	msg := queue.Message{Body: []byte(`{"event_id":"1"}`), Headers: make(map[string]string)}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.MapCarrier(msg.Headers))
+	publish.End()
+
+	s := New(logger.NewWithWriter("ERROR", io.Discard), nil, nil, nil, nil)
+	require.NoError(t, s.Send(context.Background(), msg))
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	require.Equal(t, "notification.send", spans[1].Name())
+	require.Equal(t, publish.SpanContext().TraceID(), spans[1].SpanContext().TraceID())
+	require.Equal(t, publish.SpanContext().SpanID(), spans[1].Parent().SpanID())
+}
@@ -0,0 +1,174 @@
+package sender
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/queue"
+)
+
+const templateExt = ".tmpl"
+
+type TemplateOptions struct {
+	// Dir has templates named <channel>.<locale>.tmpl, e.g. log.en.tmpl or webhook.pt-BR.tmpl.
+	// Messages of channels without templates are built in.
+	Dir string
+	// Locale is used for users without a locale, every channel with templates must have one of it.
+	Locale string
+	// TimeZone is used for users without a time zone, UTC if empty.
+	TimeZone string
+}
+
+// TemplateData is what templates are executed with.
+type TemplateData struct {
+	EventID string
+	Title   string
+	UserID  string
+	// StartsAt is in the time zone of the user.
+	StartsAt     time.Time
+	RemindBefore time.Duration
+	Locale       string
+}
+
+// Templates render notification messages in the locale and time zone of their users.
+type Templates struct {
+	locale   string
+	location *time.Location
+	// byChannel has templates of every channel by lower case locale.
+	byChannel map[string]map[string]*template.Template
+
+	mu        sync.Mutex
+	locations map[string]*time.Location
+}
+
+// LoadTemplates parses the templates and executes them once, so errors are found before the first message.
+func LoadTemplates(opts TemplateOptions) (*Templates, error) {
+	location, err := time.LoadLocation(opts.TimeZone)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load time zone %q: %w", opts.TimeZone, err)
+	}
+	t := newTemplates(opts.Locale, location)
+	if opts.Dir == "" {
+		return t, nil
+	}
+
+	entries, err := os.ReadDir(opts.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read templates: %w", err)
+	}
+	sample := TemplateData{
+		EventID:      "event",
+		Title:        "Meeting",
+		UserID:       "user",
+		StartsAt:     time.Now().In(location),
+		RemindBefore: time.Hour,
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), templateExt) {
+			continue
+		}
+		channel, locale, ok := strings.Cut(strings.TrimSuffix(entry.Name(), templateExt), ".")
+		if !ok || channel == "" || locale == "" {
+			return nil, fmt.Errorf("invalid template name %q, expected <channel>.<locale>%s", entry.Name(), templateExt)
+		}
+		text, err := os.ReadFile(filepath.Join(opts.Dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("unable to read template: %w", err)
+		}
+		tmpl, err := template.New(entry.Name()).Option("missingkey=error").Parse(string(text))
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse template: %w", err)
+		}
+		sample.Locale = locale
+		if err := tmpl.Execute(io.Discard, sample); err != nil {
+			return nil, fmt.Errorf("unable to execute template: %w", err)
+		}
+
+		if t.byChannel[channel] == nil {
+			t.byChannel[channel] = make(map[string]*template.Template)
+		}
+		t.byChannel[channel][strings.ToLower(locale)] = tmpl
+	}
+	for channel, templates := range t.byChannel {
+		if _, ok := templates[t.locale]; !ok {
+			return nil, fmt.Errorf("channel %q has no template of the default locale %q", channel, opts.Locale)
+		}
+	}
+	return t, nil
+}
+
+func newTemplates(locale string, location *time.Location) *Templates {
+	return &Templates{
+		locale:    strings.ToLower(locale),
+		location:  location,
+		byChannel: make(map[string]map[string]*template.Template),
+		locations: make(map[string]*time.Location),
+	}
+}
+
+// Render formats the notification with the template of its channel in the user's locale,
+// falling back to the base language, e.g. "pt" for "pt-BR", and then to the default locale.
+func (t *Templates) Render(n queue.Notification) (string, error) {
+	data := TemplateData{
+		EventID:      n.EventID,
+		Title:        n.Title,
+		UserID:       n.UserID,
+		StartsAt:     n.StartsAt.In(t.userLocation(n.TimeZone)),
+		RemindBefore: n.RemindBefore,
+		Locale:       n.Locale,
+	}
+	if data.Locale == "" {
+		data.Locale = t.locale
+	}
+
+	tmpl := t.lookup(n.Channel, strings.ToLower(data.Locale))
+	if tmpl == nil {
+		return fmt.Sprintf("event %q starts at %s", data.Title, data.StartsAt.Format(time.RFC3339)), nil
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("unable to execute template: %w", err)
+	}
+	return strings.TrimSpace(b.String()), nil
+}
+
+func (t *Templates) lookup(channel, locale string) *template.Template {
+	templates := t.byChannel[channel]
+	if templates == nil {
+		return nil
+	}
+	if tmpl, ok := templates[locale]; ok {
+		return tmpl
+	}
+	if base, _, ok := strings.Cut(locale, "-"); ok {
+		if tmpl, ok := templates[base]; ok {
+			return tmpl
+		}
+	}
+	return templates[t.locale]
+}
+
+// userLocation returns the default location for an empty or unknown time zone.
+func (t *Templates) userLocation(name string) *time.Location {
+	if name == "" {
+		return t.location
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if location, ok := t.locations[name]; ok {
+		return location
+	}
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		location = t.location
+	}
+	t.locations[name] = location
+	return location
+}
@@ -0,0 +1,77 @@
+package sender
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/queue"
+	"github.com/stretchr/testify/require"
+)
+
+func writeTemplates(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, text := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(text), 0o600))
+	}
+	return dir
+}
+
+func TestTemplates(t *testing.T) {
+	dir := writeTemplates(t, map[string]string{
+		"log.en.tmpl":    `{{.Title}} at {{.StartsAt.Format "Jan 2 15:04 MST"}}` + "\n",
+		"log.ru.tmpl":    `{{.Title}} в {{.StartsAt.Format "02.01 15:04"}}`,
+		"log.pt-BR.tmpl": `{{.Title}} às {{.StartsAt.Format "02/01 15:04"}} ({{.Locale}})`,
+		"README.md":      "not a template",
+	})
+	templates, err := LoadTemplates(TemplateOptions{Dir: dir, Locale: "en", TimeZone: "UTC"})
+	require.NoError(t, err)
+
+	n := queue.Notification{Title: "Meeting", StartsAt: time.Date(2021, 8, 2, 10, 0, 0, 0, time.UTC), Channel: "log"}
+	for _, c := range []struct {
+		locale, timeZone, text string
+	}{
+		{"", "", "Meeting at Aug 2 10:00 UTC"},
+		{"ru", "Europe/Moscow", "Meeting в 02.08 13:00"},
+		{"ru-RU", "Europe/Moscow", "Meeting в 02.08 13:00"},
+		{"pt-br", "America/Sao_Paulo", "Meeting às 02/08 07:00 (pt-br)"},
+		{"de", "Europe/Berlin", "Meeting at Aug 2 12:00 CEST"},
+		{"en", "Mars/Olympus", "Meeting at Aug 2 10:00 UTC"},
+	} {
+		n.Locale, n.TimeZone = c.locale, c.timeZone
+		text, err := templates.Render(n)
+		require.NoError(t, err)
+		require.Equal(t, c.text, text, "locale %q", c.locale)
+	}
+
+	// channels without templates have built-in messages
+	n.Channel, n.TimeZone = "webhook", "Europe/Moscow"
+	text, err := templates.Render(n)
+	require.NoError(t, err)
+	require.Equal(t, `event "Meeting" starts at 2021-08-02T13:00:00+03:00`, text)
+}
+
+func TestLoadTemplatesErrors(t *testing.T) {
+	for name, files := range map[string]map[string]string{
+		"syntax":         {"log.en.tmpl": "{{.Title"},
+		"unknown field":  {"log.en.tmpl": "{{.Name}}"},
+		"unknown method": {"log.en.tmpl": `{{.StartsAt.Print "15:04"}}`},
+		"no locale":      {"log.tmpl": "{{.Title}}"},
+		"no default":     {"log.en.tmpl": "{{.Title}}", "webhook.ru.tmpl": "{{.Title}}"},
+	} {
+		_, err := LoadTemplates(TemplateOptions{Dir: writeTemplates(t, files), Locale: "en"})
+		require.Error(t, err, name)
+	}
+
+	_, err := LoadTemplates(TemplateOptions{Dir: filepath.Join(t.TempDir(), "missing"), Locale: "en"})
+	require.Error(t, err)
+	_, err = LoadTemplates(TemplateOptions{Locale: "en", TimeZone: "Mars/Olympus"})
+	require.Error(t, err)
+}
+
+func TestExampleTemplates(t *testing.T) {
+	_, err := LoadTemplates(TemplateOptions{Dir: "../../configs/templates", Locale: "en", TimeZone: "UTC"})
+	require.NoError(t, err)
+}
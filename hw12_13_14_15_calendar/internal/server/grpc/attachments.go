@@ -0,0 +1,158 @@
+package internalgrpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/app"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/pkg/eventpb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// downloadChunkSize is the content size of one download message, far below the default message limit.
+const downloadChunkSize = 64 << 10
+
+func (s *Server) UploadAttachment(stream eventpb.EventService_UploadAttachmentServer) error {
+	ctx := stream.Context()
+	userID, err := requireUserID(ctx)
+	if err != nil {
+		return err
+	}
+
+	first, err := stream.Recv()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return status.Error(codes.InvalidArgument, "info is required")
+		}
+		return err
+	}
+	info := first.GetInfo()
+	if info == nil {
+		return status.Error(codes.InvalidArgument, "the first message must be the info")
+	}
+
+	attachment, err := s.app.AddAttachment(
+		ctx, info.GetEventId(), userID, info.GetName(), info.GetContentType(), &uploadReader{stream: stream})
+	if err != nil {
+		if ctx.Err() != nil {
+			return status.FromContextError(ctx.Err()).Err()
+		}
+		return s.toStatus(err)
+	}
+	return stream.SendAndClose(attachmentToProto(attachment))
+}
+
+func (s *Server) ListAttachments(
+	ctx context.Context,
+	req *eventpb.ListAttachmentsRequest,
+) (*eventpb.ListAttachmentsResponse, error) {
+	userID, err := requireUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	attachments, err := s.app.ListAttachments(ctx, req.GetEventId(), userID)
+	if err != nil {
+		return nil, s.toStatus(err)
+	}
+
+	resp := &eventpb.ListAttachmentsResponse{Attachments: make([]*eventpb.Attachment, 0, len(attachments))}
+	for _, attachment := range attachments {
+		resp.Attachments = append(resp.Attachments, attachmentToProto(attachment))
+	}
+	return resp, nil
+}
+
+func (s *Server) DownloadAttachment(
+	req *eventpb.DownloadAttachmentRequest,
+	stream eventpb.EventService_DownloadAttachmentServer,
+) error {
+	ctx := stream.Context()
+	userID, err := requireUserID(ctx)
+	if err != nil {
+		return err
+	}
+
+	attachment, content, err := s.app.OpenAttachment(ctx, req.GetEventId(), req.GetId(), userID)
+	if err != nil {
+		return s.toStatus(err)
+	}
+	defer content.Close()
+
+	err = stream.Send(&eventpb.DownloadAttachmentResponse{
+		Data: &eventpb.DownloadAttachmentResponse_Attachment{Attachment: attachmentToProto(attachment)},
+	})
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, downloadChunkSize)
+	for {
+		n, err := content.Read(buf)
+		if n > 0 {
+			chunk := &eventpb.DownloadAttachmentResponse{
+				Data: &eventpb.DownloadAttachmentResponse_Chunk{Chunk: buf[:n]},
+			}
+			if err := stream.Send(chunk); err != nil {
+				return err
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return s.toStatus(fmt.Errorf("unable to read attachment: %w", err))
+		}
+	}
+}
+
+func (s *Server) DeleteAttachment(ctx context.Context, req *eventpb.DeleteAttachmentRequest) (*emptypb.Empty, error) {
+	userID, err := requireUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.app.DeleteAttachment(ctx, req.GetEventId(), req.GetId(), userID); err != nil {
+		return nil, s.toStatus(err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// uploadReader reads the content from the chunks following the info message until the client closes the stream.
+type uploadReader struct {
+	stream eventpb.EventService_UploadAttachmentServer
+	chunk  []byte
+}
+
+func (r *uploadReader) Read(p []byte) (int, error) {
+	for len(r.chunk) == 0 {
+		req, err := r.stream.Recv()
+		if err != nil {
+			return 0, err
+		}
+		if req.GetInfo() != nil {
+			return 0, fmt.Errorf("%w: info must be sent once, before the content", app.ErrInvalidAttachment)
+		}
+		r.chunk = req.GetChunk()
+	}
+	n := copy(p, r.chunk)
+	r.chunk = r.chunk[n:]
+	return n, nil
+}
+
+func attachmentToProto(a storage.Attachment) *eventpb.Attachment {
+	return &eventpb.Attachment{
+		Id:          a.ID,
+		EventId:     a.EventID,
+		Name:        a.Name,
+		ContentType: a.ContentType,
+		Size:        a.Size,
+		UserId:      a.UserID,
+		CreatedAt:   timestamppb.New(a.CreatedAt),
+	}
+}
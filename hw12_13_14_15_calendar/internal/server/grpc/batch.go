@@ -0,0 +1,90 @@
+package internalgrpc
+
+import (
+	"context"
+	"errors"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/pkg/eventpb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	errExpectedVersionRequired = errors.New("expected_version is required")
+	errOpRequired              = errors.New("one of create, update, delete is required")
+)
+
+func (s *Server) Batch(ctx context.Context, req *eventpb.BatchRequest) (*eventpb.BatchResponse, error) {
+	userID, err := requireUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]storage.BatchItem, 0, len(req.GetItems()))
+	for i, item := range req.GetItems() {
+		batchItem, err := batchItemFromProto(item)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "item %d: %v", i, err)
+		}
+		items = append(items, batchItem)
+	}
+
+	results, err := s.app.ApplyBatch(ctx, items, userID, req.GetAtomic())
+	if err != nil {
+		return nil, s.toStatus(err)
+	}
+
+	resp := &eventpb.BatchResponse{Results: make([]*eventpb.BatchResult, 0, len(results))}
+	for i, result := range results {
+		resp.Results = append(resp.Results, s.batchResultToProto(items[i].Op, result))
+	}
+	return resp, nil
+}
+
+func batchItemFromProto(item *eventpb.BatchItem) (storage.BatchItem, error) {
+	switch {
+	case item.GetCreate() != nil:
+		event := fromProto(item.GetCreate())
+		event.ID = ""
+		return storage.BatchItem{Op: storage.BatchCreate, Event: event}, nil
+	case item.GetUpdate() != nil:
+		update := item.GetUpdate()
+		if update.GetExpectedVersion() == 0 {
+			return storage.BatchItem{}, errExpectedVersionRequired
+		}
+		return storage.BatchItem{
+			Op:              storage.BatchUpdate,
+			Event:           fromProto(update.GetEvent()),
+			ExpectedVersion: update.GetExpectedVersion(),
+		}, nil
+	case item.GetDelete() != nil:
+		remove := item.GetDelete()
+		if remove.GetExpectedVersion() == 0 {
+			return storage.BatchItem{}, errExpectedVersionRequired
+		}
+		return storage.BatchItem{
+			Op:              storage.BatchDelete,
+			Event:           storage.Event{ID: remove.GetId()},
+			ExpectedVersion: remove.GetExpectedVersion(),
+		}, nil
+	}
+	return storage.BatchItem{}, errOpRequired
+}
+
+func (s *Server) batchResultToProto(op storage.BatchOp, result storage.BatchResult) *eventpb.BatchResult {
+	if result.Err != nil {
+		st := status.Convert(s.toStatus(result.Err))
+		return &eventpb.BatchResult{
+			Code:  int32(st.Code()),
+			Error: st.Message(),
+			Event: &eventpb.Event{Id: result.Event.ID},
+		}
+	}
+	if op == storage.BatchDelete {
+		return &eventpb.BatchResult{Event: &eventpb.Event{Id: result.Event.ID}}
+	}
+	event := toProto(result.Event)
+	event.Warnings = result.Warnings
+	return &eventpb.BatchResult{Event: event}
+}
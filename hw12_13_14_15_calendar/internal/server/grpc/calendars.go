@@ -0,0 +1,97 @@
+package internalgrpc
+
+import (
+	"context"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/pkg/eventpb"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func (s *Server) CreateCalendar(ctx context.Context, req *eventpb.CreateCalendarRequest) (*eventpb.Calendar, error) {
+	userID, err := requireUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	calendar, err := s.app.CreateCalendar(ctx, userID, req.GetName())
+	if err != nil {
+		return nil, s.toStatus(err)
+	}
+	return calendarToProto(calendar), nil
+}
+
+func (s *Server) GetCalendar(ctx context.Context, req *eventpb.GetCalendarRequest) (*eventpb.Calendar, error) {
+	userID, err := requireUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	calendar, err := s.app.GetCalendar(ctx, req.GetId(), userID)
+	if err != nil {
+		return nil, s.toStatus(err)
+	}
+	return calendarToProto(calendar), nil
+}
+
+func (s *Server) ListCalendars(
+	ctx context.Context,
+	req *eventpb.ListCalendarsRequest,
+) (*eventpb.ListCalendarsResponse, error) {
+	userID, err := requireUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	calendars, err := s.app.ListCalendars(ctx, userID)
+	if err != nil {
+		return nil, s.toStatus(err)
+	}
+
+	resp := &eventpb.ListCalendarsResponse{Calendars: make([]*eventpb.Calendar, 0, len(calendars))}
+	for _, calendar := range calendars {
+		resp.Calendars = append(resp.Calendars, calendarToProto(calendar))
+	}
+	return resp, nil
+}
+
+func (s *Server) ShareCalendar(ctx context.Context, req *eventpb.ShareCalendarRequest) (*eventpb.Calendar, error) {
+	userID, err := requireUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	calendar, err := s.app.ShareCalendar(
+		ctx, req.GetId(), userID, req.GetShare().GetUserId(), storage.Access(req.GetShare().GetAccess()))
+	if err != nil {
+		return nil, s.toStatus(err)
+	}
+	return calendarToProto(calendar), nil
+}
+
+func (s *Server) UnshareCalendar(ctx context.Context, req *eventpb.UnshareCalendarRequest) (*emptypb.Empty, error) {
+	userID, err := requireUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.app.UnshareCalendar(ctx, req.GetId(), userID, req.GetUserId()); err != nil {
+		return nil, s.toStatus(err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func calendarToProto(c storage.Calendar) *eventpb.Calendar {
+	shares := make([]*eventpb.Share, 0, len(c.Shares))
+	for _, share := range c.Shares {
+		shares = append(shares, &eventpb.Share{UserId: share.UserID, Access: string(share.Access)})
+	}
+	return &eventpb.Calendar{
+		Id:        c.ID,
+		OwnerId:   c.OwnerID,
+		Name:      c.Name,
+		Shares:    shares,
+		CreatedAt: timestamppb.New(c.CreatedAt),
+	}
+}
@@ -0,0 +1,350 @@
+package internalgrpc
+
+import (
+	"context"
+	"errors"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/app"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/auth"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/markdown"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/pkg/eventpb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	userIDKey         = "x-user-id"
+	idempotencyKeyKey = "idempotency-key"
+)
+
+func (s *Server) CreateEvent(ctx context.Context, req *eventpb.CreateEventRequest) (*eventpb.Event, error) {
+	userID, err := requireUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	event, warnings, err := s.app.CreateEvent(
+		ctx, fromProto(req.GetEvent()), userID, metadataValue(ctx, idempotencyKeyKey))
+	if err != nil {
+		return nil, s.toStatus(err)
+	}
+	resp := toProto(event)
+	resp.Warnings = warnings
+	return resp, nil
+}
+
+func (s *Server) GetEvent(ctx context.Context, req *eventpb.GetEventRequest) (*eventpb.Event, error) {
+	userID, err := requireUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	event, err := s.app.GetEvent(ctx, req.GetId(), userID)
+	if err != nil {
+		return nil, s.toStatus(err)
+	}
+	resp := toProto(event)
+	if req.GetRenderDescription() {
+		if resp.DescriptionHtml, err = markdown.Render(event.Description); err != nil {
+			return nil, s.toStatus(err)
+		}
+	}
+	return resp, nil
+}
+
+func (s *Server) UpdateEvent(ctx context.Context, req *eventpb.UpdateEventRequest) (*eventpb.Event, error) {
+	userID, err := requireUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if req.GetExpectedVersion() <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "expected_version is required")
+	}
+
+	event, warnings, err := s.app.UpdateEvent(ctx, fromProto(req.GetEvent()), userID, req.GetExpectedVersion())
+	if err != nil {
+		return nil, s.toStatus(err)
+	}
+	resp := toProto(event)
+	resp.Warnings = warnings
+	return resp, nil
+}
+
+func (s *Server) DeleteEvent(ctx context.Context, req *eventpb.DeleteEventRequest) (*emptypb.Empty, error) {
+	userID, err := requireUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if req.GetExpectedVersion() <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "expected_version is required")
+	}
+	if err := s.app.DeleteEvent(ctx, req.GetId(), userID, req.GetExpectedVersion()); err != nil {
+		return nil, s.toStatus(err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (s *Server) RestoreEvent(ctx context.Context, req *eventpb.RestoreEventRequest) (*eventpb.Event, error) {
+	userID, err := requireUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if req.GetExpectedVersion() < 0 {
+		return nil, status.Error(codes.InvalidArgument, "expected_version is negative")
+	}
+
+	event, err := s.app.RestoreEvent(ctx, req.GetId(), userID, req.GetExpectedVersion())
+	if err != nil {
+		return nil, s.toStatus(err)
+	}
+	return toProto(event), nil
+}
+
+func (s *Server) GetEventHistory(
+	ctx context.Context,
+	req *eventpb.GetEventHistoryRequest,
+) (*eventpb.GetEventHistoryResponse, error) {
+	userID, err := requireUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	history, err := s.app.EventHistory(ctx, req.GetId(), userID)
+	if err != nil {
+		return nil, s.toStatus(err)
+	}
+
+	resp := &eventpb.GetEventHistoryResponse{History: make([]*eventpb.Change, 0, len(history))}
+	for _, change := range history {
+		resp.History = append(resp.History, changeToProto(change))
+	}
+	return resp, nil
+}
+
+func (s *Server) ListEvents(ctx context.Context, req *eventpb.ListEventsRequest) (*eventpb.ListEventsResponse, error) {
+	userID, err := requireUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if req.GetDate() == nil {
+		return nil, status.Error(codes.InvalidArgument, "date is required")
+	}
+	date := req.GetDate().AsTime()
+
+	opts := app.ListOptions{
+		Cursor:          req.GetCursor(),
+		PageSize:        int(req.GetPageSize()),
+		CalendarID:      req.GetCalendarId(),
+		Title:           req.GetTitle(),
+		HasNotification: req.HasNotification,
+	}
+	if req.GetUpdatedSince() != nil {
+		opts.UpdatedSince = req.GetUpdatedSince().AsTime()
+	}
+
+	var page app.Page
+	switch req.GetPeriod() {
+	case eventpb.Period_PERIOD_DAY:
+		page, err = s.app.ListDayEvents(ctx, userID, date, opts)
+	case eventpb.Period_PERIOD_WEEK:
+		page, err = s.app.ListWeekEvents(ctx, userID, date, opts)
+	case eventpb.Period_PERIOD_MONTH:
+		page, err = s.app.ListMonthEvents(ctx, userID, date, opts)
+	default:
+		return nil, status.Error(codes.InvalidArgument, "period is required")
+	}
+	if err != nil {
+		return nil, s.toStatus(err)
+	}
+
+	return &eventpb.ListEventsResponse{
+		Events:     toProtoList(page.Events),
+		NextCursor: page.NextCursor,
+	}, nil
+}
+
+func (s *Server) Search(ctx context.Context, req *eventpb.SearchRequest) (*eventpb.SearchResponse, error) {
+	userID, err := requireUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	page, err := s.app.SearchEvents(ctx, userID, req.GetQuery(), app.SearchOptions{
+		Cursor:     req.GetCursor(),
+		PageSize:   int(req.GetPageSize()),
+		CalendarID: req.GetCalendarId(),
+	})
+	if err != nil {
+		return nil, s.toStatus(err)
+	}
+	return &eventpb.SearchResponse{
+		Events:     toProtoList(page.Events),
+		NextCursor: page.NextCursor,
+	}, nil
+}
+
+func (s *Server) Watch(req *eventpb.WatchRequest, stream eventpb.EventService_WatchServer) error {
+	ctx := stream.Context()
+	userID, err := requireUserID(ctx)
+	if err != nil {
+		return err
+	}
+	afterID := req.GetAfterId()
+	switch {
+	case afterID < 0:
+		return status.Error(codes.InvalidArgument, "after_id is negative")
+	case req.AfterId == nil:
+		if afterID, err = s.app.LatestChangeID(ctx); err != nil {
+			return s.toStatus(err)
+		}
+	}
+	// the headers tell the client that changes from now on are watched
+	if err := stream.SendHeader(metadata.MD{}); err != nil {
+		return err
+	}
+
+	err = s.app.WatchChanges(ctx, userID, afterID, func(entry app.FeedEntry) error {
+		resp := &eventpb.WatchResponse{Change: changeToProto(entry.Change)}
+		if entry.Event.ID != "" {
+			resp.Event = toProto(entry.Event)
+		}
+		return stream.Send(resp)
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			return status.FromContextError(ctx.Err()).Err()
+		}
+		return s.toStatus(err)
+	}
+	return nil
+}
+
+func (s *Server) PreviewPurge(
+	ctx context.Context,
+	req *eventpb.PreviewPurgeRequest,
+) (*eventpb.PreviewPurgeResponse, error) {
+	userID, err := requireUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if req.GetRetention() == nil || req.GetMaxAge() == nil {
+		return nil, status.Error(codes.InvalidArgument, "retention and max_age are required")
+	}
+	n, err := s.app.PreviewPurge(ctx, userID, req.GetRetention().AsDuration(), req.GetMaxAge().AsDuration())
+	if err != nil {
+		return nil, s.toStatus(err)
+	}
+	return &eventpb.PreviewPurgeResponse{Events: n}, nil
+}
+
+// requireUserID returns the user set by the auth interceptor, it can be missing with metadata authentication only.
+func requireUserID(ctx context.Context) (string, error) {
+	if userID := auth.UserID(ctx); userID != "" {
+		return userID, nil
+	}
+	return "", status.Error(codes.InvalidArgument, userIDKey+" metadata is required")
+}
+
+func metadataValue(ctx context.Context, key string) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+func (s *Server) toStatus(err error) error {
+	switch {
+	case errors.Is(err, app.ErrInvalidEvent), errors.Is(err, app.ErrInvalidQuery),
+		errors.Is(err, app.ErrInvalidCalendar), errors.Is(err, app.ErrInvalidAttachment),
+		errors.Is(err, app.ErrInvalidBatch), errors.Is(err, app.ErrInvalidWorkingHours),
+		errors.Is(err, app.ErrInvalidPreferences):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, app.ErrAttachmentTooLarge):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case errors.Is(err, app.ErrAttachmentsDisabled):
+		return status.Error(codes.Unimplemented, err.Error())
+	case errors.Is(err, app.ErrAccessDenied):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, storage.ErrEventNotFound), errors.Is(err, storage.ErrCalendarNotFound),
+		errors.Is(err, storage.ErrAttachmentNotFound), errors.Is(err, storage.ErrWorkingHoursNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, storage.ErrDateBusy), errors.Is(err, storage.ErrIdempotencyKeyReused),
+		errors.Is(err, storage.ErrCalendarExists):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, storage.ErrVersionMismatch), errors.Is(err, storage.ErrEventNotDeleted),
+		errors.Is(err, app.ErrNonWorkingTime):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, storage.ErrBatchAborted):
+		return status.Error(codes.Aborted, err.Error())
+	}
+	s.logger.Error("unexpected error: " + err.Error())
+	return status.Error(codes.Internal, "internal error")
+}
+
+func fromProto(e *eventpb.Event) storage.Event {
+	reminders := make([]storage.Reminder, 0, len(e.GetReminders()))
+	for _, r := range e.GetReminders() {
+		reminders = append(reminders, storage.Reminder{
+			Before:  r.GetBefore().AsDuration(),
+			Channel: storage.Channel(r.GetChannel()),
+		})
+	}
+	return storage.Event{
+		ID:          e.GetId(),
+		Title:       e.GetTitle(),
+		StartsAt:    e.GetStartsAt().AsTime(),
+		EndsAt:      e.GetEndsAt().AsTime(),
+		Description: e.GetDescription(),
+		CalendarID:  e.GetCalendarId(),
+		Reminders:   reminders,
+	}
+}
+
+func toProto(e storage.Event) *eventpb.Event {
+	reminders := make([]*eventpb.Reminder, 0, len(e.Reminders))
+	for _, r := range e.Reminders {
+		reminders = append(reminders, &eventpb.Reminder{
+			Before:  durationpb.New(r.Before),
+			Channel: string(r.Channel),
+		})
+	}
+	return &eventpb.Event{
+		Id:          e.ID,
+		Title:       e.Title,
+		StartsAt:    timestamppb.New(e.StartsAt),
+		EndsAt:      timestamppb.New(e.EndsAt),
+		Description: e.Description,
+		UserId:      e.UserID,
+		CalendarId:  e.CalendarID,
+		Version:     e.Version,
+		CreatedAt:   timestamppb.New(e.CreatedAt),
+		UpdatedAt:   timestamppb.New(e.UpdatedAt),
+		Reminders:   reminders,
+	}
+}
+
+func changeToProto(c storage.Change) *eventpb.Change {
+	return &eventpb.Change{
+		Id:        c.ID,
+		EventId:   c.EventID,
+		Version:   c.Version,
+		Action:    string(c.Action),
+		UserId:    c.UserID,
+		Fields:    c.Fields,
+		ChangedAt: timestamppb.New(c.ChangedAt),
+	}
+}
+
+func toProtoList(events []storage.Event) []*eventpb.Event {
+	result := make([]*eventpb.Event, 0, len(events))
+	for _, event := range events {
+		result = append(result, toProto(event))
+	}
+	return result
+}
@@ -0,0 +1,198 @@
+package internalgrpc
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/auth"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/metrics"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+func loggingInterceptor(logger Logger) grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+
+		addr := "unknown"
+		if p, ok := peer.FromContext(ctx); ok {
+			addr = p.Addr.String()
+		}
+		logger.Info(fmt.Sprintf("%s [%s] %s %s %d",
+			addr,
+			start.Format("02/Jan/2006:15:04:05 -0700"),
+			info.FullMethod,
+			status.Code(err),
+			time.Since(start).Milliseconds(),
+		))
+		return resp, err
+	}
+}
+
+func streamLoggingInterceptor(logger Logger) grpc.StreamServerInterceptor {
+	return func(
+		srv interface{},
+		ss grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		start := time.Now()
+		err := handler(srv, ss)
+
+		addr := "unknown"
+		if p, ok := peer.FromContext(ss.Context()); ok {
+			addr = p.Addr.String()
+		}
+		logger.Info(fmt.Sprintf("%s [%s] %s %s %d",
+			addr,
+			start.Format("02/Jan/2006:15:04:05 -0700"),
+			info.FullMethod,
+			status.Code(err),
+			time.Since(start).Milliseconds(),
+		))
+		return err
+	}
+}
+
+func metricsInterceptor(
+	ctx context.Context,
+	req interface{},
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (interface{}, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+
+	metrics.GRPCRequests.WithLabelValues(info.FullMethod, status.Code(err).String()).Inc()
+	metrics.GRPCDuration.WithLabelValues(info.FullMethod).Observe(time.Since(start).Seconds())
+	return resp, err
+}
+
+// streamMetricsInterceptor counts streams by their final status, the duration is the lifetime of the stream.
+func streamMetricsInterceptor(
+	srv interface{},
+	ss grpc.ServerStream,
+	info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler,
+) error {
+	start := time.Now()
+	err := handler(srv, ss)
+
+	metrics.GRPCRequests.WithLabelValues(info.FullMethod, status.Code(err).String()).Inc()
+	metrics.GRPCDuration.WithLabelValues(info.FullMethod).Observe(time.Since(start).Seconds())
+	return err
+}
+
+// authInterceptor sets the user of the call. Without an authenticator the x-user-id metadata is trusted,
+// otherwise a bearer token is required and the metadata, if sent anyway, must name the same user.
+// Calls are rate limited before they are rejected: by the user if authenticated, by the peer IP otherwise,
+// so calls without or with invalid credentials are limited too.
+func (s *Server) authInterceptor(limiter Limiter) grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		ctx, err := s.authenticateLimited(ctx, limiter)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// streamAuthInterceptor limits opening of streams, messages of an open stream are not limited.
+func (s *Server) streamAuthInterceptor(limiter Limiter) grpc.StreamServerInterceptor {
+	return func(
+		srv interface{},
+		ss grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		ctx, err := s.authenticateLimited(ss.Context(), limiter)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &authenticatedStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// authenticateLimited authenticates the call and takes a token of its bucket if limiter is not nil.
+func (s *Server) authenticateLimited(ctx context.Context, limiter Limiter) (context.Context, error) {
+	authenticated, err := s.authenticate(ctx)
+	if limiter != nil {
+		keyCtx := authenticated
+		if err != nil {
+			keyCtx = ctx
+		}
+		if err := allow(keyCtx, limiter); err != nil {
+			return nil, err
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return authenticated, nil
+}
+
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *Server) authenticate(ctx context.Context) (context.Context, error) {
+	userID := metadataValue(ctx, userIDKey)
+	if s.auth != nil {
+		scheme, token, ok := strings.Cut(metadataValue(ctx, "authorization"), " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+			return nil, status.Error(codes.Unauthenticated, "bearer token is required")
+		}
+		tokenUserID, err := s.auth.Verify(token)
+		if err != nil {
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		}
+		if userID != "" && userID != tokenUserID {
+			return nil, status.Error(codes.Unauthenticated, userIDKey+" metadata does not match the token")
+		}
+		userID = tokenUserID
+	}
+
+	if userID != "" {
+		ctx = auth.WithUserID(ctx, userID)
+	}
+	return ctx, nil
+}
+
+func allow(ctx context.Context, limiter Limiter) error {
+	key := "ip:unknown"
+	if p, ok := peer.FromContext(ctx); ok {
+		key = "ip:" + p.Addr.String()
+		if host, _, err := net.SplitHostPort(p.Addr.String()); err == nil {
+			key = "ip:" + host
+		}
+	}
+	if userID := auth.UserID(ctx); userID != "" {
+		key = "user:" + userID
+	}
+
+	if ok, retryAfter := limiter.Allow(key); !ok {
+		return status.Errorf(codes.ResourceExhausted, "too many requests, retry after %s", retryAfter)
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package internalgrpc
+
+import (
+	"context"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/pkg/eventpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func (s *Server) GetNotificationPreferences(
+	ctx context.Context,
+	_ *eventpb.GetNotificationPreferencesRequest,
+) (*eventpb.NotificationPreferences, error) {
+	userID, err := requireUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	prefs, err := s.app.GetNotificationPreferences(ctx, userID)
+	if err != nil {
+		return nil, s.toStatus(err)
+	}
+	return preferencesToProto(prefs), nil
+}
+
+func (s *Server) SetNotificationPreferences(
+	ctx context.Context,
+	req *eventpb.SetNotificationPreferencesRequest,
+) (*eventpb.NotificationPreferences, error) {
+	userID, err := requireUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	prefs, err := s.app.SetNotificationPreferences(ctx, storage.NotificationPreferences{
+		UserID:   userID,
+		TimeZone: req.GetPreferences().GetTimeZone(),
+		Locale:   req.GetPreferences().GetLocale(),
+	})
+	if err != nil {
+		return nil, s.toStatus(err)
+	}
+	return preferencesToProto(prefs), nil
+}
+
+func preferencesToProto(p storage.NotificationPreferences) *eventpb.NotificationPreferences {
+	prefs := &eventpb.NotificationPreferences{TimeZone: p.TimeZone, Locale: p.Locale}
+	if !p.UpdatedAt.IsZero() {
+		prefs.UpdatedAt = timestamppb.New(p.UpdatedAt)
+	}
+	return prefs
+}
@@ -0,0 +1,143 @@
+package internalgrpc
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/app"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/pkg/eventpb"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+type Server struct {
+	eventpb.UnimplementedEventServiceServer
+
+	logger Logger
+	app    Application
+	auth   Authenticator
+	addr   string
+	server *grpc.Server
+}
+
+type Logger interface {
+	Info(msg string)
+	Error(msg string)
+}
+
+type Limiter interface {
+	Allow(key string) (bool, time.Duration)
+}
+
+// Authenticator returns the user ID from a bearer token.
+type Authenticator interface {
+	Verify(token string) (string, error)
+}
+
+type Application interface {
+	CreateEvent(
+		ctx context.Context, event storage.Event, userID, idempotencyKey string,
+	) (storage.Event, []string, error)
+	GetEvent(ctx context.Context, id, userID string) (storage.Event, error)
+	UpdateEvent(
+		ctx context.Context, event storage.Event, userID string, expectedVersion int64,
+	) (storage.Event, []string, error)
+	DeleteEvent(ctx context.Context, id, userID string, expectedVersion int64) error
+	ApplyBatch(
+		ctx context.Context, items []storage.BatchItem, userID string, atomic bool,
+	) ([]storage.BatchResult, error)
+	RestoreEvent(ctx context.Context, id, userID string, expectedVersion int64) (storage.Event, error)
+	EventHistory(ctx context.Context, id, userID string) ([]storage.Change, error)
+	ListDayEvents(ctx context.Context, userID string, date time.Time, opts app.ListOptions) (app.Page, error)
+	ListWeekEvents(ctx context.Context, userID string, date time.Time, opts app.ListOptions) (app.Page, error)
+	ListMonthEvents(ctx context.Context, userID string, date time.Time, opts app.ListOptions) (app.Page, error)
+	SearchEvents(ctx context.Context, userID, text string, opts app.SearchOptions) (app.Page, error)
+	LatestChangeID(ctx context.Context) (int64, error)
+	WatchChanges(ctx context.Context, userID string, afterID int64, send func(app.FeedEntry) error) error
+	PreviewPurge(ctx context.Context, userID string, retention, maxAge time.Duration) (int64, error)
+	CreateCalendar(ctx context.Context, userID, name string) (storage.Calendar, error)
+	GetCalendar(ctx context.Context, id, userID string) (storage.Calendar, error)
+	ListCalendars(ctx context.Context, userID string) ([]storage.Calendar, error)
+	ShareCalendar(
+		ctx context.Context, id, userID, withUserID string, access storage.Access,
+	) (storage.Calendar, error)
+	UnshareCalendar(ctx context.Context, id, userID, withUserID string) error
+	AddAttachment(
+		ctx context.Context, eventID, userID, name, contentType string, content io.Reader,
+	) (storage.Attachment, error)
+	ListAttachments(ctx context.Context, eventID, userID string) ([]storage.Attachment, error)
+	OpenAttachment(ctx context.Context, eventID, id, userID string) (storage.Attachment, io.ReadCloser, error)
+	DeleteAttachment(ctx context.Context, eventID, id, userID string) error
+	GetWorkingHours(ctx context.Context, userID string) (storage.WorkingHours, error)
+	SetWorkingHours(ctx context.Context, hours storage.WorkingHours) (storage.WorkingHours, error)
+	DeleteWorkingHours(ctx context.Context, userID string) error
+	GetNotificationPreferences(ctx context.Context, userID string) (storage.NotificationPreferences, error)
+	SetNotificationPreferences(
+		ctx context.Context, prefs storage.NotificationPreferences,
+	) (storage.NotificationPreferences, error)
+	HolidayCalendars() []string
+	FreeBusy(ctx context.Context, userID string, from, to time.Time) (app.FreeBusy, error)
+}
+
+// NewServer creates the gRPC API server, requests are not rate limited if limiter is nil.
+// If authenticator is nil, the x-user-id metadata is trusted, which is only suitable for local use.
+// The server listens in plaintext if tlsConfig is nil.
+func NewServer(
+	logger Logger,
+	app Application,
+	limiter Limiter,
+	authenticator Authenticator,
+	tlsConfig *tls.Config,
+	host, port string,
+) *Server {
+	s := &Server{
+		logger: logger,
+		app:    app,
+		auth:   authenticator,
+		addr:   net.JoinHostPort(host, port),
+	}
+
+	options := []grpc.ServerOption{
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(loggingInterceptor(logger), metricsInterceptor, s.authInterceptor(limiter)),
+		grpc.ChainStreamInterceptor(
+			streamLoggingInterceptor(logger), streamMetricsInterceptor, s.streamAuthInterceptor(limiter),
+		),
+	}
+	if tlsConfig != nil {
+		options = append(options, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+	s.server = grpc.NewServer(options...)
+	eventpb.RegisterEventServiceServer(s.server, s)
+	return s
+}
+
+func (s *Server) Start(ctx context.Context) error {
+	lis, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	s.logger.Info("grpc server is listening on " + s.addr)
+	return s.server.Serve(lis)
+}
+
+func (s *Server) Stop(ctx context.Context) error {
+	stopped := make(chan struct{})
+	go func() {
+		s.server.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-ctx.Done():
+		s.server.Stop()
+		return ctx.Err()
+	case <-stopped:
+		return nil
+	}
+}
@@ -0,0 +1,128 @@
+package internalgrpc
+
+import (
+	"context"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/app"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/pkg/eventpb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func (s *Server) GetWorkingHours(
+	ctx context.Context,
+	_ *eventpb.GetWorkingHoursRequest,
+) (*eventpb.WorkingHours, error) {
+	userID, err := requireUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	hours, err := s.app.GetWorkingHours(ctx, userID)
+	if err != nil {
+		return nil, s.toStatus(err)
+	}
+	return workingHoursToProto(hours), nil
+}
+
+func (s *Server) SetWorkingHours(
+	ctx context.Context,
+	req *eventpb.SetWorkingHoursRequest,
+) (*eventpb.WorkingHours, error) {
+	userID, err := requireUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	hours := storage.WorkingHours{
+		UserID:   userID,
+		TimeZone: req.GetWorkingHours().GetTimeZone(),
+		Holidays: req.GetWorkingHours().GetHolidays(),
+		Strict:   req.GetWorkingHours().GetStrict(),
+	}
+	for _, p := range req.GetWorkingHours().GetPeriods() {
+		hours.Periods = append(hours.Periods, storage.WorkingPeriod{
+			Weekday: time.Weekday(p.GetWeekday()),
+			Start:   p.GetStart().AsDuration(),
+			End:     p.GetEnd().AsDuration(),
+		})
+	}
+
+	hours, err = s.app.SetWorkingHours(ctx, hours)
+	if err != nil {
+		return nil, s.toStatus(err)
+	}
+	return workingHoursToProto(hours), nil
+}
+
+func (s *Server) DeleteWorkingHours(
+	ctx context.Context,
+	_ *eventpb.DeleteWorkingHoursRequest,
+) (*emptypb.Empty, error) {
+	userID, err := requireUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.app.DeleteWorkingHours(ctx, userID); err != nil {
+		return nil, s.toStatus(err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (s *Server) ListHolidayCalendars(
+	_ context.Context,
+	_ *eventpb.ListHolidayCalendarsRequest,
+) (*eventpb.ListHolidayCalendarsResponse, error) {
+	return &eventpb.ListHolidayCalendarsResponse{Names: s.app.HolidayCalendars()}, nil
+}
+
+func (s *Server) FreeBusy(ctx context.Context, req *eventpb.FreeBusyRequest) (*eventpb.FreeBusyResponse, error) {
+	userID, err := requireUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if req.GetFrom() == nil || req.GetTo() == nil {
+		return nil, status.Error(codes.InvalidArgument, "from and to are required")
+	}
+
+	freeBusy, err := s.app.FreeBusy(ctx, userID, req.GetFrom().AsTime(), req.GetTo().AsTime())
+	if err != nil {
+		return nil, s.toStatus(err)
+	}
+	return &eventpb.FreeBusyResponse{
+		Busy: intervalsToProto(freeBusy.Busy),
+		Free: intervalsToProto(freeBusy.Free),
+	}, nil
+}
+
+func workingHoursToProto(h storage.WorkingHours) *eventpb.WorkingHours {
+	periods := make([]*eventpb.WorkingPeriod, 0, len(h.Periods))
+	for _, p := range h.Periods {
+		periods = append(periods, &eventpb.WorkingPeriod{
+			Weekday: int32(p.Weekday),
+			Start:   durationpb.New(p.Start),
+			End:     durationpb.New(p.End),
+		})
+	}
+	return &eventpb.WorkingHours{
+		TimeZone:  h.TimeZone,
+		Periods:   periods,
+		Holidays:  h.Holidays,
+		Strict:    h.Strict,
+		UpdatedAt: timestamppb.New(h.UpdatedAt),
+	}
+}
+
+func intervalsToProto(periods []app.Period) []*eventpb.Interval {
+	intervals := make([]*eventpb.Interval, 0, len(periods))
+	for _, p := range periods {
+		intervals = append(intervals, &eventpb.Interval{Start: timestamppb.New(p.Start), End: timestamppb.New(p.End)})
+	}
+	return intervals
+}
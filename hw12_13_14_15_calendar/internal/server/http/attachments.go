@@ -0,0 +1,113 @@
+package internalhttp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+)
+
+// attachmentField is the multipart form field holding the uploaded file.
+const attachmentField = "file"
+
+// addAttachment streams the file from a multipart form to the app without buffering it.
+func (s *Server) addAttachment(w http.ResponseWriter, r *http.Request) {
+	userID, err := requireUserID(r)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	reader, err := r.MultipartReader()
+	if err != nil {
+		s.writeError(w, fmt.Errorf("%w: %v", errBadRequest, err))
+		return
+	}
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			s.writeError(w, fmt.Errorf("%w: %s field is required", errBadRequest, attachmentField))
+			return
+		}
+		if err != nil {
+			s.writeError(w, fmt.Errorf("%w: %v", errBadRequest, err))
+			return
+		}
+		if part.FormName() != attachmentField {
+			continue
+		}
+
+		attachment, err := s.app.AddAttachment(r.Context(), r.PathValue("id"), userID,
+			part.FileName(), part.Header.Get("Content-Type"), part)
+		if err != nil {
+			s.writeError(w, err)
+			return
+		}
+		s.writeJSON(w, http.StatusCreated, newAttachmentResponse(attachment))
+		return
+	}
+}
+
+func (s *Server) listAttachments(w http.ResponseWriter, r *http.Request) {
+	userID, err := requireUserID(r)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	attachments, err := s.app.ListAttachments(r.Context(), r.PathValue("id"), userID)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	resp := attachmentsResponse{Attachments: make([]attachmentResponse, 0, len(attachments))}
+	for _, attachment := range attachments {
+		resp.Attachments = append(resp.Attachments, newAttachmentResponse(attachment))
+	}
+	s.writeJSON(w, http.StatusOK, resp)
+}
+
+// downloadAttachment serves the content as a download, so browsers don't render files uploaded by users.
+func (s *Server) downloadAttachment(w http.ResponseWriter, r *http.Request) {
+	userID, err := requireUserID(r)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	attachment, content, err := s.app.OpenAttachment(r.Context(), r.PathValue("id"), r.PathValue("attachment"), userID)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	defer content.Close()
+
+	w.Header().Set("Content-Type", attachment.ContentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(attachment.Size, 10))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+		"filename": attachment.Name,
+	}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.Copy(w, content); err != nil {
+		s.logger.Error("unable to write attachment: " + err.Error())
+	}
+}
+
+func (s *Server) deleteAttachment(w http.ResponseWriter, r *http.Request) {
+	userID, err := requireUserID(r)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	err = s.app.DeleteAttachment(r.Context(), r.PathValue("id"), r.PathValue("attachment"), userID)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,86 @@
+package internalhttp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+)
+
+// applyBatch responds with 200 and the result of every item, even if some or all of them have failed.
+func (s *Server) applyBatch(w http.ResponseWriter, r *http.Request) {
+	userID, err := requireUserID(r)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	var req batchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.writeError(w, fmt.Errorf("%w: %v", errBadRequest, err))
+		return
+	}
+	items := make([]storage.BatchItem, 0, len(req.Items))
+	for i, item := range req.Items {
+		batchItem, err := item.toStorage()
+		if err != nil {
+			s.writeError(w, fmt.Errorf("%w: item %d: %v", errBadRequest, i, err))
+			return
+		}
+		items = append(items, batchItem)
+	}
+
+	results, err := s.app.ApplyBatch(r.Context(), items, userID, req.Atomic)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	resp := batchResponse{Results: make([]batchResultResponse, 0, len(results))}
+	for i, result := range results {
+		resp.Results = append(resp.Results, s.newBatchResultResponse(items[i].Op, result))
+	}
+	s.writeJSON(w, http.StatusOK, resp)
+}
+
+func (r batchItemRequest) toStorage() (storage.BatchItem, error) {
+	item := storage.BatchItem{Op: storage.BatchOp(r.Op), Event: storage.Event{ID: r.ID}, ExpectedVersion: r.Version}
+	switch item.Op {
+	case storage.BatchCreate:
+		item.Event.ID = ""
+	case storage.BatchUpdate, storage.BatchDelete:
+		if r.ID == "" || r.Version <= 0 {
+			return storage.BatchItem{}, errors.New("id and version are required")
+		}
+	default:
+		return storage.BatchItem{}, errors.New("op must be one of create, update, delete")
+	}
+	if item.Op != storage.BatchDelete {
+		if r.Event == nil {
+			return storage.BatchItem{}, errors.New("event is required")
+		}
+		item.Event = r.Event.toStorage(item.Event.ID)
+	}
+	return item, nil
+}
+
+func (s *Server) newBatchResultResponse(op storage.BatchOp, result storage.BatchResult) batchResultResponse {
+	resp := batchResultResponse{ID: result.Event.ID}
+	switch {
+	case result.Err != nil:
+		resp.Status, resp.Error = s.errorStatus(result.Err)
+	case op == storage.BatchDelete:
+		resp.Status = http.StatusNoContent
+	default:
+		resp.Status = http.StatusOK
+		if op == storage.BatchCreate {
+			resp.Status = http.StatusCreated
+		}
+		event := newEventResponse(result.Event)
+		event.Warnings = result.Warnings
+		resp.Event = &event
+	}
+	return resp
+}
@@ -0,0 +1,102 @@
+package internalhttp
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+)
+
+func (s *Server) createCalendar(w http.ResponseWriter, r *http.Request) {
+	userID, err := requireUserID(r)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	var req calendarRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.writeError(w, fmt.Errorf("%w: %v", errBadRequest, err))
+		return
+	}
+
+	calendar, err := s.app.CreateCalendar(r.Context(), userID, req.Name)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	s.writeJSON(w, http.StatusCreated, newCalendarResponse(calendar))
+}
+
+func (s *Server) getCalendar(w http.ResponseWriter, r *http.Request) {
+	userID, err := requireUserID(r)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	calendar, err := s.app.GetCalendar(r.Context(), r.PathValue("id"), userID)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, newCalendarResponse(calendar))
+}
+
+func (s *Server) listCalendars(w http.ResponseWriter, r *http.Request) {
+	userID, err := requireUserID(r)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	calendars, err := s.app.ListCalendars(r.Context(), userID)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	resp := calendarsResponse{Calendars: make([]calendarResponse, 0, len(calendars))}
+	for _, calendar := range calendars {
+		resp.Calendars = append(resp.Calendars, newCalendarResponse(calendar))
+	}
+	s.writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) shareCalendar(w http.ResponseWriter, r *http.Request) {
+	userID, err := requireUserID(r)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	var req shareRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.writeError(w, fmt.Errorf("%w: %v", errBadRequest, err))
+		return
+	}
+
+	calendar, err := s.app.ShareCalendar(
+		r.Context(), r.PathValue("id"), userID, r.PathValue("user"), storage.Access(req.Access))
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, newCalendarResponse(calendar))
+}
+
+// unshareCalendar revokes a share, users may also remove their own share to leave the calendar.
+func (s *Server) unshareCalendar(w http.ResponseWriter, r *http.Request) {
+	userID, err := requireUserID(r)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	if err := s.app.UnshareCalendar(r.Context(), r.PathValue("id"), userID, r.PathValue("user")); err != nil {
+		s.writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
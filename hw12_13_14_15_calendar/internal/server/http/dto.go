@@ -0,0 +1,410 @@
+package internalhttp
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/app"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+)
+
+// duration is encoded in JSON as a Go duration string, e.g. "1h30m".
+type duration time.Duration
+
+func (d duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = duration(v)
+	return nil
+}
+
+type reminderDTO struct {
+	Before  duration `json:"before"`
+	Channel string   `json:"channel"`
+}
+
+type eventRequest struct {
+	Title       string    `json:"title"`
+	StartsAt    time.Time `json:"starts_at"`
+	EndsAt      time.Time `json:"ends_at"`
+	Description string    `json:"description"`
+	// CalendarID is the default calendar of the user on creation and the current calendar on update if empty.
+	CalendarID string        `json:"calendar_id"`
+	Reminders  []reminderDTO `json:"reminders"`
+}
+
+func (r eventRequest) toStorage(id string) storage.Event {
+	reminders := make([]storage.Reminder, 0, len(r.Reminders))
+	for _, reminder := range r.Reminders {
+		reminders = append(reminders, storage.Reminder{
+			Before:  time.Duration(reminder.Before),
+			Channel: storage.Channel(reminder.Channel),
+		})
+	}
+	return storage.Event{
+		ID:          id,
+		Title:       r.Title,
+		StartsAt:    r.StartsAt,
+		EndsAt:      r.EndsAt,
+		Description: r.Description,
+		CalendarID:  r.CalendarID,
+		Reminders:   reminders,
+	}
+}
+
+type eventResponse struct {
+	ID          string    `json:"id"`
+	Title       string    `json:"title"`
+	StartsAt    time.Time `json:"starts_at"`
+	EndsAt      time.Time `json:"ends_at"`
+	Description string    `json:"description"`
+	// DescriptionHTML is the description rendered from Markdown, it is set on request only.
+	DescriptionHTML string        `json:"description_html,omitempty"`
+	CalendarID      string        `json:"calendar_id"`
+	UserID          string        `json:"user_id"`
+	Reminders       []reminderDTO `json:"reminders"`
+	Version         int64         `json:"version"`
+	CreatedAt       time.Time     `json:"created_at"`
+	UpdatedAt       time.Time     `json:"updated_at"`
+	// Warnings are about the event out of the working time of its owner, they are set on create and update.
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+func newEventResponse(e storage.Event) eventResponse {
+	reminders := make([]reminderDTO, 0, len(e.Reminders))
+	for _, r := range e.Reminders {
+		reminders = append(reminders, reminderDTO{Before: duration(r.Before), Channel: string(r.Channel)})
+	}
+	return eventResponse{
+		ID:          e.ID,
+		Title:       e.Title,
+		StartsAt:    e.StartsAt,
+		EndsAt:      e.EndsAt,
+		Description: e.Description,
+		CalendarID:  e.CalendarID,
+		UserID:      e.UserID,
+		Reminders:   reminders,
+		Version:     e.Version,
+		CreatedAt:   e.CreatedAt,
+		UpdatedAt:   e.UpdatedAt,
+	}
+}
+
+type listResponse struct {
+	Events     []eventResponse `json:"events"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+}
+
+func newListResponse(page app.Page) listResponse {
+	resp := listResponse{
+		Events:     make([]eventResponse, 0, len(page.Events)),
+		NextCursor: page.NextCursor,
+	}
+	for _, event := range page.Events {
+		resp.Events = append(resp.Events, newEventResponse(event))
+	}
+	return resp
+}
+
+type changeResponse struct {
+	Version   int64     `json:"version"`
+	Action    string    `json:"action"`
+	UserID    string    `json:"user_id"`
+	Fields    []string  `json:"fields"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+func newChangeResponse(c storage.Change) changeResponse {
+	return changeResponse{
+		Version:   c.Version,
+		Action:    string(c.Action),
+		UserID:    c.UserID,
+		Fields:    c.Fields,
+		ChangedAt: c.ChangedAt,
+	}
+}
+
+// feedEntryResponse is the data of a server-sent event, the event is omitted once it's deleted.
+type feedEntryResponse struct {
+	ID      int64          `json:"id"`
+	EventID string         `json:"event_id"`
+	Change  changeResponse `json:"change"`
+	Event   *eventResponse `json:"event,omitempty"`
+}
+
+func newFeedEntryResponse(e app.FeedEntry) feedEntryResponse {
+	resp := feedEntryResponse{
+		ID:      e.ID,
+		EventID: e.EventID,
+		Change:  newChangeResponse(e.Change),
+	}
+	if e.Event.ID != "" {
+		event := newEventResponse(e.Event)
+		resp.Event = &event
+	}
+	return resp
+}
+
+type historyResponse struct {
+	History []changeResponse `json:"history"`
+}
+
+type calendarRequest struct {
+	Name string `json:"name"`
+}
+
+type shareRequest struct {
+	Access string `json:"access"`
+}
+
+type shareDTO struct {
+	UserID string `json:"user_id"`
+	Access string `json:"access"`
+}
+
+type calendarResponse struct {
+	ID        string     `json:"id"`
+	OwnerID   string     `json:"owner_id"`
+	Name      string     `json:"name"`
+	Shares    []shareDTO `json:"shares"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+func newCalendarResponse(c storage.Calendar) calendarResponse {
+	shares := make([]shareDTO, 0, len(c.Shares))
+	for _, share := range c.Shares {
+		shares = append(shares, shareDTO{UserID: share.UserID, Access: string(share.Access)})
+	}
+	return calendarResponse{
+		ID:        c.ID,
+		OwnerID:   c.OwnerID,
+		Name:      c.Name,
+		Shares:    shares,
+		CreatedAt: c.CreatedAt,
+	}
+}
+
+type calendarsResponse struct {
+	Calendars []calendarResponse `json:"calendars"`
+}
+
+type attachmentResponse struct {
+	ID          string    `json:"id"`
+	EventID     string    `json:"event_id"`
+	Name        string    `json:"name"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	UserID      string    `json:"user_id"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func newAttachmentResponse(a storage.Attachment) attachmentResponse {
+	return attachmentResponse{
+		ID:          a.ID,
+		EventID:     a.EventID,
+		Name:        a.Name,
+		ContentType: a.ContentType,
+		Size:        a.Size,
+		UserID:      a.UserID,
+		CreatedAt:   a.CreatedAt,
+	}
+}
+
+type attachmentsResponse struct {
+	Attachments []attachmentResponse `json:"attachments"`
+}
+
+type batchRequest struct {
+	// Atomic applies all items or none of them.
+	Atomic bool               `json:"atomic"`
+	Items  []batchItemRequest `json:"items"`
+}
+
+// batchItemRequest is an operation on one event. ID and Version are required for update and delete,
+// Event is required for create and update.
+type batchItemRequest struct {
+	Op      string        `json:"op"`
+	ID      string        `json:"id"`
+	Version int64         `json:"version"`
+	Event   *eventRequest `json:"event"`
+}
+
+// batchResultResponse has the status a single request of the item would return, with the event or the error.
+type batchResultResponse struct {
+	Status int            `json:"status"`
+	ID     string         `json:"id,omitempty"`
+	Event  *eventResponse `json:"event,omitempty"`
+	Error  string         `json:"error,omitempty"`
+}
+
+type batchResponse struct {
+	Results []batchResultResponse `json:"results"`
+}
+
+// clock is an offset from the midnight encoded in JSON as "HH:MM", "24:00" is the end of the day.
+type clock time.Duration
+
+func (c clock) MarshalJSON() ([]byte, error) {
+	minutes := int(time.Duration(c) / time.Minute)
+	return json.Marshal(fmt.Sprintf("%02d:%02d", minutes/60, minutes%60))
+}
+
+func (c *clock) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	if s == "24:00" {
+		*c = clock(24 * time.Hour)
+		return nil
+	}
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return fmt.Errorf("time %q must be in the HH:MM format", s)
+	}
+	*c = clock(time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute)
+	return nil
+}
+
+// weekday is encoded in JSON as the lowercase English name of the day.
+type weekday time.Weekday
+
+func (d weekday) MarshalJSON() ([]byte, error) {
+	return json.Marshal(strings.ToLower(time.Weekday(d).String()))
+}
+
+func (d *weekday) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if strings.EqualFold(s, day.String()) {
+			*d = weekday(day)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown weekday %q", s)
+}
+
+type workingPeriodDTO struct {
+	Weekday weekday `json:"weekday"`
+	Start   clock   `json:"start"`
+	End     clock   `json:"end"`
+}
+
+type workingHoursRequest struct {
+	// TimeZone is an IANA time zone name, UTC if empty.
+	TimeZone string             `json:"time_zone"`
+	Periods  []workingPeriodDTO `json:"periods"`
+	// Holidays are names of holiday calendars listed by GET /holiday-calendars.
+	Holidays []string `json:"holidays"`
+	// Strict rejects events out of the working time instead of warning about them.
+	Strict bool `json:"strict"`
+}
+
+func (r workingHoursRequest) toStorage(userID string) storage.WorkingHours {
+	periods := make([]storage.WorkingPeriod, 0, len(r.Periods))
+	for _, p := range r.Periods {
+		periods = append(periods, storage.WorkingPeriod{
+			Weekday: time.Weekday(p.Weekday),
+			Start:   time.Duration(p.Start),
+			End:     time.Duration(p.End),
+		})
+	}
+	return storage.WorkingHours{
+		UserID:   userID,
+		TimeZone: r.TimeZone,
+		Periods:  periods,
+		Holidays: r.Holidays,
+		Strict:   r.Strict,
+	}
+}
+
+type workingHoursResponse struct {
+	TimeZone  string             `json:"time_zone"`
+	Periods   []workingPeriodDTO `json:"periods"`
+	Holidays  []string           `json:"holidays"`
+	Strict    bool               `json:"strict"`
+	UpdatedAt time.Time          `json:"updated_at"`
+}
+
+func newWorkingHoursResponse(h storage.WorkingHours) workingHoursResponse {
+	periods := make([]workingPeriodDTO, 0, len(h.Periods))
+	for _, p := range h.Periods {
+		periods = append(periods, workingPeriodDTO{Weekday: weekday(p.Weekday), Start: clock(p.Start), End: clock(p.End)})
+	}
+	holidays := h.Holidays
+	if holidays == nil {
+		holidays = []string{}
+	}
+	return workingHoursResponse{
+		TimeZone:  h.TimeZone,
+		Periods:   periods,
+		Holidays:  holidays,
+		Strict:    h.Strict,
+		UpdatedAt: h.UpdatedAt,
+	}
+}
+
+type preferencesRequest struct {
+	// TimeZone is an IANA time zone name of dates in notifications, the sender default if empty.
+	TimeZone string `json:"time_zone"`
+	// Locale is the language tag of notifications, e.g. "en" or "pt-BR", the sender default if empty.
+	Locale string `json:"locale"`
+}
+
+func (r preferencesRequest) toStorage(userID string) storage.NotificationPreferences {
+	return storage.NotificationPreferences{UserID: userID, TimeZone: r.TimeZone, Locale: r.Locale}
+}
+
+type preferencesResponse struct {
+	TimeZone  string    `json:"time_zone"`
+	Locale    string    `json:"locale"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func newPreferencesResponse(p storage.NotificationPreferences) preferencesResponse {
+	return preferencesResponse{TimeZone: p.TimeZone, Locale: p.Locale, UpdatedAt: p.UpdatedAt}
+}
+
+type holidayCalendarsResponse struct {
+	Calendars []string `json:"calendars"`
+}
+
+type periodDTO struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+type freeBusyResponse struct {
+	Busy []periodDTO `json:"busy"`
+	Free []periodDTO `json:"free"`
+}
+
+func newFreeBusyResponse(fb app.FreeBusy) freeBusyResponse {
+	toDTO := func(periods []app.Period) []periodDTO {
+		dto := make([]periodDTO, 0, len(periods))
+		for _, p := range periods {
+			dto = append(dto, periodDTO{Start: p.Start, End: p.End})
+		}
+		return dto
+	}
+	return freeBusyResponse{Busy: toDTO(fb.Busy), Free: toDTO(fb.Free)}
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
@@ -0,0 +1,411 @@
+package internalhttp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/app"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/auth"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/markdown"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+)
+
+const (
+	userIDHeader         = "X-User-ID"
+	idempotencyKeyHeader = "Idempotency-Key"
+	dateLayout           = "2006-01-02"
+)
+
+var (
+	errBadRequest      = errors.New("bad request")
+	errVersionRequired = errors.New("If-Match header is required")
+)
+
+func (s *Server) createEvent(w http.ResponseWriter, r *http.Request) {
+	userID, err := requireUserID(r)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	var req eventRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.writeError(w, fmt.Errorf("%w: %v", errBadRequest, err))
+		return
+	}
+
+	event, warnings, err := s.app.CreateEvent(r.Context(), req.toStorage(""), userID, r.Header.Get(idempotencyKeyHeader))
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	s.writeEvent(w, http.StatusCreated, event, warnings)
+}
+
+func (s *Server) getEvent(w http.ResponseWriter, r *http.Request) {
+	userID, err := requireUserID(r)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	event, err := s.app.GetEvent(r.Context(), r.PathValue("id"), userID)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	resp := newEventResponse(event)
+	switch r.URL.Query().Get("render") {
+	case "":
+	case "html":
+		if resp.DescriptionHTML, err = markdown.Render(event.Description); err != nil {
+			s.writeError(w, err)
+			return
+		}
+	default:
+		s.writeError(w, fmt.Errorf("%w: render must be html", errBadRequest))
+		return
+	}
+	w.Header().Set("ETag", etag(event.Version))
+	s.writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) updateEvent(w http.ResponseWriter, r *http.Request) {
+	userID, err := requireUserID(r)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	version, err := ifMatchVersion(r)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	var req eventRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.writeError(w, fmt.Errorf("%w: %v", errBadRequest, err))
+		return
+	}
+
+	event, warnings, err := s.app.UpdateEvent(r.Context(), req.toStorage(r.PathValue("id")), userID, version)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	s.writeEvent(w, http.StatusOK, event, warnings)
+}
+
+func (s *Server) deleteEvent(w http.ResponseWriter, r *http.Request) {
+	userID, err := requireUserID(r)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	version, err := ifMatchVersion(r)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	if err := s.app.DeleteEvent(r.Context(), r.PathValue("id"), userID, version); err != nil {
+		s.writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) restoreEvent(w http.ResponseWriter, r *http.Request) {
+	userID, err := requireUserID(r)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	// The client may not know the version of a deleted event, so If-Match is optional here.
+	version := storage.AnyVersion
+	if r.Header.Get("If-Match") != "" {
+		if version, err = ifMatchVersion(r); err != nil {
+			s.writeError(w, err)
+			return
+		}
+	}
+
+	event, err := s.app.RestoreEvent(r.Context(), r.PathValue("id"), userID, version)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	s.writeEvent(w, http.StatusOK, event, nil)
+}
+
+func (s *Server) eventHistory(w http.ResponseWriter, r *http.Request) {
+	userID, err := requireUserID(r)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	history, err := s.app.EventHistory(r.Context(), r.PathValue("id"), userID)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	resp := historyResponse{History: make([]changeResponse, 0, len(history))}
+	for _, change := range history {
+		resp.History = append(resp.History, newChangeResponse(change))
+	}
+	s.writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) listEvents(w http.ResponseWriter, r *http.Request) {
+	userID, err := requireUserID(r)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	query := r.URL.Query()
+	date, err := time.Parse(dateLayout, query.Get("date"))
+	if err != nil {
+		s.writeError(w, fmt.Errorf("%w: date must be in %s format", errBadRequest, dateLayout))
+		return
+	}
+	opts, err := listOptions(query)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	var page app.Page
+	switch query.Get("period") {
+	case "day":
+		page, err = s.app.ListDayEvents(r.Context(), userID, date, opts)
+	case "week":
+		page, err = s.app.ListWeekEvents(r.Context(), userID, date, opts)
+	case "month":
+		page, err = s.app.ListMonthEvents(r.Context(), userID, date, opts)
+	default:
+		err = fmt.Errorf("%w: period must be one of day, week, month", errBadRequest)
+	}
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, newListResponse(page))
+}
+
+func (s *Server) searchEvents(w http.ResponseWriter, r *http.Request) {
+	userID, err := requireUserID(r)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	query := r.URL.Query()
+	opts := app.SearchOptions{
+		Cursor:     query.Get("cursor"),
+		CalendarID: query.Get("calendar_id"),
+	}
+	if opts.PageSize, err = pageSize(query); err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	page, err := s.app.SearchEvents(r.Context(), userID, query.Get("q"), opts)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, newListResponse(page))
+}
+
+// streamEvents sends changes of events in calendars the user can read as server-sent events
+// until the client disconnects.
+// A reconnecting client resumes after the Last-Event-ID header or the last_event_id parameter, zero replays
+// the whole history. A client sending neither gets changes made after the response headers only.
+func (s *Server) streamEvents(w http.ResponseWriter, r *http.Request) {
+	userID, err := requireUserID(r)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("last_event_id")
+	}
+	var afterID int64
+	if lastEventID != "" {
+		if afterID, err = strconv.ParseInt(lastEventID, 10, 64); err != nil || afterID < 0 {
+			s.writeError(w, fmt.Errorf("%w: last event id must be a non-negative integer", errBadRequest))
+			return
+		}
+	} else if afterID, err = s.app.LatestChangeID(r.Context()); err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		s.logger.Error("unable to flush event stream: " + err.Error())
+		return
+	}
+
+	err = s.app.WatchChanges(r.Context(), userID, afterID, func(entry app.FeedEntry) error {
+		data, err := json.Marshal(newFeedEntryResponse(entry))
+		if err != nil {
+			return fmt.Errorf("unable to marshal change: %w", err)
+		}
+		if _, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", entry.ID, entry.Action, data); err != nil {
+			return err
+		}
+		return rc.Flush()
+	})
+	if err != nil && r.Context().Err() == nil {
+		s.logger.Error("event stream failed: " + err.Error())
+	}
+}
+
+func listOptions(query url.Values) (app.ListOptions, error) {
+	opts := app.ListOptions{
+		Cursor:     query.Get("cursor"),
+		CalendarID: query.Get("calendar_id"),
+		Title:      query.Get("title"),
+	}
+
+	var err error
+	if opts.PageSize, err = pageSize(query); err != nil {
+		return app.ListOptions{}, err
+	}
+	if v := query.Get("has_notification"); v != "" {
+		hasNotification, err := strconv.ParseBool(v)
+		if err != nil {
+			return app.ListOptions{}, fmt.Errorf("%w: has_notification must be a boolean", errBadRequest)
+		}
+		opts.HasNotification = &hasNotification
+	}
+	if v := query.Get("updated_since"); v != "" {
+		since, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return app.ListOptions{}, fmt.Errorf("%w: updated_since must be in RFC 3339 format", errBadRequest)
+		}
+		opts.UpdatedSince = since
+	}
+	return opts, nil
+}
+
+func pageSize(query url.Values) (int, error) {
+	v := query.Get("limit")
+	if v == "" {
+		return 0, nil
+	}
+	limit, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("%w: limit must be an integer", errBadRequest)
+	}
+	return limit, nil
+}
+
+// requireUserID returns the user set by authMiddleware, it can be missing with header authentication only.
+func requireUserID(r *http.Request) (string, error) {
+	userID := auth.UserID(r.Context())
+	if userID == "" {
+		return "", fmt.Errorf("%w: %s header is required", errBadRequest, userIDHeader)
+	}
+	return userID, nil
+}
+
+// ifMatchVersion extracts the expected event version from the If-Match header.
+// "*" matches any version, an unparsable tag never matches.
+func ifMatchVersion(r *http.Request) (int64, error) {
+	tag := strings.TrimSpace(r.Header.Get("If-Match"))
+	switch tag {
+	case "":
+		return 0, errVersionRequired
+	case "*":
+		return storage.AnyVersion, nil
+	}
+
+	version, err := strconv.ParseInt(strings.Trim(tag, `"`), 10, 64)
+	if err != nil || version <= 0 {
+		return 0, storage.ErrVersionMismatch
+	}
+	return version, nil
+}
+
+func etag(version int64) string {
+	return strconv.Quote(strconv.FormatInt(version, 10))
+}
+
+func (s *Server) writeEvent(w http.ResponseWriter, status int, event storage.Event, warnings []string) {
+	resp := newEventResponse(event)
+	resp.Warnings = warnings
+	w.Header().Set("ETag", etag(event.Version))
+	s.writeJSON(w, status, resp)
+}
+
+func (s *Server) writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		s.logger.Error("unable to write response: " + err.Error())
+	}
+}
+
+func (s *Server) writeError(w http.ResponseWriter, err error) {
+	status, message := s.errorStatus(err)
+	if status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+	}
+	s.writeJSON(w, status, errorResponse{Error: message})
+}
+
+// errorStatus returns the HTTP status of the error and the message for the client, unexpected errors are logged.
+func (s *Server) errorStatus(err error) (int, string) {
+	switch {
+	case errors.Is(err, errBadRequest), errors.Is(err, app.ErrInvalidEvent), errors.Is(err, app.ErrInvalidQuery),
+		errors.Is(err, app.ErrInvalidCalendar), errors.Is(err, app.ErrInvalidAttachment),
+		errors.Is(err, app.ErrInvalidBatch), errors.Is(err, app.ErrInvalidWorkingHours),
+		errors.Is(err, app.ErrInvalidPreferences):
+		return http.StatusBadRequest, err.Error()
+	case errors.Is(err, auth.ErrUnauthenticated):
+		return http.StatusUnauthorized, err.Error()
+	case errors.Is(err, app.ErrAccessDenied):
+		return http.StatusForbidden, err.Error()
+	case errors.Is(err, storage.ErrEventNotFound), errors.Is(err, storage.ErrCalendarNotFound),
+		errors.Is(err, storage.ErrAttachmentNotFound), errors.Is(err, storage.ErrWorkingHoursNotFound):
+		return http.StatusNotFound, err.Error()
+	case errors.Is(err, app.ErrAttachmentTooLarge):
+		return http.StatusRequestEntityTooLarge, err.Error()
+	case errors.Is(err, app.ErrAttachmentsDisabled):
+		return http.StatusNotImplemented, err.Error()
+	case errors.Is(err, storage.ErrDateBusy), errors.Is(err, storage.ErrEventNotDeleted),
+		errors.Is(err, storage.ErrIdempotencyKeyReused), errors.Is(err, storage.ErrCalendarExists):
+		return http.StatusConflict, err.Error()
+	case errors.Is(err, errVersionRequired):
+		return http.StatusPreconditionRequired, err.Error()
+	case errors.Is(err, storage.ErrVersionMismatch):
+		return http.StatusPreconditionFailed, err.Error()
+	case errors.Is(err, app.ErrNonWorkingTime):
+		return http.StatusUnprocessableEntity, err.Error()
+	case errors.Is(err, storage.ErrBatchAborted):
+		return http.StatusFailedDependency, err.Error()
+	}
+	s.logger.Error("unexpected error: " + err.Error())
+	return http.StatusInternalServerError, "internal server error"
+}
@@ -1,11 +1,161 @@
 package internalhttp
 
 import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/auth"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/metrics"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
-func loggingMiddleware(next http.Handler) http.Handler {
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, e.g. to flush event streams.
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+func loggingMiddleware(logger Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		next.ServeHTTP(rec, r)
+
+		logger.Info(fmt.Sprintf("%s [%s] %s %s %s %d %d %q",
+			clientIP(r),
+			start.Format("02/Jan/2006:15:04:05 -0700"),
+			r.Method,
+			r.URL.RequestURI(),
+			r.Proto,
+			rec.status,
+			time.Since(start).Milliseconds(),
+			r.UserAgent(),
+		))
+	})
+}
+
+// metricsMiddleware counts requests by the route of mux, also ones rejected before reaching it.
+func metricsMiddleware(mux *http.ServeMux, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		next.ServeHTTP(rec, r)
+
+		// the pattern of the mux keeps the label cardinality bounded
+		_, route := mux.Handler(r)
+		if route == "" {
+			route = "unmatched"
+		}
+		metrics.HTTPRequests.WithLabelValues(r.Method, route, strconv.Itoa(rec.status)).Inc()
+		metrics.HTTPDuration.WithLabelValues(r.Method, route).Observe(time.Since(start).Seconds())
+	})
+}
+
+// tracingMiddleware starts a span per request, named after the matched route once the mux is done.
+func tracingMiddleware(next http.Handler) http.Handler {
+	return otelhttp.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r)
+
+		if r.Pattern != "" {
+			span := trace.SpanFromContext(r.Context())
+			span.SetName(r.Pattern)
+			span.SetAttributes(semconv.HTTPRoute(r.Pattern))
+		}
+	}), "http.request")
+}
+
+// authMiddleware sets the user of the request. Without an authenticator the X-User-ID header is trusted,
+// otherwise a bearer token is required and the header, if sent anyway, must name the same user.
+// Requests are rate limited before they are rejected: by the user if authenticated, by the client IP otherwise,
+// so requests without or with invalid credentials are limited too.
+func (s *Server) authMiddleware(limiter Limiter, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// TODO
+		userID, err := s.authenticate(r)
+		if limiter != nil {
+			key := "user:" + userID
+			if err != nil || userID == "" {
+				key = "ip:" + clientIP(r)
+			}
+			if ok, retryAfter := limiter.Allow(key); !ok {
+				writeTooManyRequests(w, retryAfter)
+				return
+			}
+		}
+		if err != nil {
+			s.writeError(w, err)
+			return
+		}
+
+		if userID != "" {
+			r = r.WithContext(auth.WithUserID(r.Context(), userID))
+		}
+		next.ServeHTTP(w, r)
 	})
 }
+
+// authenticate returns the user of the request, empty for anonymous requests.
+func (s *Server) authenticate(r *http.Request) (string, error) {
+	userID := r.Header.Get(userIDHeader)
+	if s.auth == nil {
+		return userID, nil
+	}
+
+	token, ok := bearerToken(r)
+	if !ok {
+		return "", fmt.Errorf("%w: bearer token is required", auth.ErrUnauthenticated)
+	}
+	tokenUserID, err := s.auth.Verify(token)
+	if err != nil {
+		return "", err
+	}
+	if userID != "" && userID != tokenUserID {
+		return "", fmt.Errorf("%w: %s header does not match the token", auth.ErrUnauthenticated, userIDHeader)
+	}
+	return tokenUserID, nil
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", false
+	}
+	return token, true
+}
+
+func writeTooManyRequests(w http.ResponseWriter, retryAfter time.Duration) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusTooManyRequests)
+	_ = json.NewEncoder(w).Encode(errorResponse{Error: "too many requests"})
+}
+
+func clientIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return ip
+}
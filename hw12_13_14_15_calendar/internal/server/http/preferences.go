@@ -0,0 +1,43 @@
+package internalhttp
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+func (s *Server) getNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	userID, err := requireUserID(r)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	prefs, err := s.app.GetNotificationPreferences(r.Context(), userID)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, newPreferencesResponse(prefs))
+}
+
+func (s *Server) setNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	userID, err := requireUserID(r)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	var req preferencesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.writeError(w, fmt.Errorf("%w: %v", errBadRequest, err))
+		return
+	}
+
+	prefs, err := s.app.SetNotificationPreferences(r.Context(), req.toStorage(userID))
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, newPreferencesResponse(prefs))
+}
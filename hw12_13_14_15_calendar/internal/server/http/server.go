@@ -2,30 +2,158 @@ package internalhttp
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/app"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
 )
 
-type Server struct { // TODO
+type Server struct {
+	logger Logger
+	app    Application
+	auth   Authenticator
+	server *http.Server
+}
+
+type Logger interface {
+	Info(msg string)
+	Error(msg string)
+}
+
+type Limiter interface {
+	Allow(key string) (bool, time.Duration)
 }
 
-type Logger interface { // TODO
+// Authenticator returns the user ID from a bearer token.
+type Authenticator interface {
+	Verify(token string) (string, error)
 }
 
-type Application interface { // TODO
+type Application interface {
+	CreateEvent(
+		ctx context.Context, event storage.Event, userID, idempotencyKey string,
+	) (storage.Event, []string, error)
+	GetEvent(ctx context.Context, id, userID string) (storage.Event, error)
+	UpdateEvent(
+		ctx context.Context, event storage.Event, userID string, expectedVersion int64,
+	) (storage.Event, []string, error)
+	DeleteEvent(ctx context.Context, id, userID string, expectedVersion int64) error
+	ApplyBatch(
+		ctx context.Context, items []storage.BatchItem, userID string, atomic bool,
+	) ([]storage.BatchResult, error)
+	RestoreEvent(ctx context.Context, id, userID string, expectedVersion int64) (storage.Event, error)
+	EventHistory(ctx context.Context, id, userID string) ([]storage.Change, error)
+	ListDayEvents(ctx context.Context, userID string, date time.Time, opts app.ListOptions) (app.Page, error)
+	ListWeekEvents(ctx context.Context, userID string, date time.Time, opts app.ListOptions) (app.Page, error)
+	ListMonthEvents(ctx context.Context, userID string, date time.Time, opts app.ListOptions) (app.Page, error)
+	SearchEvents(ctx context.Context, userID, text string, opts app.SearchOptions) (app.Page, error)
+	LatestChangeID(ctx context.Context) (int64, error)
+	WatchChanges(ctx context.Context, userID string, afterID int64, send func(app.FeedEntry) error) error
+	CreateCalendar(ctx context.Context, userID, name string) (storage.Calendar, error)
+	GetCalendar(ctx context.Context, id, userID string) (storage.Calendar, error)
+	ListCalendars(ctx context.Context, userID string) ([]storage.Calendar, error)
+	ShareCalendar(
+		ctx context.Context, id, userID, withUserID string, access storage.Access,
+	) (storage.Calendar, error)
+	UnshareCalendar(ctx context.Context, id, userID, withUserID string) error
+	AddAttachment(
+		ctx context.Context, eventID, userID, name, contentType string, content io.Reader,
+	) (storage.Attachment, error)
+	ListAttachments(ctx context.Context, eventID, userID string) ([]storage.Attachment, error)
+	OpenAttachment(ctx context.Context, eventID, id, userID string) (storage.Attachment, io.ReadCloser, error)
+	DeleteAttachment(ctx context.Context, eventID, id, userID string) error
+	GetWorkingHours(ctx context.Context, userID string) (storage.WorkingHours, error)
+	SetWorkingHours(ctx context.Context, hours storage.WorkingHours) (storage.WorkingHours, error)
+	DeleteWorkingHours(ctx context.Context, userID string) error
+	GetNotificationPreferences(ctx context.Context, userID string) (storage.NotificationPreferences, error)
+	SetNotificationPreferences(
+		ctx context.Context, prefs storage.NotificationPreferences,
+	) (storage.NotificationPreferences, error)
+	HolidayCalendars() []string
+	FreeBusy(ctx context.Context, userID string, from, to time.Time) (app.FreeBusy, error)
 }
 
-func NewServer(logger Logger, app Application) *Server {
-	return &Server{}
+// NewServer creates the HTTP API server, requests are not rate limited if limiter is nil.
+// If authenticator is nil, the X-User-ID header is trusted, which is only suitable for local use.
+// The server listens in plaintext if tlsConfig is nil.
+func NewServer(
+	logger Logger,
+	app Application,
+	limiter Limiter,
+	authenticator Authenticator,
+	tlsConfig *tls.Config,
+	host, port string,
+) *Server {
+	s := &Server{
+		logger: logger,
+		app:    app,
+		auth:   authenticator,
+	}
+
+	mux := s.routes()
+	// requests rejected by the limiter or auth are counted too
+	handler := metricsMiddleware(mux, s.authMiddleware(limiter, mux))
+	s.server = &http.Server{
+		Addr:              net.JoinHostPort(host, port),
+		Handler:           tracingMiddleware(loggingMiddleware(logger, handler)),
+		ReadHeaderTimeout: 5 * time.Second,
+		TLSConfig:         tlsConfig,
+	}
+	return s
 }
 
 func (s *Server) Start(ctx context.Context) error {
-	// TODO
-	<-ctx.Done()
+	var err error
+	if s.server.TLSConfig != nil {
+		s.logger.Info("https server is listening on " + s.server.Addr)
+		// the certificate comes from the TLS config
+		err = s.server.ListenAndServeTLS("", "")
+	} else {
+		s.logger.Info("http server is listening on " + s.server.Addr)
+		err = s.server.ListenAndServe()
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
 	return nil
 }
 
 func (s *Server) Stop(ctx context.Context) error {
-	// TODO
-	return nil
+	return s.server.Shutdown(ctx)
 }
 
-// TODO
+func (s *Server) routes() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /events", s.createEvent)
+	mux.HandleFunc("GET /events", s.listEvents)
+	mux.HandleFunc("GET /events/search", s.searchEvents)
+	mux.HandleFunc("GET /events/stream", s.streamEvents)
+	mux.HandleFunc("POST /events/batch", s.applyBatch)
+	mux.HandleFunc("GET /events/{id}", s.getEvent)
+	mux.HandleFunc("PUT /events/{id}", s.updateEvent)
+	mux.HandleFunc("DELETE /events/{id}", s.deleteEvent)
+	mux.HandleFunc("POST /events/{id}/restore", s.restoreEvent)
+	mux.HandleFunc("GET /events/{id}/history", s.eventHistory)
+	mux.HandleFunc("POST /events/{id}/attachments", s.addAttachment)
+	mux.HandleFunc("GET /events/{id}/attachments", s.listAttachments)
+	mux.HandleFunc("GET /events/{id}/attachments/{attachment}", s.downloadAttachment)
+	mux.HandleFunc("DELETE /events/{id}/attachments/{attachment}", s.deleteAttachment)
+	mux.HandleFunc("POST /calendars", s.createCalendar)
+	mux.HandleFunc("GET /calendars", s.listCalendars)
+	mux.HandleFunc("GET /calendars/{id}", s.getCalendar)
+	mux.HandleFunc("PUT /calendars/{id}/shares/{user}", s.shareCalendar)
+	mux.HandleFunc("DELETE /calendars/{id}/shares/{user}", s.unshareCalendar)
+	mux.HandleFunc("GET /working-hours", s.getWorkingHours)
+	mux.HandleFunc("PUT /working-hours", s.setWorkingHours)
+	mux.HandleFunc("DELETE /working-hours", s.deleteWorkingHours)
+	mux.HandleFunc("GET /notification-preferences", s.getNotificationPreferences)
+	mux.HandleFunc("PUT /notification-preferences", s.setNotificationPreferences)
+	mux.HandleFunc("GET /holiday-calendars", s.listHolidayCalendars)
+	mux.HandleFunc("GET /freebusy", s.freeBusy)
+	return mux
+}
@@ -0,0 +1,90 @@
+package internalhttp
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+func (s *Server) getWorkingHours(w http.ResponseWriter, r *http.Request) {
+	userID, err := requireUserID(r)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	hours, err := s.app.GetWorkingHours(r.Context(), userID)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, newWorkingHoursResponse(hours))
+}
+
+func (s *Server) setWorkingHours(w http.ResponseWriter, r *http.Request) {
+	userID, err := requireUserID(r)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	var req workingHoursRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.writeError(w, fmt.Errorf("%w: %v", errBadRequest, err))
+		return
+	}
+
+	hours, err := s.app.SetWorkingHours(r.Context(), req.toStorage(userID))
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, newWorkingHoursResponse(hours))
+}
+
+func (s *Server) deleteWorkingHours(w http.ResponseWriter, r *http.Request) {
+	userID, err := requireUserID(r)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	if err := s.app.DeleteWorkingHours(r.Context(), userID); err != nil {
+		s.writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) listHolidayCalendars(w http.ResponseWriter, _ *http.Request) {
+	s.writeJSON(w, http.StatusOK, holidayCalendarsResponse{Calendars: s.app.HolidayCalendars()})
+}
+
+// freeBusy takes the period in the from and to parameters in RFC 3339 format.
+func (s *Server) freeBusy(w http.ResponseWriter, r *http.Request) {
+	userID, err := requireUserID(r)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	query := r.URL.Query()
+	from, err := time.Parse(time.RFC3339, query.Get("from"))
+	if err != nil {
+		s.writeError(w, fmt.Errorf("%w: from must be in RFC 3339 format", errBadRequest))
+		return
+	}
+	to, err := time.Parse(time.RFC3339, query.Get("to"))
+	if err != nil {
+		s.writeError(w, fmt.Errorf("%w: to must be in RFC 3339 format", errBadRequest))
+		return
+	}
+
+	freeBusy, err := s.app.FreeBusy(r.Context(), userID, from, to)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, newFreeBusyResponse(freeBusy))
+}
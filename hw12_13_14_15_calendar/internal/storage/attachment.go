@@ -0,0 +1,15 @@
+package storage
+
+import "time"
+
+// Attachment is a file attached to an event, its content is kept in a blob store by ID.
+type Attachment struct {
+	ID          string
+	EventID     string
+	Name        string
+	ContentType string
+	Size        int64
+	// UserID is the user who has attached the file.
+	UserID    string
+	CreatedAt time.Time
+}
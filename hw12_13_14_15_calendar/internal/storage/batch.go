@@ -0,0 +1,27 @@
+package storage
+
+// BatchOp is the operation of a batch item.
+type BatchOp string
+
+const (
+	BatchCreate BatchOp = "create"
+	BatchUpdate BatchOp = "update"
+	BatchDelete BatchOp = "delete"
+)
+
+// BatchItem is an operation on one event, ExpectedVersion is used by update and delete.
+// Delete uses only the ID of the event.
+type BatchItem struct {
+	Op              BatchOp
+	Event           Event
+	ExpectedVersion int64
+}
+
+// BatchResult is the outcome of a batch item. Event is the created or updated event,
+// only its ID is set for delete.
+type BatchResult struct {
+	Event Event
+	Err   error
+	// Warnings about the working time of applied items are set by the application, storages leave them empty.
+	Warnings []string
+}
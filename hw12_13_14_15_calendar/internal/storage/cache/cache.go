@@ -0,0 +1,330 @@
+// Package cache keeps results of event range queries in memory in front of a storage.
+package cache
+
+import (
+	"container/list"
+	"context"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/metrics"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+)
+
+type Storage interface {
+	CreateEvent(ctx context.Context, event storage.Event, userID string) (storage.Event, error)
+	CreateEventIdempotent(
+		ctx context.Context, event storage.Event, userID string, idempotency storage.Idempotency,
+	) (storage.Event, error)
+	GetEvent(ctx context.Context, id string) (storage.Event, error)
+	EventCalendar(ctx context.Context, id string) (string, error)
+	UpdateEvent(ctx context.Context, event storage.Event, userID string, expectedVersion int64) (storage.Event, error)
+	DeleteEvent(ctx context.Context, id, userID string, expectedVersion int64) error
+	ApplyBatch(ctx context.Context, items []storage.BatchItem, userID string) ([]storage.BatchResult, error)
+	RestoreEvent(ctx context.Context, id, userID string, expectedVersion int64) (storage.Event, error)
+	EventHistory(ctx context.Context, id string) ([]storage.Change, error)
+	ListChanges(ctx context.Context, query storage.ChangeQuery) ([]storage.Change, error)
+	LatestChangeID(ctx context.Context) (int64, error)
+	ListEvents(ctx context.Context, query storage.Query) ([]storage.Event, error)
+	SearchEvents(ctx context.Context, query storage.SearchQuery) ([]storage.SearchHit, error)
+	CountPurgeable(ctx context.Context, deletedBefore, endedBefore time.Time) (int64, error)
+	PurgeEvents(ctx context.Context, deletedBefore, endedBefore time.Time) (int64, error)
+	PurgeIdempotencyKeys(ctx context.Context, now time.Time) (int64, error)
+	DueNotifications(ctx context.Context, now time.Time) ([]storage.DueReminder, error)
+	MarkNotified(ctx context.Context, id string, reminder storage.Reminder, msg storage.OutboxMessage) error
+	PendingOutbox(ctx context.Context, limit int) ([]storage.OutboxMessage, error)
+	DeleteOutbox(ctx context.Context, id int64) error
+	CreateCalendar(ctx context.Context, calendar storage.Calendar) (storage.Calendar, error)
+	GetCalendar(ctx context.Context, id string) (storage.Calendar, error)
+	ListCalendars(ctx context.Context, userID string) ([]storage.Calendar, error)
+	ShareCalendar(ctx context.Context, calendarID, userID string, access storage.Access) error
+	UnshareCalendar(ctx context.Context, calendarID, userID string) error
+	CreateAttachment(ctx context.Context, attachment storage.Attachment) (storage.Attachment, error)
+	GetAttachment(ctx context.Context, id string) (storage.Attachment, error)
+	ListAttachments(ctx context.Context, eventID string) ([]storage.Attachment, error)
+	DeleteAttachment(ctx context.Context, id string) error
+	GetWorkingHours(ctx context.Context, userID string) (storage.WorkingHours, error)
+	SetWorkingHours(ctx context.Context, hours storage.WorkingHours) (storage.WorkingHours, error)
+	DeleteWorkingHours(ctx context.Context, userID string) error
+	GetNotificationPreferences(ctx context.Context, userID string) (storage.NotificationPreferences, error)
+	SetNotificationPreferences(
+		ctx context.Context, prefs storage.NotificationPreferences,
+	) (storage.NotificationPreferences, error)
+	ListNotificationPreferences(ctx context.Context, userIDs []string) ([]storage.NotificationPreferences, error)
+}
+
+// Options bound the cache, it keeps up to Size query results for TTL each.
+type Options struct {
+	Size int
+	TTL  time.Duration
+}
+
+// Wrapper caches results of ListEvents. A result is dropped when an event of its calendars overlapping
+// its period is created, changed or deleted through the wrapper, so users see their changes at once.
+// Changes made by other processes sharing the database are seen when the results expire.
+type Wrapper struct {
+	Storage
+	size int
+	ttl  time.Duration
+	now  func() time.Time
+
+	mu sync.Mutex
+	// lru holds entries, the most recently used first.
+	lru     *list.List
+	entries map[string]*list.Element
+	// byCalendar and byEvent find entries to drop when events change.
+	byCalendar map[string]map[*entry]struct{}
+	byEvent    map[string]map[*entry]struct{}
+	// generation is incremented when entries are dropped, results loaded meanwhile may be stale
+	// and are not cached.
+	generation uint64
+}
+
+type entry struct {
+	key       string
+	query     storage.Query
+	events    []storage.Event
+	expiresAt time.Time
+}
+
+func New(s Storage, opts Options) *Wrapper {
+	return &Wrapper{
+		Storage:    s,
+		size:       opts.Size,
+		ttl:        opts.TTL,
+		now:        time.Now,
+		lru:        list.New(),
+		entries:    make(map[string]*list.Element),
+		byCalendar: make(map[string]map[*entry]struct{}),
+		byEvent:    make(map[string]map[*entry]struct{}),
+	}
+}
+
+func (w *Wrapper) ListEvents(ctx context.Context, query storage.Query) ([]storage.Event, error) {
+	key := queryKey(query)
+	if events, ok := w.get(key); ok {
+		metrics.StorageCache.WithLabelValues("hit").Inc()
+		return events, nil
+	}
+	metrics.StorageCache.WithLabelValues("miss").Inc()
+
+	w.mu.Lock()
+	generation := w.generation
+	w.mu.Unlock()
+
+	events, err := w.Storage.ListEvents(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	w.add(key, query, events, generation)
+	return slices.Clone(events), nil
+}
+
+func (w *Wrapper) CreateEvent(ctx context.Context, event storage.Event, userID string) (storage.Event, error) {
+	created, err := w.Storage.CreateEvent(ctx, event, userID)
+	if err == nil {
+		w.invalidate(nil, []storage.Event{created})
+	}
+	return created, err
+}
+
+func (w *Wrapper) CreateEventIdempotent(
+	ctx context.Context,
+	event storage.Event,
+	userID string,
+	idempotency storage.Idempotency,
+) (storage.Event, error) {
+	created, err := w.Storage.CreateEventIdempotent(ctx, event, userID, idempotency)
+	if err == nil {
+		w.invalidate(nil, []storage.Event{created})
+	}
+	return created, err
+}
+
+// UpdateEvent drops results with the event before the update and the ones it overlaps after it.
+func (w *Wrapper) UpdateEvent(
+	ctx context.Context,
+	event storage.Event,
+	userID string,
+	expectedVersion int64,
+) (storage.Event, error) {
+	updated, err := w.Storage.UpdateEvent(ctx, event, userID, expectedVersion)
+	if err != nil {
+		w.invalidate([]string{event.ID}, nil)
+		return updated, err
+	}
+	w.invalidate([]string{event.ID}, []storage.Event{updated})
+	return updated, nil
+}
+
+func (w *Wrapper) DeleteEvent(ctx context.Context, id, userID string, expectedVersion int64) error {
+	err := w.Storage.DeleteEvent(ctx, id, userID, expectedVersion)
+	w.invalidate([]string{id}, nil)
+	return err
+}
+
+func (w *Wrapper) RestoreEvent(ctx context.Context, id, userID string, expectedVersion int64) (storage.Event, error) {
+	restored, err := w.Storage.RestoreEvent(ctx, id, userID, expectedVersion)
+	if err == nil {
+		w.invalidate(nil, []storage.Event{restored})
+	}
+	return restored, err
+}
+
+func (w *Wrapper) ApplyBatch(
+	ctx context.Context,
+	items []storage.BatchItem,
+	userID string,
+) ([]storage.BatchResult, error) {
+	results, err := w.Storage.ApplyBatch(ctx, items, userID)
+
+	ids := make([]string, 0, len(items))
+	events := make([]storage.Event, 0, len(items))
+	for i, item := range items {
+		ids = append(ids, item.Event.ID)
+		if i < len(results) && results[i].Err == nil && item.Op != storage.BatchDelete {
+			events = append(events, results[i].Event)
+		}
+	}
+	w.invalidate(ids, events)
+	return results, err
+}
+
+// PurgeEvents drops all the results, purged events are not known.
+func (w *Wrapper) PurgeEvents(ctx context.Context, deletedBefore, endedBefore time.Time) (int64, error) {
+	purged, err := w.Storage.PurgeEvents(ctx, deletedBefore, endedBefore)
+	if purged > 0 || err != nil {
+		w.mu.Lock()
+		w.generation++
+		for w.lru.Len() > 0 {
+			w.remove(w.lru.Back())
+		}
+		w.mu.Unlock()
+	}
+	return purged, err
+}
+
+// Len returns the number of cached results.
+func (w *Wrapper) Len() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.lru.Len()
+}
+
+func (w *Wrapper) get(key string) ([]storage.Event, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	elem, ok := w.entries[key]
+	if !ok {
+		return nil, false
+	}
+	e := elem.Value.(*entry)
+	if !w.now().Before(e.expiresAt) {
+		w.remove(elem)
+		return nil, false
+	}
+	w.lru.MoveToFront(elem)
+	return slices.Clone(e.events), true
+}
+
+func (w *Wrapper) add(key string, query storage.Query, events []storage.Event, generation uint64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.generation != generation {
+		return
+	}
+	if elem, ok := w.entries[key]; ok {
+		w.remove(elem)
+	}
+
+	e := &entry{key: key, query: query, events: events, expiresAt: w.now().Add(w.ttl)}
+	w.entries[key] = w.lru.PushFront(e)
+	for _, id := range query.CalendarIDs {
+		addTo(w.byCalendar, id, e)
+	}
+	for _, event := range events {
+		addTo(w.byEvent, event.ID, e)
+	}
+	for w.lru.Len() > w.size {
+		w.remove(w.lru.Back())
+	}
+}
+
+// invalidate drops results containing the events with the ids, and results the events would get in.
+func (w *Wrapper) invalidate(ids []string, events []storage.Event) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.generation++
+	for _, id := range ids {
+		for e := range w.byEvent[id] {
+			w.remove(w.entries[e.key])
+		}
+	}
+	for _, event := range events {
+		for e := range w.byCalendar[event.CalendarID] {
+			if event.Overlaps(storage.Event{StartsAt: e.query.From, EndsAt: e.query.To}) {
+				w.remove(w.entries[e.key])
+			}
+		}
+	}
+}
+
+func (w *Wrapper) remove(elem *list.Element) {
+	e := w.lru.Remove(elem).(*entry)
+	delete(w.entries, e.key)
+	for _, id := range e.query.CalendarIDs {
+		removeFrom(w.byCalendar, id, e)
+	}
+	for _, event := range e.events {
+		removeFrom(w.byEvent, event.ID, e)
+	}
+}
+
+func addTo(index map[string]map[*entry]struct{}, key string, e *entry) {
+	if index[key] == nil {
+		index[key] = make(map[*entry]struct{})
+	}
+	index[key][e] = struct{}{}
+}
+
+func removeFrom(index map[string]map[*entry]struct{}, key string, e *entry) {
+	delete(index[key], e)
+	if len(index[key]) == 0 {
+		delete(index, key)
+	}
+}
+
+// queryKey tells apart queries with different results.
+func queryKey(q storage.Query) string {
+	var b strings.Builder
+	for _, id := range q.CalendarIDs {
+		b.WriteString(strconv.Quote(id))
+	}
+	b.WriteString(" " + formatTime(q.From))
+	b.WriteString(" " + formatTime(q.To))
+	b.WriteString(" " + strconv.Quote(q.TitleContains))
+	if q.HasNotification != nil {
+		b.WriteString(" " + strconv.FormatBool(*q.HasNotification))
+	} else {
+		b.WriteString(" -")
+	}
+	b.WriteString(" " + formatTime(q.UpdatedSince))
+	if q.After != nil {
+		b.WriteString(" " + formatTime(q.After.StartsAt) + strconv.Quote(q.After.ID))
+	} else {
+		b.WriteString(" -")
+	}
+	b.WriteString(" " + strconv.Itoa(q.Limit))
+	return b.String()
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
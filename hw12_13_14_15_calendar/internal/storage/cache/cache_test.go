@@ -0,0 +1,138 @@
+package cache
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	memorystorage "github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage/memory"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage/storagetest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorage(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storagetest.Storage {
+		return New(memorystorage.New(), Options{Size: 100, TTL: time.Minute})
+	})
+}
+
+// countingStorage counts queries which reach the storage.
+type countingStorage struct {
+	*memorystorage.Storage
+	queries atomic.Int64
+}
+
+func (s *countingStorage) ListEvents(ctx context.Context, query storage.Query) ([]storage.Event, error) {
+	s.queries.Add(1)
+	return s.Storage.ListEvents(ctx, query)
+}
+
+func TestCache(t *testing.T) {
+	ctx := context.Background()
+	day := time.Date(2021, 8, 2, 0, 0, 0, 0, time.UTC)
+	now := day
+	s := &countingStorage{Storage: memorystorage.New()}
+	c := New(s, Options{Size: 3, TTL: time.Minute})
+	c.now = func() time.Time { return now }
+
+	newEvent := func(id, calendarID string, days int) storage.Event {
+		return storage.Event{
+			ID:         id,
+			Title:      "event " + id,
+			StartsAt:   day.AddDate(0, 0, days).Add(10 * time.Hour),
+			EndsAt:     day.AddDate(0, 0, days).Add(11 * time.Hour),
+			CalendarID: calendarID,
+			UserID:     "user " + calendarID,
+		}
+	}
+	dayQuery := func(days int) storage.Query {
+		return storage.Query{CalendarIDs: []string{"work"}, From: day.AddDate(0, 0, days), To: day.AddDate(0, 0, days+1)}
+	}
+	week := storage.Query{CalendarIDs: []string{"work", "home"}, From: day, To: day.AddDate(0, 0, 7)}
+	// list returns the number of events and whether they were cached
+	list := func(query storage.Query) (int, bool) {
+		before := s.queries.Load()
+		events, err := c.ListEvents(ctx, query)
+		require.NoError(t, err)
+		return len(events), s.queries.Load() == before
+	}
+	requireList := func(query storage.Query, n int, cached bool) {
+		t.Helper()
+		gotN, gotCached := list(query)
+		require.Equal(t, n, gotN)
+		require.Equal(t, cached, gotCached)
+	}
+
+	_, err := c.CreateEvent(ctx, newEvent("1", "work", 0), "user")
+	require.NoError(t, err)
+	requireList(dayQuery(0), 1, false)
+	requireList(dayQuery(0), 1, true)
+	requireList(dayQuery(1), 0, false)
+	requireList(week, 1, false)
+	requireList(week, 1, true)
+
+	// events of other calendars and periods keep the results
+	_, err = c.CreateEvent(ctx, newEvent("2", "other", 0), "user")
+	require.NoError(t, err)
+	_, err = c.CreateEvent(ctx, newEvent("3", "work", 2), "user")
+	require.NoError(t, err)
+	requireList(dayQuery(0), 1, true)
+	requireList(dayQuery(1), 0, true)
+	requireList(week, 2, false)
+
+	// the moved event leaves the first day and comes to the second one
+	moved := newEvent("1", "work", 1)
+	_, err = c.UpdateEvent(ctx, moved, "user", storage.AnyVersion)
+	require.NoError(t, err)
+	requireList(dayQuery(0), 0, false)
+	requireList(dayQuery(1), 1, false)
+	requireList(week, 2, false)
+
+	require.NoError(t, c.DeleteEvent(ctx, "1", "user", storage.AnyVersion))
+	requireList(dayQuery(1), 0, false)
+	_, err = c.RestoreEvent(ctx, "1", "user", storage.AnyVersion)
+	require.NoError(t, err)
+	requireList(dayQuery(1), 1, false)
+
+	results, err := c.ApplyBatch(ctx, []storage.BatchItem{
+		{Op: storage.BatchCreate, Event: newEvent("4", "home", 1)},
+		{Op: storage.BatchDelete, Event: storage.Event{ID: "3"}},
+	}, "user")
+	require.NoError(t, err)
+	require.NoError(t, results[0].Err)
+	requireList(dayQuery(1), 1, true)
+	requireList(week, 2, false)
+
+	// the least recently used result is evicted
+	requireList(dayQuery(3), 0, false)
+	require.Equal(t, 3, c.Len())
+	requireList(dayQuery(0), 0, false)
+	requireList(week, 2, true)
+
+	now = now.Add(time.Minute)
+	requireList(week, 2, false)
+
+	_, err = c.PurgeEvents(ctx, time.Time{}, day.AddDate(0, 0, 7))
+	require.NoError(t, err)
+	require.Zero(t, c.Len())
+}
+
+func TestStaleResultsAreNotCached(t *testing.T) {
+	ctx := context.Background()
+	day := time.Date(2021, 8, 2, 0, 0, 0, 0, time.UTC)
+	c := New(memorystorage.New(), Options{Size: 10, TTL: time.Minute})
+	query := storage.Query{CalendarIDs: []string{"work"}, From: day, To: day.AddDate(0, 0, 1)}
+
+	// a change between loading the result and caching it makes the result stale
+	c.mu.Lock()
+	generation := c.generation
+	c.mu.Unlock()
+	_, err := c.CreateEvent(ctx, storage.Event{
+		ID: "1", StartsAt: day, EndsAt: day.Add(time.Hour), CalendarID: "work", UserID: "user",
+	}, "user")
+	require.NoError(t, err)
+	c.add(queryKey(query), query, nil, generation)
+	require.Zero(t, c.Len())
+}
@@ -0,0 +1,57 @@
+package storage
+
+import "time"
+
+// Access is a level of access to a calendar, every level allows everything the lower ones do.
+type Access string
+
+const (
+	AccessRead  Access = "read"
+	AccessWrite Access = "write"
+	// AccessOwner also allows to share the calendar, it can't be granted by a share.
+	AccessOwner Access = "owner"
+)
+
+var accessLevels = map[Access]int{AccessRead: 1, AccessWrite: 2, AccessOwner: 3}
+
+// Allows reports whether the access includes the required one. The empty access allows nothing.
+func (a Access) Allows(required Access) bool {
+	return accessLevels[a] > 0 && accessLevels[a] >= accessLevels[required]
+}
+
+type Calendar struct {
+	ID      string
+	OwnerID string
+	// Name is unique among calendars of the owner.
+	Name string
+	// Shares are ordered by user ID.
+	Shares    []Share
+	CreatedAt time.Time
+}
+
+// Share grants a user other than the owner access to a calendar.
+type Share struct {
+	UserID string
+	Access Access
+}
+
+// Access returns the access of the user to the calendar, it is empty if the user has none.
+func (c Calendar) Access(userID string) Access {
+	if c.OwnerID == userID {
+		return AccessOwner
+	}
+	for _, share := range c.Shares {
+		if share.UserID == userID {
+			return share.Access
+		}
+	}
+	return ""
+}
+
+// Less reports whether the calendar goes before the other one in listings.
+func (c Calendar) Less(other Calendar) bool {
+	if c.Name == other.Name {
+		return c.ID < other.ID
+	}
+	return c.Name < other.Name
+}
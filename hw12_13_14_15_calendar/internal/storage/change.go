@@ -0,0 +1,62 @@
+package storage
+
+import (
+	"slices"
+	"time"
+)
+
+type Action string
+
+const (
+	ActionCreated  Action = "created"
+	ActionUpdated  Action = "updated"
+	ActionDeleted  Action = "deleted"
+	ActionRestored Action = "restored"
+)
+
+// Change is an entry of the append-only event history.
+type Change struct {
+	// ID orders changes of an event, readers of all changes resume after it.
+	ID        int64
+	EventID   string
+	Version   int64
+	Action    Action
+	UserID    string
+	Fields    []string
+	ChangedAt time.Time
+}
+
+// ChangeQuery selects changes of events in the calendars after the change AfterID. Changes are listed
+// in a stable order in which a change committed later never precedes the ones listed before.
+type ChangeQuery struct {
+	CalendarIDs []string
+	AfterID     int64
+	Limit       int
+}
+
+// ChangedFields returns names of the fields that differ between two revisions of an event.
+func ChangedFields(old, new Event) []string {
+	fields := make([]string, 0)
+	if old.Title != new.Title {
+		fields = append(fields, "title")
+	}
+	if !old.StartsAt.Equal(new.StartsAt) {
+		fields = append(fields, "starts_at")
+	}
+	if !old.EndsAt.Equal(new.EndsAt) {
+		fields = append(fields, "ends_at")
+	}
+	if old.Description != new.Description {
+		fields = append(fields, "description")
+	}
+	if old.CalendarID != new.CalendarID {
+		fields = append(fields, "calendar_id")
+	}
+	if old.UserID != new.UserID {
+		fields = append(fields, "user_id")
+	}
+	if !slices.Equal(old.Reminders, new.Reminders) {
+		fields = append(fields, "reminders")
+	}
+	return fields
+}
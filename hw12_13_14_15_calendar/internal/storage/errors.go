@@ -0,0 +1,24 @@
+package storage
+
+import "errors"
+
+var (
+	ErrEventNotFound      = errors.New("event not found")
+	ErrDateBusy           = errors.New("date is busy by another event")
+	ErrVersionMismatch    = errors.New("event version mismatch")
+	ErrEventNotDeleted    = errors.New("event is not deleted")
+	ErrCalendarNotFound   = errors.New("calendar not found")
+	ErrAttachmentNotFound = errors.New("attachment not found")
+	// ErrWorkingHoursNotFound is returned for users who haven't set their working hours.
+	ErrWorkingHoursNotFound = errors.New("working hours not found")
+	// ErrPreferencesNotFound is returned for users who haven't set their notification preferences.
+	ErrPreferencesNotFound = errors.New("notification preferences not found")
+	// ErrCalendarExists is returned when the owner already has a calendar with the same name.
+	ErrCalendarExists = errors.New("calendar with this name already exists")
+	// ErrIdempotencyKeyReused is returned when a key is sent again with a different request.
+	ErrIdempotencyKeyReused = errors.New("idempotency key is already used by another request")
+	// ErrAlreadyNotified is returned when another scan has already marked the reminder notified.
+	ErrAlreadyNotified = errors.New("reminder is already notified")
+	// ErrBatchAborted is the result of items rolled back because another item of an atomic batch has failed.
+	ErrBatchAborted = errors.New("batch is rolled back because another item has failed")
+)
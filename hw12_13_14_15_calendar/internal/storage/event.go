@@ -1,7 +1,52 @@
 package storage
 
+import "time"
+
 type Event struct {
-	ID    string
-	Title string
-	// TODO
+	ID          string
+	Title       string
+	StartsAt    time.Time
+	EndsAt      time.Time
+	Description string
+	// CalendarID is the calendar the event belongs to.
+	CalendarID string
+	// UserID is the owner of the calendar, who is reminded about the event.
+	UserID string
+	// Reminders are ordered by offset from the earliest and then by channel.
+	Reminders []Reminder
+	// Version is incremented on every change and is used for optimistic locking.
+	Version   int64
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	// DeletedAt is set when the event is soft deleted.
+	DeletedAt time.Time
+}
+
+// AnyVersion disables the version check on update and delete.
+const AnyVersion int64 = 0
+
+func (e Event) IsDeleted() bool {
+	return !e.DeletedAt.IsZero()
+}
+
+func (e Event) Overlaps(other Event) bool {
+	return e.StartsAt.Before(other.EndsAt) && other.StartsAt.Before(e.EndsAt)
+}
+
+// Purgeable reports whether the event is soft deleted before deletedBefore or ended before endedBefore.
+func (e Event) Purgeable(deletedBefore, endedBefore time.Time) bool {
+	return (e.IsDeleted() && e.DeletedAt.Before(deletedBefore)) || e.EndsAt.Before(endedBefore)
+}
+
+// ReminderDue reports whether the reminder about the live event is due at now.
+func (e Event) ReminderDue(r Reminder, now time.Time) bool {
+	return !e.IsDeleted() && !now.Before(e.StartsAt.Add(-r.Before)) && now.Before(e.StartsAt)
+}
+
+// Less reports whether the event goes before the other one in listings.
+func (e Event) Less(other Event) bool {
+	if e.StartsAt.Equal(other.StartsAt) {
+		return e.ID < other.ID
+	}
+	return e.StartsAt.Before(other.StartsAt)
 }
@@ -0,0 +1,12 @@
+package storage
+
+import "time"
+
+// Idempotency identifies a request which may be retried by the client.
+// Requests of the same user with the same Key are executed once while the key is not expired.
+type Idempotency struct {
+	Key string
+	// RequestHash tells apart different requests sent with the same key.
+	RequestHash string
+	ExpiresAt   time.Time
+}
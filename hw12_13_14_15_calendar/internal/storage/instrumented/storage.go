@@ -0,0 +1,267 @@
+package instrumented
+
+import (
+	"context"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/metrics"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+)
+
+type Storage interface {
+	CreateEvent(ctx context.Context, event storage.Event, userID string) (storage.Event, error)
+	CreateEventIdempotent(
+		ctx context.Context, event storage.Event, userID string, idempotency storage.Idempotency,
+	) (storage.Event, error)
+	GetEvent(ctx context.Context, id string) (storage.Event, error)
+	EventCalendar(ctx context.Context, id string) (string, error)
+	UpdateEvent(ctx context.Context, event storage.Event, userID string, expectedVersion int64) (storage.Event, error)
+	DeleteEvent(ctx context.Context, id, userID string, expectedVersion int64) error
+	ApplyBatch(ctx context.Context, items []storage.BatchItem, userID string) ([]storage.BatchResult, error)
+	RestoreEvent(ctx context.Context, id, userID string, expectedVersion int64) (storage.Event, error)
+	EventHistory(ctx context.Context, id string) ([]storage.Change, error)
+	ListChanges(ctx context.Context, query storage.ChangeQuery) ([]storage.Change, error)
+	LatestChangeID(ctx context.Context) (int64, error)
+	ListEvents(ctx context.Context, query storage.Query) ([]storage.Event, error)
+	SearchEvents(ctx context.Context, query storage.SearchQuery) ([]storage.SearchHit, error)
+	CountPurgeable(ctx context.Context, deletedBefore, endedBefore time.Time) (int64, error)
+	PurgeEvents(ctx context.Context, deletedBefore, endedBefore time.Time) (int64, error)
+	PurgeIdempotencyKeys(ctx context.Context, now time.Time) (int64, error)
+	DueNotifications(ctx context.Context, now time.Time) ([]storage.DueReminder, error)
+	MarkNotified(ctx context.Context, id string, reminder storage.Reminder, msg storage.OutboxMessage) error
+	PendingOutbox(ctx context.Context, limit int) ([]storage.OutboxMessage, error)
+	DeleteOutbox(ctx context.Context, id int64) error
+	CreateCalendar(ctx context.Context, calendar storage.Calendar) (storage.Calendar, error)
+	GetCalendar(ctx context.Context, id string) (storage.Calendar, error)
+	ListCalendars(ctx context.Context, userID string) ([]storage.Calendar, error)
+	ShareCalendar(ctx context.Context, calendarID, userID string, access storage.Access) error
+	UnshareCalendar(ctx context.Context, calendarID, userID string) error
+	CreateAttachment(ctx context.Context, attachment storage.Attachment) (storage.Attachment, error)
+	GetAttachment(ctx context.Context, id string) (storage.Attachment, error)
+	ListAttachments(ctx context.Context, eventID string) ([]storage.Attachment, error)
+	DeleteAttachment(ctx context.Context, id string) error
+	GetWorkingHours(ctx context.Context, userID string) (storage.WorkingHours, error)
+	SetWorkingHours(ctx context.Context, hours storage.WorkingHours) (storage.WorkingHours, error)
+	DeleteWorkingHours(ctx context.Context, userID string) error
+	GetNotificationPreferences(ctx context.Context, userID string) (storage.NotificationPreferences, error)
+	SetNotificationPreferences(
+		ctx context.Context, prefs storage.NotificationPreferences,
+	) (storage.NotificationPreferences, error)
+	ListNotificationPreferences(ctx context.Context, userIDs []string) ([]storage.NotificationPreferences, error)
+}
+
+// Wrapper records the duration of every storage operation.
+type Wrapper struct {
+	storage Storage
+}
+
+func New(storage Storage) *Wrapper {
+	return &Wrapper{storage: storage}
+}
+
+func (w *Wrapper) CreateEvent(ctx context.Context, event storage.Event, userID string) (storage.Event, error) {
+	defer observe("create_event", time.Now())
+	return w.storage.CreateEvent(ctx, event, userID)
+}
+
+func (w *Wrapper) CreateEventIdempotent(
+	ctx context.Context,
+	event storage.Event,
+	userID string,
+	idempotency storage.Idempotency,
+) (storage.Event, error) {
+	defer observe("create_event_idempotent", time.Now())
+	return w.storage.CreateEventIdempotent(ctx, event, userID, idempotency)
+}
+
+func (w *Wrapper) GetEvent(ctx context.Context, id string) (storage.Event, error) {
+	defer observe("get_event", time.Now())
+	return w.storage.GetEvent(ctx, id)
+}
+
+func (w *Wrapper) EventCalendar(ctx context.Context, id string) (string, error) {
+	defer observe("event_calendar", time.Now())
+	return w.storage.EventCalendar(ctx, id)
+}
+
+func (w *Wrapper) UpdateEvent(
+	ctx context.Context,
+	event storage.Event,
+	userID string,
+	expectedVersion int64,
+) (storage.Event, error) {
+	defer observe("update_event", time.Now())
+	return w.storage.UpdateEvent(ctx, event, userID, expectedVersion)
+}
+
+func (w *Wrapper) DeleteEvent(ctx context.Context, id, userID string, expectedVersion int64) error {
+	defer observe("delete_event", time.Now())
+	return w.storage.DeleteEvent(ctx, id, userID, expectedVersion)
+}
+
+func (w *Wrapper) ApplyBatch(
+	ctx context.Context,
+	items []storage.BatchItem,
+	userID string,
+) ([]storage.BatchResult, error) {
+	defer observe("apply_batch", time.Now())
+	return w.storage.ApplyBatch(ctx, items, userID)
+}
+
+func (w *Wrapper) RestoreEvent(ctx context.Context, id, userID string, expectedVersion int64) (storage.Event, error) {
+	defer observe("restore_event", time.Now())
+	return w.storage.RestoreEvent(ctx, id, userID, expectedVersion)
+}
+
+func (w *Wrapper) EventHistory(ctx context.Context, id string) ([]storage.Change, error) {
+	defer observe("event_history", time.Now())
+	return w.storage.EventHistory(ctx, id)
+}
+
+func (w *Wrapper) ListChanges(ctx context.Context, query storage.ChangeQuery) ([]storage.Change, error) {
+	defer observe("list_changes", time.Now())
+	return w.storage.ListChanges(ctx, query)
+}
+
+func (w *Wrapper) LatestChangeID(ctx context.Context) (int64, error) {
+	defer observe("latest_change_id", time.Now())
+	return w.storage.LatestChangeID(ctx)
+}
+
+func (w *Wrapper) ListEvents(ctx context.Context, query storage.Query) ([]storage.Event, error) {
+	defer observe("list_events", time.Now())
+	return w.storage.ListEvents(ctx, query)
+}
+
+func (w *Wrapper) SearchEvents(ctx context.Context, query storage.SearchQuery) ([]storage.SearchHit, error) {
+	defer observe("search_events", time.Now())
+	return w.storage.SearchEvents(ctx, query)
+}
+
+func (w *Wrapper) PurgeEvents(ctx context.Context, deletedBefore, endedBefore time.Time) (int64, error) {
+	defer observe("purge_events", time.Now())
+	return w.storage.PurgeEvents(ctx, deletedBefore, endedBefore)
+}
+
+func (w *Wrapper) PurgeIdempotencyKeys(ctx context.Context, now time.Time) (int64, error) {
+	defer observe("purge_idempotency_keys", time.Now())
+	return w.storage.PurgeIdempotencyKeys(ctx, now)
+}
+
+func (w *Wrapper) CountPurgeable(ctx context.Context, deletedBefore, endedBefore time.Time) (int64, error) {
+	defer observe("count_purgeable", time.Now())
+	return w.storage.CountPurgeable(ctx, deletedBefore, endedBefore)
+}
+
+func (w *Wrapper) DueNotifications(ctx context.Context, now time.Time) ([]storage.DueReminder, error) {
+	defer observe("due_notifications", time.Now())
+	return w.storage.DueNotifications(ctx, now)
+}
+
+func (w *Wrapper) MarkNotified(
+	ctx context.Context,
+	id string,
+	reminder storage.Reminder,
+	msg storage.OutboxMessage,
+) error {
+	defer observe("mark_notified", time.Now())
+	return w.storage.MarkNotified(ctx, id, reminder, msg)
+}
+
+func (w *Wrapper) PendingOutbox(ctx context.Context, limit int) ([]storage.OutboxMessage, error) {
+	defer observe("pending_outbox", time.Now())
+	return w.storage.PendingOutbox(ctx, limit)
+}
+
+func (w *Wrapper) DeleteOutbox(ctx context.Context, id int64) error {
+	defer observe("delete_outbox", time.Now())
+	return w.storage.DeleteOutbox(ctx, id)
+}
+
+func (w *Wrapper) CreateCalendar(ctx context.Context, calendar storage.Calendar) (storage.Calendar, error) {
+	defer observe("create_calendar", time.Now())
+	return w.storage.CreateCalendar(ctx, calendar)
+}
+
+func (w *Wrapper) GetCalendar(ctx context.Context, id string) (storage.Calendar, error) {
+	defer observe("get_calendar", time.Now())
+	return w.storage.GetCalendar(ctx, id)
+}
+
+func (w *Wrapper) ListCalendars(ctx context.Context, userID string) ([]storage.Calendar, error) {
+	defer observe("list_calendars", time.Now())
+	return w.storage.ListCalendars(ctx, userID)
+}
+
+func (w *Wrapper) ShareCalendar(ctx context.Context, calendarID, userID string, access storage.Access) error {
+	defer observe("share_calendar", time.Now())
+	return w.storage.ShareCalendar(ctx, calendarID, userID, access)
+}
+
+func (w *Wrapper) UnshareCalendar(ctx context.Context, calendarID, userID string) error {
+	defer observe("unshare_calendar", time.Now())
+	return w.storage.UnshareCalendar(ctx, calendarID, userID)
+}
+
+func (w *Wrapper) CreateAttachment(ctx context.Context, attachment storage.Attachment) (storage.Attachment, error) {
+	defer observe("create_attachment", time.Now())
+	return w.storage.CreateAttachment(ctx, attachment)
+}
+
+func (w *Wrapper) GetAttachment(ctx context.Context, id string) (storage.Attachment, error) {
+	defer observe("get_attachment", time.Now())
+	return w.storage.GetAttachment(ctx, id)
+}
+
+func (w *Wrapper) ListAttachments(ctx context.Context, eventID string) ([]storage.Attachment, error) {
+	defer observe("list_attachments", time.Now())
+	return w.storage.ListAttachments(ctx, eventID)
+}
+
+func (w *Wrapper) DeleteAttachment(ctx context.Context, id string) error {
+	defer observe("delete_attachment", time.Now())
+	return w.storage.DeleteAttachment(ctx, id)
+}
+
+func (w *Wrapper) GetWorkingHours(ctx context.Context, userID string) (storage.WorkingHours, error) {
+	defer observe("get_working_hours", time.Now())
+	return w.storage.GetWorkingHours(ctx, userID)
+}
+
+func (w *Wrapper) SetWorkingHours(ctx context.Context, hours storage.WorkingHours) (storage.WorkingHours, error) {
+	defer observe("set_working_hours", time.Now())
+	return w.storage.SetWorkingHours(ctx, hours)
+}
+
+func (w *Wrapper) DeleteWorkingHours(ctx context.Context, userID string) error {
+	defer observe("delete_working_hours", time.Now())
+	return w.storage.DeleteWorkingHours(ctx, userID)
+}
+
+func (w *Wrapper) GetNotificationPreferences(
+	ctx context.Context,
+	userID string,
+) (storage.NotificationPreferences, error) {
+	defer observe("get_notification_preferences", time.Now())
+	return w.storage.GetNotificationPreferences(ctx, userID)
+}
+
+func (w *Wrapper) SetNotificationPreferences(
+	ctx context.Context,
+	prefs storage.NotificationPreferences,
+) (storage.NotificationPreferences, error) {
+	defer observe("set_notification_preferences", time.Now())
+	return w.storage.SetNotificationPreferences(ctx, prefs)
+}
+
+func (w *Wrapper) ListNotificationPreferences(
+	ctx context.Context,
+	userIDs []string,
+) ([]storage.NotificationPreferences, error) {
+	defer observe("list_notification_preferences", time.Now())
+	return w.storage.ListNotificationPreferences(ctx, userIDs)
+}
+
+func observe(operation string, start time.Time) {
+	metrics.StorageDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+}
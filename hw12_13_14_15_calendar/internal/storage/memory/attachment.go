@@ -0,0 +1,73 @@
+package memorystorage
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+)
+
+func (s *Storage) CreateAttachment(ctx context.Context, a storage.Attachment) (storage.Attachment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return storage.Attachment{}, s.err
+	}
+
+	if _, ok := s.events[a.EventID]; !ok {
+		return storage.Attachment{}, storage.ErrEventNotFound
+	}
+	a.CreatedAt = time.Now().UTC()
+	s.attachments[a.ID] = a
+	mark(s.changed.attachments, a.ID)
+	return a, s.flush()
+}
+
+func (s *Storage) GetAttachment(ctx context.Context, id string) (storage.Attachment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	a, ok := s.attachments[id]
+	if !ok {
+		return storage.Attachment{}, storage.ErrAttachmentNotFound
+	}
+	return a, nil
+}
+
+// ListAttachments returns attachments of the event in the order they were added.
+func (s *Storage) ListAttachments(ctx context.Context, eventID string) ([]storage.Attachment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	attachments := make([]storage.Attachment, 0)
+	for _, a := range s.attachments {
+		if a.EventID == eventID {
+			attachments = append(attachments, a)
+		}
+	}
+	sort.Slice(attachments, func(i, j int) bool {
+		if attachments[i].CreatedAt.Equal(attachments[j].CreatedAt) {
+			return attachments[i].ID < attachments[j].ID
+		}
+		return attachments[i].CreatedAt.Before(attachments[j].CreatedAt)
+	})
+	return attachments, nil
+}
+
+func (s *Storage) DeleteAttachment(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return s.err
+	}
+
+	if _, ok := s.attachments[id]; !ok {
+		return storage.ErrAttachmentNotFound
+	}
+	delete(s.attachments, id)
+	mark(s.changed.attachments, id)
+	return s.flush()
+}
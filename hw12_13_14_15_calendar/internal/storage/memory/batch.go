@@ -0,0 +1,121 @@
+package memorystorage
+
+import (
+	"context"
+	"fmt"
+	"maps"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+)
+
+// snapshot is the state of an event before a batch item, to roll the item back.
+type snapshot struct {
+	id           string
+	event        storage.Event
+	existed      bool
+	historyLen   int
+	notified     map[storage.Reminder]struct{}
+	lastChangeID int64
+}
+
+// ApplyBatch applies all items or none of them. If an item fails, the others are rolled back
+// with storage.ErrBatchAborted, all items are tried to report every failure at once.
+func (s *Storage) ApplyBatch(
+	ctx context.Context,
+	items []storage.BatchItem,
+	userID string,
+) ([]storage.BatchResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	results := make([]storage.BatchResult, len(items))
+	snapshots := make([]snapshot, 0, len(items))
+	failed := false
+	for i, item := range items {
+		snap := s.snapshot(item.Event.ID)
+		results[i] = s.apply(item, userID)
+		if results[i].Err != nil {
+			failed = true
+			continue
+		}
+		snapshots = append(snapshots, snap)
+	}
+	if !failed {
+		return results, s.flush()
+	}
+
+	for i := len(snapshots) - 1; i >= 0; i-- {
+		s.restore(snapshots[i])
+	}
+	for i := range results {
+		if results[i].Err == nil {
+			results[i] = storage.BatchResult{Event: storage.Event{ID: items[i].Event.ID}, Err: storage.ErrBatchAborted}
+		}
+	}
+	return results, nil
+}
+
+func (s *Storage) apply(item storage.BatchItem, userID string) storage.BatchResult {
+	var (
+		event storage.Event
+		err   error
+	)
+	switch item.Op {
+	case storage.BatchCreate:
+		event, err = s.create(item.Event, userID)
+	case storage.BatchUpdate:
+		event, err = s.update(item.Event, userID, item.ExpectedVersion)
+	case storage.BatchDelete:
+		err = s.delete(item.Event.ID, userID, item.ExpectedVersion)
+		event = storage.Event{ID: item.Event.ID}
+	default:
+		err = fmt.Errorf("unknown batch operation %q", item.Op)
+	}
+	if err != nil {
+		return storage.BatchResult{Event: storage.Event{ID: item.Event.ID}, Err: err}
+	}
+	return storage.BatchResult{Event: event}
+}
+
+func (s *Storage) snapshot(id string) snapshot {
+	event, existed := s.events[id]
+	return snapshot{
+		id:           id,
+		event:        event,
+		existed:      existed,
+		historyLen:   len(s.history[id]),
+		notified:     maps.Clone(s.notified[id]),
+		lastChangeID: s.lastChangeID,
+	}
+}
+
+func (s *Storage) restore(snap snapshot) {
+	if current, ok := s.events[snap.id]; ok && !current.IsDeleted() {
+		s.index.remove(current)
+	}
+	if snap.existed {
+		s.events[snap.id] = snap.event
+		if !snap.event.IsDeleted() {
+			s.index.add(snap.event)
+		}
+	} else {
+		delete(s.events, snap.id)
+	}
+
+	if snap.historyLen > 0 {
+		s.history[snap.id] = s.history[snap.id][:snap.historyLen]
+	} else {
+		delete(s.history, snap.id)
+	}
+	if snap.notified != nil {
+		s.notified[snap.id] = snap.notified
+	} else {
+		delete(s.notified, snap.id)
+	}
+	s.lastChangeID = snap.lastChangeID
+	mark(s.changed.events, snap.id)
+}
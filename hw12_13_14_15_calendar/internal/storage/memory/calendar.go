@@ -0,0 +1,112 @@
+package memorystorage
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+)
+
+func (s *Storage) CreateCalendar(ctx context.Context, calendar storage.Calendar) (storage.Calendar, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return storage.Calendar{}, s.err
+	}
+
+	for _, c := range s.calendars {
+		if c.OwnerID == calendar.OwnerID && c.Name == calendar.Name {
+			return storage.Calendar{}, storage.ErrCalendarExists
+		}
+	}
+
+	calendar.Shares = nil
+	calendar.CreatedAt = time.Now().UTC()
+	s.calendars[calendar.ID] = calendar
+	mark(s.changed.calendars, calendar.ID)
+	return calendar, s.flush()
+}
+
+func (s *Storage) GetCalendar(ctx context.Context, id string) (storage.Calendar, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	calendar, ok := s.calendars[id]
+	if !ok {
+		return storage.Calendar{}, storage.ErrCalendarNotFound
+	}
+	calendar.Shares = slices.Clone(calendar.Shares)
+	return calendar, nil
+}
+
+// ListCalendars returns calendars owned by the user or shared with them.
+func (s *Storage) ListCalendars(ctx context.Context, userID string) ([]storage.Calendar, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	calendars := make([]storage.Calendar, 0)
+	for _, calendar := range s.calendars {
+		if calendar.Access(userID) != "" {
+			calendar.Shares = slices.Clone(calendar.Shares)
+			calendars = append(calendars, calendar)
+		}
+	}
+
+	sort.Slice(calendars, func(i, j int) bool {
+		return calendars[i].Less(calendars[j])
+	})
+	return calendars, nil
+}
+
+// ShareCalendar grants the user access to the calendar replacing the access granted before.
+func (s *Storage) ShareCalendar(ctx context.Context, calendarID, userID string, access storage.Access) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return s.err
+	}
+
+	calendar, ok := s.calendars[calendarID]
+	if !ok {
+		return storage.ErrCalendarNotFound
+	}
+	shares := slices.DeleteFunc(slices.Clone(calendar.Shares), func(share storage.Share) bool {
+		return share.UserID == userID
+	})
+	shares = append(shares, storage.Share{UserID: userID, Access: access})
+	sort.Slice(shares, func(i, j int) bool {
+		return shares[i].UserID < shares[j].UserID
+	})
+	calendar.Shares = shares
+	s.calendars[calendarID] = calendar
+	mark(s.changed.calendars, calendarID)
+	return s.flush()
+}
+
+// UnshareCalendar revokes access of the user to the calendar, it does nothing if there is none.
+func (s *Storage) UnshareCalendar(ctx context.Context, calendarID, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return s.err
+	}
+
+	calendar, ok := s.calendars[calendarID]
+	if !ok {
+		return storage.ErrCalendarNotFound
+	}
+	calendar.Shares = slices.DeleteFunc(slices.Clone(calendar.Shares), func(share storage.Share) bool {
+		return share.UserID == userID
+	})
+	if len(calendar.Shares) == 0 {
+		calendar.Shares = nil
+	}
+	s.calendars[calendarID] = calendar
+	mark(s.changed.calendars, calendarID)
+	return s.flush()
+}
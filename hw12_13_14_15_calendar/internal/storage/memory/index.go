@@ -0,0 +1,103 @@
+package memorystorage
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+)
+
+// Weights of words found in the title and the description, the same as default Postgres ranking weights.
+const (
+	titleWeight       = 1.0
+	descriptionWeight = 0.4
+)
+
+// index is an inverted index from words to ids of events containing them.
+type index map[string]map[string]struct{}
+
+func (idx index) add(e storage.Event) {
+	for _, word := range words(e.Title + " " + e.Description) {
+		ids, ok := idx[word]
+		if !ok {
+			ids = make(map[string]struct{})
+			idx[word] = ids
+		}
+		ids[e.ID] = struct{}{}
+	}
+}
+
+func (idx index) remove(e storage.Event) {
+	for _, word := range words(e.Title + " " + e.Description) {
+		delete(idx[word], e.ID)
+		if len(idx[word]) == 0 {
+			delete(idx, word)
+		}
+	}
+}
+
+// lookup returns ids of events containing all the words.
+func (idx index) lookup(words []string) []string {
+	if len(words) == 0 {
+		return nil
+	}
+
+	smallest := idx[words[0]]
+	for _, word := range words[1:] {
+		if len(idx[word]) < len(smallest) {
+			smallest = idx[word]
+		}
+	}
+
+	ids := make([]string, 0, len(smallest))
+	for id := range smallest {
+		found := true
+		for _, word := range words {
+			if _, ok := idx[word][id]; !ok {
+				found = false
+				break
+			}
+		}
+		if found {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func rank(e storage.Event, query []string) float64 {
+	var r float64
+	for _, word := range query {
+		r += titleWeight * float64(count(words(e.Title), word))
+		r += descriptionWeight * float64(count(words(e.Description), word))
+	}
+	return r
+}
+
+func words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func unique(words []string) []string {
+	seen := make(map[string]struct{}, len(words))
+	result := make([]string, 0, len(words))
+	for _, w := range words {
+		if _, ok := seen[w]; !ok {
+			seen[w] = struct{}{}
+			result = append(result, w)
+		}
+	}
+	return result
+}
+
+func count(words []string, word string) int {
+	n := 0
+	for _, w := range words {
+		if w == word {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,517 @@
+package memorystorage
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+)
+
+// Options persist the storage in files, it is kept in memory only if SnapshotFile is empty.
+type Options struct {
+	// SnapshotFile keeps the whole state, it is replaced atomically on every snapshot.
+	SnapshotFile string
+	// LogFile is the optional write-ahead log of changes since the last snapshot. Without it the changes
+	// since the last snapshot are lost if the process crashes.
+	LogFile string
+}
+
+type Logger interface {
+	Error(msg string)
+}
+
+// state is the JSON form of the storage in snapshots. Log records use it for the changed keys only.
+type state struct {
+	Events       []eventState                      `json:",omitempty"`
+	Idempotency  []idempotencyState                `json:",omitempty"`
+	Calendars    []storage.Calendar                `json:",omitempty"`
+	Attachments  []storage.Attachment              `json:",omitempty"`
+	WorkingHours []storage.WorkingHours            `json:",omitempty"`
+	Preferences  []storage.NotificationPreferences `json:",omitempty"`
+	Outbox       []storage.OutboxMessage           `json:",omitempty"`
+	LastChangeID int64
+	LastOutboxID int64
+}
+
+// eventState is an event with its history and sent reminders.
+type eventState struct {
+	Event    storage.Event
+	History  []storage.Change
+	Notified []storage.Reminder `json:",omitempty"`
+}
+
+type idempotencyID struct {
+	UserID string
+	Key    string
+}
+
+type idempotencyState struct {
+	UserID string
+	storage.Idempotency
+	Event storage.Event
+}
+
+// logRecord is the state of keys changed by an operation, keys missing from the storage are deleted.
+type logRecord struct {
+	state
+	Deleted deletedKeys
+}
+
+type deletedKeys struct {
+	Events       []string        `json:",omitempty"`
+	Idempotency  []idempotencyID `json:",omitempty"`
+	Attachments  []string        `json:",omitempty"`
+	WorkingHours []string        `json:",omitempty"`
+	Outbox       []int64         `json:",omitempty"`
+}
+
+// changes are keys changed since the last log record, the maps are nil if there is no log.
+type changes struct {
+	events       map[string]struct{}
+	idempotency  map[idempotencyKey]struct{}
+	calendars    map[string]struct{}
+	attachments  map[string]struct{}
+	workingHours map[string]struct{}
+	preferences  map[string]struct{}
+	outbox       map[int64]struct{}
+}
+
+func newChanges() changes {
+	return changes{
+		events:       make(map[string]struct{}),
+		idempotency:  make(map[idempotencyKey]struct{}),
+		calendars:    make(map[string]struct{}),
+		attachments:  make(map[string]struct{}),
+		workingHours: make(map[string]struct{}),
+		preferences:  make(map[string]struct{}),
+		outbox:       make(map[int64]struct{}),
+	}
+}
+
+func (c changes) empty() bool {
+	return len(c.events)+len(c.idempotency)+len(c.calendars)+len(c.attachments)+
+		len(c.workingHours)+len(c.preferences)+len(c.outbox) == 0
+}
+
+func (c changes) reset() {
+	clear(c.events)
+	clear(c.idempotency)
+	clear(c.calendars)
+	clear(c.attachments)
+	clear(c.workingHours)
+	clear(c.preferences)
+	clear(c.outbox)
+}
+
+// mark remembers the key is changed, it does nothing without the log.
+func mark[K comparable](keys map[K]struct{}, key K) {
+	if keys != nil {
+		keys[key] = struct{}{}
+	}
+}
+
+// Open returns the storage restored from the snapshot and the log. The log is replayed on top of the snapshot,
+// a record torn by a crash at the end of the log is dropped.
+func Open(opts Options) (*Storage, error) {
+	s := New()
+	if opts.SnapshotFile == "" {
+		if opts.LogFile != "" {
+			return nil, errors.New("storage log requires a snapshot file")
+		}
+		return s, nil
+	}
+	s.opts = opts
+
+	if err := s.loadSnapshot(); err != nil {
+		return nil, err
+	}
+	if opts.LogFile != "" {
+		if err := s.openLog(); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+func (s *Storage) loadSnapshot() error {
+	data, err := os.ReadFile(s.opts.SnapshotFile)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return fmt.Errorf("unable to read snapshot: %w", err)
+	default:
+		var st state
+		if err := json.Unmarshal(data, &st); err != nil {
+			return fmt.Errorf("unable to decode snapshot %s: %w", s.opts.SnapshotFile, err)
+		}
+		s.put(st)
+	}
+	return nil
+}
+
+func (s *Storage) openLog() error {
+	f, err := os.OpenFile(s.opts.LogFile, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("unable to open storage log: %w", err)
+	}
+	offset, err := s.replay(f)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Truncate(offset); err != nil {
+		f.Close()
+		return fmt.Errorf("unable to truncate storage log: %w", err)
+	}
+
+	s.log = f
+	s.logSize = offset
+	s.changed = newChanges()
+	return nil
+}
+
+// replay applies the log records and returns the end of the last whole one.
+func (s *Storage) replay(f io.Reader) (int64, error) {
+	var offset int64
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			// the last write hasn't completed, what is left of it is dropped
+			return offset, nil
+		}
+		if err != nil {
+			return 0, fmt.Errorf("unable to read storage log: %w", err)
+		}
+		var record logRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			return 0, fmt.Errorf("unable to decode storage log %s at offset %d: %w", s.opts.LogFile, offset, err)
+		}
+		s.put(record.state)
+		s.remove(record.Deleted)
+		offset += int64(len(line))
+	}
+}
+
+// rollback restores the state from the snapshot and the whole records of the log, so the change
+// which failed to be logged is undone, and truncates what has been written of the failed record.
+func (s *Storage) rollback() error {
+	restored := New()
+	restored.opts = s.opts
+	if err := restored.loadSnapshot(); err != nil {
+		return err
+	}
+	f, err := os.Open(s.opts.LogFile)
+	if err != nil {
+		return fmt.Errorf("unable to open storage log: %w", err)
+	}
+	defer f.Close()
+	if _, err := restored.replay(io.LimitReader(f, s.logSize)); err != nil {
+		return err
+	}
+
+	s.events, s.history, s.lastChangeID, s.index = restored.events, restored.history, restored.lastChangeID, restored.index
+	s.notified, s.idempotency = restored.notified, restored.idempotency
+	s.calendars, s.attachments, s.workingHours = restored.calendars, restored.attachments, restored.workingHours
+	s.preferences = restored.preferences
+	s.outbox, s.lastOutboxID = restored.outbox, restored.lastOutboxID
+	s.changed.reset()
+
+	// the next record would be appended to the torn one otherwise
+	if err := s.log.Truncate(s.logSize); err != nil {
+		return fmt.Errorf("unable to truncate storage log: %w", err)
+	}
+	return nil
+}
+
+// Snapshot writes the whole state to the snapshot file and empties the log. Changes wait until it is written.
+func (s *Storage) Snapshot() error {
+	if s.opts.SnapshotFile == "" {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := json.Marshal(s.state())
+	if err != nil {
+		return fmt.Errorf("unable to encode snapshot: %w", err)
+	}
+	if err := writeFileAtomic(s.opts.SnapshotFile, data); err != nil {
+		return fmt.Errorf("unable to write snapshot: %w", err)
+	}
+
+	if s.log != nil {
+		if err := s.log.Truncate(0); err != nil {
+			return fmt.Errorf("unable to truncate storage log: %w", err)
+		}
+		if err := s.log.Sync(); err != nil {
+			return fmt.Errorf("unable to truncate storage log: %w", err)
+		}
+		s.logSize = 0
+		s.changed.reset()
+	}
+	return nil
+}
+
+// RunSnapshots writes a snapshot every interval until ctx is done.
+func (s *Storage) RunSnapshots(ctx context.Context, interval time.Duration, logger Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Snapshot(); err != nil {
+				logger.Error(err.Error())
+			}
+		}
+	}
+}
+
+// Close writes the last snapshot and closes the log.
+func (s *Storage) Close() error {
+	err := s.Snapshot()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.log != nil {
+		if closeErr := s.log.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("unable to close storage log: %w", closeErr)
+		}
+		s.log = nil
+		s.changed = changes{}
+	}
+	return err
+}
+
+// flush appends the state of the changed keys to the log. If it fails, the changes are rolled back,
+// so nothing is visible which isn't in the log.
+func (s *Storage) flush() error {
+	if s.log == nil || s.changed.empty() {
+		return nil
+	}
+
+	record := logRecord{state: state{LastChangeID: s.lastChangeID, LastOutboxID: s.lastOutboxID}}
+	for id := range s.changed.events {
+		if _, ok := s.events[id]; ok {
+			record.Events = append(record.Events, s.eventState(id))
+		} else {
+			record.Deleted.Events = append(record.Deleted.Events, id)
+		}
+	}
+	for key := range s.changed.idempotency {
+		if r, ok := s.idempotency[key]; ok {
+			record.Idempotency = append(record.Idempotency, idempotencyState{
+				UserID: key.userID, Idempotency: r.Idempotency, Event: r.event,
+			})
+		} else {
+			record.Deleted.Idempotency = append(record.Deleted.Idempotency,
+				idempotencyID{UserID: key.userID, Key: key.key})
+		}
+	}
+	for id := range s.changed.calendars {
+		record.Calendars = append(record.Calendars, s.calendars[id])
+	}
+	for id := range s.changed.attachments {
+		if a, ok := s.attachments[id]; ok {
+			record.Attachments = append(record.Attachments, a)
+		} else {
+			record.Deleted.Attachments = append(record.Deleted.Attachments, id)
+		}
+	}
+	for userID := range s.changed.workingHours {
+		if hours, ok := s.workingHours[userID]; ok {
+			record.WorkingHours = append(record.WorkingHours, hours)
+		} else {
+			record.Deleted.WorkingHours = append(record.Deleted.WorkingHours, userID)
+		}
+	}
+	for userID := range s.changed.preferences {
+		record.Preferences = append(record.Preferences, s.preferences[userID])
+	}
+	for id := range s.changed.outbox {
+		i := slices.IndexFunc(s.outbox, func(msg storage.OutboxMessage) bool { return msg.ID == id })
+		if i >= 0 {
+			record.Outbox = append(record.Outbox, s.outbox[i])
+		} else {
+			record.Deleted.Outbox = append(record.Deleted.Outbox, id)
+		}
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		err = fmt.Errorf("unable to encode storage log record: %w", err)
+	} else {
+		err = s.append(append(data, '\n'))
+	}
+	if err != nil {
+		if rollbackErr := s.rollback(); rollbackErr != nil {
+			s.err = fmt.Errorf("storage is inconsistent with its log: %w", rollbackErr)
+			return errors.Join(err, s.err)
+		}
+		return err
+	}
+	s.changed.reset()
+	return nil
+}
+
+func (s *Storage) append(record []byte) error {
+	if _, err := s.log.Write(record); err != nil {
+		return fmt.Errorf("unable to write storage log: %w", err)
+	}
+	if err := s.log.Sync(); err != nil {
+		return fmt.Errorf("unable to write storage log: %w", err)
+	}
+	s.logSize += int64(len(record))
+	return nil
+}
+
+func (s *Storage) state() state {
+	st := state{LastChangeID: s.lastChangeID, LastOutboxID: s.lastOutboxID, Outbox: s.outbox}
+	for id := range s.events {
+		st.Events = append(st.Events, s.eventState(id))
+	}
+	for key, r := range s.idempotency {
+		st.Idempotency = append(st.Idempotency, idempotencyState{
+			UserID: key.userID, Idempotency: r.Idempotency, Event: r.event,
+		})
+	}
+	for _, c := range s.calendars {
+		st.Calendars = append(st.Calendars, c)
+	}
+	for _, a := range s.attachments {
+		st.Attachments = append(st.Attachments, a)
+	}
+	for _, hours := range s.workingHours {
+		st.WorkingHours = append(st.WorkingHours, hours)
+	}
+	for _, prefs := range s.preferences {
+		st.Preferences = append(st.Preferences, prefs)
+	}
+	return st
+}
+
+func (s *Storage) eventState(id string) eventState {
+	es := eventState{Event: s.events[id], History: s.history[id]}
+	for reminder := range s.notified[id] {
+		es.Notified = append(es.Notified, reminder)
+	}
+	return es
+}
+
+// put replaces the state of the keys in st.
+func (s *Storage) put(st state) {
+	for _, es := range st.Events {
+		if current, ok := s.events[es.Event.ID]; ok && !current.IsDeleted() {
+			s.index.remove(current)
+		}
+		s.events[es.Event.ID] = es.Event
+		if !es.Event.IsDeleted() {
+			s.index.add(es.Event)
+		}
+		s.history[es.Event.ID] = es.History
+		delete(s.notified, es.Event.ID)
+		for _, reminder := range es.Notified {
+			if s.notified[es.Event.ID] == nil {
+				s.notified[es.Event.ID] = make(map[storage.Reminder]struct{})
+			}
+			s.notified[es.Event.ID][reminder] = struct{}{}
+		}
+	}
+	for _, r := range st.Idempotency {
+		s.idempotency[idempotencyKey{userID: r.UserID, key: r.Key}] = idempotencyRecord{
+			Idempotency: r.Idempotency, event: r.Event,
+		}
+	}
+	for _, c := range st.Calendars {
+		s.calendars[c.ID] = c
+	}
+	for _, a := range st.Attachments {
+		s.attachments[a.ID] = a
+	}
+	for _, hours := range st.WorkingHours {
+		s.workingHours[hours.UserID] = hours
+	}
+	for _, prefs := range st.Preferences {
+		s.preferences[prefs.UserID] = prefs
+	}
+	for _, msg := range st.Outbox {
+		s.outbox = slices.DeleteFunc(s.outbox, func(m storage.OutboxMessage) bool { return m.ID == msg.ID })
+		s.outbox = append(s.outbox, msg)
+	}
+	sort.Slice(s.outbox, func(i, j int) bool {
+		return s.outbox[i].ID < s.outbox[j].ID
+	})
+	s.lastChangeID = max(s.lastChangeID, st.LastChangeID)
+	s.lastOutboxID = max(s.lastOutboxID, st.LastOutboxID)
+}
+
+// remove deletes the keys.
+func (s *Storage) remove(keys deletedKeys) {
+	for _, id := range keys.Events {
+		if event, ok := s.events[id]; ok && !event.IsDeleted() {
+			s.index.remove(event)
+		}
+		delete(s.events, id)
+		delete(s.history, id)
+		delete(s.notified, id)
+	}
+	for _, r := range keys.Idempotency {
+		delete(s.idempotency, idempotencyKey{userID: r.UserID, key: r.Key})
+	}
+	for _, id := range keys.Attachments {
+		delete(s.attachments, id)
+	}
+	for _, userID := range keys.WorkingHours {
+		delete(s.workingHours, userID)
+	}
+	for _, id := range keys.Outbox {
+		s.outbox = slices.DeleteFunc(s.outbox, func(msg storage.OutboxMessage) bool { return msg.ID == id })
+	}
+}
+
+// writeFileAtomic replaces the file with data, so readers see either the old or the new content
+// even after a crash.
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	f, err := os.CreateTemp(dir, filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		return err
+	}
+
+	// the rename itself is durable once the directory is synced
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
@@ -0,0 +1,185 @@
+package memorystorage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage/storagetest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPersistedStorage(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storagetest.Storage {
+		dir := t.TempDir()
+		s, err := Open(Options{
+			SnapshotFile: filepath.Join(dir, "snapshot.json"),
+			LogFile:      filepath.Join(dir, "storage.log"),
+		})
+		require.NoError(t, err)
+		t.Cleanup(func() { require.NoError(t, s.Close()) })
+		return s
+	})
+}
+
+func TestPersistence(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	opts := Options{SnapshotFile: filepath.Join(dir, "snapshot.json"), LogFile: filepath.Join(dir, "storage.log")}
+	day := time.Date(2021, 8, 2, 0, 0, 0, 0, time.UTC)
+	reminder := storage.Reminder{Before: time.Hour, Channel: storage.ChannelLog}
+
+	s, err := Open(opts)
+	require.NoError(t, err)
+	_, err = s.CreateCalendar(ctx, storage.Calendar{ID: "calendar", OwnerID: "user", Name: "Work"})
+	require.NoError(t, err)
+	require.NoError(t, s.ShareCalendar(ctx, "calendar", "reader", storage.AccessRead))
+	newEvent := func(id string, hours int) storage.Event {
+		return storage.Event{
+			ID:         id,
+			Title:      "Meeting " + id,
+			StartsAt:   day.Add(time.Duration(hours) * time.Hour),
+			EndsAt:     day.Add(time.Duration(hours+1) * time.Hour),
+			CalendarID: "calendar",
+			UserID:     "user",
+			Reminders:  []storage.Reminder{reminder},
+		}
+	}
+	first, err := s.CreateEvent(ctx, newEvent("1", 10), "user")
+	require.NoError(t, err)
+	first.Title = "Planning"
+	_, err = s.UpdateEvent(ctx, first, "user", storage.AnyVersion)
+	require.NoError(t, err)
+	_, err = s.CreateEventIdempotent(ctx, newEvent("2", 12), "user", storage.Idempotency{
+		Key: "key", RequestHash: "hash", ExpiresAt: day.Add(24 * time.Hour),
+	})
+	require.NoError(t, err)
+	require.NoError(t, s.DeleteEvent(ctx, "2", "user", storage.AnyVersion))
+	require.NoError(t, s.MarkNotified(ctx, "1", reminder, storage.OutboxMessage{Body: []byte("first")}))
+	_, err = s.CreateAttachment(ctx, storage.Attachment{ID: "attachment", EventID: "1", Name: "notes.txt"})
+	require.NoError(t, err)
+	_, err = s.SetWorkingHours(ctx, storage.WorkingHours{
+		UserID:   "user",
+		TimeZone: "UTC",
+		Periods:  []storage.WorkingPeriod{{Weekday: time.Monday, Start: 9 * time.Hour, End: 18 * time.Hour}},
+	})
+	require.NoError(t, err)
+	_, err = s.SetNotificationPreferences(ctx, storage.NotificationPreferences{UserID: "user", Locale: "ru"})
+	require.NoError(t, err)
+	// the failed batch is rolled back and doesn't get to the log
+	results, err := s.ApplyBatch(ctx, []storage.BatchItem{
+		{Op: storage.BatchCreate, Event: newEvent("3", 14)},
+		{Op: storage.BatchDelete, Event: storage.Event{ID: "missing"}},
+	}, "user")
+	require.NoError(t, err)
+	require.ErrorIs(t, results[0].Err, storage.ErrBatchAborted)
+
+	// nothing is lost when the storage is not closed, as if the process crashed
+	requireRestored(t, s, opts)
+
+	require.NoError(t, s.Snapshot())
+	info, err := os.Stat(opts.LogFile)
+	require.NoError(t, err)
+	require.Zero(t, info.Size())
+
+	_, err = s.CreateEvent(ctx, newEvent("4", 16), "user")
+	require.NoError(t, err)
+	purged, err := s.PurgeEvents(ctx, time.Now().Add(time.Hour), time.Time{})
+	require.NoError(t, err)
+	require.EqualValues(t, 1, purged)
+	_, err = s.PurgeIdempotencyKeys(ctx, day.Add(48*time.Hour))
+	require.NoError(t, err)
+	require.NoError(t, s.DeleteOutbox(ctx, 1))
+	require.NoError(t, s.DeleteAttachment(ctx, "attachment"))
+	require.NoError(t, s.DeleteWorkingHours(ctx, "user"))
+	require.NoError(t, s.UnshareCalendar(ctx, "calendar", "reader"))
+	restored := requireRestored(t, s, opts)
+	hits, err := restored.SearchEvents(ctx, storage.SearchQuery{CalendarIDs: []string{"calendar"}, Text: "planning"})
+	require.NoError(t, err)
+	require.Len(t, hits, 1)
+
+	// a record torn by a crash is dropped, and the next records are appended after the last whole one
+	f, err := os.OpenFile(opts.LogFile, os.O_WRONLY|os.O_APPEND, 0o600)
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"Events":[{"Event":`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	restored = requireRestored(t, s, opts)
+	_, err = restored.CreateCalendar(ctx, storage.Calendar{ID: "home", OwnerID: "user", Name: "Home"})
+	require.NoError(t, err)
+	requireRestored(t, restored, opts)
+
+	require.NoError(t, s.Close())
+	requireRestored(t, s, Options{SnapshotFile: opts.SnapshotFile})
+
+	require.NoError(t, os.WriteFile(opts.LogFile, []byte("{}\nnot json\n{}\n"), 0o600))
+	_, err = Open(opts)
+	require.Error(t, err)
+	_, err = Open(Options{LogFile: opts.LogFile})
+	require.Error(t, err)
+}
+
+func TestLogFailure(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	opts := Options{SnapshotFile: filepath.Join(dir, "snapshot.json"), LogFile: filepath.Join(dir, "storage.log")}
+	s, err := Open(opts)
+	require.NoError(t, err)
+	t.Cleanup(func() { s.log.Close() })
+	_, err = s.CreateCalendar(ctx, storage.Calendar{ID: "work", OwnerID: "user", Name: "Work"})
+	require.NoError(t, err)
+
+	// the log can't be written nor truncated through a read-only file
+	s.mu.Lock()
+	writable := s.log
+	s.log, err = os.Open(opts.LogFile)
+	s.mu.Unlock()
+	require.NoError(t, err)
+	require.NoError(t, writable.Close())
+
+	_, err = s.CreateCalendar(ctx, storage.Calendar{ID: "home", OwnerID: "user", Name: "Home"})
+	require.Error(t, err)
+	_, err = s.GetCalendar(ctx, "home")
+	require.ErrorIs(t, err, storage.ErrCalendarNotFound, "changes which failed to be logged are rolled back")
+	_, err = s.GetCalendar(ctx, "work")
+	require.NoError(t, err)
+
+	// the log may have a torn record, so nothing is written after it
+	require.Error(t, s.err)
+	require.ErrorIs(t, s.ShareCalendar(ctx, "work", "reader", storage.AccessRead), s.err)
+	requireRestored(t, s, opts)
+}
+
+// requireRestored opens the storage from the files and checks it has the same state as s.
+func requireRestored(t *testing.T, s *Storage, opts Options) *Storage {
+	t.Helper()
+	restored, err := Open(opts)
+	require.NoError(t, err)
+	require.Equal(t, sortedState(s), sortedState(restored))
+	require.Equal(t, s.index, restored.index)
+	return restored
+}
+
+func sortedState(s *Storage) state {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	st := s.state()
+	sort.Slice(st.Events, func(i, j int) bool { return st.Events[i].Event.ID < st.Events[j].Event.ID })
+	for _, e := range st.Events {
+		sort.Slice(e.Notified, func(i, j int) bool { return e.Notified[i].Less(e.Notified[j]) })
+	}
+	sort.Slice(st.Idempotency, func(i, j int) bool { return st.Idempotency[i].Key < st.Idempotency[j].Key })
+	sort.Slice(st.Calendars, func(i, j int) bool { return st.Calendars[i].ID < st.Calendars[j].ID })
+	sort.Slice(st.Attachments, func(i, j int) bool { return st.Attachments[i].ID < st.Attachments[j].ID })
+	sort.Slice(st.WorkingHours, func(i, j int) bool { return st.WorkingHours[i].UserID < st.WorkingHours[j].UserID })
+	sort.Slice(st.Preferences, func(i, j int) bool { return st.Preferences[i].UserID < st.Preferences[j].UserID })
+	if len(st.Outbox) == 0 {
+		st.Outbox = nil
+	}
+	return st
+}
@@ -0,0 +1,57 @@
+package memorystorage
+
+import (
+	"context"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+)
+
+func (s *Storage) GetNotificationPreferences(
+	ctx context.Context,
+	userID string,
+) (storage.NotificationPreferences, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	prefs, ok := s.preferences[userID]
+	if !ok {
+		return storage.NotificationPreferences{}, storage.ErrPreferencesNotFound
+	}
+	return prefs, nil
+}
+
+// SetNotificationPreferences replaces the notification preferences of the user.
+func (s *Storage) SetNotificationPreferences(
+	ctx context.Context,
+	prefs storage.NotificationPreferences,
+) (storage.NotificationPreferences, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return storage.NotificationPreferences{}, s.err
+	}
+
+	prefs.UpdatedAt = time.Now().UTC()
+	s.preferences[prefs.UserID] = prefs
+	mark(s.changed.preferences, prefs.UserID)
+	return prefs, s.flush()
+}
+
+// ListNotificationPreferences returns the preferences of the users who have set them.
+func (s *Storage) ListNotificationPreferences(
+	ctx context.Context,
+	userIDs []string,
+) ([]storage.NotificationPreferences, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var list []storage.NotificationPreferences
+	for _, userID := range userIDs {
+		if prefs, ok := s.preferences[userID]; ok {
+			list = append(list, prefs)
+		}
+	}
+	return list, nil
+}
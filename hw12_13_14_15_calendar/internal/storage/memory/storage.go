@@ -1,14 +1,553 @@
 package memorystorage
 
-import "sync"
+import (
+	"context"
+	"os"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+)
 
 type Storage struct {
-	// TODO
-	mu sync.RWMutex
+	mu      sync.RWMutex
+	events  map[string]storage.Event
+	history map[string][]storage.Change
+	// lastChangeID is the ID of the last recorded change.
+	lastChangeID int64
+	// index contains live events only.
+	index index
+	// notified contains reminders sent by event id.
+	notified map[string]map[storage.Reminder]struct{}
+	// idempotency contains created events by user id and idempotency key.
+	idempotency map[idempotencyKey]idempotencyRecord
+	calendars   map[string]storage.Calendar
+	attachments map[string]storage.Attachment
+	// workingHours contains working hours by user id.
+	workingHours map[string]storage.WorkingHours
+	// preferences contains notification preferences by user id.
+	preferences map[string]storage.NotificationPreferences
+	// outbox contains messages waiting to be published ordered by id.
+	outbox       []storage.OutboxMessage
+	lastOutboxID int64
+
+	// opts, log and changed persist the storage, see Open.
+	opts Options
+	log  *os.File
+	// logSize is the end of the last whole record in the log.
+	logSize int64
+	changed changes
+	// err is set when a failed log record couldn't be rolled back, changes are rejected since then.
+	err error
+}
+
+type idempotencyKey struct {
+	userID string
+	key    string
+}
+
+type idempotencyRecord struct {
+	storage.Idempotency
+	event storage.Event
 }
 
 func New() *Storage {
-	return &Storage{}
+	return &Storage{
+		events:       make(map[string]storage.Event),
+		history:      make(map[string][]storage.Change),
+		index:        make(index),
+		notified:     make(map[string]map[storage.Reminder]struct{}),
+		idempotency:  make(map[idempotencyKey]idempotencyRecord),
+		calendars:    make(map[string]storage.Calendar),
+		attachments:  make(map[string]storage.Attachment),
+		workingHours: make(map[string]storage.WorkingHours),
+		preferences:  make(map[string]storage.NotificationPreferences),
+	}
+}
+
+// CreateEvent creates the event, userID is the user making the change.
+func (s *Storage) CreateEvent(ctx context.Context, event storage.Event, userID string) (storage.Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return storage.Event{}, s.err
+	}
+
+	event, err := s.create(event, userID)
+	if err != nil {
+		return storage.Event{}, err
+	}
+	return event, s.flush()
+}
+
+// CreateEventIdempotent creates the event once per user and idempotency key,
+// the event created first is returned for retries.
+func (s *Storage) CreateEventIdempotent(
+	ctx context.Context,
+	event storage.Event,
+	userID string,
+	idempotency storage.Idempotency,
+) (storage.Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return storage.Event{}, s.err
+	}
+
+	now := time.Now()
+	key := idempotencyKey{userID: userID, key: idempotency.Key}
+	if record, ok := s.idempotency[key]; ok && record.ExpiresAt.After(now) {
+		if record.RequestHash != idempotency.RequestHash {
+			return storage.Event{}, storage.ErrIdempotencyKeyReused
+		}
+		return record.event, nil
+	}
+
+	event, err := s.create(event, userID)
+	if err != nil {
+		return storage.Event{}, err
+	}
+	s.idempotency[key] = idempotencyRecord{Idempotency: idempotency, event: event}
+	mark(s.changed.idempotency, key)
+	return event, s.flush()
+}
+
+func (s *Storage) create(event storage.Event, userID string) (storage.Event, error) {
+	if s.isBusy(event) {
+		return storage.Event{}, storage.ErrDateBusy
+	}
+
+	now := time.Now().UTC()
+	event.Version = 1
+	event.CreatedAt = now
+	event.UpdatedAt = now
+	event.DeletedAt = time.Time{}
+	event.Reminders = slices.Clone(event.Reminders)
+	s.events[event.ID] = event
+	s.index.add(event)
+	s.record(event, storage.ActionCreated, userID, storage.ChangedFields(storage.Event{}, event))
+	return event, nil
+}
+
+func (s *Storage) GetEvent(ctx context.Context, id string) (storage.Event, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	event, ok := s.events[id]
+	if !ok || event.IsDeleted() {
+		return storage.Event{}, storage.ErrEventNotFound
+	}
+	return event, nil
+}
+
+func (s *Storage) UpdateEvent(
+	ctx context.Context,
+	event storage.Event,
+	userID string,
+	expectedVersion int64,
+) (storage.Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return storage.Event{}, s.err
+	}
+
+	event, err := s.update(event, userID, expectedVersion)
+	if err != nil {
+		return storage.Event{}, err
+	}
+	return event, s.flush()
+}
+
+func (s *Storage) update(event storage.Event, userID string, expectedVersion int64) (storage.Event, error) {
+	current, err := s.current(event.ID, expectedVersion)
+	if err != nil {
+		return storage.Event{}, err
+	}
+	if s.isBusy(event) {
+		return storage.Event{}, storage.ErrDateBusy
+	}
+
+	event.Version = current.Version + 1
+	event.CreatedAt = current.CreatedAt
+	event.UpdatedAt = time.Now().UTC()
+	event.DeletedAt = time.Time{}
+	event.Reminders = slices.Clone(event.Reminders)
+	s.events[event.ID] = event
+	s.index.remove(current)
+	s.index.add(event)
+	// a moved event is reminded again, removed reminders are forgotten
+	if !event.StartsAt.Equal(current.StartsAt) {
+		delete(s.notified, event.ID)
+	}
+	for reminder := range s.notified[event.ID] {
+		if !slices.Contains(event.Reminders, reminder) {
+			delete(s.notified[event.ID], reminder)
+		}
+	}
+	s.record(event, storage.ActionUpdated, userID, storage.ChangedFields(current, event))
+	return event, nil
+}
+
+func (s *Storage) DeleteEvent(ctx context.Context, id, userID string, expectedVersion int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return s.err
+	}
+
+	if err := s.delete(id, userID, expectedVersion); err != nil {
+		return err
+	}
+	return s.flush()
+}
+
+func (s *Storage) delete(id, userID string, expectedVersion int64) error {
+	event, err := s.current(id, expectedVersion)
+	if err != nil {
+		return err
+	}
+
+	event.Version++
+	event.UpdatedAt = time.Now().UTC()
+	event.DeletedAt = event.UpdatedAt
+	s.events[id] = event
+	s.index.remove(event)
+	s.record(event, storage.ActionDeleted, userID, nil)
+	return nil
+}
+
+func (s *Storage) RestoreEvent(ctx context.Context, id, userID string, expectedVersion int64) (storage.Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return storage.Event{}, s.err
+	}
+
+	event, ok := s.events[id]
+	if !ok {
+		return storage.Event{}, storage.ErrEventNotFound
+	}
+	if !event.IsDeleted() {
+		return storage.Event{}, storage.ErrEventNotDeleted
+	}
+	if expectedVersion != storage.AnyVersion && event.Version != expectedVersion {
+		return storage.Event{}, storage.ErrVersionMismatch
+	}
+	if s.isBusy(event) {
+		return storage.Event{}, storage.ErrDateBusy
+	}
+
+	event.Version++
+	event.UpdatedAt = time.Now().UTC()
+	event.DeletedAt = time.Time{}
+	s.events[id] = event
+	s.index.add(event)
+	s.record(event, storage.ActionRestored, userID, nil)
+	return event, s.flush()
+}
+
+// EventCalendar returns the calendar ID of the event, soft deleted events are found too.
+func (s *Storage) EventCalendar(ctx context.Context, id string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	event, ok := s.events[id]
+	if !ok {
+		return "", storage.ErrEventNotFound
+	}
+	return event.CalendarID, nil
+}
+
+func (s *Storage) EventHistory(ctx context.Context, id string) ([]storage.Change, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.events[id]; !ok {
+		return nil, storage.ErrEventNotFound
+	}
+	history := make([]storage.Change, len(s.history[id]))
+	copy(history, s.history[id])
+	return history, nil
+}
+
+func (s *Storage) ListChanges(ctx context.Context, query storage.ChangeQuery) ([]storage.Change, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	changes := make([]storage.Change, 0)
+	for id, history := range s.history {
+		if !slices.Contains(query.CalendarIDs, s.events[id].CalendarID) {
+			continue
+		}
+		for _, change := range history {
+			if change.ID > query.AfterID {
+				changes = append(changes, change)
+			}
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].ID < changes[j].ID
+	})
+	if query.Limit > 0 && len(changes) > query.Limit {
+		changes = changes[:query.Limit]
+	}
+	return changes, nil
+}
+
+func (s *Storage) LatestChangeID(ctx context.Context) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.lastChangeID, nil
+}
+
+func (s *Storage) ListEvents(ctx context.Context, query storage.Query) ([]storage.Event, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	events := make([]storage.Event, 0)
+	for _, event := range s.events {
+		if query.Match(event) && (query.After == nil || query.After.Before(event)) {
+			events = append(events, event)
+		}
+	}
+
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].Less(events[j])
+	})
+	if query.Limit > 0 && len(events) > query.Limit {
+		events = events[:query.Limit]
+	}
+	return events, nil
+}
+
+func (s *Storage) SearchEvents(ctx context.Context, query storage.SearchQuery) ([]storage.SearchHit, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	terms := unique(words(query.Text))
+	hits := make([]storage.SearchHit, 0)
+	for _, id := range s.index.lookup(terms) {
+		event := s.events[id]
+		if !slices.Contains(query.CalendarIDs, event.CalendarID) {
+			continue
+		}
+		hit := storage.SearchHit{Event: event, Rank: rank(event, terms)}
+		if query.After == nil || query.After.Before(hit) {
+			hits = append(hits, hit)
+		}
+	}
+
+	sort.Slice(hits, func(i, j int) bool {
+		return hits[i].Less(hits[j])
+	})
+	if query.Limit > 0 && len(hits) > query.Limit {
+		hits = hits[:query.Limit]
+	}
+	return hits, nil
+}
+
+func (s *Storage) PurgeEvents(ctx context.Context, deletedBefore, endedBefore time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return 0, s.err
+	}
+
+	var purged int64
+	for id, event := range s.events {
+		if event.Purgeable(deletedBefore, endedBefore) {
+			delete(s.events, id)
+			delete(s.history, id)
+			delete(s.notified, id)
+			s.index.remove(event)
+			mark(s.changed.events, id)
+			for attachmentID, a := range s.attachments {
+				if a.EventID == id {
+					delete(s.attachments, attachmentID)
+					mark(s.changed.attachments, attachmentID)
+				}
+			}
+			purged++
+		}
+	}
+	return purged, s.flush()
+}
+
+// CountPurgeable returns the number of events PurgeEvents would delete.
+func (s *Storage) CountPurgeable(ctx context.Context, deletedBefore, endedBefore time.Time) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var n int64
+	for _, event := range s.events {
+		if event.Purgeable(deletedBefore, endedBefore) {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// PurgeIdempotencyKeys deletes idempotency keys expired before now.
+func (s *Storage) PurgeIdempotencyKeys(ctx context.Context, now time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return 0, s.err
+	}
+
+	var purged int64
+	for key, record := range s.idempotency {
+		if !record.ExpiresAt.After(now) {
+			delete(s.idempotency, key)
+			mark(s.changed.idempotency, key)
+			purged++
+		}
+	}
+	return purged, s.flush()
 }
 
-// TODO
+// DueNotifications returns reminders about live events which have not started yet
+// which should be sent at now, the ones already sent are skipped.
+func (s *Storage) DueNotifications(ctx context.Context, now time.Time) ([]storage.DueReminder, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	due := make([]storage.DueReminder, 0)
+	for id, event := range s.events {
+		for _, reminder := range event.Reminders {
+			if _, ok := s.notified[id][reminder]; ok || !event.ReminderDue(reminder, now) {
+				continue
+			}
+			due = append(due, storage.DueReminder{Event: event, Reminder: reminder})
+		}
+	}
+
+	sort.Slice(due, func(i, j int) bool {
+		if due[i].Event.ID == due[j].Event.ID {
+			return due[i].Reminder.Less(due[j].Reminder)
+		}
+		return due[i].Event.Less(due[j].Event)
+	})
+	return due, nil
+}
+
+// MarkNotified marks the reminder sent and adds the notification message to the outbox at once.
+func (s *Storage) MarkNotified(
+	ctx context.Context,
+	id string,
+	reminder storage.Reminder,
+	msg storage.OutboxMessage,
+) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return s.err
+	}
+
+	event, ok := s.events[id]
+	if !ok || !slices.Contains(event.Reminders, reminder) {
+		return storage.ErrEventNotFound
+	}
+	if _, ok := s.notified[id][reminder]; ok {
+		return storage.ErrAlreadyNotified
+	}
+	if s.notified[id] == nil {
+		s.notified[id] = make(map[storage.Reminder]struct{})
+	}
+	s.notified[id][reminder] = struct{}{}
+	mark(s.changed.events, id)
+
+	s.lastOutboxID++
+	msg.ID = s.lastOutboxID
+	msg.CreatedAt = time.Now().UTC()
+	s.outbox = append(s.outbox, msg)
+	mark(s.changed.outbox, msg.ID)
+	return s.flush()
+}
+
+// PendingOutbox returns up to limit outbox messages in the order they were added.
+func (s *Storage) PendingOutbox(ctx context.Context, limit int) ([]storage.OutboxMessage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return slices.Clone(s.outbox[:min(limit, len(s.outbox))]), nil
+}
+
+// DeleteOutbox deletes the published outbox message, deleting a missing message is not an error.
+func (s *Storage) DeleteOutbox(ctx context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return s.err
+	}
+
+	s.outbox = slices.DeleteFunc(s.outbox, func(msg storage.OutboxMessage) bool { return msg.ID == id })
+	mark(s.changed.outbox, id)
+	return s.flush()
+}
+
+// Len returns the number of live events.
+func (s *Storage) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	n := 0
+	for _, event := range s.events {
+		if !event.IsDeleted() {
+			n++
+		}
+	}
+	return n
+}
+
+// current returns the live event with the given id checking its version.
+func (s *Storage) current(id string, expectedVersion int64) (storage.Event, error) {
+	event, ok := s.events[id]
+	if !ok || event.IsDeleted() {
+		return storage.Event{}, storage.ErrEventNotFound
+	}
+	if expectedVersion != storage.AnyVersion && event.Version != expectedVersion {
+		return storage.Event{}, storage.ErrVersionMismatch
+	}
+	return event, nil
+}
+
+func (s *Storage) record(event storage.Event, action storage.Action, userID string, fields []string) {
+	if fields == nil {
+		fields = make([]string, 0)
+	}
+	s.lastChangeID++
+	mark(s.changed.events, event.ID)
+	s.history[event.ID] = append(s.history[event.ID], storage.Change{
+		ID:        s.lastChangeID,
+		EventID:   event.ID,
+		Version:   event.Version,
+		Action:    action,
+		UserID:    userID,
+		Fields:    fields,
+		ChangedAt: time.Now().UTC(),
+	})
+}
+
+func (s *Storage) isBusy(event storage.Event) bool {
+	for _, e := range s.events {
+		if e.ID != event.ID && e.UserID == event.UserID && !e.IsDeleted() && e.Overlaps(event) {
+			return true
+		}
+	}
+	return false
+}
@@ -1,7 +1,58 @@
 package memorystorage
 
-import "testing"
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage/storagetest"
+	"github.com/stretchr/testify/require"
+)
 
 func TestStorage(t *testing.T) {
-	// TODO
+	storagetest.Run(t, func(t *testing.T) storagetest.Storage {
+		return New()
+	})
+}
+
+func TestSearchRank(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+	day := time.Date(2021, 8, 2, 0, 0, 0, 0, time.UTC)
+
+	for id, e := range map[string]struct{ title, description string }{
+		"0": {"Team meeting", "weekly sync of the team"},
+		"1": {"Lunch", "with the team"},
+	} {
+		startsAt := day
+		if id == "1" {
+			startsAt = day.Add(time.Hour)
+		}
+		_, err := s.CreateEvent(ctx, storage.Event{
+			ID:          id,
+			Title:       e.title,
+			Description: e.description,
+			StartsAt:    startsAt,
+			EndsAt:      startsAt.Add(time.Hour),
+			CalendarID:  "calendar",
+			UserID:      "user",
+		}, "user")
+		require.NoError(t, err)
+	}
+
+	hits, err := s.SearchEvents(ctx, storage.SearchQuery{CalendarIDs: []string{"calendar"}, Text: "team"})
+	require.NoError(t, err)
+	require.Len(t, hits, 2)
+	require.Equal(t, titleWeight+descriptionWeight, hits[0].Rank)
+	require.Equal(t, descriptionWeight, hits[1].Rank)
+
+	hits, err = s.SearchEvents(ctx, storage.SearchQuery{
+		CalendarIDs: []string{"calendar"},
+		Text:        "team",
+		After:       &storage.SearchCursor{Rank: titleWeight + descriptionWeight, ID: "0"},
+	})
+	require.NoError(t, err)
+	require.Len(t, hits, 1)
+	require.Equal(t, "1", hits[0].Event.ID)
 }
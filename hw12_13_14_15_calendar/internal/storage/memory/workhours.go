@@ -0,0 +1,63 @@
+package memorystorage
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+)
+
+func (s *Storage) GetWorkingHours(ctx context.Context, userID string) (storage.WorkingHours, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	hours, ok := s.workingHours[userID]
+	if !ok {
+		return storage.WorkingHours{}, storage.ErrWorkingHoursNotFound
+	}
+	return cloneWorkingHours(hours), nil
+}
+
+// SetWorkingHours replaces the working hours of the user.
+func (s *Storage) SetWorkingHours(ctx context.Context, hours storage.WorkingHours) (storage.WorkingHours, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return storage.WorkingHours{}, s.err
+	}
+
+	hours = cloneWorkingHours(hours)
+	sort.Slice(hours.Periods, func(i, j int) bool {
+		return hours.Periods[i].Less(hours.Periods[j])
+	})
+	sort.Strings(hours.Holidays)
+	hours.UpdatedAt = time.Now().UTC()
+	s.workingHours[hours.UserID] = hours
+	mark(s.changed.workingHours, hours.UserID)
+	return cloneWorkingHours(hours), s.flush()
+}
+
+func (s *Storage) DeleteWorkingHours(ctx context.Context, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return s.err
+	}
+
+	if _, ok := s.workingHours[userID]; !ok {
+		return storage.ErrWorkingHoursNotFound
+	}
+	delete(s.workingHours, userID)
+	mark(s.changed.workingHours, userID)
+	return s.flush()
+}
+
+func cloneWorkingHours(hours storage.WorkingHours) storage.WorkingHours {
+	hours.Periods = slices.Clone(hours.Periods)
+	hours.Holidays = slices.Clone(hours.Holidays)
+	return hours
+}
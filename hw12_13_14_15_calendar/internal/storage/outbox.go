@@ -0,0 +1,13 @@
+package storage
+
+import "time"
+
+// OutboxMessage is a notification waiting to be published to the queue.
+// It is added together with marking the reminder notified, so a reminder is either both or neither.
+type OutboxMessage struct {
+	// ID orders messages, it is set by the storage.
+	ID        int64
+	Body      []byte
+	Headers   map[string]string
+	CreatedAt time.Time
+}
@@ -0,0 +1,13 @@
+package storage
+
+import "time"
+
+// NotificationPreferences are how notifications are written for a user, apart from the working hours.
+type NotificationPreferences struct {
+	UserID string
+	// TimeZone is the IANA name of the time zone of event dates, the sender default if empty.
+	TimeZone string
+	// Locale is the language tag of messages, e.g. "en" or "pt-BR", the sender default if empty.
+	Locale    string
+	UpdatedAt time.Time
+}
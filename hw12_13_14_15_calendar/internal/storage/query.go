@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"slices"
+	"strings"
+	"time"
+)
+
+// Query selects events of the calendars overlapping the [From, To) period.
+// Events are ordered by start time and then by ID.
+type Query struct {
+	CalendarIDs []string
+	From        time.Time
+	To          time.Time
+
+	// TitleContains is a case-insensitive substring of the title.
+	TitleContains string
+	// HasNotification selects events with or without reminders if set.
+	HasNotification *bool
+	// UpdatedSince selects events created or updated at or after the moment if set.
+	UpdatedSince time.Time
+
+	// After is the position of the last event of the previous page.
+	After *Cursor
+	// Limit is the maximum number of events, zero means no limit.
+	Limit int
+}
+
+// Cursor is a position in the events ordering.
+type Cursor struct {
+	StartsAt time.Time
+	ID       string
+}
+
+// Before reports whether the cursor position goes before the event.
+func (c Cursor) Before(e Event) bool {
+	return Event{StartsAt: c.StartsAt, ID: c.ID}.Less(e)
+}
+
+// Match reports whether the event satisfies the query filters, the cursor and limit are not checked.
+func (q Query) Match(e Event) bool {
+	switch {
+	case !slices.Contains(q.CalendarIDs, e.CalendarID), e.IsDeleted():
+		return false
+	case !e.Overlaps(Event{StartsAt: q.From, EndsAt: q.To}):
+		return false
+	case q.TitleContains != "" && !containsFold(e.Title, q.TitleContains):
+		return false
+	case q.HasNotification != nil && *q.HasNotification != (len(e.Reminders) > 0):
+		return false
+	case !q.UpdatedSince.IsZero() && e.UpdatedAt.Before(q.UpdatedSince):
+		return false
+	}
+	return true
+}
+
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
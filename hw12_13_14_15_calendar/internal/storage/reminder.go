@@ -0,0 +1,32 @@
+package storage
+
+import "time"
+
+// Channel is a way to deliver reminders to the user.
+type Channel string
+
+const (
+	ChannelLog     Channel = "log"
+	ChannelWebhook Channel = "webhook"
+)
+
+// Reminder is sent Before the event starts through the Channel.
+// An event has at most one reminder with the same offset and channel.
+type Reminder struct {
+	Before  time.Duration
+	Channel Channel
+}
+
+// Less reports whether the reminder goes before the other one in the event reminders.
+func (r Reminder) Less(other Reminder) bool {
+	if r.Before == other.Before {
+		return r.Channel < other.Channel
+	}
+	return r.Before > other.Before
+}
+
+// DueReminder is a reminder about the event which should be sent.
+type DueReminder struct {
+	Event    Event
+	Reminder Reminder
+}
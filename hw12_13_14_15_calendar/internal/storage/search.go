@@ -0,0 +1,37 @@
+package storage
+
+// SearchQuery selects live events of the calendars containing all words of Text
+// in the title or description. Events are ordered by rank descending and then by ID.
+type SearchQuery struct {
+	CalendarIDs []string
+	Text        string
+
+	// After is the position of the last event of the previous page.
+	After *SearchCursor
+	// Limit is the maximum number of events, zero means no limit.
+	Limit int
+}
+
+// SearchCursor is a position in the search results ordering.
+type SearchCursor struct {
+	Rank float64
+	ID   string
+}
+
+type SearchHit struct {
+	Event Event
+	Rank  float64
+}
+
+// Less reports whether the hit goes before the other one in search results.
+func (h SearchHit) Less(other SearchHit) bool {
+	if h.Rank == other.Rank {
+		return h.Event.ID < other.Event.ID
+	}
+	return h.Rank > other.Rank
+}
+
+// Before reports whether the cursor position goes before the hit.
+func (c SearchCursor) Before(h SearchHit) bool {
+	return SearchHit{Event: Event{ID: c.ID}, Rank: c.Rank}.Less(h)
+}
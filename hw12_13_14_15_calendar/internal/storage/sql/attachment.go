@@ -0,0 +1,92 @@
+package sqlstorage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+)
+
+const attachmentColumns = `id, event_id, name, content_type, size, user_id, created_at`
+
+type attachment struct {
+	ID          string    `db:"id"`
+	EventID     string    `db:"event_id"`
+	Name        string    `db:"name"`
+	ContentType string    `db:"content_type"`
+	Size        int64     `db:"size"`
+	UserID      string    `db:"user_id"`
+	CreatedAt   time.Time `db:"created_at"`
+}
+
+func (a attachment) toStorage() storage.Attachment {
+	return storage.Attachment{
+		ID:          a.ID,
+		EventID:     a.EventID,
+		Name:        a.Name,
+		ContentType: a.ContentType,
+		Size:        a.Size,
+		UserID:      a.UserID,
+		CreatedAt:   a.CreatedAt,
+	}
+}
+
+func (s *Storage) CreateAttachment(ctx context.Context, a storage.Attachment) (storage.Attachment, error) {
+	a.CreatedAt = time.Now().UTC()
+	res, err := s.db.ExecContext(ctx, `
+		INSERT INTO event_attachments (`+attachmentColumns+`)
+		SELECT $1, id, $3, $4, $5, $6, $7 FROM events WHERE id = $2`,
+		a.ID, a.EventID, a.Name, a.ContentType, a.Size, a.UserID, a.CreatedAt)
+	if err != nil {
+		return storage.Attachment{}, fmt.Errorf("unable to create attachment: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return storage.Attachment{}, storage.ErrEventNotFound
+	}
+	return a, nil
+}
+
+func (s *Storage) GetAttachment(ctx context.Context, id string) (storage.Attachment, error) {
+	var row attachment
+	err := s.db.GetContext(ctx, &row, `SELECT `+attachmentColumns+` FROM event_attachments WHERE id = $1`, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.Attachment{}, storage.ErrAttachmentNotFound
+	}
+	if err != nil {
+		return storage.Attachment{}, fmt.Errorf("unable to get attachment: %w", err)
+	}
+	return row.toStorage(), nil
+}
+
+// ListAttachments returns attachments of the event in the order they were added.
+func (s *Storage) ListAttachments(ctx context.Context, eventID string) ([]storage.Attachment, error) {
+	var rows []attachment
+	err := s.db.SelectContext(ctx, &rows, `
+		SELECT `+attachmentColumns+` FROM event_attachments
+		WHERE event_id = $1
+		ORDER BY created_at, id COLLATE "C"`,
+		eventID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list attachments: %w", err)
+	}
+
+	attachments := make([]storage.Attachment, 0, len(rows))
+	for _, row := range rows {
+		attachments = append(attachments, row.toStorage())
+	}
+	return attachments, nil
+}
+
+func (s *Storage) DeleteAttachment(ctx context.Context, id string) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM event_attachments WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("unable to delete attachment: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return storage.ErrAttachmentNotFound
+	}
+	return nil
+}
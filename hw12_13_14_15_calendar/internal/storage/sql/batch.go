@@ -0,0 +1,85 @@
+package sqlstorage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	"github.com/jmoiron/sqlx"
+)
+
+// errBatchFailed rolls back the transaction of a batch with a failed item.
+var errBatchFailed = errors.New("batch has failed")
+
+// ApplyBatch applies all items in one transaction or none of them. Every item runs in a savepoint,
+// so a failed item doesn't abort the transaction and failures of all items are reported at once.
+// If an item fails, the others are rolled back with storage.ErrBatchAborted.
+func (s *Storage) ApplyBatch(
+	ctx context.Context,
+	items []storage.BatchItem,
+	userID string,
+) ([]storage.BatchResult, error) {
+	results := make([]storage.BatchResult, len(items))
+	err := s.inTx(ctx, func(tx *sqlx.Tx) error {
+		failed := false
+		for i, item := range items {
+			if _, err := tx.ExecContext(ctx, `SAVEPOINT batch_item`); err != nil {
+				return fmt.Errorf("unable to create savepoint: %w", err)
+			}
+			results[i] = apply(ctx, tx, item, userID)
+			if results[i].Err == nil {
+				if _, err := tx.ExecContext(ctx, `RELEASE SAVEPOINT batch_item`); err != nil {
+					return fmt.Errorf("unable to release savepoint: %w", err)
+				}
+				continue
+			}
+			failed = true
+			if _, err := tx.ExecContext(ctx, `ROLLBACK TO SAVEPOINT batch_item`); err != nil {
+				return fmt.Errorf("unable to roll back to savepoint: %w", err)
+			}
+		}
+		if failed {
+			return errBatchFailed
+		}
+		return nil
+	})
+	switch {
+	case errors.Is(err, errBatchFailed):
+		for i := range results {
+			if results[i].Err == nil {
+				results[i] = storage.BatchResult{Event: storage.Event{ID: items[i].Event.ID}, Err: storage.ErrBatchAborted}
+			}
+		}
+	case err != nil:
+		return nil, err
+	}
+	return results, nil
+}
+
+func apply(
+	ctx context.Context,
+	tx *sqlx.Tx,
+	item storage.BatchItem,
+	userID string,
+) storage.BatchResult {
+	var (
+		event storage.Event
+		err   error
+	)
+	switch item.Op {
+	case storage.BatchCreate:
+		event, err = create(ctx, tx, item.Event, userID)
+	case storage.BatchUpdate:
+		event, err = update(ctx, tx, item.Event, userID, item.ExpectedVersion)
+	case storage.BatchDelete:
+		err = remove(ctx, tx, item.Event.ID, userID, item.ExpectedVersion)
+		event = storage.Event{ID: item.Event.ID}
+	default:
+		err = fmt.Errorf("unknown batch operation %q", item.Op)
+	}
+	if err != nil {
+		return storage.BatchResult{Event: storage.Event{ID: item.Event.ID}, Err: err}
+	}
+	return storage.BatchResult{Event: event}
+}
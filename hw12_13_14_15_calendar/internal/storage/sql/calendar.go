@@ -0,0 +1,170 @@
+package sqlstorage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jmoiron/sqlx"
+)
+
+type calendar struct {
+	ID        string    `db:"id"`
+	OwnerID   string    `db:"owner_id"`
+	Name      string    `db:"name"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+type share struct {
+	CalendarID string `db:"calendar_id"`
+	UserID     string `db:"user_id"`
+	Access     string `db:"access"`
+}
+
+func (s *Storage) CreateCalendar(ctx context.Context, c storage.Calendar) (storage.Calendar, error) {
+	c.Shares = nil
+	c.CreatedAt = time.Now().UTC()
+	res, err := s.db.ExecContext(ctx, `
+		INSERT INTO calendars (id, owner_id, name, created_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (owner_id, name) DO NOTHING`,
+		c.ID, c.OwnerID, c.Name, c.CreatedAt)
+	if err != nil {
+		return storage.Calendar{}, fmt.Errorf("unable to create calendar: %w", err)
+	}
+	inserted, err := res.RowsAffected()
+	if err != nil {
+		return storage.Calendar{}, fmt.Errorf("unable to create calendar: %w", err)
+	}
+	if inserted == 0 {
+		return storage.Calendar{}, storage.ErrCalendarExists
+	}
+	return c, nil
+}
+
+func (s *Storage) GetCalendar(ctx context.Context, id string) (storage.Calendar, error) {
+	var row calendar
+	err := s.db.GetContext(ctx, &row, `SELECT id, owner_id, name, created_at FROM calendars WHERE id = $1`, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.Calendar{}, storage.ErrCalendarNotFound
+	}
+	if err != nil {
+		return storage.Calendar{}, fmt.Errorf("unable to get calendar: %w", err)
+	}
+	calendars := []storage.Calendar{row.toStorage()}
+	if err := loadShares(ctx, s.db, calendars); err != nil {
+		return storage.Calendar{}, err
+	}
+	return calendars[0], nil
+}
+
+// ListCalendars returns calendars owned by the user or shared with them.
+func (s *Storage) ListCalendars(ctx context.Context, userID string) ([]storage.Calendar, error) {
+	var rows []calendar
+	err := s.db.SelectContext(ctx, &rows, `
+		SELECT id, owner_id, name, created_at
+		FROM calendars
+		WHERE owner_id = $1 OR id IN (SELECT calendar_id FROM calendar_shares WHERE user_id = $1)
+		ORDER BY name COLLATE "C", id COLLATE "C"`,
+		userID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list calendars: %w", err)
+	}
+
+	calendars := make([]storage.Calendar, 0, len(rows))
+	for _, row := range rows {
+		calendars = append(calendars, row.toStorage())
+	}
+	if err := loadShares(ctx, s.db, calendars); err != nil {
+		return nil, err
+	}
+	return calendars, nil
+}
+
+// ShareCalendar grants the user access to the calendar replacing the access granted before.
+func (s *Storage) ShareCalendar(ctx context.Context, calendarID, userID string, access storage.Access) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO calendar_shares (calendar_id, user_id, access)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (calendar_id, user_id) DO UPDATE SET access = excluded.access`,
+		calendarID, userID, string(access))
+	if isForeignKeyViolation(err) {
+		return storage.ErrCalendarNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("unable to share calendar: %w", err)
+	}
+	return nil
+}
+
+// UnshareCalendar revokes access of the user to the calendar, it does nothing if there is none.
+func (s *Storage) UnshareCalendar(ctx context.Context, calendarID, userID string) error {
+	var exists bool
+	err := s.db.GetContext(ctx, &exists, `SELECT EXISTS (SELECT 1 FROM calendars WHERE id = $1)`, calendarID)
+	if err != nil {
+		return fmt.Errorf("unable to get calendar: %w", err)
+	}
+	if !exists {
+		return storage.ErrCalendarNotFound
+	}
+
+	_, err = s.db.ExecContext(ctx,
+		`DELETE FROM calendar_shares WHERE calendar_id = $1 AND user_id = $2`,
+		calendarID, userID)
+	if err != nil {
+		return fmt.Errorf("unable to unshare calendar: %w", err)
+	}
+	return nil
+}
+
+// loadShares sets shares of the calendars with a single query.
+func loadShares(ctx context.Context, q sqlx.QueryerContext, calendars []storage.Calendar) error {
+	if len(calendars) == 0 {
+		return nil
+	}
+	ids := make([]string, 0, len(calendars))
+	for _, c := range calendars {
+		ids = append(ids, c.ID)
+	}
+
+	var rows []share
+	err := sqlx.SelectContext(ctx, q, &rows, `
+		SELECT calendar_id, user_id, access
+		FROM calendar_shares
+		WHERE calendar_id = ANY($1)
+		ORDER BY user_id COLLATE "C"`,
+		ids)
+	if err != nil {
+		return fmt.Errorf("unable to get calendar shares: %w", err)
+	}
+
+	shares := make(map[string][]storage.Share)
+	for _, row := range rows {
+		shares[row.CalendarID] = append(shares[row.CalendarID], storage.Share{
+			UserID: row.UserID,
+			Access: storage.Access(row.Access),
+		})
+	}
+	for i := range calendars {
+		calendars[i].Shares = shares[calendars[i].ID]
+	}
+	return nil
+}
+
+func (c calendar) toStorage() storage.Calendar {
+	return storage.Calendar{
+		ID:        c.ID,
+		OwnerID:   c.OwnerID,
+		Name:      c.Name,
+		CreatedAt: c.CreatedAt,
+	}
+}
+
+func isForeignKeyViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23503"
+}
@@ -0,0 +1,82 @@
+package sqlstorage
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// AdvisoryLock is a session level Postgres advisory lock held on a dedicated connection.
+// The database releases it when the connection of a dead holder is closed.
+type AdvisoryLock struct {
+	db  *sql.DB
+	key int64
+
+	mu   sync.Mutex
+	conn *sql.Conn
+}
+
+// AdvisoryLock returns the lock identified by key, the storage must be connected.
+func (s *Storage) AdvisoryLock(key int64) *AdvisoryLock {
+	return &AdvisoryLock{db: s.db.DB, key: key}
+}
+
+func (l *AdvisoryLock) TryLock(ctx context.Context) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.conn != nil {
+		return true, nil
+	}
+	conn, err := l.db.Conn(ctx)
+	if err != nil {
+		return false, fmt.Errorf("unable to get connection: %w", err)
+	}
+	var locked bool
+	if err := conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1)`, l.key).Scan(&locked); err != nil {
+		_ = conn.Close()
+		return false, fmt.Errorf("unable to lock: %w", err)
+	}
+	if !locked {
+		_ = conn.Close()
+		return false, nil
+	}
+	l.conn = conn
+	return true, nil
+}
+
+// Check fails if the connection holding the lock is broken, the lock is released by the database then.
+func (l *AdvisoryLock) Check(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.conn == nil {
+		return errors.New("lock is not held")
+	}
+	if err := l.conn.PingContext(ctx); err != nil {
+		return fmt.Errorf("unable to check lock connection: %w", err)
+	}
+	return nil
+}
+
+func (l *AdvisoryLock) Unlock(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.conn == nil {
+		return nil
+	}
+	defer func() { l.conn = nil }()
+
+	_, err := l.conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, l.key)
+	// the connection goes back to the pool, it must not keep the lock if unlocking has failed
+	if err != nil {
+		_ = l.conn.Raw(func(any) error { return driver.ErrBadConn })
+		_ = l.conn.Close()
+		return fmt.Errorf("unable to unlock: %w", err)
+	}
+	return l.conn.Close()
+}
@@ -0,0 +1,81 @@
+package sqlstorage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+)
+
+type notificationPreferences struct {
+	UserID    string    `db:"user_id"`
+	TimeZone  string    `db:"time_zone"`
+	Locale    string    `db:"locale"`
+	UpdatedAt time.Time `db:"updated_at"`
+}
+
+func (p notificationPreferences) toStorage() storage.NotificationPreferences {
+	return storage.NotificationPreferences{
+		UserID:    p.UserID,
+		TimeZone:  p.TimeZone,
+		Locale:    p.Locale,
+		UpdatedAt: p.UpdatedAt,
+	}
+}
+
+func (s *Storage) GetNotificationPreferences(
+	ctx context.Context,
+	userID string,
+) (storage.NotificationPreferences, error) {
+	var row notificationPreferences
+	err := s.db.GetContext(ctx, &row, `
+		SELECT user_id, time_zone, locale, updated_at FROM notification_preferences WHERE user_id = $1`,
+		userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.NotificationPreferences{}, storage.ErrPreferencesNotFound
+	}
+	if err != nil {
+		return storage.NotificationPreferences{}, fmt.Errorf("unable to get notification preferences: %w", err)
+	}
+	return row.toStorage(), nil
+}
+
+// SetNotificationPreferences replaces the notification preferences of the user.
+func (s *Storage) SetNotificationPreferences(
+	ctx context.Context,
+	prefs storage.NotificationPreferences,
+) (storage.NotificationPreferences, error) {
+	prefs.UpdatedAt = time.Now().UTC()
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO notification_preferences (user_id, time_zone, locale, updated_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id) DO UPDATE
+		SET time_zone = excluded.time_zone, locale = excluded.locale, updated_at = excluded.updated_at`,
+		prefs.UserID, prefs.TimeZone, prefs.Locale, prefs.UpdatedAt)
+	if err != nil {
+		return storage.NotificationPreferences{}, fmt.Errorf("unable to save notification preferences: %w", err)
+	}
+	return prefs, nil
+}
+
+// ListNotificationPreferences returns the preferences of the users who have set them.
+func (s *Storage) ListNotificationPreferences(
+	ctx context.Context,
+	userIDs []string,
+) ([]storage.NotificationPreferences, error) {
+	var rows []notificationPreferences
+	err := s.db.SelectContext(ctx, &rows, `
+		SELECT user_id, time_zone, locale, updated_at FROM notification_preferences WHERE user_id = ANY($1)`,
+		userIDs)
+	if err != nil {
+		return nil, fmt.Errorf("unable to select notification preferences: %w", err)
+	}
+	list := make([]storage.NotificationPreferences, 0, len(rows))
+	for _, row := range rows {
+		list = append(list, row.toStorage())
+	}
+	return list, nil
+}
@@ -1,20 +1,881 @@
 package sqlstorage
 
-import "context"
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
 
-type Storage struct { // TODO
+	"github.com/XSAM/otelsql"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	_ "github.com/jackc/pgx/v5/stdlib" // register pgx driver
+	"github.com/jmoiron/sqlx"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+const eventColumns = `id, title, starts_at, ends_at, description, calendar_id, user_id, version,
+	created_at, updated_at, deleted_at`
+
+type Storage struct {
+	dsn string
+	db  *sqlx.DB
+}
+
+type event struct {
+	ID          string       `db:"id"`
+	Title       string       `db:"title"`
+	StartsAt    time.Time    `db:"starts_at"`
+	EndsAt      time.Time    `db:"ends_at"`
+	Description string       `db:"description"`
+	CalendarID  string       `db:"calendar_id"`
+	UserID      string       `db:"user_id"`
+	Version     int64        `db:"version"`
+	CreatedAt   time.Time    `db:"created_at"`
+	UpdatedAt   time.Time    `db:"updated_at"`
+	DeletedAt   sql.NullTime `db:"deleted_at"`
+}
+
+type reminder struct {
+	EventID       string       `db:"event_id"`
+	BeforeSeconds int64        `db:"before_seconds"`
+	Channel       string       `db:"channel"`
+	NotifiedAt    sql.NullTime `db:"notified_at"`
+}
+
+type dueReminder struct {
+	event
+	BeforeSeconds int64  `db:"before_seconds"`
+	Channel       string `db:"channel"`
 }
 
-func New() *Storage {
-	return &Storage{}
+type outboxMessage struct {
+	ID        int64     `db:"id"`
+	Body      []byte    `db:"body"`
+	Headers   []byte    `db:"headers"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+type hit struct {
+	event
+	Rank float64 `db:"rank"`
+}
+
+type change struct {
+	ID        int64     `db:"id"`
+	EventID   string    `db:"event_id"`
+	Version   int64     `db:"version"`
+	Action    string    `db:"action"`
+	UserID    string    `db:"user_id"`
+	Fields    string    `db:"fields"`
+	ChangedAt time.Time `db:"changed_at"`
+}
+
+func New(dsn string) *Storage {
+	return &Storage{dsn: dsn}
 }
 
 func (s *Storage) Connect(ctx context.Context) error {
-	// TODO
+	db, err := otelsql.Open("pgx", s.dsn, otelsql.WithAttributes(semconv.DBSystemPostgreSQL))
+	if err != nil {
+		return fmt.Errorf("unable to open database: %w", err)
+	}
+	if err := db.PingContext(ctx); err != nil {
+		_ = db.Close()
+		return fmt.Errorf("unable to connect to database: %w", err)
+	}
+	s.db = sqlx.NewDb(db, "pgx")
 	return nil
 }
 
 func (s *Storage) Close(ctx context.Context) error {
-	// TODO
+	if s.db == nil {
+		return nil
+	}
+	return s.db.Close()
+}
+
+// CreateEvent creates the event, userID is the user making the change.
+func (s *Storage) CreateEvent(ctx context.Context, e storage.Event, userID string) (storage.Event, error) {
+	err := s.inTx(ctx, func(tx *sqlx.Tx) error {
+		var err error
+		e, err = create(ctx, tx, e, userID)
+		return err
+	})
+	if err != nil {
+		return storage.Event{}, err
+	}
+	return e, nil
+}
+
+// CreateEventIdempotent creates the event once per user and idempotency key,
+// the event created first is returned for retries.
+func (s *Storage) CreateEventIdempotent(
+	ctx context.Context,
+	e storage.Event,
+	userID string,
+	idempotency storage.Idempotency,
+) (storage.Event, error) {
+	err := s.inTx(ctx, func(tx *sqlx.Tx) error {
+		_, err := tx.ExecContext(ctx,
+			`DELETE FROM idempotency_keys WHERE user_id = $1 AND key = $2 AND expires_at <= now()`,
+			userID, idempotency.Key)
+		if err != nil {
+			return fmt.Errorf("unable to delete expired idempotency key: %w", err)
+		}
+
+		// a concurrent request with the same key waits here until the first one commits
+		res, err := tx.ExecContext(ctx, `
+			INSERT INTO idempotency_keys (user_id, key, request_hash, event, expires_at)
+			VALUES ($1, $2, $3, '{}', $4)
+			ON CONFLICT DO NOTHING`,
+			userID, idempotency.Key, idempotency.RequestHash, idempotency.ExpiresAt)
+		if err != nil {
+			return fmt.Errorf("unable to save idempotency key: %w", err)
+		}
+		inserted, err := res.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("unable to save idempotency key: %w", err)
+		}
+		if inserted == 0 {
+			e, err = replay(ctx, tx, userID, idempotency)
+			return err
+		}
+
+		if e, err = create(ctx, tx, e, userID); err != nil {
+			return err
+		}
+		response, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx,
+			`UPDATE idempotency_keys SET event = $3 WHERE user_id = $1 AND key = $2`,
+			userID, idempotency.Key, response)
+		if err != nil {
+			return fmt.Errorf("unable to save idempotent response: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return storage.Event{}, err
+	}
+	return e, nil
+}
+
+func (s *Storage) GetEvent(ctx context.Context, id string) (storage.Event, error) {
+	var e event
+	err := s.db.GetContext(ctx, &e, `SELECT `+eventColumns+` FROM events WHERE id = $1 AND deleted_at IS NULL`, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.Event{}, storage.ErrEventNotFound
+	}
+	if err != nil {
+		return storage.Event{}, fmt.Errorf("unable to get event: %w", err)
+	}
+	events := []storage.Event{e.toStorage()}
+	if err := loadReminders(ctx, s.db, events); err != nil {
+		return storage.Event{}, err
+	}
+	return events[0], nil
+}
+
+// EventCalendar returns the calendar ID of the event, soft deleted events are found too.
+func (s *Storage) EventCalendar(ctx context.Context, id string) (string, error) {
+	var calendarID string
+	err := s.db.GetContext(ctx, &calendarID, `SELECT calendar_id FROM events WHERE id = $1`, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", storage.ErrEventNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("unable to get event calendar: %w", err)
+	}
+	return calendarID, nil
+}
+
+func (s *Storage) UpdateEvent(
+	ctx context.Context,
+	e storage.Event,
+	userID string,
+	expectedVersion int64,
+) (storage.Event, error) {
+	err := s.inTx(ctx, func(tx *sqlx.Tx) error {
+		var err error
+		e, err = update(ctx, tx, e, userID, expectedVersion)
+		return err
+	})
+	if err != nil {
+		return storage.Event{}, err
+	}
+	return e, nil
+}
+
+func (s *Storage) DeleteEvent(ctx context.Context, id, userID string, expectedVersion int64) error {
+	return s.inTx(ctx, func(tx *sqlx.Tx) error {
+		return remove(ctx, tx, id, userID, expectedVersion)
+	})
+}
+
+func (s *Storage) RestoreEvent(ctx context.Context, id, userID string, expectedVersion int64) (storage.Event, error) {
+	var e storage.Event
+	err := s.inTx(ctx, func(tx *sqlx.Tx) error {
+		var err error
+		e, err = lockEvent(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if !e.IsDeleted() {
+			return storage.ErrEventNotDeleted
+		}
+		if expectedVersion != storage.AnyVersion && e.Version != expectedVersion {
+			return storage.ErrVersionMismatch
+		}
+		if err := checkBusy(ctx, tx, e); err != nil {
+			return err
+		}
+
+		e.Version++
+		e.UpdatedAt = time.Now().UTC()
+		e.DeletedAt = time.Time{}
+		_, err = tx.ExecContext(ctx,
+			`UPDATE events SET deleted_at = NULL, updated_at = $2, version = $3 WHERE id = $1`,
+			id, e.UpdatedAt, e.Version)
+		if err != nil {
+			return err
+		}
+		return record(ctx, tx, e, storage.ActionRestored, userID, nil)
+	})
+	if err != nil {
+		return storage.Event{}, err
+	}
+	return e, nil
+}
+
+func (s *Storage) EventHistory(ctx context.Context, id string) ([]storage.Change, error) {
+	var exists bool
+	if err := s.db.GetContext(ctx, &exists, `SELECT EXISTS (SELECT 1 FROM events WHERE id = $1)`, id); err != nil {
+		return nil, fmt.Errorf("unable to get event: %w", err)
+	}
+	if !exists {
+		return nil, storage.ErrEventNotFound
+	}
+
+	var rows []change
+	err := s.db.SelectContext(ctx, &rows, `
+		SELECT id, event_id, version, action, user_id, fields, changed_at
+		FROM event_history
+		WHERE event_id = $1
+		ORDER BY id`,
+		id)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get event history: %w", err)
+	}
+
+	history := make([]storage.Change, 0, len(rows))
+	for _, row := range rows {
+		history = append(history, row.toStorage())
+	}
+	return history, nil
+}
+
+// ListChanges lists changes in the order of their transactions. Ids are taken from a sequence, so a change
+// with a lower id may be committed later; changes of transactions older than every running one are final,
+// so only they are listed and a reader resuming after a change doesn't skip any committed meanwhile.
+// A reader resuming after a purged change gets the changes with greater ids.
+func (s *Storage) ListChanges(ctx context.Context, query storage.ChangeQuery) ([]storage.Change, error) {
+	limit := ""
+	args := []interface{}{query.CalendarIDs, query.AfterID}
+	if query.Limit > 0 {
+		limit = " LIMIT $3"
+		args = append(args, query.Limit)
+	}
+
+	var rows []change
+	err := s.db.SelectContext(ctx, &rows, `
+		WITH after AS (SELECT (SELECT tx_id FROM event_history WHERE id = $2) AS tx_id)
+		SELECT h.id, h.event_id, h.version, h.action, h.user_id, h.fields, h.changed_at
+		FROM event_history h
+		JOIN events e ON e.id = h.event_id
+		CROSS JOIN after
+		WHERE e.calendar_id = ANY($1)
+			AND h.tx_id < pg_snapshot_xmin(pg_current_snapshot())
+			AND CASE WHEN after.tx_id IS NULL THEN h.id > $2 ELSE (h.tx_id, h.id) > (after.tx_id, $2) END
+		ORDER BY h.tx_id, h.id`+limit,
+		args...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list changes: %w", err)
+	}
+
+	changes := make([]storage.Change, 0, len(rows))
+	for _, row := range rows {
+		changes = append(changes, row.toStorage())
+	}
+	return changes, nil
+}
+
+// LatestChangeID returns the id of the last change ListChanges lists now, zero if there is none.
+func (s *Storage) LatestChangeID(ctx context.Context) (int64, error) {
+	var id int64
+	err := s.db.GetContext(ctx, &id, `
+		SELECT COALESCE((
+			SELECT id FROM event_history
+			WHERE tx_id < pg_snapshot_xmin(pg_current_snapshot())
+			ORDER BY tx_id DESC, id DESC
+			LIMIT 1
+		), 0)`)
+	if err != nil {
+		return 0, fmt.Errorf("unable to get latest change: %w", err)
+	}
+	return id, nil
+}
+
+func (s *Storage) ListEvents(ctx context.Context, query storage.Query) ([]storage.Event, error) {
+	where := []string{"calendar_id = ANY($1)", "deleted_at IS NULL", "starts_at < $3", "ends_at > $2"}
+	args := []interface{}{query.CalendarIDs, query.From, query.To}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if query.TitleContains != "" {
+		where = append(where, "strpos(lower(title), lower("+arg(query.TitleContains)+")) > 0")
+	}
+	if query.HasNotification != nil {
+		exists := "EXISTS (SELECT 1 FROM event_reminders r WHERE r.event_id = events.id)"
+		if *query.HasNotification {
+			where = append(where, exists)
+		} else {
+			where = append(where, "NOT "+exists)
+		}
+	}
+	if !query.UpdatedSince.IsZero() {
+		where = append(where, "updated_at >= "+arg(query.UpdatedSince))
+	}
+	// ids are compared bytewise like in the memory storage regardless of the database collation
+	if query.After != nil {
+		startsAt, id := arg(query.After.StartsAt), arg(query.After.ID)
+		where = append(where, fmt.Sprintf(
+			`(starts_at > %[1]s OR (starts_at = %[1]s AND id COLLATE "C" > %[2]s))`, startsAt, id))
+	}
+	limit := ""
+	if query.Limit > 0 {
+		limit = " LIMIT " + arg(query.Limit)
+	}
+
+	var rows []event
+	err := s.db.SelectContext(ctx, &rows, `
+		SELECT `+eventColumns+`
+		FROM events
+		WHERE `+strings.Join(where, " AND ")+`
+		ORDER BY starts_at, id COLLATE "C"`+limit,
+		args...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list events: %w", err)
+	}
+
+	events := make([]storage.Event, 0, len(rows))
+	for _, row := range rows {
+		events = append(events, row.toStorage())
+	}
+	if err := loadReminders(ctx, s.db, events); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+func (s *Storage) SearchEvents(ctx context.Context, query storage.SearchQuery) ([]storage.SearchHit, error) {
+	where := []string{"true"}
+	args := []interface{}{query.CalendarIDs, query.Text}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if query.After != nil {
+		rank, id := arg(query.After.Rank), arg(query.After.ID)
+		where = append(where, fmt.Sprintf(
+			`(rank < %[1]s OR (rank = %[1]s AND id COLLATE "C" > %[2]s))`, rank, id))
+	}
+	limit := ""
+	if query.Limit > 0 {
+		limit = " LIMIT " + arg(query.Limit)
+	}
+
+	// ts_rank is computed as real, it is cast to double precision to round trip through the cursor exactly
+	var rows []hit
+	err := s.db.SelectContext(ctx, &rows, `
+		SELECT * FROM (
+			SELECT `+eventColumns+`, ts_rank(search, q)::double precision AS rank
+			FROM events, plainto_tsquery('simple', $2) AS q
+			WHERE calendar_id = ANY($1) AND deleted_at IS NULL AND search @@ q
+		) AS hits
+		WHERE `+strings.Join(where, " AND ")+`
+		ORDER BY rank DESC, id COLLATE "C"`+limit,
+		args...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to search events: %w", err)
+	}
+
+	events := make([]storage.Event, 0, len(rows))
+	for _, row := range rows {
+		events = append(events, row.toStorage())
+	}
+	if err := loadReminders(ctx, s.db, events); err != nil {
+		return nil, err
+	}
+
+	hits := make([]storage.SearchHit, 0, len(rows))
+	for i, row := range rows {
+		hits = append(hits, storage.SearchHit{Event: events[i], Rank: row.Rank})
+	}
+	return hits, nil
+}
+
+// PurgeEvents hard deletes events soft deleted before deletedBefore
+// and events ended before endedBefore together with their history.
+func (s *Storage) PurgeEvents(ctx context.Context, deletedBefore, endedBefore time.Time) (int64, error) {
+	res, err := s.db.ExecContext(ctx,
+		`DELETE FROM events WHERE deleted_at < $1 OR ends_at < $2`,
+		deletedBefore, endedBefore)
+	if err != nil {
+		return 0, fmt.Errorf("unable to purge events: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// CountPurgeable returns the number of events PurgeEvents would delete.
+func (s *Storage) CountPurgeable(ctx context.Context, deletedBefore, endedBefore time.Time) (int64, error) {
+	var n int64
+	err := s.db.GetContext(ctx, &n,
+		`SELECT count(*) FROM events WHERE deleted_at < $1 OR ends_at < $2`,
+		deletedBefore, endedBefore)
+	if err != nil {
+		return 0, fmt.Errorf("unable to count purgeable events: %w", err)
+	}
+	return n, nil
+}
+
+// DueNotifications returns reminders about live events which have not started yet
+// which should be sent at now, the ones already sent are skipped.
+func (s *Storage) DueNotifications(ctx context.Context, now time.Time) ([]storage.DueReminder, error) {
+	var rows []dueReminder
+	err := s.db.SelectContext(ctx, &rows, `
+		SELECT `+prefixed("e", eventColumns)+`, r.before_seconds, r.channel
+		FROM event_reminders r
+		JOIN events e ON e.id = r.event_id
+		WHERE r.notified_at IS NULL AND r.remind_at <= $1 AND e.deleted_at IS NULL AND e.starts_at > $1
+		ORDER BY e.starts_at, e.id COLLATE "C", r.before_seconds DESC, r.channel COLLATE "C"`,
+		now)
+	if err != nil {
+		return nil, fmt.Errorf("unable to select due notifications: %w", err)
+	}
+
+	events := make([]storage.Event, 0, len(rows))
+	for _, row := range rows {
+		events = append(events, row.toStorage())
+	}
+	if err := loadReminders(ctx, s.db, events); err != nil {
+		return nil, err
+	}
+
+	due := make([]storage.DueReminder, 0, len(rows))
+	for i, row := range rows {
+		due = append(due, storage.DueReminder{
+			Event:    events[i],
+			Reminder: reminder{BeforeSeconds: row.BeforeSeconds, Channel: row.Channel}.toStorage(),
+		})
+	}
+	return due, nil
+}
+
+// MarkNotified marks the reminder sent and adds the notification message to the outbox in one transaction.
+func (s *Storage) MarkNotified(ctx context.Context, id string, r storage.Reminder, msg storage.OutboxMessage) error {
+	headers, err := json.Marshal(msg.Headers)
+	if err != nil {
+		return fmt.Errorf("unable to marshal message headers: %w", err)
+	}
+
+	return s.inTx(ctx, func(tx *sqlx.Tx) error {
+		now := time.Now().UTC()
+		res, err := tx.ExecContext(ctx, `
+			UPDATE event_reminders SET notified_at = $4
+			WHERE event_id = $1 AND before_seconds = $2 AND channel = $3 AND notified_at IS NULL`,
+			id, int64(r.Before/time.Second), string(r.Channel), now)
+		if err != nil {
+			return fmt.Errorf("unable to mark reminder notified: %w", err)
+		}
+		if n, err := res.RowsAffected(); err == nil && n == 0 {
+			var exists bool
+			err := tx.GetContext(ctx, &exists, `
+				SELECT EXISTS (SELECT 1 FROM event_reminders WHERE event_id = $1 AND before_seconds = $2 AND channel = $3)`,
+				id, int64(r.Before/time.Second), string(r.Channel))
+			if err != nil {
+				return fmt.Errorf("unable to select reminder: %w", err)
+			}
+			if exists {
+				return storage.ErrAlreadyNotified
+			}
+			return storage.ErrEventNotFound
+		}
+
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO notification_outbox (body, headers, created_at) VALUES ($1, $2, $3)`,
+			msg.Body, headers, now)
+		if err != nil {
+			return fmt.Errorf("unable to add message to outbox: %w", err)
+		}
+		return nil
+	})
+}
+
+// PendingOutbox returns up to limit outbox messages in the order they were added.
+func (s *Storage) PendingOutbox(ctx context.Context, limit int) ([]storage.OutboxMessage, error) {
+	var rows []outboxMessage
+	err := s.db.SelectContext(ctx, &rows,
+		`SELECT id, body, headers, created_at FROM notification_outbox ORDER BY id LIMIT $1`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("unable to select outbox messages: %w", err)
+	}
+
+	messages := make([]storage.OutboxMessage, 0, len(rows))
+	for _, row := range rows {
+		msg := storage.OutboxMessage{ID: row.ID, Body: row.Body, CreatedAt: row.CreatedAt}
+		if err := json.Unmarshal(row.Headers, &msg.Headers); err != nil {
+			return nil, fmt.Errorf("unable to unmarshal message headers: %w", err)
+		}
+		messages = append(messages, msg)
+	}
+	return messages, nil
+}
+
+// DeleteOutbox deletes the published outbox message, deleting a missing message is not an error.
+func (s *Storage) DeleteOutbox(ctx context.Context, id int64) error {
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM notification_outbox WHERE id = $1`, id); err != nil {
+		return fmt.Errorf("unable to delete outbox message: %w", err)
+	}
+	return nil
+}
+
+// PurgeIdempotencyKeys deletes idempotency keys expired before now.
+func (s *Storage) PurgeIdempotencyKeys(ctx context.Context, now time.Time) (int64, error) {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE expires_at <= $1`, now)
+	if err != nil {
+		return 0, fmt.Errorf("unable to purge idempotency keys: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+func (s *Storage) inTx(ctx context.Context, fn func(tx *sqlx.Tx) error) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("unable to begin transaction: %w", err)
+	}
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func create(
+	ctx context.Context,
+	tx *sqlx.Tx,
+	e storage.Event,
+	userID string,
+) (storage.Event, error) {
+	e.Version = 1
+	e.CreatedAt = time.Now().UTC()
+	e.UpdatedAt = e.CreatedAt
+	e.DeletedAt = time.Time{}
+
+	if err := checkBusy(ctx, tx, e); err != nil {
+		return storage.Event{}, err
+	}
+	_, err := tx.NamedExecContext(ctx, `
+		INSERT INTO events (`+eventColumns+`)
+		VALUES (:id, :title, :starts_at, :ends_at, :description, :calendar_id, :user_id, :version,
+			:created_at, :updated_at, :deleted_at)`,
+		fromStorage(e))
+	if err != nil {
+		return storage.Event{}, fmt.Errorf("unable to create event: %w", err)
+	}
+	if err := saveReminders(ctx, tx, e, true); err != nil {
+		return storage.Event{}, err
+	}
+	if err := record(ctx, tx, e, storage.ActionCreated, userID, storage.ChangedFields(storage.Event{}, e)); err != nil {
+		return storage.Event{}, err
+	}
+	return e, nil
+}
+
+func update(
+	ctx context.Context,
+	tx *sqlx.Tx,
+	e storage.Event,
+	userID string,
+	expectedVersion int64,
+) (storage.Event, error) {
+	current, err := lockEvent(ctx, tx, e.ID)
+	if err != nil {
+		return storage.Event{}, err
+	}
+	if err := checkLive(current, expectedVersion); err != nil {
+		return storage.Event{}, err
+	}
+	if err := checkBusy(ctx, tx, e); err != nil {
+		return storage.Event{}, err
+	}
+
+	e.Version = current.Version + 1
+	e.CreatedAt = current.CreatedAt
+	e.UpdatedAt = time.Now().UTC()
+	e.DeletedAt = time.Time{}
+	_, err = tx.ExecContext(ctx, `
+		UPDATE events
+		SET title = $2, starts_at = $3, ends_at = $4, description = $5,
+			calendar_id = $6, user_id = $7, version = $8, updated_at = $9
+		WHERE id = $1`,
+		e.ID, e.Title, e.StartsAt, e.EndsAt, e.Description, e.CalendarID, e.UserID, e.Version, e.UpdatedAt)
+	if err != nil {
+		return storage.Event{}, err
+	}
+	if err := saveReminders(ctx, tx, e, !e.StartsAt.Equal(current.StartsAt)); err != nil {
+		return storage.Event{}, err
+	}
+	if err := record(ctx, tx, e, storage.ActionUpdated, userID, storage.ChangedFields(current, e)); err != nil {
+		return storage.Event{}, err
+	}
+	return e, nil
+}
+
+// remove soft deletes the event.
+func remove(ctx context.Context, tx *sqlx.Tx, id, userID string, expectedVersion int64) error {
+	e, err := lockEvent(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+	if err := checkLive(e, expectedVersion); err != nil {
+		return err
+	}
+
+	e.Version++
+	e.UpdatedAt = time.Now().UTC()
+	e.DeletedAt = e.UpdatedAt
+	_, err = tx.ExecContext(ctx,
+		`UPDATE events SET deleted_at = $2, updated_at = $2, version = $3 WHERE id = $1`,
+		id, e.DeletedAt, e.Version)
+	if err != nil {
+		return err
+	}
+	return record(ctx, tx, e, storage.ActionDeleted, userID, nil)
+}
+
+// replay returns the event created by the request which used the idempotency key first.
+func replay(ctx context.Context, tx *sqlx.Tx, userID string, idempotency storage.Idempotency) (storage.Event, error) {
+	var saved struct {
+		RequestHash string `db:"request_hash"`
+		Event       []byte `db:"event"`
+	}
+	err := tx.GetContext(ctx, &saved,
+		`SELECT request_hash, event FROM idempotency_keys WHERE user_id = $1 AND key = $2`,
+		userID, idempotency.Key)
+	if err != nil {
+		return storage.Event{}, fmt.Errorf("unable to get idempotency key: %w", err)
+	}
+	if saved.RequestHash != idempotency.RequestHash {
+		return storage.Event{}, storage.ErrIdempotencyKeyReused
+	}
+
+	var e storage.Event
+	if err := json.Unmarshal(saved.Event, &e); err != nil {
+		return storage.Event{}, fmt.Errorf("unable to decode idempotent response: %w", err)
+	}
+	return e, nil
+}
+
+// lockEvent locks the event row until the end of the transaction.
+func lockEvent(ctx context.Context, tx *sqlx.Tx, id string) (storage.Event, error) {
+	var e event
+	err := tx.GetContext(ctx, &e, `SELECT `+eventColumns+` FROM events WHERE id = $1 FOR UPDATE`, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.Event{}, storage.ErrEventNotFound
+	}
+	if err != nil {
+		return storage.Event{}, fmt.Errorf("unable to lock event: %w", err)
+	}
+	events := []storage.Event{e.toStorage()}
+	if err := loadReminders(ctx, tx, events); err != nil {
+		return storage.Event{}, err
+	}
+	return events[0], nil
+}
+
+func checkLive(e storage.Event, expectedVersion int64) error {
+	if e.IsDeleted() {
+		return storage.ErrEventNotFound
+	}
+	if expectedVersion != storage.AnyVersion && e.Version != expectedVersion {
+		return storage.ErrVersionMismatch
+	}
+	return nil
+}
+
+// checkBusy serializes writes of the same user with an advisory lock,
+// so two concurrent transactions can't both pass the overlap check.
+func checkBusy(ctx context.Context, tx *sqlx.Tx, e storage.Event) error {
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, e.UserID); err != nil {
+		return fmt.Errorf("unable to lock user events: %w", err)
+	}
+
+	var busy bool
+	err := tx.GetContext(ctx, &busy, `
+		SELECT EXISTS (
+			SELECT 1 FROM events
+			WHERE user_id = $1 AND id <> $2 AND deleted_at IS NULL AND starts_at < $4 AND ends_at > $3
+		)`,
+		e.UserID, e.ID, e.StartsAt, e.EndsAt)
+	if err != nil {
+		return fmt.Errorf("unable to check busy date: %w", err)
+	}
+	if busy {
+		return storage.ErrDateBusy
+	}
 	return nil
 }
+
+// record appends the change to the history, its tx_id is set to the current transaction by the column default.
+func record(ctx context.Context, tx *sqlx.Tx, e storage.Event, action storage.Action, userID string, fields []string) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO event_history (event_id, version, action, user_id, fields, changed_at)
+		VALUES ($1, $2, $3, $4, $5, $6)`,
+		e.ID, e.Version, string(action), userID, strings.Join(fields, ","), time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("unable to record event history: %w", err)
+	}
+	return nil
+}
+
+// saveReminders replaces reminders of the event. Kept reminders stay sent unless the event is moved.
+func saveReminders(ctx context.Context, tx *sqlx.Tx, e storage.Event, moved bool) error {
+	var old []reminder
+	err := tx.SelectContext(ctx, &old, `
+		DELETE FROM event_reminders WHERE event_id = $1
+		RETURNING event_id, before_seconds, channel, notified_at`,
+		e.ID)
+	if err != nil {
+		return fmt.Errorf("unable to delete reminders: %w", err)
+	}
+
+	notified := make(map[storage.Reminder]sql.NullTime, len(old))
+	if !moved {
+		for _, r := range old {
+			notified[r.toStorage()] = r.NotifiedAt
+		}
+	}
+	for _, r := range e.Reminders {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO event_reminders (event_id, before_seconds, channel, remind_at, notified_at)
+			VALUES ($1, $2, $3, $4, $5)`,
+			e.ID, int64(r.Before/time.Second), string(r.Channel), e.StartsAt.Add(-r.Before), notified[r])
+		if err != nil {
+			return fmt.Errorf("unable to save reminder: %w", err)
+		}
+	}
+	return nil
+}
+
+// loadReminders sets reminders of the events with a single query.
+func loadReminders(ctx context.Context, q sqlx.QueryerContext, events []storage.Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+	ids := make([]string, 0, len(events))
+	for _, e := range events {
+		ids = append(ids, e.ID)
+	}
+
+	var rows []reminder
+	err := sqlx.SelectContext(ctx, q, &rows, `
+		SELECT event_id, before_seconds, channel, notified_at
+		FROM event_reminders
+		WHERE event_id = ANY($1)
+		ORDER BY before_seconds DESC, channel COLLATE "C"`,
+		ids)
+	if err != nil {
+		return fmt.Errorf("unable to get reminders: %w", err)
+	}
+
+	reminders := make(map[string][]storage.Reminder)
+	for _, row := range rows {
+		reminders[row.EventID] = append(reminders[row.EventID], row.toStorage())
+	}
+	for i := range events {
+		events[i].Reminders = reminders[events[i].ID]
+	}
+	return nil
+}
+
+// prefixed qualifies every column of the list with the table alias.
+func prefixed(alias, columns string) string {
+	list := strings.Split(columns, ",")
+	for i, column := range list {
+		list[i] = alias + "." + strings.TrimSpace(column)
+	}
+	return strings.Join(list, ", ")
+}
+
+func fromStorage(e storage.Event) event {
+	return event{
+		ID:          e.ID,
+		Title:       e.Title,
+		StartsAt:    e.StartsAt,
+		EndsAt:      e.EndsAt,
+		Description: e.Description,
+		CalendarID:  e.CalendarID,
+		UserID:      e.UserID,
+		Version:     e.Version,
+		CreatedAt:   e.CreatedAt,
+		UpdatedAt:   e.UpdatedAt,
+		DeletedAt:   sql.NullTime{Time: e.DeletedAt, Valid: !e.DeletedAt.IsZero()},
+	}
+}
+
+func (e event) toStorage() storage.Event {
+	return storage.Event{
+		ID:          e.ID,
+		Title:       e.Title,
+		StartsAt:    e.StartsAt,
+		EndsAt:      e.EndsAt,
+		Description: e.Description,
+		CalendarID:  e.CalendarID,
+		UserID:      e.UserID,
+		Version:     e.Version,
+		CreatedAt:   e.CreatedAt,
+		UpdatedAt:   e.UpdatedAt,
+		DeletedAt:   e.DeletedAt.Time,
+	}
+}
+
+func (r reminder) toStorage() storage.Reminder {
+	return storage.Reminder{
+		Before:  time.Duration(r.BeforeSeconds) * time.Second,
+		Channel: storage.Channel(r.Channel),
+	}
+}
+
+func (c change) toStorage() storage.Change {
+	fields := make([]string, 0)
+	if c.Fields != "" {
+		fields = strings.Split(c.Fields, ",")
+	}
+	return storage.Change{
+		ID:        c.ID,
+		EventID:   c.EventID,
+		Version:   c.Version,
+		Action:    storage.Action(c.Action),
+		UserID:    c.UserID,
+		Fields:    fields,
+		ChangedAt: c.ChangedAt,
+	}
+}
@@ -0,0 +1,74 @@
+package sqlstorage
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage/storagetest"
+	"github.com/stretchr/testify/require"
+)
+
+// dsnEnv points to a migrated database, its events are deleted by the tests.
+const dsnEnv = "CALENDAR_TEST_DSN"
+
+func TestStorage(t *testing.T) {
+	dsn := os.Getenv(dsnEnv)
+	if dsn == "" {
+		t.Skip(dsnEnv + " is not set")
+	}
+
+	ctx := context.Background()
+	s := New(dsn)
+	require.NoError(t, s.Connect(ctx))
+	t.Cleanup(func() { s.Close(ctx) })
+
+	storagetest.Run(t, func(t *testing.T) storagetest.Storage {
+		_, err := s.db.ExecContext(ctx,
+			`TRUNCATE events, event_history, event_reminders, idempotency_keys, calendar_shares, calendars,
+				notification_outbox, event_attachments, working_hours, working_periods, working_holidays,
+				notification_preferences`)
+		require.NoError(t, err)
+		return s
+	})
+}
+
+func TestAdvisoryLock(t *testing.T) {
+	dsn := os.Getenv(dsnEnv)
+	if dsn == "" {
+		t.Skip(dsnEnv + " is not set")
+	}
+
+	ctx := context.Background()
+	leader, follower := New(dsn), New(dsn)
+	require.NoError(t, leader.Connect(ctx))
+	t.Cleanup(func() { leader.Close(ctx) })
+	require.NoError(t, follower.Connect(ctx))
+	t.Cleanup(func() { follower.Close(ctx) })
+
+	held, waiting := leader.AdvisoryLock(1), follower.AdvisoryLock(1)
+	locked, err := held.TryLock(ctx)
+	require.NoError(t, err)
+	require.True(t, locked)
+	require.NoError(t, held.Check(ctx))
+
+	locked, err = waiting.TryLock(ctx)
+	require.NoError(t, err)
+	require.False(t, locked)
+	require.Error(t, waiting.Check(ctx))
+
+	locked, err = follower.AdvisoryLock(2).TryLock(ctx)
+	require.NoError(t, err)
+	require.True(t, locked, "locks with other keys are independent")
+
+	require.NoError(t, held.Unlock(ctx))
+	locked, err = waiting.TryLock(ctx)
+	require.NoError(t, err)
+	require.True(t, locked)
+
+	// a dead holder releases the lock with its connection
+	require.NoError(t, follower.Close(ctx))
+	locked, err = leader.AdvisoryLock(1).TryLock(ctx)
+	require.NoError(t, err)
+	require.True(t, locked)
+}
@@ -0,0 +1,127 @@
+package sqlstorage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	"github.com/jmoiron/sqlx"
+)
+
+type workingHours struct {
+	UserID    string    `db:"user_id"`
+	TimeZone  string    `db:"time_zone"`
+	Strict    bool      `db:"strict"`
+	UpdatedAt time.Time `db:"updated_at"`
+}
+
+type workingPeriod struct {
+	Weekday      int   `db:"weekday"`
+	StartSeconds int64 `db:"start_seconds"`
+	EndSeconds   int64 `db:"end_seconds"`
+}
+
+func (s *Storage) GetWorkingHours(ctx context.Context, userID string) (storage.WorkingHours, error) {
+	var row workingHours
+	err := s.db.GetContext(ctx, &row, `
+		SELECT user_id, time_zone, strict, updated_at FROM working_hours WHERE user_id = $1`,
+		userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.WorkingHours{}, storage.ErrWorkingHoursNotFound
+	}
+	if err != nil {
+		return storage.WorkingHours{}, fmt.Errorf("unable to get working hours: %w", err)
+	}
+
+	var periods []workingPeriod
+	err = s.db.SelectContext(ctx, &periods, `
+		SELECT weekday, start_seconds, end_seconds
+		FROM working_periods
+		WHERE user_id = $1
+		ORDER BY weekday, start_seconds`,
+		userID)
+	if err != nil {
+		return storage.WorkingHours{}, fmt.Errorf("unable to get working periods: %w", err)
+	}
+	hours := storage.WorkingHours{
+		UserID:    row.UserID,
+		TimeZone:  row.TimeZone,
+		Strict:    row.Strict,
+		UpdatedAt: row.UpdatedAt,
+	}
+	for _, p := range periods {
+		hours.Periods = append(hours.Periods, storage.WorkingPeriod{
+			Weekday: time.Weekday(p.Weekday),
+			Start:   time.Duration(p.StartSeconds) * time.Second,
+			End:     time.Duration(p.EndSeconds) * time.Second,
+		})
+	}
+
+	err = s.db.SelectContext(ctx, &hours.Holidays, `
+		SELECT calendar FROM working_holidays WHERE user_id = $1 ORDER BY calendar COLLATE "C"`,
+		userID)
+	if err != nil {
+		return storage.WorkingHours{}, fmt.Errorf("unable to get holiday calendars: %w", err)
+	}
+	return hours, nil
+}
+
+// SetWorkingHours replaces the working hours of the user.
+func (s *Storage) SetWorkingHours(ctx context.Context, hours storage.WorkingHours) (storage.WorkingHours, error) {
+	hours.UpdatedAt = time.Now().UTC()
+	err := s.inTx(ctx, func(tx *sqlx.Tx) error {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO working_hours (user_id, time_zone, strict, updated_at)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (user_id) DO UPDATE
+			SET time_zone = excluded.time_zone, strict = excluded.strict, updated_at = excluded.updated_at`,
+			hours.UserID, hours.TimeZone, hours.Strict, hours.UpdatedAt)
+		if err != nil {
+			return fmt.Errorf("unable to save working hours: %w", err)
+		}
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM working_periods WHERE user_id = $1`, hours.UserID); err != nil {
+			return fmt.Errorf("unable to delete working periods: %w", err)
+		}
+		for _, p := range hours.Periods {
+			_, err := tx.ExecContext(ctx, `
+				INSERT INTO working_periods (user_id, weekday, start_seconds, end_seconds)
+				VALUES ($1, $2, $3, $4)`,
+				hours.UserID, int(p.Weekday), int64(p.Start/time.Second), int64(p.End/time.Second))
+			if err != nil {
+				return fmt.Errorf("unable to save working period: %w", err)
+			}
+		}
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM working_holidays WHERE user_id = $1`, hours.UserID); err != nil {
+			return fmt.Errorf("unable to delete holiday calendars: %w", err)
+		}
+		for _, name := range hours.Holidays {
+			_, err := tx.ExecContext(ctx,
+				`INSERT INTO working_holidays (user_id, calendar) VALUES ($1, $2)`,
+				hours.UserID, name)
+			if err != nil {
+				return fmt.Errorf("unable to save holiday calendar: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return storage.WorkingHours{}, err
+	}
+	return s.GetWorkingHours(ctx, hours.UserID)
+}
+
+func (s *Storage) DeleteWorkingHours(ctx context.Context, userID string) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM working_hours WHERE user_id = $1`, userID)
+	if err != nil {
+		return fmt.Errorf("unable to delete working hours: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return storage.ErrWorkingHoursNotFound
+	}
+	return nil
+}
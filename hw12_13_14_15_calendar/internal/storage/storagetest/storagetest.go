@@ -0,0 +1,1037 @@
+// Package storagetest is a conformance suite every storage implementation has to pass.
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	"github.com/stretchr/testify/require"
+)
+
+type Storage interface {
+	CreateEvent(ctx context.Context, event storage.Event, userID string) (storage.Event, error)
+	CreateEventIdempotent(
+		ctx context.Context, event storage.Event, userID string, idempotency storage.Idempotency,
+	) (storage.Event, error)
+	GetEvent(ctx context.Context, id string) (storage.Event, error)
+	EventCalendar(ctx context.Context, id string) (string, error)
+	UpdateEvent(ctx context.Context, event storage.Event, userID string, expectedVersion int64) (storage.Event, error)
+	DeleteEvent(ctx context.Context, id, userID string, expectedVersion int64) error
+	ApplyBatch(ctx context.Context, items []storage.BatchItem, userID string) ([]storage.BatchResult, error)
+	RestoreEvent(ctx context.Context, id, userID string, expectedVersion int64) (storage.Event, error)
+	EventHistory(ctx context.Context, id string) ([]storage.Change, error)
+	ListChanges(ctx context.Context, query storage.ChangeQuery) ([]storage.Change, error)
+	LatestChangeID(ctx context.Context) (int64, error)
+	ListEvents(ctx context.Context, query storage.Query) ([]storage.Event, error)
+	SearchEvents(ctx context.Context, query storage.SearchQuery) ([]storage.SearchHit, error)
+	CountPurgeable(ctx context.Context, deletedBefore, endedBefore time.Time) (int64, error)
+	PurgeEvents(ctx context.Context, deletedBefore, endedBefore time.Time) (int64, error)
+	PurgeIdempotencyKeys(ctx context.Context, now time.Time) (int64, error)
+	DueNotifications(ctx context.Context, now time.Time) ([]storage.DueReminder, error)
+	MarkNotified(ctx context.Context, id string, reminder storage.Reminder, msg storage.OutboxMessage) error
+	PendingOutbox(ctx context.Context, limit int) ([]storage.OutboxMessage, error)
+	DeleteOutbox(ctx context.Context, id int64) error
+	CreateCalendar(ctx context.Context, calendar storage.Calendar) (storage.Calendar, error)
+	GetCalendar(ctx context.Context, id string) (storage.Calendar, error)
+	ListCalendars(ctx context.Context, userID string) ([]storage.Calendar, error)
+	ShareCalendar(ctx context.Context, calendarID, userID string, access storage.Access) error
+	UnshareCalendar(ctx context.Context, calendarID, userID string) error
+	CreateAttachment(ctx context.Context, attachment storage.Attachment) (storage.Attachment, error)
+	GetAttachment(ctx context.Context, id string) (storage.Attachment, error)
+	ListAttachments(ctx context.Context, eventID string) ([]storage.Attachment, error)
+	DeleteAttachment(ctx context.Context, id string) error
+	GetWorkingHours(ctx context.Context, userID string) (storage.WorkingHours, error)
+	SetWorkingHours(ctx context.Context, hours storage.WorkingHours) (storage.WorkingHours, error)
+	DeleteWorkingHours(ctx context.Context, userID string) error
+	GetNotificationPreferences(ctx context.Context, userID string) (storage.NotificationPreferences, error)
+	SetNotificationPreferences(
+		ctx context.Context, prefs storage.NotificationPreferences,
+	) (storage.NotificationPreferences, error)
+	ListNotificationPreferences(ctx context.Context, userIDs []string) ([]storage.NotificationPreferences, error)
+}
+
+var day = time.Date(2021, 8, 2, 0, 0, 0, 0, time.UTC)
+
+// Every storage starts with a calendar of "user" and a calendar of "other user".
+const (
+	calendarID      = "calendar"
+	otherCalendarID = "other calendar"
+)
+
+func newEvent(id string, startsAt time.Time) storage.Event {
+	return storage.Event{
+		ID:         id,
+		Title:      "event " + id,
+		StartsAt:   startsAt,
+		EndsAt:     startsAt.Add(time.Hour),
+		CalendarID: calendarID,
+		UserID:     "user",
+	}
+}
+
+// newOtherEvent returns an event of the other user.
+func newOtherEvent(id string, startsAt time.Time) storage.Event {
+	e := newEvent(id, startsAt)
+	e.CalendarID = otherCalendarID
+	e.UserID = "other user"
+	return e
+}
+
+func dayQuery(calendarIDs ...string) storage.Query {
+	return storage.Query{CalendarIDs: calendarIDs, From: day, To: day.AddDate(0, 0, 1)}
+}
+
+// requireEqualEvent compares events ignoring time zones and sub-microsecond precision
+// which databases don't keep.
+func requireEqualEvent(t *testing.T, expected, actual storage.Event) {
+	t.Helper()
+	require.Equal(t, normalize(expected), normalize(actual))
+}
+
+func normalize(e storage.Event) storage.Event {
+	for _, ts := range []*time.Time{&e.StartsAt, &e.EndsAt, &e.CreatedAt, &e.UpdatedAt, &e.DeletedAt} {
+		if !ts.IsZero() {
+			*ts = ts.UTC().Truncate(time.Microsecond)
+		}
+	}
+	if len(e.Reminders) == 0 {
+		e.Reminders = nil
+	}
+	return e
+}
+
+// Run checks the storage returned by newStorage behaves as expected,
+// newStorage is called for every subtest and must return an empty storage.
+func Run(t *testing.T, newEmptyStorage func(t *testing.T) Storage) {
+	t.Helper()
+	ctx := context.Background()
+
+	newStorage := func(t *testing.T) Storage {
+		t.Helper()
+		s := newEmptyStorage(t)
+		for id, owner := range map[string]string{calendarID: "user", otherCalendarID: "other user"} {
+			_, err := s.CreateCalendar(ctx, storage.Calendar{ID: id, OwnerID: owner, Name: "default"})
+			require.NoError(t, err)
+		}
+		return s
+	}
+
+	t.Run("crud", func(t *testing.T) {
+		s := newStorage(t)
+
+		event := newEvent("1", day)
+		event.Reminders = []storage.Reminder{
+			{Before: 24 * time.Hour, Channel: storage.ChannelWebhook},
+			{Before: 10 * time.Minute, Channel: storage.ChannelLog},
+		}
+		created, err := s.CreateEvent(ctx, event, "user")
+		require.NoError(t, err)
+		require.Equal(t, int64(1), created.Version)
+
+		got, err := s.GetEvent(ctx, "1")
+		require.NoError(t, err)
+		requireEqualEvent(t, created, got)
+
+		got.Title = "updated"
+		got.Reminders = got.Reminders[1:]
+		updated, err := s.UpdateEvent(ctx, got, "user", got.Version)
+		require.NoError(t, err)
+		require.Equal(t, "updated", updated.Title)
+		require.Equal(t, int64(2), updated.Version)
+
+		got, err = s.GetEvent(ctx, "1")
+		require.NoError(t, err)
+		requireEqualEvent(t, updated, got)
+
+		require.NoError(t, s.DeleteEvent(ctx, "1", "user", updated.Version))
+
+		_, err = s.GetEvent(ctx, "1")
+		require.ErrorIs(t, err, storage.ErrEventNotFound)
+	})
+
+	t.Run("calendars", func(t *testing.T) {
+		s := newStorage(t)
+
+		work, err := s.CreateCalendar(ctx, storage.Calendar{ID: "work", OwnerID: "user", Name: "work"})
+		require.NoError(t, err)
+		require.False(t, work.CreatedAt.IsZero())
+
+		_, err = s.CreateCalendar(ctx, storage.Calendar{ID: "work 2", OwnerID: "user", Name: "work"})
+		require.ErrorIs(t, err, storage.ErrCalendarExists)
+		_, err = s.CreateCalendar(ctx, storage.Calendar{ID: "other work", OwnerID: "other user", Name: "work"})
+		require.NoError(t, err)
+
+		require.NoError(t, s.ShareCalendar(ctx, "work", "other user", storage.AccessRead))
+		require.NoError(t, s.ShareCalendar(ctx, "work", "third user", storage.AccessRead))
+		require.NoError(t, s.ShareCalendar(ctx, "work", "other user", storage.AccessWrite))
+
+		got, err := s.GetCalendar(ctx, "work")
+		require.NoError(t, err)
+		require.Equal(t, "user", got.OwnerID)
+		require.Equal(t, "work", got.Name)
+		require.Equal(t, []storage.Share{
+			{UserID: "other user", Access: storage.AccessWrite},
+			{UserID: "third user", Access: storage.AccessRead},
+		}, got.Shares)
+
+		calendarIDs := func(userID string) []string {
+			calendars, err := s.ListCalendars(ctx, userID)
+			require.NoError(t, err)
+			ids := make([]string, 0, len(calendars))
+			for _, c := range calendars {
+				ids = append(ids, c.ID)
+			}
+			return ids
+		}
+		require.Equal(t, []string{otherCalendarID, "other work", "work"}, calendarIDs("other user"))
+		require.Equal(t, []string{"work"}, calendarIDs("third user"))
+		require.Empty(t, calendarIDs("nobody"))
+
+		require.NoError(t, s.UnshareCalendar(ctx, "work", "other user"))
+		require.NoError(t, s.UnshareCalendar(ctx, "work", "other user"))
+		require.Equal(t, []string{otherCalendarID, "other work"}, calendarIDs("other user"))
+
+		_, err = s.GetCalendar(ctx, "unknown")
+		require.ErrorIs(t, err, storage.ErrCalendarNotFound)
+		require.ErrorIs(t, s.ShareCalendar(ctx, "unknown", "user", storage.AccessRead), storage.ErrCalendarNotFound)
+		require.ErrorIs(t, s.UnshareCalendar(ctx, "unknown", "user"), storage.ErrCalendarNotFound)
+
+		// the calendar of soft deleted events is found too
+		event := newEvent("1", day)
+		event.CalendarID = "work"
+		_, err = s.CreateEvent(ctx, event, "user")
+		require.NoError(t, err)
+		require.NoError(t, s.DeleteEvent(ctx, "1", "user", storage.AnyVersion))
+		id, err := s.EventCalendar(ctx, "1")
+		require.NoError(t, err)
+		require.Equal(t, "work", id)
+
+		_, err = s.EventCalendar(ctx, "2")
+		require.ErrorIs(t, err, storage.ErrEventNotFound)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		s := newStorage(t)
+
+		_, err := s.UpdateEvent(ctx, newEvent("1", day), "user", storage.AnyVersion)
+		require.ErrorIs(t, err, storage.ErrEventNotFound)
+
+		err = s.DeleteEvent(ctx, "1", "user", storage.AnyVersion)
+		require.ErrorIs(t, err, storage.ErrEventNotFound)
+	})
+
+	t.Run("date busy", func(t *testing.T) {
+		s := newStorage(t)
+
+		_, err := s.CreateEvent(ctx, newEvent("1", day), "user")
+		require.NoError(t, err)
+
+		_, err = s.CreateEvent(ctx, newEvent("2", day.Add(30*time.Minute)), "user")
+		require.ErrorIs(t, err, storage.ErrDateBusy)
+
+		_, err = s.CreateEvent(ctx, newOtherEvent("3", day), "other user")
+		require.NoError(t, err)
+
+		_, err = s.CreateEvent(ctx, newEvent("4", day.Add(time.Hour)), "user")
+		require.NoError(t, err)
+
+		_, err = s.UpdateEvent(ctx, newEvent("4", day.Add(10*time.Minute)), "user", storage.AnyVersion)
+		require.ErrorIs(t, err, storage.ErrDateBusy)
+	})
+
+	t.Run("version mismatch", func(t *testing.T) {
+		s := newStorage(t)
+
+		created, err := s.CreateEvent(ctx, newEvent("1", day), "user")
+		require.NoError(t, err)
+
+		first, err := s.UpdateEvent(ctx, created, "user", created.Version)
+		require.NoError(t, err)
+
+		_, err = s.UpdateEvent(ctx, created, "user", created.Version)
+		require.ErrorIs(t, err, storage.ErrVersionMismatch)
+
+		err = s.DeleteEvent(ctx, "1", "user", created.Version)
+		require.ErrorIs(t, err, storage.ErrVersionMismatch)
+
+		_, err = s.UpdateEvent(ctx, created, "user", storage.AnyVersion)
+		require.NoError(t, err)
+
+		err = s.DeleteEvent(ctx, "1", "user", first.Version+1)
+		require.NoError(t, err)
+	})
+
+	t.Run("soft delete and restore", func(t *testing.T) {
+		s := newStorage(t)
+
+		created, err := s.CreateEvent(ctx, newEvent("1", day), "user")
+		require.NoError(t, err)
+
+		_, err = s.RestoreEvent(ctx, "1", "user", storage.AnyVersion)
+		require.ErrorIs(t, err, storage.ErrEventNotDeleted)
+
+		require.NoError(t, s.DeleteEvent(ctx, "1", "user", created.Version))
+
+		_, err = s.GetEvent(ctx, "1")
+		require.ErrorIs(t, err, storage.ErrEventNotFound)
+
+		err = s.DeleteEvent(ctx, "1", "user", storage.AnyVersion)
+		require.ErrorIs(t, err, storage.ErrEventNotFound)
+
+		events, err := s.ListEvents(ctx, dayQuery(calendarID))
+		require.NoError(t, err)
+		require.Empty(t, events)
+
+		// the slot of a deleted event is free
+		_, err = s.CreateEvent(ctx, newEvent("2", day), "user")
+		require.NoError(t, err)
+
+		_, err = s.RestoreEvent(ctx, "1", "user", storage.AnyVersion)
+		require.ErrorIs(t, err, storage.ErrDateBusy)
+
+		require.NoError(t, s.DeleteEvent(ctx, "2", "user", storage.AnyVersion))
+
+		_, err = s.RestoreEvent(ctx, "1", "user", created.Version)
+		require.ErrorIs(t, err, storage.ErrVersionMismatch)
+
+		restored, err := s.RestoreEvent(ctx, "1", "admin", created.Version+1)
+		require.NoError(t, err)
+		require.Equal(t, created.Version+2, restored.Version)
+		require.False(t, restored.IsDeleted())
+
+		got, err := s.GetEvent(ctx, "1")
+		require.NoError(t, err)
+		requireEqualEvent(t, restored, got)
+	})
+
+	t.Run("history", func(t *testing.T) {
+		s := newStorage(t)
+
+		created, err := s.CreateEvent(ctx, newEvent("1", day), "user")
+		require.NoError(t, err)
+
+		changed := created
+		changed.Title = "new title"
+		changed.Reminders = []storage.Reminder{{Before: time.Minute, Channel: storage.ChannelLog}}
+		updated, err := s.UpdateEvent(ctx, changed, "user", created.Version)
+		require.NoError(t, err)
+
+		require.NoError(t, s.DeleteEvent(ctx, "1", "admin", updated.Version))
+
+		history, err := s.EventHistory(ctx, "1")
+		require.NoError(t, err)
+		require.Len(t, history, 3)
+
+		require.Equal(t, storage.ActionCreated, history[0].Action)
+		require.Equal(t, int64(1), history[0].Version)
+		require.Equal(t, []string{"title", "starts_at", "ends_at", "calendar_id", "user_id"}, history[0].Fields)
+		require.Equal(t, "user", history[0].UserID)
+
+		require.Equal(t, storage.ActionUpdated, history[1].Action)
+		require.Equal(t, []string{"title", "reminders"}, history[1].Fields)
+
+		require.Equal(t, storage.ActionDeleted, history[2].Action)
+		require.Equal(t, "admin", history[2].UserID)
+		require.Equal(t, int64(3), history[2].Version)
+		require.Empty(t, history[2].Fields)
+
+		_, err = s.EventHistory(ctx, "2")
+		require.ErrorIs(t, err, storage.ErrEventNotFound)
+	})
+
+	t.Run("batch", func(t *testing.T) {
+		s := newStorage(t)
+
+		created, err := s.CreateEvent(ctx, newEvent("1", day), "user")
+		require.NoError(t, err)
+
+		// every failure is reported and nothing is applied
+		results, err := s.ApplyBatch(ctx, []storage.BatchItem{
+			{Op: storage.BatchCreate, Event: newEvent("2", day.Add(2*time.Hour))},
+			{Op: storage.BatchUpdate, Event: created, ExpectedVersion: created.Version + 1},
+			{Op: storage.BatchCreate, Event: newEvent("3", day.Add(150*time.Minute))},
+			{Op: storage.BatchDelete, Event: storage.Event{ID: "missing"}},
+		}, "user")
+		require.NoError(t, err)
+		require.Len(t, results, 4)
+		for i, expected := range []error{
+			storage.ErrBatchAborted, storage.ErrVersionMismatch, storage.ErrDateBusy, storage.ErrEventNotFound,
+		} {
+			require.ErrorIs(t, results[i].Err, expected, "item %d", i)
+		}
+		require.Equal(t, "2", results[0].Event.ID)
+
+		_, err = s.GetEvent(ctx, "2")
+		require.ErrorIs(t, err, storage.ErrEventNotFound)
+		changes, err := s.ListChanges(ctx, storage.ChangeQuery{CalendarIDs: []string{calendarID}})
+		require.NoError(t, err)
+		require.Len(t, changes, 1)
+
+		// items see the changes of the previous ones
+		moved := created
+		moved.StartsAt, moved.EndsAt = day.Add(5*time.Hour), day.Add(6*time.Hour)
+		results, err = s.ApplyBatch(ctx, []storage.BatchItem{
+			{Op: storage.BatchUpdate, Event: moved, ExpectedVersion: created.Version},
+			{Op: storage.BatchCreate, Event: newEvent("2", day)},
+			{Op: storage.BatchDelete, Event: storage.Event{ID: "2"}, ExpectedVersion: 1},
+		}, "user")
+		require.NoError(t, err)
+		for i, result := range results {
+			require.NoError(t, result.Err, "item %d", i)
+		}
+		require.Equal(t, int64(2), results[0].Event.Version)
+		require.Equal(t, int64(1), results[1].Event.Version)
+		require.Equal(t, "2", results[2].Event.ID)
+
+		got, err := s.GetEvent(ctx, "1")
+		require.NoError(t, err)
+		requireEqualEvent(t, results[0].Event, got)
+		_, err = s.GetEvent(ctx, "2")
+		require.ErrorIs(t, err, storage.ErrEventNotFound)
+
+		changes, err = s.ListChanges(ctx, storage.ChangeQuery{CalendarIDs: []string{calendarID}})
+		require.NoError(t, err)
+		require.Len(t, changes, 4)
+		actions := make([]storage.Action, 0, len(changes))
+		for _, change := range changes {
+			actions = append(actions, change.Action)
+		}
+		require.Equal(t, []storage.Action{
+			storage.ActionCreated, storage.ActionUpdated, storage.ActionCreated, storage.ActionDeleted,
+		}, actions)
+	})
+
+	t.Run("list changes", func(t *testing.T) {
+		s := newStorage(t)
+		latest, err := s.LatestChangeID(ctx)
+		require.NoError(t, err)
+		require.Zero(t, latest)
+
+		first, err := s.CreateEvent(ctx, newEvent("1", day), "user")
+		require.NoError(t, err)
+		_, err = s.CreateEvent(ctx, newOtherEvent("2", day), "other user")
+		require.NoError(t, err)
+		_, err = s.CreateEvent(ctx, newEvent("3", day.Add(2*time.Hour)), "user")
+		require.NoError(t, err)
+		require.NoError(t, s.DeleteEvent(ctx, "1", "user", first.Version))
+
+		changes, err := s.ListChanges(ctx, storage.ChangeQuery{CalendarIDs: []string{calendarID}})
+		require.NoError(t, err)
+		require.Len(t, changes, 3)
+		require.Equal(t, "1", changes[0].EventID)
+		require.Equal(t, storage.ActionCreated, changes[0].Action)
+		require.Equal(t, "3", changes[1].EventID)
+		require.Equal(t, "1", changes[2].EventID)
+		require.Equal(t, storage.ActionDeleted, changes[2].Action)
+		require.Less(t, changes[0].ID, changes[1].ID)
+		require.Less(t, changes[1].ID, changes[2].ID)
+
+		changes, err = s.ListChanges(ctx, storage.ChangeQuery{
+			CalendarIDs: []string{calendarID},
+			AfterID:     changes[0].ID,
+			Limit:       1,
+		})
+		require.NoError(t, err)
+		require.Len(t, changes, 1)
+		require.Equal(t, "3", changes[0].EventID)
+
+		history, err := s.EventHistory(ctx, "1")
+		require.NoError(t, err)
+		require.Less(t, history[0].ID, history[1].ID)
+
+		changes, err = s.ListChanges(ctx, storage.ChangeQuery{CalendarIDs: []string{calendarID, otherCalendarID}})
+		require.NoError(t, err)
+		require.Len(t, changes, 4)
+		latest, err = s.LatestChangeID(ctx)
+		require.NoError(t, err)
+		require.Equal(t, changes[3].ID, latest)
+		changes, err = s.ListChanges(ctx, storage.ChangeQuery{
+			CalendarIDs: []string{calendarID, otherCalendarID},
+			AfterID:     latest,
+		})
+		require.NoError(t, err)
+		require.Empty(t, changes)
+
+		changes, err = s.ListChanges(ctx, storage.ChangeQuery{})
+		require.NoError(t, err)
+		require.Empty(t, changes)
+	})
+
+	t.Run("purge", func(t *testing.T) {
+		s := newStorage(t)
+
+		_, err := s.CreateEvent(ctx, newEvent("live", day), "user")
+		require.NoError(t, err)
+		_, err = s.CreateEvent(ctx, newEvent("deleted", day.Add(time.Hour)), "user")
+		require.NoError(t, err)
+		_, err = s.CreateEvent(ctx, newEvent("old", day.AddDate(-2, 0, 0)), "user")
+		require.NoError(t, err)
+		require.NoError(t, s.DeleteEvent(ctx, "deleted", "user", storage.AnyVersion))
+
+		n, err := s.CountPurgeable(ctx, time.Now().Add(time.Hour), day.AddDate(-1, 0, 0))
+		require.NoError(t, err)
+		require.Equal(t, int64(2), n)
+
+		purged, err := s.PurgeEvents(ctx, time.Now().Add(-time.Hour), day.AddDate(-1, 0, 0))
+		require.NoError(t, err)
+		require.Equal(t, int64(1), purged)
+
+		purged, err = s.PurgeEvents(ctx, time.Now().Add(time.Hour), day.AddDate(-1, 0, 0))
+		require.NoError(t, err)
+		require.Equal(t, int64(1), purged)
+
+		_, err = s.EventHistory(ctx, "deleted")
+		require.ErrorIs(t, err, storage.ErrEventNotFound)
+
+		_, err = s.GetEvent(ctx, "live")
+		require.NoError(t, err)
+	})
+
+	t.Run("notifications", func(t *testing.T) {
+		s := newStorage(t)
+
+		early := storage.Reminder{Before: time.Hour, Channel: storage.ChannelWebhook}
+		late := storage.Reminder{Before: 10 * time.Minute, Channel: storage.ChannelLog}
+		event := newEvent("1", day)
+		event.Reminders = []storage.Reminder{early, late}
+		event, err := s.CreateEvent(ctx, event, "user")
+		require.NoError(t, err)
+
+		due, err := s.DueNotifications(ctx, day.Add(-2*time.Hour))
+		require.NoError(t, err)
+		require.Empty(t, due)
+
+		due, err = s.DueNotifications(ctx, day.Add(-time.Hour))
+		require.NoError(t, err)
+		require.Len(t, due, 1)
+		require.Equal(t, early, due[0].Reminder)
+		requireEqualEvent(t, event, due[0].Event)
+
+		require.NoError(t, s.MarkNotified(ctx, "1", early, storage.OutboxMessage{Body: []byte("early")}))
+		// an overlapping scan doesn't notify again
+		require.ErrorIs(t, s.MarkNotified(ctx, "1", early, storage.OutboxMessage{Body: []byte("again")}),
+			storage.ErrAlreadyNotified)
+		due, err = s.DueNotifications(ctx, day.Add(-time.Hour))
+		require.NoError(t, err)
+		require.Empty(t, due)
+
+		due, err = s.DueNotifications(ctx, day.Add(-5*time.Minute))
+		require.NoError(t, err)
+		require.Len(t, due, 1, "sent reminders are skipped")
+		require.Equal(t, late, due[0].Reminder)
+
+		// sent reminders which are kept stay sent
+		event.Title = "renamed"
+		event, err = s.UpdateEvent(ctx, event, "user", storage.AnyVersion)
+		require.NoError(t, err)
+		due, err = s.DueNotifications(ctx, day.Add(-30*time.Minute))
+		require.NoError(t, err)
+		require.Empty(t, due)
+
+		// rescheduling makes all reminders due again
+		event.StartsAt = event.StartsAt.Add(time.Minute)
+		event.EndsAt = event.EndsAt.Add(time.Minute)
+		_, err = s.UpdateEvent(ctx, event, "user", storage.AnyVersion)
+		require.NoError(t, err)
+		due, err = s.DueNotifications(ctx, day.Add(-5*time.Minute))
+		require.NoError(t, err)
+		require.Len(t, due, 2)
+		require.Equal(t, early, due[0].Reminder)
+		require.Equal(t, late, due[1].Reminder)
+
+		due, err = s.DueNotifications(ctx, day.Add(time.Minute))
+		require.NoError(t, err)
+		require.Empty(t, due, "started events are not notified")
+
+		require.ErrorIs(t, s.MarkNotified(ctx, "2", early, storage.OutboxMessage{}), storage.ErrEventNotFound)
+		unknown := storage.Reminder{Before: time.Minute, Channel: storage.ChannelLog}
+		require.ErrorIs(t, s.MarkNotified(ctx, "1", unknown, storage.OutboxMessage{}), storage.ErrEventNotFound)
+
+		outbox, err := s.PendingOutbox(ctx, 10)
+		require.NoError(t, err)
+		require.Len(t, outbox, 1, "failed marks add nothing to the outbox")
+	})
+
+	t.Run("outbox", func(t *testing.T) {
+		s := newStorage(t)
+
+		event := newEvent("1", day)
+		event.Reminders = []storage.Reminder{
+			{Before: time.Hour, Channel: storage.ChannelWebhook},
+			{Before: 10 * time.Minute, Channel: storage.ChannelLog},
+		}
+		_, err := s.CreateEvent(ctx, event, "user")
+		require.NoError(t, err)
+
+		outbox, err := s.PendingOutbox(ctx, 10)
+		require.NoError(t, err)
+		require.Empty(t, outbox)
+
+		for i, reminder := range event.Reminders {
+			require.NoError(t, s.MarkNotified(ctx, "1", reminder, storage.OutboxMessage{
+				Body:    []byte(strconv.Itoa(i)),
+				Headers: map[string]string{"traceparent": "trace " + strconv.Itoa(i)},
+			}))
+		}
+
+		outbox, err = s.PendingOutbox(ctx, 1)
+		require.NoError(t, err)
+		require.Len(t, outbox, 1)
+		require.Equal(t, []byte("0"), outbox[0].Body)
+		require.Equal(t, map[string]string{"traceparent": "trace 0"}, outbox[0].Headers)
+		require.False(t, outbox[0].CreatedAt.IsZero())
+
+		require.NoError(t, s.DeleteOutbox(ctx, outbox[0].ID))
+		require.NoError(t, s.DeleteOutbox(ctx, outbox[0].ID), "deleting twice is not an error")
+		outbox, err = s.PendingOutbox(ctx, 10)
+		require.NoError(t, err)
+		require.Len(t, outbox, 1)
+		require.Equal(t, []byte("1"), outbox[0].Body)
+
+		// messages outlive their events
+		_, err = s.PurgeEvents(ctx, time.Now().Add(time.Hour), day.AddDate(1, 0, 0))
+		require.NoError(t, err)
+		outbox, err = s.PendingOutbox(ctx, 10)
+		require.NoError(t, err)
+		require.Len(t, outbox, 1)
+	})
+
+	t.Run("attachments", func(t *testing.T) {
+		s := newStorage(t)
+
+		_, err := s.CreateEvent(ctx, newEvent("1", day), "user")
+		require.NoError(t, err)
+		_, err = s.CreateEvent(ctx, newEvent("2", day.Add(2*time.Hour)), "user")
+		require.NoError(t, err)
+
+		attachments, err := s.ListAttachments(ctx, "1")
+		require.NoError(t, err)
+		require.Empty(t, attachments)
+
+		agenda, err := s.CreateAttachment(ctx, storage.Attachment{
+			ID: "agenda", EventID: "1", Name: "agenda.md", ContentType: "text/markdown", Size: 10, UserID: "user",
+		})
+		require.NoError(t, err)
+		require.False(t, agenda.CreatedAt.IsZero())
+		_, err = s.CreateAttachment(ctx, storage.Attachment{
+			ID: "slides", EventID: "1", Name: "slides.pdf", ContentType: "application/pdf", Size: 20, UserID: "user",
+		})
+		require.NoError(t, err)
+		_, err = s.CreateAttachment(ctx, storage.Attachment{ID: "other", EventID: "2", Name: "other", UserID: "user"})
+		require.NoError(t, err)
+		_, err = s.CreateAttachment(ctx, storage.Attachment{ID: "missing", EventID: "3", Name: "missing"})
+		require.ErrorIs(t, err, storage.ErrEventNotFound)
+
+		got, err := s.GetAttachment(ctx, "agenda")
+		require.NoError(t, err)
+		require.Equal(t, agenda.Name, got.Name)
+		require.Equal(t, agenda.ContentType, got.ContentType)
+		require.Equal(t, agenda.Size, got.Size)
+		require.Equal(t, agenda.UserID, got.UserID)
+		require.WithinDuration(t, agenda.CreatedAt, got.CreatedAt, time.Millisecond)
+
+		attachments, err = s.ListAttachments(ctx, "1")
+		require.NoError(t, err)
+		require.Len(t, attachments, 2)
+		require.Equal(t, "agenda", attachments[0].ID)
+		require.Equal(t, "slides", attachments[1].ID)
+
+		require.NoError(t, s.DeleteAttachment(ctx, "agenda"))
+		require.ErrorIs(t, s.DeleteAttachment(ctx, "agenda"), storage.ErrAttachmentNotFound)
+		_, err = s.GetAttachment(ctx, "agenda")
+		require.ErrorIs(t, err, storage.ErrAttachmentNotFound)
+
+		// purged events take their attachments with them
+		_, err = s.PurgeEvents(ctx, time.Now().Add(time.Hour), day.Add(time.Hour+time.Minute))
+		require.NoError(t, err)
+		_, err = s.GetAttachment(ctx, "slides")
+		require.ErrorIs(t, err, storage.ErrAttachmentNotFound)
+		_, err = s.GetAttachment(ctx, "other")
+		require.NoError(t, err)
+	})
+
+	t.Run("working hours", func(t *testing.T) {
+		s := newStorage(t)
+
+		_, err := s.GetWorkingHours(ctx, "user")
+		require.ErrorIs(t, err, storage.ErrWorkingHoursNotFound)
+		require.ErrorIs(t, s.DeleteWorkingHours(ctx, "user"), storage.ErrWorkingHoursNotFound)
+
+		hours := storage.WorkingHours{
+			UserID:   "user",
+			TimeZone: "Europe/Moscow",
+			Periods: []storage.WorkingPeriod{
+				{Weekday: time.Tuesday, Start: 9 * time.Hour, End: 18 * time.Hour},
+				{Weekday: time.Monday, Start: 14 * time.Hour, End: 18 * time.Hour},
+				{Weekday: time.Monday, Start: 9 * time.Hour, End: 13 * time.Hour},
+			},
+			Holidays: []string{"us", "ru"},
+			Strict:   true,
+		}
+		set, err := s.SetWorkingHours(ctx, hours)
+		require.NoError(t, err)
+		require.False(t, set.UpdatedAt.IsZero())
+
+		got, err := s.GetWorkingHours(ctx, "user")
+		require.NoError(t, err)
+		require.Equal(t, "Europe/Moscow", got.TimeZone)
+		require.True(t, got.Strict)
+		require.Equal(t, []storage.WorkingPeriod{hours.Periods[2], hours.Periods[1], hours.Periods[0]}, got.Periods)
+		require.Equal(t, []string{"ru", "us"}, got.Holidays)
+		require.WithinDuration(t, set.UpdatedAt, got.UpdatedAt, time.Millisecond)
+
+		// setting replaces all periods and holidays
+		hours.Periods = hours.Periods[:1]
+		hours.Holidays = nil
+		hours.Strict = false
+		_, err = s.SetWorkingHours(ctx, hours)
+		require.NoError(t, err)
+		got, err = s.GetWorkingHours(ctx, "user")
+		require.NoError(t, err)
+		require.False(t, got.Strict)
+		require.Equal(t, hours.Periods, got.Periods)
+		require.Empty(t, got.Holidays)
+		_, err = s.GetWorkingHours(ctx, "other user")
+		require.ErrorIs(t, err, storage.ErrWorkingHoursNotFound)
+
+		require.NoError(t, s.DeleteWorkingHours(ctx, "user"))
+		_, err = s.GetWorkingHours(ctx, "user")
+		require.ErrorIs(t, err, storage.ErrWorkingHoursNotFound)
+	})
+
+	t.Run("notification preferences", func(t *testing.T) {
+		s := newStorage(t)
+
+		_, err := s.GetNotificationPreferences(ctx, "user")
+		require.ErrorIs(t, err, storage.ErrPreferencesNotFound)
+
+		set, err := s.SetNotificationPreferences(ctx, storage.NotificationPreferences{
+			UserID: "user", TimeZone: "Europe/Moscow", Locale: "ru",
+		})
+		require.NoError(t, err)
+		require.False(t, set.UpdatedAt.IsZero())
+		_, err = s.SetNotificationPreferences(ctx, storage.NotificationPreferences{UserID: "other", Locale: "en"})
+		require.NoError(t, err)
+
+		got, err := s.GetNotificationPreferences(ctx, "user")
+		require.NoError(t, err)
+		require.Equal(t, "Europe/Moscow", got.TimeZone)
+		require.Equal(t, "ru", got.Locale)
+		require.WithinDuration(t, set.UpdatedAt, got.UpdatedAt, time.Millisecond)
+
+		// setting replaces all preferences
+		_, err = s.SetNotificationPreferences(ctx, storage.NotificationPreferences{UserID: "user", Locale: "de"})
+		require.NoError(t, err)
+		got, err = s.GetNotificationPreferences(ctx, "user")
+		require.NoError(t, err)
+		require.Empty(t, got.TimeZone)
+		require.Equal(t, "de", got.Locale)
+
+		list, err := s.ListNotificationPreferences(ctx, []string{"user", "other", "unknown"})
+		require.NoError(t, err)
+		sort.Slice(list, func(i, j int) bool { return list[i].UserID < list[j].UserID })
+		require.Len(t, list, 2)
+		require.Equal(t, "other", list[0].UserID)
+		require.Equal(t, "de", list[1].Locale)
+	})
+
+	t.Run("list", func(t *testing.T) {
+		s := newStorage(t)
+
+		for i, startsAt := range []time.Time{
+			day.Add(-time.Hour),
+			day.Add(10 * time.Hour),
+			day.Add(23*time.Hour + 30*time.Minute),
+			day.Add(24*time.Hour + 30*time.Minute),
+		} {
+			_, err := s.CreateEvent(ctx, newEvent(strconv.Itoa(i), startsAt), "user")
+			require.NoError(t, err)
+		}
+
+		events, err := s.ListEvents(ctx, dayQuery(calendarID))
+		require.NoError(t, err)
+		ids := make([]string, 0, len(events))
+		for _, e := range events {
+			ids = append(ids, e.ID)
+		}
+		require.Equal(t, []string{"1", "2"}, ids)
+
+		events, err = s.ListEvents(ctx, dayQuery(otherCalendarID))
+		require.NoError(t, err)
+		require.Empty(t, events)
+
+		_, err = s.CreateEvent(ctx, newOtherEvent("other", day.Add(10*time.Hour)), "other user")
+		require.NoError(t, err)
+		events, err = s.ListEvents(ctx, dayQuery(calendarID, otherCalendarID))
+		require.NoError(t, err)
+		require.Len(t, events, 3)
+		require.Equal(t, "1", events[0].ID)
+		require.Equal(t, "other", events[1].ID)
+
+		events, err = s.ListEvents(ctx, dayQuery())
+		require.NoError(t, err)
+		require.Empty(t, events)
+	})
+
+	t.Run("list filters and pages", func(t *testing.T) {
+		s := newStorage(t)
+
+		for i, title := range []string{"Standup", "Lunch", "Team standup", "Retro"} {
+			e := newEvent(strconv.Itoa(i), day.Add(time.Duration(i)*time.Hour))
+			e.Title = title
+			if i%2 == 0 {
+				e.Reminders = []storage.Reminder{{Before: 10 * time.Minute, Channel: storage.ChannelLog}}
+			}
+			_, err := s.CreateEvent(ctx, e, "user")
+			require.NoError(t, err)
+		}
+		ids := func(q storage.Query) []string {
+			events, err := s.ListEvents(ctx, q)
+			require.NoError(t, err)
+			ids := make([]string, 0, len(events))
+			for _, e := range events {
+				ids = append(ids, e.ID)
+			}
+			return ids
+		}
+
+		q := dayQuery(calendarID)
+		q.TitleContains = "STANDUP"
+		require.Equal(t, []string{"0", "2"}, ids(q))
+
+		hasNotification := false
+		q = dayQuery(calendarID)
+		q.HasNotification = &hasNotification
+		require.Equal(t, []string{"1", "3"}, ids(q))
+
+		q = dayQuery(calendarID)
+		q.UpdatedSince = time.Now().Add(time.Hour)
+		require.Empty(t, ids(q))
+
+		q = dayQuery(calendarID)
+		q.Limit = 2
+		require.Equal(t, []string{"0", "1"}, ids(q))
+
+		q.After = &storage.Cursor{StartsAt: day.Add(time.Hour), ID: "1"}
+		require.Equal(t, []string{"2", "3"}, ids(q))
+
+		q.After = &storage.Cursor{StartsAt: day, ID: "-"}
+		require.Equal(t, []string{"0", "1"}, ids(q))
+	})
+
+	t.Run("search", func(t *testing.T) {
+		s := newStorage(t)
+
+		for i, e := range []struct{ title, description string }{
+			{"Team meeting", "weekly sync of the team"},
+			{"Lunch", "with the team"},
+			{"Planning", "quarter planning meeting"},
+			{"Dentist", ""},
+		} {
+			event := newEvent(strconv.Itoa(i), day.Add(time.Duration(i)*time.Hour))
+			event.Title = e.title
+			event.Description = e.description
+			_, err := s.CreateEvent(ctx, event, "user")
+			require.NoError(t, err)
+		}
+
+		search := func(q storage.SearchQuery) []string {
+			q.CalendarIDs = []string{calendarID}
+			hits, err := s.SearchEvents(ctx, q)
+			require.NoError(t, err)
+			ids := make([]string, 0, len(hits))
+			for _, h := range hits {
+				ids = append(ids, h.Event.ID)
+			}
+			return ids
+		}
+
+		// title matches rank higher than description ones
+		require.Equal(t, []string{"0", "1"}, search(storage.SearchQuery{Text: "TEAM"}))
+		require.Equal(t, []string{"0", "2"}, search(storage.SearchQuery{Text: "meeting"}))
+		require.Equal(t, []string{"0"}, search(storage.SearchQuery{Text: "team, meeting"}))
+		require.Empty(t, search(storage.SearchQuery{Text: "unknown"}))
+
+		require.Equal(t, []string{"0"}, search(storage.SearchQuery{Text: "team", Limit: 1}))
+
+		updated := newEvent("1", day.Add(time.Hour))
+		updated.Title = "Lunch"
+		_, err := s.UpdateEvent(ctx, updated, "user", storage.AnyVersion)
+		require.NoError(t, err)
+		require.Equal(t, []string{"0"}, search(storage.SearchQuery{Text: "team"}))
+
+		require.NoError(t, s.DeleteEvent(ctx, "0", "user", storage.AnyVersion))
+		require.Empty(t, search(storage.SearchQuery{Text: "team"}))
+
+		_, err = s.RestoreEvent(ctx, "0", "user", storage.AnyVersion)
+		require.NoError(t, err)
+		require.Equal(t, []string{"0"}, search(storage.SearchQuery{Text: "team"}))
+
+		hits, err := s.SearchEvents(ctx, storage.SearchQuery{CalendarIDs: []string{otherCalendarID}, Text: "team"})
+		require.NoError(t, err)
+		require.Empty(t, hits)
+	})
+
+	t.Run("list boundaries", func(t *testing.T) {
+		s := newStorage(t)
+
+		for id, startsAt := range map[string]time.Time{
+			"ends at from":    day.Add(-time.Hour),
+			"starts at to":    day.AddDate(0, 0, 1),
+			"crosses from":    day.Add(-30 * time.Minute),
+			"crosses to":      day.Add(23*time.Hour + 30*time.Minute),
+			"within":          day.Add(12 * time.Hour),
+			"ends before":     day.Add(-2 * time.Hour),
+			"starts after to": day.AddDate(0, 0, 1).Add(time.Hour),
+		} {
+			e := newEvent(id, startsAt)
+			// events of different users may overlap
+			e.UserID = "user " + id
+			_, err := s.CreateEvent(ctx, e, "user")
+			require.NoError(t, err)
+		}
+
+		events, err := s.ListEvents(ctx, dayQuery(calendarID))
+		require.NoError(t, err)
+		ids := make([]string, 0, len(events))
+		for _, e := range events {
+			ids = append(ids, e.ID)
+		}
+		require.Equal(t, []string{"crosses from", "within", "crosses to"}, ids)
+	})
+
+	t.Run("adjacent events are not busy", func(t *testing.T) {
+		s := newStorage(t)
+
+		_, err := s.CreateEvent(ctx, newEvent("1", day), "user")
+		require.NoError(t, err)
+		_, err = s.CreateEvent(ctx, newEvent("2", day.Add(time.Hour)), "user")
+		require.NoError(t, err)
+		_, err = s.CreateEvent(ctx, newEvent("3", day.Add(-time.Hour)), "user")
+		require.NoError(t, err)
+	})
+
+	t.Run("concurrent creates", func(t *testing.T) {
+		s := newStorage(t)
+
+		var (
+			wg        sync.WaitGroup
+			succeeded atomic.Int32
+		)
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := s.CreateEvent(ctx, newEvent(strconv.Itoa(i), day.Add(time.Duration(i)*time.Minute)), "user")
+				if err == nil {
+					succeeded.Add(1)
+					return
+				}
+				if !errors.Is(err, storage.ErrDateBusy) {
+					t.Errorf("unexpected error: %v", err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		require.Equal(t, int32(1), succeeded.Load())
+		events, err := s.ListEvents(ctx, dayQuery(calendarID))
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+	})
+
+	t.Run("idempotent create", func(t *testing.T) {
+		s := newStorage(t)
+		idempotency := storage.Idempotency{Key: "key", RequestHash: "hash", ExpiresAt: time.Now().Add(time.Hour)}
+
+		created, err := s.CreateEventIdempotent(ctx, newEvent("1", day), "user", idempotency)
+		require.NoError(t, err)
+
+		// a retry gets a new id assigned by the app, the event created first is returned
+		replayed, err := s.CreateEventIdempotent(ctx, newEvent("2", day), "user", idempotency)
+		require.NoError(t, err)
+		requireEqualEvent(t, created, replayed)
+
+		changed := idempotency
+		changed.RequestHash = "other hash"
+		_, err = s.CreateEventIdempotent(ctx, newEvent("3", day.Add(time.Hour)), "user", changed)
+		require.ErrorIs(t, err, storage.ErrIdempotencyKeyReused)
+
+		// keys are scoped by the user making the request
+		_, err = s.CreateEventIdempotent(ctx, newOtherEvent("4", day), "other user", idempotency)
+		require.NoError(t, err)
+
+		// failed requests don't take the key
+		failed := storage.Idempotency{Key: "failed", RequestHash: "hash", ExpiresAt: time.Now().Add(time.Hour)}
+		_, err = s.CreateEventIdempotent(ctx, newEvent("5", day), "user", failed)
+		require.ErrorIs(t, err, storage.ErrDateBusy)
+		_, err = s.CreateEventIdempotent(ctx, newEvent("5", day.Add(time.Hour)), "user", failed)
+		require.NoError(t, err)
+
+		purged, err := s.PurgeIdempotencyKeys(ctx, time.Now().Add(2*time.Hour))
+		require.NoError(t, err)
+		require.Equal(t, int64(3), purged)
+
+		_, err = s.CreateEventIdempotent(ctx, newEvent("6", day.Add(2*time.Hour)), "user", changed)
+		require.NoError(t, err)
+	})
+
+	t.Run("concurrent idempotent creates", func(t *testing.T) {
+		s := newStorage(t)
+		idempotency := storage.Idempotency{Key: "key", RequestHash: "hash", ExpiresAt: time.Now().Add(time.Hour)}
+
+		var wg sync.WaitGroup
+		ids := make([]string, 10)
+		for i := range ids {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				e, err := s.CreateEventIdempotent(ctx, newEvent(strconv.Itoa(i), day), "user", idempotency)
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+					return
+				}
+				ids[i] = e.ID
+			}()
+		}
+		wg.Wait()
+
+		for _, id := range ids {
+			require.Equal(t, ids[0], id)
+		}
+	})
+
+	t.Run("concurrent updates", func(t *testing.T) {
+		s := newStorage(t)
+
+		created, err := s.CreateEvent(ctx, newEvent("1", day), "user")
+		require.NoError(t, err)
+
+		var (
+			wg        sync.WaitGroup
+			mu        sync.Mutex
+			succeeded int
+		)
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := s.UpdateEvent(ctx, created, "user", created.Version); err == nil {
+					mu.Lock()
+					succeeded++
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+
+		require.Equal(t, 1, succeeded)
+	})
+}
@@ -0,0 +1,33 @@
+package storage
+
+import "time"
+
+// WorkingHours is the working time of a user, events out of it are warned about or rejected.
+type WorkingHours struct {
+	UserID string
+	// TimeZone is the IANA name of the time zone of the periods, UTC if empty.
+	TimeZone string
+	// Periods are ordered by weekday and start, a weekday without periods is a day off.
+	Periods []WorkingPeriod
+	// Holidays are names of the holiday calendars attached by the user ordered by name,
+	// their holidays are days off.
+	Holidays []string
+	// Strict rejects events out of the working time instead of warning about them.
+	Strict    bool
+	UpdatedAt time.Time
+}
+
+// WorkingPeriod is a part of a weekday, Start and End are offsets from the midnight.
+type WorkingPeriod struct {
+	Weekday time.Weekday
+	Start   time.Duration
+	End     time.Duration
+}
+
+// Less reports whether the period goes before the other one in a week starting on Sunday.
+func (p WorkingPeriod) Less(other WorkingPeriod) bool {
+	if p.Weekday == other.Weekday {
+		return p.Start < other.Start
+	}
+	return p.Weekday < other.Weekday
+}
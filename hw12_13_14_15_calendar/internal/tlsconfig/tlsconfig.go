@@ -0,0 +1,209 @@
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// checkInterval limits how often certificate files are checked for changes.
+const checkInterval = 5 * time.Second
+
+type Logger interface {
+	Info(msg string)
+	Error(msg string)
+}
+
+type Options struct {
+	// CertFile and KeyFile are the PEM encoded certificate and key, they are reloaded when the files change.
+	// Clients present them to servers requiring mutual TLS.
+	CertFile string
+	KeyFile  string
+	// CAFile verifies peers: servers require client certificates signed by it, which enables mutual TLS,
+	// clients verify the server certificate with it instead of the system roots.
+	CAFile string
+	// MinVersion is "1.2" or "1.3", "1.2" if empty.
+	MinVersion string
+	// Server makes New return the config of a server instead of a client.
+	Server bool
+	// URL is the address a client connects to, a secure scheme like amqps:// enables TLS without files.
+	URL string
+}
+
+// New returns the TLS config of a server or a client, nil if TLS is not configured so the connection is plaintext.
+// Servers use TLS when the certificate is set, clients when any of the files is set or the URL scheme is secure.
+func New(opts Options, logger Logger) (*tls.Config, error) {
+	if opts.Server {
+		if opts.CertFile == "" {
+			return nil, nil
+		}
+		return Server(opts, logger)
+	}
+	if !secureURL(opts.URL) && opts.CAFile == "" && opts.CertFile == "" && opts.KeyFile == "" {
+		return nil, nil
+	}
+	return Client(opts, logger)
+}
+
+// Server returns the TLS config of a server, the certificate and the key are required.
+func Server(opts Options, logger Logger) (*tls.Config, error) {
+	if opts.CertFile == "" || opts.KeyFile == "" {
+		return nil, errors.New("certificate and key files are required")
+	}
+	config, err := base(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	pair, err := newKeyPair(opts.CertFile, opts.KeyFile, logger)
+	if err != nil {
+		return nil, err
+	}
+	config.GetCertificate = func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+		return pair.certificate(), nil
+	}
+
+	if opts.CAFile != "" {
+		if config.ClientCAs, err = loadPool(opts.CAFile); err != nil {
+			return nil, err
+		}
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return config, nil
+}
+
+// Client returns the TLS config of a client, the certificate and the key are optional.
+func Client(opts Options, logger Logger) (*tls.Config, error) {
+	config, err := base(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.CertFile != "" || opts.KeyFile != "" {
+		pair, err := newKeyPair(opts.CertFile, opts.KeyFile, logger)
+		if err != nil {
+			return nil, err
+		}
+		config.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return pair.certificate(), nil
+		}
+	}
+
+	if opts.CAFile != "" {
+		if config.RootCAs, err = loadPool(opts.CAFile); err != nil {
+			return nil, err
+		}
+	}
+	return config, nil
+}
+
+func secureURL(url string) bool {
+	scheme, _, ok := strings.Cut(url, "://")
+	if !ok {
+		return false
+	}
+	switch strings.ToLower(scheme) {
+	case "amqps", "https":
+		return true
+	}
+	return false
+}
+
+func base(opts Options) (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	switch opts.MinVersion {
+	case "", "1.2":
+	case "1.3":
+		config.MinVersion = tls.VersionTLS13
+	default:
+		return nil, fmt.Errorf("unsupported minimum TLS version %q", opts.MinVersion)
+	}
+	return config, nil
+}
+
+func loadPool(file string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read CA file: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %s", file)
+	}
+	return pool, nil
+}
+
+// keyPair reloads the certificate on handshakes if its files have changed since the last check.
+// A pair failing to load, e.g. while the files are being replaced, keeps the previous one until the next check.
+type keyPair struct {
+	certFile string
+	keyFile  string
+	logger   Logger
+
+	mu       sync.Mutex
+	cert     *tls.Certificate
+	modified [2]time.Time
+	checked  time.Time
+	now      func() time.Time
+}
+
+func newKeyPair(certFile, keyFile string, logger Logger) (*keyPair, error) {
+	k := &keyPair{certFile: certFile, keyFile: keyFile, logger: logger, now: time.Now}
+	modified, err := k.modTimes()
+	if err != nil {
+		return nil, err
+	}
+	if err := k.load(modified); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+func (k *keyPair) certificate() *tls.Certificate {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if k.now().Sub(k.checked) < checkInterval {
+		return k.cert
+	}
+	k.checked = k.now()
+	modified, err := k.modTimes()
+	if err == nil && modified != k.modified {
+		err = k.load(modified)
+		if err == nil {
+			k.logger.Info("certificate " + k.certFile + " is reloaded")
+		}
+	}
+	if err != nil {
+		k.logger.Error("unable to reload certificate: " + err.Error())
+	}
+	return k.cert
+}
+
+func (k *keyPair) load(modified [2]time.Time) error {
+	cert, err := tls.LoadX509KeyPair(k.certFile, k.keyFile)
+	if err != nil {
+		return fmt.Errorf("unable to load certificate: %w", err)
+	}
+	k.cert = &cert
+	k.modified = modified
+	k.checked = k.now()
+	return nil
+}
+
+func (k *keyPair) modTimes() ([2]time.Time, error) {
+	var modified [2]time.Time
+	for i, file := range []string{k.certFile, k.keyFile} {
+		info, err := os.Stat(file)
+		if err != nil {
+			return modified, fmt.Errorf("unable to stat certificate file: %w", err)
+		}
+		modified[i] = info.ModTime()
+	}
+	return modified, nil
+}
@@ -0,0 +1,16 @@
+-- +goose Up
+CREATE TABLE events (
+    id            TEXT PRIMARY KEY,
+    title         TEXT        NOT NULL,
+    starts_at     TIMESTAMPTZ NOT NULL,
+    ends_at       TIMESTAMPTZ NOT NULL,
+    description   TEXT        NOT NULL DEFAULT '',
+    user_id       TEXT        NOT NULL,
+    notify_before BIGINT      NOT NULL DEFAULT 0,
+    version       BIGINT      NOT NULL DEFAULT 1
+);
+
+CREATE INDEX events_user_id_starts_at_idx ON events (user_id, starts_at);
+
+-- +goose Down
+DROP TABLE events;
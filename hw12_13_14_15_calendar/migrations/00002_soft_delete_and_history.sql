@@ -0,0 +1,20 @@
+-- +goose Up
+ALTER TABLE events ADD COLUMN deleted_at TIMESTAMPTZ;
+
+CREATE TABLE event_history (
+    id         BIGSERIAL PRIMARY KEY,
+    event_id   TEXT        NOT NULL REFERENCES events (id) ON DELETE CASCADE,
+    version    BIGINT      NOT NULL,
+    action     TEXT        NOT NULL,
+    user_id    TEXT        NOT NULL,
+    fields     TEXT        NOT NULL DEFAULT '',
+    changed_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX event_history_event_id_idx ON event_history (event_id, id);
+CREATE INDEX events_deleted_at_idx ON events (deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- +goose Down
+DROP INDEX events_deleted_at_idx;
+DROP TABLE event_history;
+ALTER TABLE events DROP COLUMN deleted_at;
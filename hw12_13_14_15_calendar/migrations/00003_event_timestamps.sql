@@ -0,0 +1,15 @@
+-- +goose Up
+ALTER TABLE events
+    ADD COLUMN created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    ADD COLUMN updated_at TIMESTAMPTZ NOT NULL DEFAULT now();
+
+DROP INDEX events_user_id_starts_at_idx;
+CREATE INDEX events_user_id_starts_at_idx ON events (user_id, starts_at, id COLLATE "C") WHERE deleted_at IS NULL;
+
+-- +goose Down
+DROP INDEX events_user_id_starts_at_idx;
+CREATE INDEX events_user_id_starts_at_idx ON events (user_id, starts_at);
+
+ALTER TABLE events
+    DROP COLUMN created_at,
+    DROP COLUMN updated_at;
@@ -0,0 +1,11 @@
+-- +goose Up
+ALTER TABLE events ADD COLUMN search TSVECTOR GENERATED ALWAYS AS (
+    setweight(to_tsvector('simple', title), 'A') ||
+    setweight(to_tsvector('simple', description), 'B')
+) STORED;
+
+CREATE INDEX events_search_idx ON events USING GIN (search);
+
+-- +goose Down
+DROP INDEX events_search_idx;
+ALTER TABLE events DROP COLUMN search;
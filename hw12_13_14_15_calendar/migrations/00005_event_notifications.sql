@@ -0,0 +1,10 @@
+-- +goose Up
+ALTER TABLE events ADD COLUMN notified_at TIMESTAMPTZ;
+
+CREATE INDEX events_notifications_idx ON events (starts_at)
+    WHERE deleted_at IS NULL AND notified_at IS NULL AND notify_before > 0;
+
+-- +goose Down
+DROP INDEX events_notifications_idx;
+
+ALTER TABLE events DROP COLUMN notified_at;
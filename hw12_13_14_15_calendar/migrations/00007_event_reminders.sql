@@ -0,0 +1,39 @@
+-- +goose Up
+CREATE TABLE event_reminders (
+    event_id       TEXT        NOT NULL REFERENCES events (id) ON DELETE CASCADE,
+    before_seconds BIGINT      NOT NULL,
+    channel        TEXT        NOT NULL,
+    remind_at      TIMESTAMPTZ NOT NULL,
+    notified_at    TIMESTAMPTZ,
+    PRIMARY KEY (event_id, before_seconds, channel)
+);
+
+CREATE INDEX event_reminders_due_idx ON event_reminders (remind_at) WHERE notified_at IS NULL;
+
+INSERT INTO event_reminders (event_id, before_seconds, channel, remind_at, notified_at)
+SELECT id, notify_before, 'log', starts_at - make_interval(secs => notify_before), notified_at
+FROM events
+WHERE notify_before > 0;
+
+DROP INDEX events_notifications_idx;
+ALTER TABLE events DROP COLUMN notified_at, DROP COLUMN notify_before;
+
+-- +goose Down
+ALTER TABLE events
+    ADD COLUMN notify_before BIGINT NOT NULL DEFAULT 0,
+    ADD COLUMN notified_at TIMESTAMPTZ;
+
+-- only the earliest reminder of an event survives the rollback
+UPDATE events e
+SET notify_before = r.before_seconds, notified_at = r.notified_at
+FROM (
+    SELECT DISTINCT ON (event_id) event_id, before_seconds, notified_at
+    FROM event_reminders
+    ORDER BY event_id, before_seconds DESC
+) r
+WHERE e.id = r.event_id;
+
+CREATE INDEX events_notifications_idx ON events (starts_at)
+    WHERE deleted_at IS NULL AND notified_at IS NULL AND notify_before > 0;
+
+DROP TABLE event_reminders;
@@ -0,0 +1,39 @@
+-- +goose Up
+CREATE TABLE calendars (
+    id         TEXT PRIMARY KEY,
+    owner_id   TEXT        NOT NULL,
+    name       TEXT        NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (owner_id, name)
+);
+
+CREATE TABLE calendar_shares (
+    calendar_id TEXT NOT NULL REFERENCES calendars (id) ON DELETE CASCADE,
+    user_id     TEXT NOT NULL,
+    access      TEXT NOT NULL,
+    PRIMARY KEY (calendar_id, user_id)
+);
+
+CREATE INDEX calendar_shares_user_id_idx ON calendar_shares (user_id);
+
+-- existing events move to the default calendar of their owner
+INSERT INTO calendars (id, owner_id, name)
+SELECT gen_random_uuid()::text, user_id, 'default'
+FROM (SELECT DISTINCT user_id FROM events) AS owners;
+
+ALTER TABLE events ADD COLUMN calendar_id TEXT REFERENCES calendars (id);
+
+UPDATE events e
+SET calendar_id = c.id
+FROM calendars c
+WHERE c.owner_id = e.user_id AND c.name = 'default';
+
+ALTER TABLE events ALTER COLUMN calendar_id SET NOT NULL;
+
+CREATE INDEX events_calendar_id_starts_at_idx ON events (calendar_id, starts_at);
+
+-- +goose Down
+ALTER TABLE events DROP COLUMN calendar_id;
+
+DROP TABLE calendar_shares;
+DROP TABLE calendars;
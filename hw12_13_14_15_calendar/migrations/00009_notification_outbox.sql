@@ -0,0 +1,10 @@
+-- +goose Up
+CREATE TABLE notification_outbox (
+    id         BIGSERIAL PRIMARY KEY,
+    body       BYTEA       NOT NULL,
+    headers    JSONB       NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+-- +goose Down
+DROP TABLE notification_outbox;
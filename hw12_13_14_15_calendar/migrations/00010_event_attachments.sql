@@ -0,0 +1,15 @@
+-- +goose Up
+CREATE TABLE event_attachments (
+    id           TEXT PRIMARY KEY,
+    event_id     TEXT        NOT NULL REFERENCES events (id) ON DELETE CASCADE,
+    name         TEXT        NOT NULL,
+    content_type TEXT        NOT NULL,
+    size         BIGINT      NOT NULL,
+    user_id      TEXT        NOT NULL,
+    created_at   TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX event_attachments_event_id_idx ON event_attachments (event_id, created_at);
+
+-- +goose Down
+DROP TABLE event_attachments;
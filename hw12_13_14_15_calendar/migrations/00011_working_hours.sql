@@ -0,0 +1,26 @@
+-- +goose Up
+CREATE TABLE working_hours (
+    user_id    TEXT PRIMARY KEY,
+    time_zone  TEXT        NOT NULL,
+    strict     BOOLEAN     NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE working_periods (
+    user_id       TEXT     NOT NULL REFERENCES working_hours (user_id) ON DELETE CASCADE,
+    weekday       SMALLINT NOT NULL,
+    start_seconds INTEGER  NOT NULL,
+    end_seconds   INTEGER  NOT NULL,
+    PRIMARY KEY (user_id, weekday, start_seconds)
+);
+
+CREATE TABLE working_holidays (
+    user_id  TEXT NOT NULL REFERENCES working_hours (user_id) ON DELETE CASCADE,
+    calendar TEXT NOT NULL,
+    PRIMARY KEY (user_id, calendar)
+);
+
+-- +goose Down
+DROP TABLE working_holidays;
+DROP TABLE working_periods;
+DROP TABLE working_hours;
@@ -0,0 +1,10 @@
+-- +goose Up
+CREATE TABLE notification_preferences (
+    user_id    TEXT PRIMARY KEY,
+    time_zone  TEXT        NOT NULL,
+    locale     TEXT        NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+
+-- +goose Down
+DROP TABLE notification_preferences;
@@ -0,0 +1,9 @@
+-- +goose Up
+-- tx_id orders changes by their transactions instead of a global lock, see ListChanges.
+ALTER TABLE event_history ADD COLUMN tx_id XID8 NOT NULL DEFAULT pg_current_xact_id();
+
+CREATE INDEX event_history_tx_id_idx ON event_history (tx_id, id);
+
+-- +goose Down
+DROP INDEX event_history_tx_id_idx;
+ALTER TABLE event_history DROP COLUMN tx_id;
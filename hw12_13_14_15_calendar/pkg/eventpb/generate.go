@@ -0,0 +1,3 @@
+package eventpb
+
+//go:generate protoc -I ../../api --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative EventService.proto
@@ -0,0 +1,109 @@
+package integration
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/pkg/eventpb"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestGRPCEvents(t *testing.T) {
+	conn, err := grpc.NewClient(grpcAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	client := eventpb.NewEventServiceClient(conn)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	ctx = metadata.AppendToOutgoingContext(ctx, "x-user-id", uuid.NewString())
+	day := time.Date(2030, 3, 4, 0, 0, 0, 0, time.UTC)
+
+	created, err := client.CreateEvent(ctx, &eventpb.CreateEventRequest{Event: &eventpb.Event{
+		Title:    "retro",
+		StartsAt: timestamppb.New(day.Add(15 * time.Hour)),
+		EndsAt:   timestamppb.New(day.Add(16 * time.Hour)),
+	}})
+	require.NoError(t, err)
+
+	_, err = client.CreateEvent(ctx, &eventpb.CreateEventRequest{Event: &eventpb.Event{
+		Title:    "overlapping",
+		StartsAt: timestamppb.New(day.Add(15*time.Hour + 30*time.Minute)),
+		EndsAt:   timestamppb.New(day.Add(17 * time.Hour)),
+	}})
+	require.Equal(t, codes.AlreadyExists, status.Code(err))
+
+	list, err := client.ListEvents(ctx, &eventpb.ListEventsRequest{
+		Period: eventpb.Period_PERIOD_WEEK,
+		Date:   timestamppb.New(day),
+	})
+	require.NoError(t, err)
+	require.Len(t, list.GetEvents(), 1)
+	require.Equal(t, created.GetId(), list.GetEvents()[0].GetId())
+
+	created.Title = "team retro"
+	updated, err := client.UpdateEvent(ctx, &eventpb.UpdateEventRequest{Event: created, ExpectedVersion: 1})
+	require.NoError(t, err)
+	require.Equal(t, "team retro", updated.GetTitle())
+
+	_, err = client.DeleteEvent(ctx, &eventpb.DeleteEventRequest{Id: created.GetId(), ExpectedVersion: 1})
+	require.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	_, err = client.DeleteEvent(ctx, &eventpb.DeleteEventRequest{Id: created.GetId(), ExpectedVersion: updated.GetVersion()})
+	require.NoError(t, err)
+
+	_, err = client.GetEvent(ctx, &eventpb.GetEventRequest{Id: created.GetId()})
+	require.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestGRPCWatch(t *testing.T) {
+	conn, err := grpc.NewClient(grpcAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	client := eventpb.NewEventServiceClient(conn)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	ctx = metadata.AppendToOutgoingContext(ctx, "x-user-id", uuid.NewString())
+	day := time.Date(2030, 3, 5, 0, 0, 0, 0, time.UTC)
+
+	stream, err := client.Watch(ctx, &eventpb.WatchRequest{})
+	require.NoError(t, err)
+	// changes are watched from the moment the headers are sent
+	_, err = stream.Header()
+	require.NoError(t, err)
+
+	created, err := client.CreateEvent(ctx, &eventpb.CreateEventRequest{Event: &eventpb.Event{
+		Title:    "planning",
+		StartsAt: timestamppb.New(day.Add(10 * time.Hour)),
+		EndsAt:   timestamppb.New(day.Add(11 * time.Hour)),
+	}})
+	require.NoError(t, err)
+
+	first, err := stream.Recv()
+	require.NoError(t, err)
+	require.Equal(t, "created", first.GetChange().GetAction())
+	require.Equal(t, created.GetId(), first.GetChange().GetEventId())
+	require.Equal(t, "planning", first.GetEvent().GetTitle())
+
+	_, err = client.DeleteEvent(ctx, &eventpb.DeleteEventRequest{Id: created.GetId(), ExpectedVersion: created.GetVersion()})
+	require.NoError(t, err)
+
+	// A reconnecting client resumes after the last change it has seen.
+	resumed, err := client.Watch(ctx, &eventpb.WatchRequest{AfterId: proto.Int64(first.GetChange().GetId())})
+	require.NoError(t, err)
+	deleted, err := resumed.Recv()
+	require.NoError(t, err)
+	require.Equal(t, "deleted", deleted.GetChange().GetAction())
+	require.Equal(t, created.GetId(), deleted.GetChange().GetEventId())
+	require.Nil(t, deleted.GetEvent())
+}
@@ -0,0 +1,157 @@
+package integration
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+type reminder struct {
+	Before  string `json:"before"`
+	Channel string `json:"channel"`
+}
+
+type event struct {
+	ID          string     `json:"id,omitempty"`
+	Title       string     `json:"title"`
+	StartsAt    time.Time  `json:"starts_at"`
+	EndsAt      time.Time  `json:"ends_at"`
+	Description string     `json:"description,omitempty"`
+	UserID      string     `json:"user_id,omitempty"`
+	Reminders   []reminder `json:"reminders,omitempty"`
+	Version     int64      `json:"version,omitempty"`
+}
+
+type eventList struct {
+	Events     []event `json:"events"`
+	NextCursor string  `json:"next_cursor"`
+}
+
+type client struct {
+	t      *testing.T
+	userID string
+}
+
+func newClient(t *testing.T) *client {
+	t.Helper()
+	return &client{t: t, userID: uuid.NewString()}
+}
+
+func (c *client) do(method, path string, body interface{}, headers map[string]string, out interface{}) *http.Response {
+	c.t.Helper()
+
+	var buf bytes.Buffer
+	if body != nil {
+		require.NoError(c.t, json.NewEncoder(&buf).Encode(body))
+	}
+	req, err := http.NewRequest(method, "http://"+httpAddr+path, &buf)
+	require.NoError(c.t, err)
+	req.Header.Set("X-User-ID", c.userID)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(c.t, err)
+	defer resp.Body.Close()
+	if out != nil && resp.StatusCode < 300 {
+		require.NoError(c.t, json.NewDecoder(resp.Body).Decode(out))
+	}
+	return resp
+}
+
+func (c *client) list(period string, date time.Time) []string {
+	c.t.Helper()
+
+	var list eventList
+	resp := c.do(http.MethodGet, fmt.Sprintf("/events?period=%s&date=%s", period, date.Format("2006-01-02")), nil, nil, &list)
+	require.Equal(c.t, http.StatusOK, resp.StatusCode)
+
+	titles := make([]string, 0, len(list.Events))
+	for _, e := range list.Events {
+		titles = append(titles, e.Title)
+	}
+	return titles
+}
+
+func TestHTTPEvents(t *testing.T) {
+	c := newClient(t)
+	day := time.Date(2030, 3, 4, 0, 0, 0, 0, time.UTC)
+
+	var created event
+	resp := c.do(http.MethodPost, "/events", event{
+		Title:    "standup",
+		StartsAt: day.Add(10 * time.Hour),
+		EndsAt:   day.Add(10*time.Hour + 15*time.Minute),
+	}, nil, &created)
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	require.NotEmpty(t, created.ID)
+	require.Equal(t, c.userID, created.UserID)
+
+	t.Run("business errors", func(t *testing.T) {
+		resp := c.do(http.MethodPost, "/events", event{
+			Title:    "overlapping",
+			StartsAt: day.Add(10*time.Hour + 10*time.Minute),
+			EndsAt:   day.Add(11 * time.Hour),
+		}, nil, nil)
+		require.Equal(t, http.StatusConflict, resp.StatusCode)
+
+		resp = c.do(http.MethodPost, "/events", event{
+			Title:    "backwards",
+			StartsAt: day.Add(13 * time.Hour),
+			EndsAt:   day.Add(12 * time.Hour),
+		}, nil, nil)
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+		resp = c.do(http.MethodGet, "/events/"+uuid.NewString(), nil, nil, nil)
+		require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+
+	t.Run("listing", func(t *testing.T) {
+		for title, startsAt := range map[string]time.Time{
+			"next day":   day.AddDate(0, 0, 1),
+			"next week":  day.AddDate(0, 0, 7),
+			"next month": day.AddDate(0, 1, 0),
+		} {
+			resp := c.do(http.MethodPost, "/events", event{
+				Title:    title,
+				StartsAt: startsAt,
+				EndsAt:   startsAt.Add(time.Hour),
+			}, nil, nil)
+			require.Equal(t, http.StatusCreated, resp.StatusCode)
+		}
+
+		require.Equal(t, []string{"standup"}, c.list("day", day))
+		require.Equal(t, []string{"standup", "next day"}, c.list("week", day))
+		require.Equal(t, []string{"standup", "next day", "next week"}, c.list("month", day))
+		require.Empty(t, newClient(t).list("month", day), "events of other users are not listed")
+	})
+
+	t.Run("update and delete", func(t *testing.T) {
+		var updated event
+		resp := c.do(http.MethodPut, "/events/"+created.ID, event{
+			Title:    "daily standup",
+			StartsAt: created.StartsAt,
+			EndsAt:   created.EndsAt,
+		}, map[string]string{"If-Match": `"1"`}, &updated)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, "daily standup", updated.Title)
+		require.Equal(t, int64(2), updated.Version)
+
+		resp = c.do(http.MethodDelete, "/events/"+created.ID, nil, map[string]string{"If-Match": `"1"`}, nil)
+		require.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+
+		resp = c.do(http.MethodDelete, "/events/"+created.ID, nil, map[string]string{"If-Match": `"2"`}, nil)
+		require.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+		resp = c.do(http.MethodGet, "/events/"+created.ID, nil, nil, nil)
+		require.Equal(t, http.StatusNotFound, resp.StatusCode)
+		require.Equal(t, []string{"next day"}, c.list("week", day))
+	})
+}
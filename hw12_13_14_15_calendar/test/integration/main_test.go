@@ -0,0 +1,147 @@
+// Package integration tests the calendar through its public APIs.
+//
+// By default the calendar, the scheduler and the sender are started in-process
+// with the memory storage and the in-process queue. Set CALENDAR_HTTP_ADDR,
+// CALENDAR_GRPC_ADDR and CALENDAR_AMQP_URL to run against a deployed stack instead.
+package integration
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/app"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/logger"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/queue"
+	memoryqueue "github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/queue/memory"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/queue/rabbit"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/scheduler"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/sender"
+	internalgrpc "github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/server/grpc"
+	internalhttp "github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/server/http"
+	memorystorage "github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage/memory"
+)
+
+var (
+	httpAddr string
+	grpcAddr string
+	// statuses receives delivery statuses published by the sender.
+	statuses queue.Consumer
+)
+
+func TestMain(m *testing.M) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var err error
+	stop := func() {}
+	if os.Getenv("CALENDAR_HTTP_ADDR") != "" {
+		err = connectRemote(ctx)
+	} else {
+		stop, err = startLocal(ctx)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		cancel()
+		os.Exit(1)
+	}
+
+	code := m.Run()
+	cancel()
+	stop()
+	os.Exit(code)
+}
+
+func connectRemote(ctx context.Context) error {
+	httpAddr = os.Getenv("CALENDAR_HTTP_ADDR")
+	grpcAddr = os.Getenv("CALENDAR_GRPC_ADDR")
+
+	statusQueue := os.Getenv("CALENDAR_STATUS_QUEUE")
+	if statusQueue == "" {
+		statusQueue = "notifications.status"
+	}
+	client := rabbit.New(os.Getenv("CALENDAR_AMQP_URL"), "calendar", statusQueue, nil)
+	if err := client.Connect(ctx); err != nil {
+		return err
+	}
+	statuses = client
+	return waitFor(httpAddr, grpcAddr)
+}
+
+func startLocal(ctx context.Context) (func(), error) {
+	logg := logger.NewWithWriter("ERROR", io.Discard)
+	storage := memorystorage.New()
+	calendar := app.New(logg, storage, app.Options{})
+
+	httpPort, err := freePort()
+	if err != nil {
+		return nil, err
+	}
+	grpcPort, err := freePort()
+	if err != nil {
+		return nil, err
+	}
+	httpAddr = net.JoinHostPort("127.0.0.1", httpPort)
+	grpcAddr = net.JoinHostPort("127.0.0.1", grpcPort)
+
+	httpServer := internalhttp.NewServer(logg, calendar, nil, nil, nil, "127.0.0.1", httpPort)
+	grpcServer := internalgrpc.NewServer(logg, calendar, nil, nil, nil, "127.0.0.1", grpcPort)
+	go func() { _ = httpServer.Start(ctx) }()
+	go func() { _ = grpcServer.Start(ctx) }()
+
+	notifications := memoryqueue.New(100)
+	statusQueue := memoryqueue.New(100)
+	statuses = statusQueue
+	go scheduler.New(logg, storage, notifications, scheduler.Config{
+		NotifyInterval: 100 * time.Millisecond,
+		RelayInterval:  time.Second,
+		PurgeInterval:  time.Hour,
+		Retention:      time.Hour,
+		MaxAge:         100 * 365 * 24 * time.Hour,
+	}).Run(ctx)
+	go func() { _ = sender.New(logg, notifications, statusQueue, nil, nil).Run(ctx) }()
+
+	stop := func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
+		_ = httpServer.Stop(ctx)
+		_ = grpcServer.Stop(ctx)
+	}
+	if err := waitFor(httpAddr, grpcAddr); err != nil {
+		stop()
+		return nil, err
+	}
+	return stop, nil
+}
+
+func freePort() (string, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	defer l.Close()
+	_, port, err := net.SplitHostPort(l.Addr().String())
+	return port, err
+}
+
+// waitFor waits until the servers accept connections.
+func waitFor(addrs ...string) error {
+	deadline := time.Now().Add(30 * time.Second)
+	for _, addr := range addrs {
+		for {
+			conn, err := net.DialTimeout("tcp", addr, time.Second)
+			if err == nil {
+				conn.Close()
+				break
+			}
+			if time.Now().After(deadline) {
+				return fmt.Errorf("%s is not available: %w", addr, err)
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package integration
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/queue"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNotification(t *testing.T) {
+	c := newClient(t)
+	startsAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+
+	var created event
+	resp := c.do(http.MethodPost, "/events", event{
+		Title:     "due soon",
+		StartsAt:  startsAt,
+		EndsAt:    startsAt.Add(time.Hour),
+		Reminders: []reminder{{Before: "2h", Channel: "log"}},
+	}, nil, &created)
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	// the scheduler scans once a minute in the compose stack
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	delivered := make(chan queue.Status, 1)
+	go func() {
+		_ = statuses.Consume(ctx, func(ctx context.Context, msg queue.Message) error {
+			var s queue.Status
+			if err := json.Unmarshal(msg.Body, &s); err != nil {
+				return err
+			}
+			if s.EventID == created.ID {
+				select {
+				case delivered <- s:
+				default:
+				}
+			}
+			return nil
+		})
+	}()
+
+	select {
+	case s := <-delivered:
+		require.Equal(t, queue.StatusSent, s.Status)
+		require.Equal(t, c.userID, s.UserID)
+		require.Equal(t, "log", s.Channel)
+	case <-ctx.Done():
+		t.Fatal("notification is not delivered")
+	}
+}